@@ -115,7 +115,13 @@ func printState(w *tabwriter.Writer, state vpn.State, connected types.ConnectedR
 		}
 		portInfo += fmt.Sprintf("%d)", connected.ServerPort)
 	}
+	if len(connected.ServerHostname) > 0 {
+		fmt.Fprintf(w, "    Server host\t:\t%v\n", connected.ServerHostname)
+	}
 	fmt.Fprintf(w, "    Server IP\t:\t%v%v\n", connected.ServerIP, portInfo)
+	if connected.Mtu > 0 {
+		fmt.Fprintf(w, "    MTU\t:\t%v\n", connected.Mtu)
+	}
 
 	fmt.Fprintf(w, "    Connected\t:\t%v\n", since)
 
@@ -130,7 +136,13 @@ func printDNSState(w *tabwriter.Writer, dnsStatus types.DnsStatus, servers *apit
 	if dnsStatus.AntiTrackerStatus.Enabled {
 		fmt.Fprintf(w, "AntiTracker\t:\t%v\n", GetAntiTrackerStatusText(dnsStatus.AntiTrackerStatus))
 	} else {
-		if dnsStatus.Dns.IsEmpty() {
+		if dnsStatus.AutoDnsStatus.IsEnabled && (dnsStatus.Dns.IsEmpty() || dnsStatus.Dns.Equal(dnsStatus.AutoDnsStatus.Selected)) {
+			if dnsStatus.AutoDnsStatus.Selected.IsEmpty() {
+				fmt.Fprintf(w, "DNS\t:\tAuto (selecting the fastest provider...)\n")
+			} else {
+				fmt.Fprintf(w, "DNS\t:\tAuto: %v\n", dnsStatus.AutoDnsStatus.Selected.InfoString())
+			}
+		} else if dnsStatus.Dns.IsEmpty() {
 			fmt.Fprintf(w, "DNS\t:\tDefault (auto)\n")
 		} else {
 			fmt.Fprintf(w, "DNS\t:\t%v\n", dnsStatus.Dns.InfoString())
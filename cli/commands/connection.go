@@ -133,7 +133,13 @@ type CmdConnect struct {
 	antitrackerHard bool
 	isIPv6Tunnel    bool
 
-	mtu int // MTU value (applicable only for WireGuard)
+	mtu     int  // MTU value (applicable only for WireGuard)
+	mtuAuto bool // detect MTU automatically (applicable only for WireGuard)
+
+	underlayIface         string // physical interface for the encrypted traffic
+	underlayIfaceFailover string // interface to use when 'underlayIface' has lost carrier
+
+	hostSelection string // strategy to select a host of the server: 'weighted_random' (default), 'least_load', 'lowest_ping'
 
 	filter_proto       string
 	filter_location    bool
@@ -169,6 +175,7 @@ func (c *CmdConnect) Init() {
 	c.BoolVar(&c.fastest, "fastest", false, "Connect to fastest server")
 	c.BoolVar(&c.last, "last", false, "Connect with the last used connection parameters")
 	c.BoolVar(&c.any, "any", false, "Use a random server from the found results to connect")
+	c.StringVar(&c.hostSelection, "host_selection", "", "STRATEGY", "Strategy to select a host of the server (weighted_random|least_load|lowest_ping)\n  (default: weighted_random - random host, lightly loaded and low-latency hosts are more likely)")
 
 	// Multi-Hop
 	c.StringVar(&c.multihopExitSvr, "exit_svr", "", "LOCATION", "Exit-server for Multi-Hop connection\n  (use full serverID as a parameter, servers filtering not applicable for it)")
@@ -177,6 +184,9 @@ func (c *CmdConnect) Init() {
 	c.StringVar(&c.filter_proto, "protocol", "", "PROTOCOL", "Protocol type (OpenVPN|ovpn|WireGuard|wg)")
 	c.StringVar(&c.filter_proto, "p", "", "PROTOCOL", "Protocol type (OpenVPN|ovpn|WireGuard|wg)")
 	c.IntVar(&c.mtu, "mtu", 0, "MTU", "Maximum transmission unit (applicable only for WireGuard connections)")
+	c.BoolVar(&c.mtuAuto, "mtu_auto", false, "Detect the maximum transmission unit automatically after connection (applicable only for WireGuard connections)\n  (ignored when '-mtu' is defined)")
+	c.StringVar(&c.underlayIface, "iface", "", "INTERFACE", "Send the encrypted VPN traffic via the specified network interface\n  (instead of following the default route; e.g. 'eth0')")
+	c.StringVar(&c.underlayIfaceFailover, "iface_failover", "", "INTERFACE", "Network interface to use when the '-iface' interface has lost carrier\n  (e.g. LTE modem 'wwan0')")
 	c.BoolVar(&c.isIPv6Tunnel, "ipv6tunnel", false, "Enable IPv6 in VPN tunnel (WireGuard connections only)\n  (IPv6 addresses are preferred when a host has a dual stack IPv6/IPv4; IPv4-only hosts are unaffected)")
 
 	// Port flags
@@ -524,6 +534,9 @@ func (c *CmdConnect) Run() (retError error) {
 					if c.mtu > 0 {
 						fmt.Printf("[!] Using custom MTU: %d\n", c.mtu)
 						req.Params.WireGuardParameters.Mtu = c.mtu
+					} else if c.mtuAuto {
+						fmt.Printf("[!] Using automatic MTU detection\n")
+						req.Params.WireGuardParameters.MtuAuto = true
 					}
 
 					var destPort port
@@ -710,7 +723,7 @@ func (c *CmdConnect) Run() (retError error) {
 			// Taking default configuration parameters
 			req.Params.ManualDNS = defaultConnSettings.Params.ManualDNS
 			if !req.Params.ManualDNS.IsEmpty() {
-				printDNSConfigInfo(nil, req.Params.ManualDNS).Flush()
+				printDNSConfigInfo(nil, req.Params.ManualDNS, req.Params.Metadata.DnsAuto).Flush()
 			}
 		}
 
@@ -722,6 +735,23 @@ func (c *CmdConnect) Run() (retError error) {
 		}
 	}
 
+	// underlay interface
+	if len(c.underlayIface) > 0 {
+		req.Params.UnderlayInterface = c.underlayIface
+		req.Params.UnderlayFailoverInterface = c.underlayIfaceFailover
+	} else if len(c.underlayIfaceFailover) > 0 {
+		return flags.BadParameter{Message: "'-iface_failover' is applicable only with '-iface'"}
+	}
+
+	// host selection strategy
+	if len(c.hostSelection) > 0 {
+		strategy, err := service_types.ParseHostSelectionStrategy(c.hostSelection)
+		if err != nil {
+			return flags.BadParameter{Message: err.Error()}
+		}
+		req.Params.Metadata.HostSelection = strategy
+	}
+
 	fmt.Println("Connecting...")
 	_, err = _proto.ConnectVPN(req)
 	if err != nil {
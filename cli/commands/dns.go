@@ -25,6 +25,7 @@ package commands
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"text/tabwriter"
@@ -33,6 +34,7 @@ import (
 	"github.com/ivpn/desktop-app/cli/flags"
 	apitypes "github.com/ivpn/desktop-app/daemon/api/types"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/dns/dnscryptproxy"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/vpn"
 )
@@ -44,6 +46,13 @@ type CmdDns struct {
 	dohTemplate          string
 	dotTemplate          string
 	linuxManagementStyle string // LinuxDnsMgmt
+	auto                 bool
+	test                 bool
+	safe                 bool
+	stats                bool
+	statsOn              bool
+	statsOnStrict        bool
+	statsOff             bool
 }
 
 type LinuxDnsMgmt string
@@ -57,6 +66,10 @@ const (
 	ArgName_DoH        = "doh"
 	ArgName_DoT        = "dot"
 	ArgName_Management = "management"
+	ArgName_Stats      = "stats"
+	ArgName_Test       = "test"
+	ArgName_Auto       = "auto"
+	ArgName_Safe       = "safe"
 )
 
 func IsParamApplicable_LinuxForceModifyResolvconf() (bool, error) {
@@ -110,6 +123,14 @@ func (c *CmdDns) Init() {
 				return ret
 			})
 	}
+
+	c.BoolVar(&c.auto, ArgName_Auto, false, "Automatically select the fastest predefined encrypted DNS provider (when VPN connected)\n The selection is re-checked periodically. Use '-off' to disable")
+	c.BoolVar(&c.test, ArgName_Test, false, "Test DNS configuration without applying it (resolve a probe name through the DNS server)\n If DNS_IP is not defined - the current custom DNS configuration is tested\n  Example: ivpn dns -test -doh https://cloudflare-dns.com/dns-query 1.1.1.1")
+	c.BoolVar(&c.safe, ArgName_Safe, false, "Test the new DNS configuration before applying it; do not apply if the test failed\n  Example: ivpn dns -safe 1.1.1.1")
+	c.BoolVar(&c.stats, ArgName_Stats, false, "Show DNS query statistics\n Statistics is available only when DNS requests are processed locally (encrypted DNS or custom AntiTracker lists)")
+	c.BoolVar(&c.statsOn, ArgName_Stats+"_on", false, "Enable DNS query logging (statistics)")
+	c.BoolVar(&c.statsOnStrict, ArgName_Stats+"_on_strict", false, "Enable DNS query logging (statistics) in strict privacy mode\n Domain names are not collected; the query log is processed in memory and never written to disk\n (not supported on Windows)")
+	c.BoolVar(&c.statsOff, ArgName_Stats+"_off", false, "Disable DNS query logging (statistics)")
 }
 
 func (c *CmdDns) Run() error {
@@ -117,10 +138,30 @@ func (c *CmdDns) Run() error {
 		return flags.BadParameter{}
 	}
 
+	if c.stats || c.statsOn || c.statsOnStrict || c.statsOff {
+		if c.NFlag() > 1 {
+			return flags.BadParameter{Message: "Not allowed to combine statistics arguments with other arguments"}
+		}
+		return c.runStats()
+	}
+
 	if len(c.dohTemplate) > 0 && len(c.dotTemplate) > 0 {
 		return flags.BadParameter{}
 	}
 
+	if c.test {
+		if c.reset || c.safe || len(c.linuxManagementStyle) > 0 {
+			return flags.BadParameter{Message: fmt.Sprintf("Not allowed to combine '-%s' with '-%s', '-%s' or '-%s' arguments", ArgName_Test, ArgName_Off, ArgName_Safe, ArgName_Management)}
+		}
+		return c.runTest()
+	}
+	if c.auto && (c.reset || len(c.dns) > 0) {
+		return flags.BadParameter{Message: fmt.Sprintf("Not allowed to combine '-%s' with custom DNS or '-%s' arguments", ArgName_Auto, ArgName_Off)}
+	}
+	if c.safe && len(c.dns) == 0 {
+		return flags.BadParameter{Message: fmt.Sprintf("DNS_IP not defined for '-%s' argument", ArgName_Safe)}
+	}
+
 	hr := _proto.GetHelloResponse()
 	uPrefs := hr.DaemonSettings.UserPrefs
 
@@ -152,6 +193,12 @@ func (c *CmdDns) Run() error {
 		}
 	}
 
+	if c.auto {
+		if err := _proto.SetDnsAutoMode(true); err != nil {
+			return err
+		}
+	}
+
 	var servers *apitypes.ServersInfoResponse
 	// do we have to change custom DNS configuration ?
 	if c.reset || len(c.dns) > 0 {
@@ -164,19 +211,16 @@ func (c *CmdDns) Run() error {
 
 		if c.reset {
 			defManualDns = dns.DnsSettings{}
-		} else {
-			defManualDns.DnsHost = c.dns
-			if len(c.dohTemplate) > 0 {
-				defManualDns.Encryption = dns.EncryptionDnsOverHttps
-				defManualDns.DohTemplate = c.dohTemplate
-			}
-			if len(c.dotTemplate) > 0 {
-				defManualDns.Encryption = dns.EncryptionDnsOverTls
-				defManualDns.DohTemplate = c.dotTemplate
+			if defConnCfg.Params.Metadata.DnsAuto {
+				if err := _proto.SetDnsAutoMode(false); err != nil {
+					return err
+				}
 			}
+		} else {
+			defManualDns = c.getDnsSettings(defManualDns)
 		}
 
-		if err := _proto.SetManualDNS(defManualDns, service_types.AntiTrackerMetadata{}); err != nil {
+		if err := _proto.SetManualDNSEx(defManualDns, service_types.AntiTrackerMetadata{}, c.safe); err != nil {
 			return err
 		}
 	}
@@ -200,7 +244,7 @@ func (c *CmdDns) Run() error {
 		if err != nil {
 			return err
 		}
-		w = printDNSConfigInfo(w, defConnCfg.Params.ManualDNS)
+		w = printDNSConfigInfo(w, defConnCfg.Params.ManualDNS, defConnCfg.Params.Metadata.DnsAuto)
 	}
 	w.Flush()
 
@@ -211,10 +255,14 @@ func (c *CmdDns) Run() error {
 
 type CmdAntitracker struct {
 	flags.CmdInfo
-	on         string
-	off        bool
-	hardcore   string
-	blocklists bool
+	on          string
+	off         bool
+	hardcore    string
+	blocklists  bool
+	custom      bool
+	customBlock string
+	customAllow string
+	customOff   bool
 }
 
 const EmptyBlockListName = "<default>"
@@ -233,6 +281,11 @@ func (c *CmdAntitracker) Init() {
 	c.StringVar(&c.hardcore, "on_hardcore", "", "[BLOCK_LIST]", "Enable AntiTracker 'hardcore' mode\n BLOCK_LIST - optional parameter used to set custom DNS block list\n "+tipText)
 	c.BoolVar(&c.off, "off", false, "Disable AntiTracker")
 	c.BoolVar(&c.blocklists, argNameShowBlocklists, false, "Show all supported DNS block lists")
+
+	c.BoolVar(&c.custom, "custom", false, "Show configuration of custom block/allow lists\n Custom lists are enforced locally, on top of the AntiTracker or custom DNS")
+	c.StringVar(&c.customBlock, "custom_block", "", "SOURCES", "Enable custom block lists\n SOURCES - comma separated list of local files or https URLs\n Supported formats: hosts file or plain list of domains (one per line)\n (example: ivpn antitracker -custom_block /home/user/blocklist.txt,https://example.com/hosts)")
+	c.StringVar(&c.customAllow, "custom_allow", "", "SOURCES", "Set custom allow lists (domains which bypass the custom block lists)\n SOURCES - comma separated list of local files or https URLs\n Can be combined with '-custom_block'")
+	c.BoolVar(&c.customOff, "custom_off", false, "Disable custom block/allow lists")
 }
 
 func (c *CmdAntitracker) preParse(arguments []string) ([]string, error) {
@@ -249,10 +302,15 @@ func (c *CmdAntitracker) preParse(arguments []string) ([]string, error) {
 }
 
 func (c *CmdAntitracker) Run() error {
-	if c.NFlag() > 1 {
+	isCustomBlockAndAllow := c.NFlag() == 2 && c.customBlock != "" && c.customAllow != ""
+	if c.NFlag() > 1 && !isCustomBlockAndAllow {
 		return flags.BadParameter{Message: "Not allowed to use more than one argument for this command"}
 	}
 
+	if c.custom || c.customBlock != "" || c.customAllow != "" || c.customOff {
+		return c.runCustomLists()
+	}
+
 	if c.blocklists {
 		svrs, err := _proto.GetServers()
 		if err != nil {
@@ -315,15 +373,240 @@ func (c *CmdAntitracker) Run() error {
 	return nil
 }
 
+// getDnsSettings returns DNS settings defined by command arguments (based on 'base' settings)
+func (c *CmdDns) getDnsSettings(base dns.DnsSettings) dns.DnsSettings {
+	base.DnsHost = c.dns
+	if len(c.dohTemplate) > 0 {
+		base.Encryption = dns.EncryptionDnsOverHttps
+		base.DohTemplate = c.dohTemplate
+	}
+	if len(c.dotTemplate) > 0 {
+		base.Encryption = dns.EncryptionDnsOverTls
+		base.DohTemplate = c.dotTemplate
+	}
+	return base
+}
+
+func (c *CmdDns) runTest() error {
+	var dnsCfg dns.DnsSettings
+	if len(c.dns) > 0 {
+		dnsCfg = c.getDnsSettings(dns.DnsSettings{})
+	} else {
+		defConnCfg, err := _proto.GetDefConnectionParams()
+		if err != nil {
+			return err
+		}
+		dnsCfg = defConnCfg.Params.ManualDNS
+		if dnsCfg.IsEmpty() {
+			return flags.BadParameter{Message: "Custom DNS not defined. Please, specify DNS_IP to test"}
+		}
+	}
+
+	fmt.Printf("Testing DNS %s ...\n", dnsCfg.InfoString())
+	res, err := _proto.TestDnsConfig(dnsCfg)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	if res.IsSkipped {
+		fmt.Fprintf(w, "Result\t:\tSKIPPED\n")
+		fmt.Fprintf(w, "Reason\t:\t%s\n", res.Error)
+		w.Flush()
+		return fmt.Errorf("DNS configuration test not performed")
+	}
+	if res.IsOk {
+		fmt.Fprintf(w, "Result\t:\tOK\n")
+	} else {
+		fmt.Fprintf(w, "Result\t:\tFAILED\n")
+		fmt.Fprintf(w, "Error\t:\t%s\n", res.Error)
+	}
+	fmt.Fprintf(w, "Latency\t:\t%dms\n", res.LatencyMs)
+	if len(res.ResolvedIPs) > 0 {
+		fmt.Fprintf(w, "Resolved\t:\t%s -> %s\n", res.ProbeName, strings.Join(res.ResolvedIPs, ", "))
+	}
+	if len(res.TlsServerName) > 0 {
+		fmt.Fprintf(w, "TLS server name\t:\t%s\n", res.TlsServerName)
+	}
+	if len(res.TlsCertSubject) > 0 {
+		fmt.Fprintf(w, "TLS certificate\t:\t%s (issuer: %s)\n", res.TlsCertSubject, res.TlsCertIssuer)
+	}
+	w.Flush()
+
+	if !res.IsOk {
+		return fmt.Errorf("DNS configuration test failed")
+	}
+	return nil
+}
+
+func (c *CmdDns) runStats() error {
+	var stats dns.DnsStats
+	var err error
+
+	if c.statsOn || c.statsOnStrict || c.statsOff {
+		cfg := dns.DnsStatsConfig{Enabled: c.statsOn || c.statsOnStrict, StrictPrivacy: c.statsOnStrict}
+		stats, err = _proto.SetDnsStatsConfig(cfg)
+	} else {
+		stats, err = _proto.GetDnsStats()
+	}
+	if err != nil {
+		return err
+	}
+
+	w := printDnsStats(nil, stats)
+	w.Flush()
+	return nil
+}
+
+func (c *CmdAntitracker) runCustomLists() error {
+	status, err := _proto.GetAntiTrackerCustomLists()
+	if err != nil {
+		return err
+	}
+
+	if c.customOff || c.customBlock != "" || c.customAllow != "" {
+		cfg := status.Config
+		if c.customOff {
+			cfg.Enabled = false
+		} else {
+			if c.customBlock != "" {
+				cfg.BlockLists = splitListSources(c.customBlock)
+			}
+			if c.customAllow != "" {
+				cfg.AllowLists = splitListSources(c.customAllow)
+			}
+			if len(cfg.BlockLists) == 0 {
+				return flags.BadParameter{Message: "custom block lists not defined (use '-custom_block' argument)"}
+			}
+			cfg.Enabled = true
+		}
+
+		localContent, err := readLocalListSources(cfg)
+		if err != nil {
+			return err
+		}
+		if status, err = _proto.SetAntiTrackerCustomLists(cfg, localContent); err != nil {
+			return err
+		}
+	}
+
+	w := printAntitrackerCustomListsInfo(nil, status)
+	w.Flush()
+	return nil
+}
+
+func splitListSources(sources string) []string {
+	ret := []string{}
+	for _, s := range strings.Split(sources, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			if !dnscryptproxy.IsNamesSourceURL(s) {
+				if abs, err := filepath.Abs(s); err == nil {
+					s = abs
+				}
+			}
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// readLocalListSources reads local files of the custom lists (the daemon does not read local files on behalf of the client)
+func readLocalListSources(cfg dns.DnsFilterConfig) (map[string][]byte, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	ret := make(map[string][]byte)
+	for _, src := range append(append([]string{}, cfg.BlockLists...), cfg.AllowLists...) {
+		if dnscryptproxy.IsNamesSourceURL(src) {
+			continue
+		}
+		if _, exists := ret[src]; exists {
+			continue
+		}
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read list file: %w", err)
+		}
+		ret[src] = content
+	}
+	return ret, nil
+}
+
 //----------------------------------------------------------------------------------------
 
-func printDNSConfigInfo(w *tabwriter.Writer, customDNS dns.DnsSettings) *tabwriter.Writer {
+func printAntitrackerCustomListsInfo(w *tabwriter.Writer, status dns.DnsFilterStatus) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	if !status.Config.Enabled {
+		fmt.Fprintf(w, "Custom lists\t:\tDisabled\n")
+	} else {
+		fmt.Fprintf(w, "Custom lists\t:\tEnabled (blocked names: %d; allowed names: %d)\n", status.BlockedNamesCount, status.AllowedNamesCount)
+	}
+	for i, src := range status.Config.BlockLists {
+		name := ""
+		if i == 0 {
+			name = "Block lists"
+		}
+		fmt.Fprintf(w, "%s\t:\t%s\n", name, src)
+	}
+	for i, src := range status.Config.AllowLists {
+		name := ""
+		if i == 0 {
+			name = "Allow lists"
+		}
+		fmt.Fprintf(w, "%s\t:\t%s\n", name, src)
+	}
+	return w
+}
+
+func printDnsStats(w *tabwriter.Writer, stats dns.DnsStats) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	if !stats.Config.Enabled {
+		fmt.Fprintf(w, "Query logging\t:\tDisabled\n")
+		return w
+	}
+
+	mode := ""
+	if stats.Config.StrictPrivacy {
+		mode = " (strict privacy)"
+	}
+	if stats.IsActive {
+		fmt.Fprintf(w, "Query logging\t:\tEnabled%s\n", mode)
+	} else {
+		fmt.Fprintf(w, "Query logging\t:\tEnabled%s; inactive (DNS requests are not processed locally)\n", mode)
+	}
+
+	st := stats.Stats
+	fmt.Fprintf(w, "Total queries\t:\t%d\n", st.Total)
+	fmt.Fprintf(w, "Blocked\t:\t%d\n", st.Blocked)
+	fmt.Fprintf(w, "Failed\t:\t%d\n", st.Failed)
+	fmt.Fprintf(w, "Answered locally\t:\t%d\n", st.Cached)
+	fmt.Fprintf(w, "Upstream latency\t:\tavg %dms; max %dms\n", st.AvgLatencyMs, st.MaxLatencyMs)
+
+	for i, d := range st.TopBlocked {
+		name := ""
+		if i == 0 {
+			name = "Top blocked"
+		}
+		fmt.Fprintf(w, "%s\t:\t%s (%d)\n", name, d.Domain, d.Count)
+	}
+	return w
+}
+
+func printDNSConfigInfo(w *tabwriter.Writer, customDNS dns.DnsSettings, isDnsAuto bool) *tabwriter.Writer {
 	if w == nil {
 		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
 	}
 
 	if !customDNS.IsEmpty() {
 		fmt.Fprintf(w, "Default config\t:\tCustom DNS %v\n", customDNS.InfoString())
+	} else if isDnsAuto {
+		fmt.Fprintf(w, "Default config\t:\tAuto (the fastest predefined encrypted DNS provider)\n")
 	} else {
 		fmt.Fprintf(w, "Default config\t:\tCustom DNS not defined\n")
 	}
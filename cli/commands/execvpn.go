@@ -0,0 +1,114 @@
+//
+//  IVPN command line interface (CLI)
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the IVPN command line interface.
+//
+//  The IVPN command line interface is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The IVPN command line interface is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the IVPN command line interface. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ivpn/desktop-app/cli/flags"
+)
+
+type ExecVpn struct {
+	flags.CmdInfo
+	execute              string // this parameter is not in use. We need it just for help info. (using executeSpecParseArgs after special parsing)
+	executeSpecParseArgs []string
+}
+
+func (c *ExecVpn) Init() {
+	// register special parse function (for COMMAND)
+	c.SetParseSpecialFunc(c.specialParse)
+
+	c.Initialize("exec-vpn", "Run command in \"VPN-only\" network namespace\nThe command has network access only through the WireGuard VPN tunnel\n(no connectivity when VPN is disconnected)\nNote! The network namespace mode must be enabled: 'ivpn splittun -netns on'\nExamples:\n    ivpn exec-vpn firefox\n    ivpn exec-vpn curl https://api.ivpn.net/v4/geo-lookup")
+	c.DefaultStringVar(&c.execute, "COMMAND")
+}
+
+func (c *ExecVpn) Run() error {
+	if len(c.executeSpecParseArgs) <= 0 {
+		c.Usage(false)
+		return fmt.Errorf("no parameters defined")
+	}
+
+	cfg, err := _proto.GetSplitTunnelStatus()
+	if err != nil {
+		return err
+	}
+	if !cfg.IsNetnsMode {
+		return fmt.Errorf("the network namespace mode is disabled (use 'ivpn splittun -netns on' to enable it)")
+	}
+
+	// The command is started by the daemon (it has to be started inside the network namespace)
+	// using the credentials, basic environment (display, locale, etc.) and standard input/output of the current process
+	pid, err := _proto.SplitTunnelNetnsExec(c.executeSpecParseArgs)
+	if err != nil {
+		return err
+	}
+
+	return waitForProcess(pid)
+}
+
+func (c *ExecVpn) specialParse(arguments []string) bool {
+	if len(arguments) <= 0 {
+		return false
+	}
+	if strings.ToLower(arguments[0]) == "-h" {
+		return false
+	}
+	c.executeSpecParseArgs = arguments
+	return true
+}
+
+// waitForProcess waits until the process (not a child of the current process) finished.
+// The interrupt/terminate signals received by the current process are forwarded to the process.
+func waitForProcess(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	isRunning := func() bool {
+		err := proc.Signal(syscall.Signal(0))
+		// EPERM: the process exists (e.g. privileges are not dropped yet)
+		return err == nil || errors.Is(err, syscall.EPERM)
+	}
+
+	ticker := time.NewTicker(time.Millisecond * 200)
+	defer ticker.Stop()
+	for isRunning() {
+		select {
+		case sig := <-sigs:
+			proc.Signal(sig)
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -24,13 +24,26 @@ package commands
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/ivpn/desktop-app/cli/flags"
+	"github.com/ivpn/desktop-app/daemon/protocol/types"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 )
 
 type CmdFirewall struct {
 	flags.CmdInfo
 	status             bool
+	verbose            bool
 	on                 bool
 	off                bool
 	allowLan           bool
@@ -40,6 +53,16 @@ type CmdFirewall struct {
 	persistentOn       bool
 	persistentOff      bool
 	exceptions         string
+	interfaces         string
+	interfacesForward  string
+	allowApps          string
+	allowUsers         string
+	blocked            bool
+	blockedLogOn       bool
+	blockedLogOff      bool
+	blockedClear       bool
+	captivePortalOn    bool
+	captivePortalOff   bool
 	//allowLanMulticast bool
 	//blockLanMulticast bool
 }
@@ -49,6 +72,7 @@ const StringValueNoData = "<!NO DATA!>"
 func (c *CmdFirewall) Init() {
 	c.Initialize("firewall", "Firewall management")
 	c.BoolVar(&c.status, "status", false, "(default) Show info about current firewall status")
+	c.BoolVar(&c.verbose, "verbose", false, "Show detailed info about active firewall rules (in combination with '-status')\nIncludes the result of the consistency check with the rules present in the system (Linux only)")
 	c.BoolVar(&c.off, "off", false, "Switch-off firewall")
 	c.BoolVar(&c.on, "on", false, "Switch-on firewall")
 	c.BoolVar(&c.allowLan, "lan_allow", false, "Set configuration: allow LAN communication (take effect when firewall enabled)")
@@ -57,7 +81,17 @@ func (c *CmdFirewall) Init() {
 	c.BoolVar(&c.ivpnSvrAccessBlock, "ivpn_access_block", false, "Block access to IVPN servers when Firewall is enabled")
 	c.BoolVar(&c.persistentOff, "persistent_off", false, "Persistent firewall (Always-on firewall): disable")
 	c.BoolVar(&c.persistentOn, "persistent_on", false, "Persistent firewall (Always-on firewall): enable. When the option is enabled the IVPN Firewall is started during system boot")
-	c.StringVar(&c.exceptions, "exceptions", StringValueNoData, "EXCEPTIONS", "Set configuration: comma-separated list of IP addresses or subnets (using CIDR notation)\nthat will be allowed through the firewall when enabled\nExamples:\n\tivpn firewall -exceptions '192.0.2.0/24, 198.51.100.1'\n\tivpn firewall -exceptions ''")
+	c.StringVar(&c.exceptions, "exceptions", StringValueNoData, "EXCEPTIONS", "Set configuration: comma-separated list of IP addresses or subnets (using CIDR notation)\nthat will be allowed through the firewall when enabled\nHost names are also accepted: they are resolved by trusted DNS resolver and the addresses are refreshed periodically\nException can be restricted by protocol, port and direction (Linux only):\n\t[DIRECTION:][PROTOCOL[:PORT[-PORT]]@]ADDRESS[/MASK]\n\tPROTOCOL - tcp, udp or icmp\n\tDIRECTION - 'out' (only outbound connections; inbound traffic allowed only for established connections)\n\t            or 'both' (default)\nExamples:\n\tivpn firewall -exceptions '192.0.2.0/24, 198.51.100.1'\n\tivpn firewall -exceptions 'tcp:22@192.0.2.0/24, udp:51820@198.51.100.7, icmp@10.0.0.0/8'\n\tivpn firewall -exceptions 'out:tcp:8000-8080@192.0.2.1, out@10.0.0.0/8'\n\tivpn firewall -exceptions 'git.example.com, 192.0.2.0/24'\n\tivpn firewall -exceptions ''")
+	c.StringVar(&c.interfaces, "interfaces", StringValueNoData, "PATTERNS", "(Linux only) Set configuration: comma-separated list of network interface name patterns\n(e.g. bridges of containers and virtual machines) which local traffic will be allowed through the firewall when enabled\n(patterns matching physical interfaces, e.g. '*' or 'en*', are rejected; the default route and VPN interfaces are never allowed)\nExamples:\n\tivpn firewall -interfaces 'docker0, br-*, virbr*'\n\tivpn firewall -interfaces ''")
+	c.StringVar(&c.interfacesForward, "interfaces_forward", "", "POLICY", "(Linux only) Set configuration: policy for traffic forwarded from the interfaces defined by '-interfaces':\n\ttunnel - (default) forwarded traffic is allowed only through the VPN tunnel\n\tblock  - forwarded traffic is blocked")
+	c.StringVar(&c.allowApps, "allow_apps", StringValueNoData, "APPS", "(Linux only) Set configuration: comma-separated list of applications (binaries)\nwhich are allowed to communicate when the firewall is enabled (even when VPN is not connected)\nExamples:\n\tivpn firewall -allow_apps '/usr/bin/apt-get, /opt/sso-agent/agent'\n\tivpn firewall -allow_apps ''")
+	c.StringVar(&c.allowUsers, "allow_users", StringValueNoData, "USERS", "(Linux only) Set configuration: comma-separated list of users (names or UIDs)\nwhich processes are allowed to communicate when the firewall is enabled (even when VPN is not connected)\nExamples:\n\tivpn firewall -allow_users '_apt, 1001'\n\tivpn firewall -allow_users ''")
+	c.BoolVar(&c.blocked, "blocked", false, "(Linux only) Show packets blocked by firewall (requires logging to be enabled by '-blocked_log_on')")
+	c.BoolVar(&c.blockedClear, "blocked_clear", false, "(Linux only) Show packets blocked by firewall and clear the collected info")
+	c.BoolVar(&c.blockedLogOn, "blocked_log_on", false, "(Linux only) Enable logging of packets blocked by firewall\nThe logging is rate-limited and aggregated by destination, port and process (where possible)")
+	c.BoolVar(&c.blockedLogOff, "blocked_log_off", false, "(Linux only) Disable logging of packets blocked by firewall")
+	c.BoolVar(&c.captivePortalOn, "captive_portal_on", false, "Enable captive portal detection: when firewall is enabled and VPN is not connected,\nthe daemon checks the connectivity after WiFi network change and, if a captive portal is detected,\ntemporarily allows access to the gateway and the portal (HTTP/HTTPS) to be able to log in")
+	c.BoolVar(&c.captivePortalOff, "captive_portal_off", false, "Disable captive portal detection")
 	//c.BoolVar(&c.allowLanMulticast, "lan_multicast_allow", false, "Same as 'lan_allow' + allow multicast communication ")
 	//c.BoolVar(&c.blockLanMulticast, "lan_multicast_block", false, "Same as 'lan_block' + block multicast communication")
 }
@@ -78,6 +112,14 @@ func (c *CmdFirewall) Run() error {
 		return flags.BadParameter{}
 	}
 
+	if c.blockedLogOn && c.blockedLogOff {
+		return flags.BadParameter{}
+	}
+
+	if c.captivePortalOn && c.captivePortalOff {
+		return flags.BadParameter{}
+	}
+
 	//if c.allowLanMulticast && c.blockLanMulticast {
 	//	return flags.BadParameter{}
 	//}
@@ -118,6 +160,18 @@ func (c *CmdFirewall) Run() error {
 		}
 	}
 
+	if c.interfaces != StringValueNoData || len(c.interfacesForward) > 0 {
+		if err := c.setInterfaceExceptions(); err != nil {
+			return err
+		}
+	}
+
+	if c.allowApps != StringValueNoData || c.allowUsers != StringValueNoData {
+		if err := c.setAppAllowlist(); err != nil {
+			return err
+		}
+	}
+
 	if c.persistentOn {
 		if err := _proto.FirewallPersistentSet(true); err != nil {
 			return err
@@ -148,14 +202,55 @@ func (c *CmdFirewall) Run() error {
 		}
 	}
 
+	if c.blockedLogOn {
+		if err := _proto.FirewallSetBlockedPacketsLogging(true); err != nil {
+			return err
+		}
+	} else if c.blockedLogOff {
+		if err := _proto.FirewallSetBlockedPacketsLogging(false); err != nil {
+			return err
+		}
+	}
+
+	if c.captivePortalOn {
+		if err := _proto.SetPreferences(string(types.Prefs_IsCaptivePortalDetection), "true"); err != nil {
+			return err
+		}
+	} else if c.captivePortalOff {
+		if err := _proto.SetPreferences(string(types.Prefs_IsCaptivePortalDetection), "false"); err != nil {
+			return err
+		}
+	}
+
+	if c.blocked || c.blockedClear {
+		info, err := _proto.FirewallGetBlockedPackets(c.blockedClear)
+		if err != nil {
+			return err
+		}
+		printFirewallBlockedPackets(info)
+		return nil
+	}
+
 	state, err := _proto.FirewallStatus()
 	if err != nil {
 		return err
 	}
 
 	w := printFirewallState(nil, state.IsEnabled, state.IsPersistent, state.IsAllowLAN, state.IsAllowMulticast, state.IsAllowApiServers, state.UserExceptions, nil)
+	printFirewallExceptionHosts(w, state.UserExceptionsHosts)
+	printFirewallInterfaceExceptions(w, state.InterfaceExceptions, state.InterfaceExceptionsActive)
+	printFirewallAppAllowlist(w, state.AppAllowlist)
+	printFirewallCaptivePortal(w, state.CaptivePortal)
 	w.Flush()
 
+	if c.verbose {
+		rules, err := _proto.FirewallGetRules()
+		if err != nil {
+			return err
+		}
+		printFirewallRules(rules)
+	}
+
 	// TIPS
 	tips := make([]TipType, 0, 2)
 	if state.IsEnabled == false {
@@ -166,3 +261,227 @@ func (c *CmdFirewall) Run() error {
 	PrintTips(tips)
 	return nil
 }
+
+func printFirewallExceptionHosts(w *tabwriter.Writer, hosts []service_types.FwExceptionHost) {
+	for _, h := range hosts {
+		ips := strings.Join(h.IPs, ", ")
+		if len(ips) == 0 {
+			ips = "<not resolved>"
+		}
+		fmt.Fprintf(w, "    Allow host %s\t:\t%s\n", h.Host, ips)
+		if len(h.Error) > 0 {
+			fmt.Fprintf(w, "        Last error\t:\t%s\n", h.Error)
+		}
+		if h.NextUpdate > 0 {
+			fmt.Fprintf(w, "        Next update\t:\t%s\n", time.Unix(h.NextUpdate, 0).Format(time.TimeOnly))
+		}
+	}
+}
+
+func (c *CmdFirewall) setInterfaceExceptions() error {
+	var cfg firewall.InterfaceExceptions
+	if c.interfaces == StringValueNoData || len(c.interfacesForward) == 0 {
+		// keep current configuration for the values which are not defined
+		state, err := _proto.FirewallStatus()
+		if err != nil {
+			return err
+		}
+		cfg = state.InterfaceExceptions
+	}
+
+	if c.interfaces != StringValueNoData {
+		cfg.Patterns = strings.FieldsFunc(c.interfaces, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	if len(c.interfacesForward) > 0 {
+		cfg.ForwardPolicy = firewall.ForwardPolicy(strings.ToLower(strings.TrimSpace(c.interfacesForward)))
+		if cfg.ForwardPolicy != firewall.ForwardPolicyTunnel && cfg.ForwardPolicy != firewall.ForwardPolicyBlock {
+			return flags.BadParameter{Message: fmt.Sprintf("interfaces_forward: unexpected value '%s'", c.interfacesForward)}
+		}
+	}
+
+	return _proto.FirewallSetInterfaceExceptions(cfg)
+}
+
+func (c *CmdFirewall) setAppAllowlist() error {
+	state, err := _proto.FirewallStatus()
+	if err != nil {
+		return err
+	}
+	cfg := state.AppAllowlist // keep current configuration for the values which are not defined
+
+	splitFunc := func(r rune) bool { return r == ',' || r == ' ' }
+	if c.allowApps != StringValueNoData {
+		cfg.Apps = nil
+		for _, app := range strings.FieldsFunc(c.allowApps, splitFunc) {
+			binary, err := exec.LookPath(app)
+			if err != nil {
+				return flags.BadParameter{Message: fmt.Sprintf("allow_apps: %s", err)}
+			}
+			if binary, err = filepath.Abs(binary); err != nil {
+				return err
+			}
+			cfg.Apps = append(cfg.Apps, binary)
+		}
+	}
+
+	if c.allowUsers != StringValueNoData {
+		cfg.Uids = nil
+		for _, name := range strings.FieldsFunc(c.allowUsers, splitFunc) {
+			uid, err := strconv.Atoi(name)
+			if err != nil {
+				u, err := user.Lookup(name)
+				if err != nil {
+					return flags.BadParameter{Message: fmt.Sprintf("allow_users: %s", err)}
+				}
+				if uid, err = strconv.Atoi(u.Uid); err != nil {
+					return err
+				}
+			}
+			cfg.Uids = append(cfg.Uids, uid)
+		}
+	}
+
+	return _proto.FirewallSetAppAllowlist(cfg)
+}
+
+func printFirewallCaptivePortal(w *tabwriter.Writer, status service_types.CaptivePortalStatus) {
+	if !status.IsEnabled {
+		return
+	}
+	fmt.Fprintf(w, "    Captive portal detection\t:\tEnabled\n")
+	if !status.IsDetected {
+		return
+	}
+	fmt.Fprintf(w, "        Captive portal\t:\t%s\n", status.PortalURL)
+	fmt.Fprintf(w, "        Temporarily allowed\t:\t%s\n", strings.Join(status.AllowedIPs, ", "))
+	if status.ExpiresAt > 0 {
+		fmt.Fprintf(w, "        Allowed till\t:\t%s\n", time.Unix(status.ExpiresAt, 0).Format(time.TimeOnly))
+	}
+}
+
+func printFirewallAppAllowlist(w *tabwriter.Writer, cfg firewall.AppAllowlist) {
+	if len(cfg.Apps) > 0 {
+		fmt.Fprintf(w, "    Allow applications\t:\t%s\n", strings.Join(cfg.Apps, ", "))
+	}
+	if len(cfg.Uids) > 0 {
+		users := make([]string, 0, len(cfg.Uids))
+		for _, uid := range cfg.Uids {
+			if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+				users = append(users, fmt.Sprintf("%s (%d)", u.Username, uid))
+			} else {
+				users = append(users, strconv.Itoa(uid))
+			}
+		}
+		fmt.Fprintf(w, "    Allow users\t:\t%s\n", strings.Join(users, ", "))
+	}
+}
+
+func printFirewallInterfaceExceptions(w *tabwriter.Writer, cfg firewall.InterfaceExceptions, active []string) {
+	if cfg.IsEmpty() {
+		return
+	}
+	activeStr := strings.Join(active, ", ")
+	if len(activeStr) == 0 {
+		activeStr = "<no matching interfaces>"
+	}
+	fmt.Fprintf(w, "    Allow interfaces\t:\t%s\n", strings.Join(cfg.Patterns, ", "))
+	fmt.Fprintf(w, "        Active\t:\t%s\n", activeStr)
+	fmt.Fprintf(w, "        Forwarded traffic\t:\t%s\n", cfg.ForwardPolicy)
+}
+
+func printFirewallBlockedPackets(info firewall.BlockedPacketsInfo) {
+	if !info.IsEnabled {
+		fmt.Println("Logging of blocked packets is disabled")
+		PrintTips([]TipType{TipFirewallBlockedLogEnable})
+		if len(info.Entries) == 0 {
+			return
+		}
+		fmt.Println()
+	}
+
+	if len(info.Entries) == 0 {
+		fmt.Println("No blocked packets")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LAST SEEN\tDIRECTION\tPROTOCOL\tREMOTE\tLOCAL PORT\tPROCESS\tINTERFACE\tCOUNT")
+	for _, e := range info.Entries {
+		remote := e.RemoteIP
+		if e.RemotePort > 0 {
+			remote = net.JoinHostPort(e.RemoteIP, strconv.Itoa(e.RemotePort))
+		}
+		localPort := "-"
+		if e.LocalPort > 0 {
+			localPort = strconv.Itoa(e.LocalPort)
+		}
+		process := e.Process
+		if len(process) == 0 {
+			process = "-"
+		}
+		if e.UID >= 0 {
+			process += fmt.Sprintf(" (uid %d)", e.UID)
+		}
+		iface := e.Interface
+		if len(iface) == 0 {
+			iface = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			time.Unix(e.LastSeen, 0).Format(time.TimeOnly), e.Direction, e.Protocol, remote, localPort, process, iface, e.Count)
+	}
+	w.Flush()
+	fmt.Printf("\nTotal logged packets: %d (the logging is rate-limited)\n", info.TotalCount)
+}
+
+func printFirewallRules(rules firewall.RuleSet) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Active rules:")
+	if rules.VpnServer != nil {
+		fmt.Fprintf(w, "    VPN server\t:\t%s\n", net.JoinHostPort(rules.VpnServer.Address, strconv.Itoa(rules.VpnServer.Port))+" ("+rules.VpnServer.Protocol+")")
+	}
+	if len(rules.ClientAddresses) > 0 {
+		fmt.Fprintf(w, "    Tunnel addresses\t:\t%s\n", strings.Join(rules.ClientAddresses, ", "))
+	}
+
+	dnsRestriction := "all plain DNS requests are blocked"
+	if len(rules.Dns.AllowedAddress) > 0 {
+		dnsRestriction = "plain DNS requests allowed only to " + rules.Dns.AllowedAddress
+	}
+	if len(rules.Dns.Dns) > 0 {
+		fmt.Fprintf(w, "    DNS\t:\t%s\n", rules.Dns.Dns)
+	}
+	fmt.Fprintf(w, "    DNS restriction\t:\t%s\n", dnsRestriction)
+
+	for _, h := range rules.Hosts {
+		categories := make([]string, 0, len(h.Categories))
+		for _, c := range h.Categories {
+			categories = append(categories, string(c))
+		}
+		if len(categories) == 0 {
+			categories = append(categories, "-")
+		}
+		fmt.Fprintf(w, "    Allow %s\t:\t%s\n", h.Address, strings.Join(categories, ", "))
+	}
+	for _, r := range rules.UserExceptionRules {
+		fmt.Fprintf(w, "    Allow rule\t:\t%s\n", r)
+	}
+	for _, iface := range rules.Interfaces {
+		fmt.Fprintf(w, "    Allow interface\t:\t%s\n", iface)
+	}
+
+	fmt.Fprintln(w, "")
+	switch {
+	case !rules.Consistency.IsChecked:
+		fmt.Fprintln(w, "Consistency check: not supported on this platform")
+	case rules.Consistency.IsConsistent:
+		fmt.Fprintln(w, "Consistency check: OK")
+	default:
+		fmt.Fprintln(w, "Consistency check: FAILED")
+		for _, issue := range rules.Consistency.Issues {
+			fmt.Fprintf(w, "    %s\n", issue)
+		}
+	}
+	w.Flush()
+}
@@ -26,14 +26,18 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/ivpn/desktop-app/cli/cliplatform"
 	"github.com/ivpn/desktop-app/cli/flags"
 	"github.com/ivpn/desktop-app/cli/helpers"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
+	"github.com/ivpn/desktop-app/daemon/splittun"
 )
 
 type Exclude struct {
@@ -173,6 +177,22 @@ type SplitTun struct {
 	appremove  string
 	appadd     string // this parameter is not in use. We need it just for help info (using 'appaddArgs' parsed with specific logic)
 	appaddArgs []string
+
+	appruleAdd    string // (Linux) persistent rule by executable path
+	appruleRemove string
+
+	route        string // destination-based split tunnel: IPv4 network or domain name
+	routeRemove  string
+	routeInverse string // [on/off]
+	routeClear   bool
+
+	netns string // [on/off] (Linux) "VPN-only" network namespace mode
+
+	dns string // (Linux) DNS resolver for the apps: default, system or IPv4 address
+
+	user       string // (Linux) rules by user account: USER or group:GROUP
+	userVpn    string
+	userRemove string
 }
 
 const (
@@ -202,6 +222,13 @@ func (c *SplitTun) Init() {
 		c.BoolVar(&c.reset, "clean", false, "Erase configuration (remove applications from configuration and disable Split Tunnel)")
 		c.StringVar(&c.appadd, "appadd", "", "COMMAND", "Execute command (binary) in Split Tunnel environment\nInfo: short version of this command is 'ivpn exclude <command>'\nExamples:\n    ivpn splittun -appadd firefox\n    ivpn splittun -appadd ping 1.1.1.1\n    ivpn splittun -appadd /usr/bin/google-chrome")
 		c.StringVar(&c.appremove, "appremove", "", "PID", "Remove application from Split Tunnel environment\n(argument: Process ID)")
+		c.StringVar(&c.appruleAdd, "apprule_add", "", "PATH", "Add persistent rule for the binary (use full path to binary)\nAll processes started from the binary (e.g. from the desktop launcher)\nwill be added to Split Tunnel environment automatically\nExamples:\n    ivpn splittun -apprule_add /usr/bin/steam\n    ivpn splittun -apprule_add /opt/zoom/zoom")
+		c.StringVar(&c.appruleRemove, "apprule_remove", "", "PATH", "Remove persistent rule for the binary (use full path to binary)")
+		c.StringVar(&c.dns, "dns", "", "DNS", "DNS resolver for the applications in Split Tunnel environment:\n    default - the system DNS configuration (the VPN DNS when VPN is connected)\n    system  - the original system DNS (saved before connecting to VPN)\n    <IPv4>  - the specified DNS server\nDNS requests of the applications are sent outside the VPN tunnel (except 'default').\nNote! Requests to a local resolver (loopback address, e.g. systemd-resolved 127.0.0.53) are not redirected\nNote! Not applicable for Inverse Split Tunnel mode\nExamples:\n    ivpn splittun -dns system\n    ivpn splittun -dns 9.9.9.9")
+		c.StringVar(&c.user, "user", "", "USER", "Add rule by user account: all traffic of the user bypasses the VPN tunnel\n(no matter how its processes start; the rule is effective when Split Tunnel is enabled)\nUSER is a user name or UID; use 'group:GROUP' for a group name or GID\nExamples:\n    ivpn splittun -user backup\n    ivpn splittun -user group:ci-runners")
+		c.StringVar(&c.userVpn, "user_vpn", "", "USER", "Add rule by user account: all traffic of the user uses only the VPN tunnel\n(the traffic is blocked when VPN is not connected)\nExample:\n    ivpn splittun -user_vpn 1001")
+		c.StringVar(&c.userRemove, "user_remove", "", "USER", "Remove rule by user account")
+		c.StringVar(&c.netns, "netns", "", "[on/off]", "\"VPN-only\" network namespace mode (WireGuard only)\nThe VPN interface is moved to the dedicated network namespace;\napplications started by 'ivpn exec-vpn <command>' use only the VPN tunnel\nwhile the rest of the system uses the default connection.\nNote! The mode can be changed only when VPN is disconnected.")
 	}
 
 	c.BoolVar(&c.onInverse, cmd_name_on_inverse, false,
@@ -226,6 +253,21 @@ func (c *SplitTun) Init() {
 
 	c.BoolVar(&c.on, "on", false, "Enable: exclude traffic from specific applications from being routed trough the VPN")
 
+	c.StringVar(&c.route, "route", "", "DESTINATION", `Route destination outside the VPN tunnel (IPv4 network, IPv4 address or domain name)
+		Domain names are resolved by the daemon and refreshed periodically.
+		The destinations are also allowed by the IVPN Firewall.
+		Note! IPv6 is not supported: IPv6 networks are rejected and only IPv4 addresses of domain names are used.
+		Note! Routes are independent of the application-based Split Tunnel configuration.
+		Examples:
+		    ivpn splittun -route 192.0.2.0/24
+		    ivpn splittun -route example.com`)
+	c.StringVar(&c.routeRemove, "route_remove", "", "DESTINATION", "Remove destination from the routes configuration")
+	c.StringVar(&c.routeInverse, "route_inverse", "", "[on/off]",
+		`Inverse mode for routes: only the specified destinations utilize the VPN connection,
+		while all other traffic circumvents the VPN, using the default connection.
+		Note! The IVPN Firewall must be disabled to use this mode.`)
+	c.BoolVar(&c.routeClear, "route_clear", false, "Remove all destinations from the routes configuration")
+
 	c.BoolVar(&c.off, "off", false, "Disable Split Tunnel mode")
 
 }
@@ -241,12 +283,66 @@ func (c *SplitTun) Run() error {
 	if len(c.appadd) > 0 && len(c.appremove) > 0 {
 		return flags.ConflictingParameters{}
 	}
+	if len(c.appruleAdd) > 0 && len(c.appruleRemove) > 0 {
+		return flags.ConflictingParameters{}
+	}
+
+	if len(c.route) > 0 && len(c.routeRemove) > 0 {
+		return flags.ConflictingParameters{}
+	}
+	if (len(c.user) > 0 && len(c.userVpn) > 0) || (len(c.user) > 0 && len(c.userRemove) > 0) || (len(c.userVpn) > 0 && len(c.userRemove) > 0) {
+		return flags.ConflictingParameters{}
+	}
 
 	cfg, err := _proto.GetSplitTunnelStatus()
 	if err != nil {
 		return err
 	}
 
+	if len(c.dns) > 0 {
+		policy, customDns := splittun.DnsPolicyCustom, c.dns
+		switch strings.ToLower(strings.TrimSpace(c.dns)) {
+		case "default":
+			policy, customDns = splittun.DnsPolicyDefault, ""
+		case "system":
+			policy, customDns = splittun.DnsPolicySystem, ""
+		}
+		if err = _proto.SplitTunnelSetDnsPolicy(policy, customDns); err != nil {
+			return err
+		}
+		if cfg, err = _proto.GetSplitTunnelStatus(); err != nil {
+			return err
+		}
+		w := printSplitTunDns(nil, cfg)
+		w.Flush()
+		return nil
+	}
+
+	if len(c.user) > 0 || len(c.userVpn) > 0 || len(c.userRemove) > 0 {
+		return c.doUpdateUserRules(cfg)
+	}
+
+	if len(c.netns) > 0 {
+		isEnabled, err := helpers.BoolParameterParse(c.netns) // [on/off]
+		if err != nil {
+			return err
+		}
+		if err = _proto.SplitTunnelSetNetns(isEnabled); err != nil {
+			return err
+		}
+		if cfg, err = _proto.GetSplitTunnelStatus(); err != nil {
+			return err
+		}
+		w := printSplitTunNetns(nil, cfg)
+		w.Flush()
+		return nil
+	}
+
+	// destination-based split tunnel (available even if application-based Split Tunnel is not supported)
+	if len(c.route) > 0 || len(c.routeRemove) > 0 || len(c.routeInverse) > 0 || c.routeClear {
+		return c.doUpdateRoutes(cfg)
+	}
+
 	dnsFirewall := !cfg.IsAnyDns
 	if len(c.dnsFirewall) > 0 {
 		if !c.onInverse {
@@ -309,6 +405,34 @@ func (c *SplitTun) Run() error {
 		return c.doShowStatusShort(cfg)
 	}
 
+	if len(c.appruleAdd) > 0 || len(c.appruleRemove) > 0 {
+		if len(c.appruleAdd) > 0 {
+			binary, err := exec.LookPath(c.appruleAdd)
+			if err != nil {
+				return err
+			}
+			if binary, err = filepath.Abs(binary); err != nil {
+				return err
+			}
+			if err = _proto.SplitTunnelAddAppRule(binary); err != nil {
+				return err
+			}
+		} else {
+			binary, err := filepath.Abs(c.appruleRemove)
+			if err != nil {
+				return err
+			}
+			if err = _proto.SplitTunnelRemoveApp(binary); err != nil {
+				return err
+			}
+		}
+
+		cfg, err = _proto.GetSplitTunnelStatus()
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(c.appaddArgs) > 0 || len(c.appremove) > 0 {
 		if len(c.appaddArgs) > 0 {
 			if err = doAddApp(c.appaddArgs, "", false); err != nil {
@@ -329,12 +453,255 @@ func (c *SplitTun) Run() error {
 	return c.doShowStatus(cfg, c.statusFull)
 }
 
+func (c *SplitTun) doUpdateRoutes(cfg types.SplitTunnelStatus) error {
+	routes := cfg.Routes
+	isInversed := cfg.IsRoutesInversed
+
+	if c.routeClear {
+		routes = nil
+	}
+	if len(c.route) > 0 {
+		routes = append(routes, c.route)
+	}
+	if len(c.routeRemove) > 0 {
+		toRemove := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(c.routeRemove), "."))
+		newRoutes := make([]string, 0, len(routes))
+		for _, r := range routes {
+			if r == toRemove || r == toRemove+"/32" {
+				continue
+			}
+			newRoutes = append(newRoutes, r)
+		}
+		if len(newRoutes) == len(routes) {
+			return fmt.Errorf("destination '%s' not found in the routes configuration", c.routeRemove)
+		}
+		routes = newRoutes
+	}
+	if len(c.routeInverse) > 0 {
+		v, err := helpers.BoolParameterParse(c.routeInverse) // [on/off]
+		if err != nil {
+			return err
+		}
+		isInversed = v
+	}
+
+	if err := _proto.SplitTunnelSetRoutes(routes, isInversed); err != nil {
+		return err
+	}
+	cfg, err := _proto.GetSplitTunnelStatus()
+	if err != nil {
+		return err
+	}
+	w := printSplitTunRoutes(nil, cfg)
+	w.Flush()
+	return nil
+}
+
 func (c *SplitTun) doShowStatus(cfg types.SplitTunnelStatus, isFull bool) error {
 	w := printSplitTunState(nil, false, isFull, cfg.IsEnabled, cfg.IsInversed, cfg.IsAnyDns, cfg.IsAllowWhenNoVpn, cfg.SplitTunnelApps, cfg.RunningApps)
+	w = printSplitTunRoutes(w, cfg)
+	if cliplatform.IsSplitTunRunsApp() {
+		w = printSplitTunDns(w, cfg)
+		w = printSplitTunUserRules(w, cfg)
+		w = printSplitTunNetns(w, cfg)
+	}
+	w.Flush()
+	return nil
+}
+
+// printSplitTunRoutes prints configuration of destination-based split tunnel
+func printSplitTunRoutes(w *tabwriter.Writer, cfg types.SplitTunnelStatus) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	if len(cfg.Routes) == 0 {
+		fmt.Fprintf(w, "Split Tunnel routes\t:\tNone\n")
+		return w
+	}
+
+	mode := "bypass VPN"
+	if cfg.IsRoutesInversed {
+		mode = "only these use VPN (INVERSE MODE)"
+	}
+	for i, r := range cfg.Routes {
+		if i == 0 {
+			fmt.Fprintf(w, "Split Tunnel routes\t:\t%v [%s]\n", r, mode)
+		} else {
+			fmt.Fprintf(w, "\t\t%v\n", r)
+		}
+	}
+	for i, r := range cfg.AppliedRoutes {
+		if i == 0 {
+			fmt.Fprintf(w, "    Applied routes\t:\t%v\n", r)
+		} else {
+			fmt.Fprintf(w, "\t\t%v\n", r)
+		}
+	}
+	return w
+}
+
+func (c *SplitTun) doUpdateUserRules(cfg types.SplitTunnelStatus) error {
+	var (
+		arg   string
+		isVpn bool
+	)
+	switch {
+	case len(c.user) > 0:
+		arg = c.user
+	case len(c.userVpn) > 0:
+		arg, isVpn = c.userVpn, true
+	default:
+		arg = c.userRemove
+	}
+
+	id, isGroup, err := parseUserAccount(arg)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]splittun.UserRule, 0, len(cfg.UserRules)+1)
+	isRemoved := false
+	for _, r := range cfg.UserRules {
+		if r.Id == id && r.IsGroup == isGroup {
+			isRemoved = true
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if len(c.userRemove) > 0 {
+		if !isRemoved {
+			return fmt.Errorf("rule for '%s' not found in the Split Tunnel configuration", c.userRemove)
+		}
+	} else {
+		rules = append(rules, splittun.UserRule{Id: id, IsGroup: isGroup, IsVpn: isVpn})
+	}
+
+	if err := _proto.SplitTunnelSetUserRules(rules); err != nil {
+		return err
+	}
+	if cfg, err = _proto.GetSplitTunnelStatus(); err != nil {
+		return err
+	}
+	w := printSplitTunUserRules(nil, cfg)
 	w.Flush()
+
+	if !cfg.IsEnabled {
+		fmt.Println("\nNote! The rules by user account are effective only when Split Tunnel is enabled")
+		PrintTips([]TipType{TipSplittunEnable})
+	}
 	return nil
 }
 
+// parseUserAccount converts user account argument to UID (or GID when the argument has prefix 'group:')
+func parseUserAccount(arg string) (id uint32, isGroup bool, err error) {
+	arg = strings.TrimSpace(arg)
+	if strings.HasPrefix(strings.ToLower(arg), "group:") {
+		isGroup = true
+		arg = strings.TrimSpace(arg[len("group:"):])
+	}
+	if len(arg) == 0 {
+		return 0, false, flags.BadParameter{Message: "user account is not defined"}
+	}
+
+	idStr := arg
+	if _, e := strconv.ParseUint(arg, 10, 32); e != nil {
+		// not a numeric ID: resolve the name
+		if isGroup {
+			g, err := user.LookupGroup(arg)
+			if err != nil {
+				return 0, false, err
+			}
+			idStr = g.Gid
+		} else {
+			u, err := user.Lookup(arg)
+			if err != nil {
+				return 0, false, err
+			}
+			idStr = u.Uid
+		}
+	}
+
+	v, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("unexpected ID '%s': %w", idStr, err)
+	}
+	return uint32(v), isGroup, nil
+}
+
+// printSplitTunDns prints DNS resolver for the apps in Split Tunnel environment (Linux)
+func printSplitTunDns(w *tabwriter.Writer, cfg types.SplitTunnelStatus) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	var policy string
+	switch cfg.DnsPolicy {
+	case splittun.DnsPolicySystem:
+		policy = "original system DNS"
+	case splittun.DnsPolicyCustom:
+		policy = "custom DNS " + cfg.DnsCustom
+	default:
+		policy = "default (system DNS configuration)"
+	}
+	if cfg.IsInversed && cfg.DnsPolicy != splittun.DnsPolicyDefault {
+		policy += " [not applicable in inverse mode]"
+	} else if len(cfg.DnsServer) > 0 {
+		policy += fmt.Sprintf(" [in use: %s]", cfg.DnsServer)
+	}
+	fmt.Fprintf(w, "Split Tunnel DNS\t:\t%s\n", policy)
+	return w
+}
+
+// printSplitTunUserRules prints Split Tunnel rules by user account (Linux)
+func printSplitTunUserRules(w *tabwriter.Writer, cfg types.SplitTunnelStatus) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	if len(cfg.UserRules) == 0 {
+		fmt.Fprintf(w, "Split Tunnel users\t:\tNone\n")
+		return w
+	}
+
+	for i, r := range cfg.UserRules {
+		account := fmt.Sprintf("uid %d", r.Id)
+		if r.IsGroup {
+			account = fmt.Sprintf("gid %d", r.Id)
+			if g, err := user.LookupGroupId(strconv.FormatUint(uint64(r.Id), 10)); err == nil {
+				account += " (group " + g.Name + ")"
+			}
+		} else if u, err := user.LookupId(strconv.FormatUint(uint64(r.Id), 10)); err == nil {
+			account += " (" + u.Username + ")"
+		}
+		mode := "bypass VPN"
+		if r.IsVpn {
+			mode = "VPN only"
+		}
+
+		if i == 0 {
+			fmt.Fprintf(w, "Split Tunnel users\t:\t%s [%s]\n", account, mode)
+		} else {
+			fmt.Fprintf(w, "\t\t%s [%s]\n", account, mode)
+		}
+	}
+	return w
+}
+
+// printSplitTunNetns prints state of "VPN-only" network namespace mode (Linux)
+func printSplitTunNetns(w *tabwriter.Writer, cfg types.SplitTunnelStatus) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	if !cfg.IsNetnsMode {
+		fmt.Fprintf(w, "VPN-only namespace\t:\tDisabled\n")
+		return w
+	}
+	fmt.Fprintf(w, "VPN-only namespace\t:\tEnabled ('%s'; use 'ivpn exec-vpn <command>')\n", cfg.NetnsName)
+	return w
+}
+
 func (c *SplitTun) doShowStatusShort(cfg types.SplitTunnelStatus) error {
 	w := printSplitTunState(nil, true, false, cfg.IsEnabled, cfg.IsInversed, cfg.IsAnyDns, cfg.IsAllowWhenNoVpn, cfg.SplitTunnelApps, cfg.RunningApps)
 	w.Flush()
@@ -45,6 +45,7 @@ const (
 	TipFirewallDisable           TipType = iota
 	TipFirewallEnable            TipType = iota
 	TipFirewallDisablePersistent TipType = iota
+	TipFirewallBlockedLogEnable  TipType = iota
 	TipSplittunEnable            TipType = iota
 	TipEaaDisable                TipType = iota
 	TipWiFiStatus                TipType = iota
@@ -97,6 +98,8 @@ func PrintTip(w *tabwriter.Writer, tip TipType) {
 		str = newTip("firewall -on", "Enable firewall (to block all connectivity outside VPN)")
 	case TipFirewallDisablePersistent:
 		str = newTip("firewall -persistent_off", "Disable firewall persistency (Always-on firewall)")
+	case TipFirewallBlockedLogEnable:
+		str = newTip("firewall -blocked_log_on", "Enable logging of packets blocked by firewall")
 	case TipSplittunEnable:
 		str = newTip("splittun -on", "Enable Split Tunnel functionality")
 	case TipEaaDisable:
@@ -24,6 +24,7 @@ package commands
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"runtime"
 	"strings"
@@ -60,6 +61,7 @@ type CmdWiFi struct {
 	default_trust_status string //[none/trusted/untrusted]
 	set_trusted_action   string // [action:value] // actions: 'trusted_vpn_off:[true/false]', 'trusted_firewall_off', 'untrusted_vpn_on', 'untrusted_firewall_on', untrusted_block_lan
 	set_trusted_network  string // [network:status] (status: none/trusted/untrusted; e.g. 'my_home_wifi':trusted)
+	set_trusted_rule     string // [type=value:status] (status: none/trusted/untrusted; e.g. 'dns_suffix=corp.example.com':trusted)
 	reset_settings       bool
 }
 
@@ -97,15 +99,41 @@ func (c *CmdWiFi) Init() {
 					ivpn wifi -set_trusted_action untrusted_enable_firewall:on`)
 	c.StringVar(&c.set_trusted_network, "set_trusted_network", "", "CONFIG",
 		`Set trust status for WiFi network
-			CONFIG parameter format: '<NETWORK_NAME>':<VALUE> 
-				NETWORK_NAME: if empty - will be used WiFi network name which is currently connected
+			CONFIG parameter format: '<NETWORK>':<VALUE> 
+				NETWORK: [glob:|regex:]<NETWORK_NAME>[@<BSSID>[,<BSSID>...]]
+					NETWORK_NAME: if empty - will be used WiFi network name which is currently connected
+					glob:  - NETWORK_NAME is a pattern ('*' - any characters; '?' - any single character)
+					regex: - NETWORK_NAME is a regular expression (must match the whole network name)
+					BSSID  - MAC address of the access point. When defined, the rule is applied only
+					         if both network name and BSSID are matching.
+					         If '@' is defined without BSSID - the BSSID of the currently connected network is used
 				VALUE: [none/trusted/untrusted]
 					(Set the value to "none" to remove the network from the list)
 			Example:
 					ivpn wifi -set_trusted_network work:untrusted
 					ivpn wifi -set_trusted_network 'my home network':trusted
+					ivpn wifi -set_trusted_network 'glob:Starbucks*':untrusted
+					ivpn wifi -set_trusted_network 'CorpWiFi@01:23:45:67:89:ab,01:23:45:67:89:ac':trusted
 					Define current WiFi network as 'untrusted':
-						ivpn wifi -set_trusted_network untrusted`)
+						ivpn wifi -set_trusted_network untrusted
+					Define current WiFi network (only current access point) as 'trusted':
+						ivpn wifi -set_trusted_network @:trusted`)
+	c.StringVar(&c.set_trusted_rule, "set_trusted_rule", "", "CONFIG",
+		`Set trust status for network identified by its properties (applicable for wired networks)
+			The rules are used when no WiFi network rule matches the current network.
+			CONFIG parameter format: '<TYPE>=<VALUE>':<STATUS>
+				TYPE:
+					gateway_mac    - MAC address of the default gateway
+					dns_suffix     - DHCP domain / DNS search suffix (sub-domains are matching too)
+					host_reachable - internal host accepting TCP connections (VALUE format: <IP>:<port>)
+					                 (only 'untrusted' status: the host reachability can be spoofed by any network;
+					                 the host is checked through the physical network interface)
+				STATUS: [none/trusted/untrusted]
+					(Set the status to "none" to remove the rule from the list)
+			Example:
+					ivpn wifi -set_trusted_rule 'gateway_mac=a4:2b:b0:11:22:33':trusted
+					ivpn wifi -set_trusted_rule 'dns_suffix=corp.example.com':trusted
+					ivpn wifi -set_trusted_rule 'host_reachable=10.0.0.5:443':untrusted`)
 
 	c.BoolVar(&c.reset_settings, "reset_settings", false, "Reset WiFi settings to defaults")
 }
@@ -235,32 +263,110 @@ func (c *CmdWiFi) Run() error {
 					string(Untrusted))}
 		}
 
-		if len(netName) == 0 {
+		network, isCurrentBssid := parseTrustedNetwork(netName)
+
+		if len(network.SSID) == 0 || isCurrentBssid {
 			curNet, err := _proto.GetWiFiCurrentNetwork()
 			if err != nil {
 				return fmt.Errorf("failed to obtain info about the currently connected WiFi network: %w", err)
 			}
-			netName = curNet.SSID
-			if len(netName) == 0 {
-				return fmt.Errorf("Unable to obtain info about currently connected WiFi network. Please, specify network name")
+			if len(network.SSID) == 0 {
+				if network.IsPattern() {
+					return flags.BadParameter{Message: "network name pattern not defined"}
+				}
+				network.SSID = curNet.SSID
+				if len(network.SSID) == 0 {
+					return fmt.Errorf("Unable to obtain info about currently connected WiFi network. Please, specify network name")
+				}
+				fmt.Printf("WiFi network not defined. Using current network: '%s'\n", network.SSID)
+			}
+			if isCurrentBssid {
+				if len(curNet.BSSID) == 0 {
+					return fmt.Errorf("Unable to obtain BSSID of the currently connected WiFi network. Please, specify BSSID")
+				}
+				network.BSSIDs = []string{curNet.BSSID}
+				fmt.Printf("BSSID not defined. Using current access point: '%s'\n", curNet.BSSID)
 			}
-			fmt.Printf("WiFi network not defined. Using current network: '%s'\n", netName)
 		}
+		network.IsTrusted = isTrusted
+		if err := network.Validate(); err != nil {
+			return flags.BadParameter{Message: err.Error()}
+		}
+		network = network.Normalize()
 
 		// check if network already exists
+		isExists := false
 		for i, n := range wifiSettings.Networks {
-			if n.SSID == netName {
+			if n.Key() == network.Key() {
 				if isUndefined {
 					wifiSettings.Networks = append(wifiSettings.Networks[:i], wifiSettings.Networks[i+1:]...)
 				} else {
 					wifiSettings.Networks[i].IsTrusted = isTrusted
 				}
-				isSettingsChanged = true
+				isExists = true
 				break
 			}
 		}
-		if !isSettingsChanged {
-			wifiSettings.Networks = append(wifiSettings.Networks, preferences.WiFiNetwork{SSID: netName, IsTrusted: isTrusted})
+		if !isExists && !isUndefined {
+			wifiSettings.Networks = append(wifiSettings.Networks, network)
+		}
+		isSettingsChanged = true
+	}
+
+	if len(c.set_trusted_rule) > 0 {
+		dividerIdx := strings.LastIndex(c.set_trusted_rule, ":")
+		if dividerIdx < 0 {
+			return flags.BadParameter{Message: "rule trust status not defined"}
+		}
+		ruleStr := helpers.TrimSpacesAndRemoveQuotes(c.set_trusted_rule[:dividerIdx])
+		valueStr := helpers.TrimSpacesAndRemoveQuotes(c.set_trusted_rule[dividerIdx+1:])
+
+		isTrusted := false
+		isUndefined := false
+		switch strings.ToLower(valueStr) {
+		case string(NoTrustState):
+			isUndefined = true
+		case string(Trusted):
+			isTrusted = true
+		case string(Untrusted):
+			isTrusted = false
+		default:
+			return flags.BadParameter{
+				Message: fmt.Sprintf("not supported trust state '%s' (acceptable values: %s, %s, %s)", valueStr,
+					string(NoTrustState),
+					string(Trusted),
+					string(Untrusted))}
+		}
+
+		ruleCols := strings.SplitN(ruleStr, "=", 2)
+		if len(ruleCols) != 2 {
+			return flags.BadParameter{Message: fmt.Sprintf("bad rule definition '%s' (expected format: <TYPE>=<VALUE>)", ruleStr)}
+		}
+		rule := preferences.NetworkRule{
+			Type:      preferences.NetworkRuleType(strings.ToLower(strings.TrimSpace(ruleCols[0]))),
+			Value:     strings.TrimSpace(ruleCols[1]),
+			IsTrusted: isTrusted,
+		}
+		if err := rule.Validate(); err != nil {
+			return flags.BadParameter{Message: err.Error()}
+		}
+		rule = rule.Normalize()
+
+		// check if rule already exists
+		isExists := false
+		for i, r := range wifiSettings.NetworkRules {
+			if r.Key() == rule.Key() {
+				if isUndefined {
+					wifiSettings.NetworkRules = append(wifiSettings.NetworkRules[:i], wifiSettings.NetworkRules[i+1:]...)
+				} else {
+					wifiSettings.NetworkRules[i].IsTrusted = isTrusted
+				}
+				isExists = true
+				break
+			}
+		}
+		if !isExists && !isUndefined {
+			wifiSettings.NetworkRules = append(wifiSettings.NetworkRules, rule)
 		}
 		isSettingsChanged = true
 	}
@@ -293,6 +399,39 @@ func (c *CmdWiFi) Run() error {
 	return nil
 }
 
+// parseTrustedNetwork parses network definition in format: [glob:|regex:]NETWORK_NAME[@BSSID[,BSSID...]]
+// Returns isCurrentBssid=true when '@' is defined without BSSID (BSSID of the current network must be used)
+func parseTrustedNetwork(def string) (network preferences.WiFiNetwork, isCurrentBssid bool) {
+	for _, m := range []preferences.WiFiSSIDMatch{preferences.WiFiSSIDMatchGlob, preferences.WiFiSSIDMatchRegex} {
+		if prefix := string(m) + ":"; strings.HasPrefix(strings.ToLower(def), prefix) {
+			network.SSIDMatch = m
+			def = def[len(prefix):]
+			break
+		}
+	}
+
+	network.SSID = def
+	if idx := strings.LastIndex(def, "@"); idx >= 0 {
+		bssidsStr := strings.TrimSpace(def[idx+1:])
+		if len(bssidsStr) == 0 {
+			network.SSID = def[:idx]
+			return network, true
+		}
+
+		var bssids []string
+		for _, b := range strings.Split(bssidsStr, ",") {
+			if _, err := net.ParseMAC(strings.TrimSpace(b)); err != nil {
+				// not a BSSID list: '@' is a part of the network name
+				return network, false
+			}
+			bssids = append(bssids, strings.TrimSpace(b))
+		}
+		network.SSID = def[:idx]
+		network.BSSIDs = bssids
+	}
+	return network, false
+}
+
 func isInsecureNetworksSuppported() bool {
 	return runtime.GOOS != "linux"
 }
@@ -331,6 +470,12 @@ func (c *CmdWiFi) printStatus(w *tabwriter.Writer) *tabwriter.Writer {
 			curNetworkInfo = fmt.Sprintf(" (no encryption)")
 		}
 		fmt.Fprintf(w, "Connected WiFi network%s\t:\t%v\n", curNetworkInfo, curNetworkName)
+		if len(curNet.BSSID) > 0 {
+			fmt.Fprintf(w, "    BSSID\t:\t%v\n", curNet.BSSID)
+		}
+		if len(curNet.Security) > 0 {
+			fmt.Fprintf(w, "    Security\t:\t%v\n", strings.ToUpper(curNet.Security))
+		}
 	}
 
 	wifiSettings := _proto.GetHelloResponse().DaemonSettings.WiFi
@@ -359,7 +504,21 @@ func (c *CmdWiFi) printStatus(w *tabwriter.Writer) *tabwriter.Writer {
 	} else {
 		fmt.Fprintf(w, "Networks:\t\n")
 		for _, n := range wifiSettings.Networks {
-			fmt.Fprintf(w, "        %s\t:\t%v\n", n.SSID, boolToStrEx(&n.IsTrusted, "Trusted", "Untrusted", "No status", ""))
+			name := n.SSID
+			if n.IsPattern() {
+				name = fmt.Sprintf("%s (%s)", n.SSID, n.SSIDMatch)
+			}
+			fmt.Fprintf(w, "        %s\t:\t%v\n", name, boolToStrEx(&n.IsTrusted, "Trusted", "Untrusted", "No status", ""))
+			if len(n.BSSIDs) > 0 {
+				fmt.Fprintf(w, "            BSSID\t:\t%v\n", strings.Join(n.BSSIDs, ", "))
+			}
+		}
+	}
+
+	if len(wifiSettings.NetworkRules) > 0 {
+		fmt.Fprintf(w, "Network rules:\t\n")
+		for _, r := range wifiSettings.NetworkRules {
+			fmt.Fprintf(w, "        %s=%s\t:\t%v\n", r.Type, r.Value, boolToStrEx(&r.IsTrusted, "Trusted", "Untrusted", "No status", ""))
 		}
 	}
 	return w
@@ -105,6 +105,7 @@ func main() {
 		addCommand(&commands.SplitTun{})
 		if cliplatform.IsSplitTunRunsApp() {
 			addCommand(&commands.Exclude{})
+			addCommand(&commands.ExecVpn{})
 		}
 	}
 	addCommand(&commands.CmdWireGuard{})
@@ -39,8 +39,10 @@ import (
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
+	"github.com/ivpn/desktop-app/daemon/splittun"
 	"github.com/ivpn/desktop-app/daemon/version"
 	"github.com/ivpn/desktop-app/daemon/vpn"
 	"golang.org/x/crypto/pbkdf2"
@@ -323,6 +325,66 @@ func (c *Client) FirewallSetUserExceptions(exceptions string) error {
 	return nil
 }
 
+// FirewallSetInterfaceExceptions set configuration 'firewall exceptions for network interfaces' (Linux only)
+func (c *Client) FirewallSetInterfaceExceptions(cfg firewall.InterfaceExceptions) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.KillSwitchSetInterfaceExceptions{InterfaceExceptions: cfg}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FirewallSetAppAllowlist set applications allowed to communicate when firewall is enabled (Linux only)
+func (c *Client) FirewallSetAppAllowlist(cfg firewall.AppAllowlist) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.KillSwitchSetAppAllowlist{AppAllowlist: cfg}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FirewallSetBlockedPacketsLogging enable/disable logging of packets blocked by firewall (Linux only)
+func (c *Client) FirewallSetBlockedPacketsLogging(enable bool) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.KillSwitchSetBlockedPacketsLogging{IsEnabled: enable}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FirewallGetBlockedPackets get info about packets blocked by firewall
+func (c *Client) FirewallGetBlockedPackets(clear bool) (info firewall.BlockedPacketsInfo, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return info, err
+	}
+
+	req := types.KillSwitchGetBlockedPackets{Clear: clear}
+	var resp types.KillSwitchBlockedPacketsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return info, err
+	}
+
+	return resp.Info, nil
+}
+
 // FirewallAllowApiServers set configuration 'Allow access to IVPN servers when Firewall is enabled'
 func (c *Client) FirewallAllowApiServers(allow bool) error {
 	if err := c.ensureConnected(); err != nil {
@@ -370,6 +432,21 @@ func (c *Client) FirewallStatus() (state types.KillSwitchStatusResp, err error)
 	return state, nil
 }
 
+// FirewallGetRules requests structured description of the active firewall rules
+func (c *Client) FirewallGetRules() (rules firewall.RuleSet, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return rules, err
+	}
+
+	req := types.KillSwitchGetRules{}
+	var resp types.KillSwitchRulesResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return rules, err
+	}
+
+	return resp.Rules, nil
+}
+
 // GetSplitTunnelStatus requests the Split-Tunnelling configuration
 func (c *Client) GetSplitTunnelStatus() (cfg types.SplitTunnelStatus, err error) {
 	if err := c.ensureConnected(); err != nil {
@@ -476,6 +553,99 @@ func (c *Client) SplitTunnelAddApp(execCmd string) (isRequiredToExecuteCommand b
 	return true, nil
 }
 
+// SplitTunnelAddAppRule adds persistent Split Tunnel rule by executable path (Linux)
+// All processes started from the binary will be added to Split Tunnel environment automatically
+func (c *Client) SplitTunnelAddAppRule(binaryPath string) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelAddApp{Exec: binaryPath, IsPersistent: true}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SplitTunnelSetRoutes sets destinations (IPv4 networks or domain names) of destination-based split tunnelling
+func (c *Client) SplitTunnelSetRoutes(routes []string, isInversed bool) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelSetRoutes{Routes: routes, IsInversed: isInversed}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SplitTunnelSetUserRules sets Split Tunnel rules by user account (Linux)
+func (c *Client) SplitTunnelSetUserRules(rules []splittun.UserRule) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelSetUserRules{Rules: rules}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SplitTunnelSetDnsPolicy sets DNS resolver for the apps in Split Tunnel environment (Linux)
+func (c *Client) SplitTunnelSetDnsPolicy(policy splittun.DnsPolicy, customDns string) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelSetDnsPolicy{Policy: policy, CustomDns: customDns}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SplitTunnelSetNetns enables/disables "VPN-only" network namespace mode (Linux)
+func (c *Client) SplitTunnelSetNetns(isEnabled bool) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelSetNetns{IsEnabled: isEnabled}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SplitTunnelNetnsExec runs the command in "VPN-only" network namespace (Linux)
+// The daemon starts the command with the credentials, environment and standard input/output of the current process.
+// Returns PID of the started process
+func (c *Client) SplitTunnelNetnsExec(args []string) (pid int, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return 0, err
+	}
+
+	req := types.SplitTunnelNetnsExec{Args: args}
+	var resp types.SplitTunnelNetnsExecResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Pid, nil
+}
+
 func (c *Client) SplitTunnelRemoveApp(cmdOrPid string) error {
 	if err := c.ensureConnected(); err != nil {
 		return err
@@ -693,11 +863,17 @@ func (c *Client) PingServers(vpnTypePrioritized *vpn.Type) (pingResults []types.
 
 // SetManualDNS - sets manual DNS for current VPN connection
 func (c *Client) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker service_types.AntiTrackerMetadata) error {
+	return c.SetManualDNSEx(dnsCfg, antiTracker, false)
+}
+
+// SetManualDNSEx - sets manual DNS for current VPN connection
+// 'failIfTestFailed' - test DNS configuration before applying; do not apply if test failed
+func (c *Client) SetManualDNSEx(dnsCfg dns.DnsSettings, antiTracker service_types.AntiTrackerMetadata, failIfTestFailed bool) error {
 	if err := c.ensureConnected(); err != nil {
 		return err
 	}
 
-	req := types.SetAlternateDns{Dns: dnsCfg, AntiTracker: antiTracker}
+	req := types.SetAlternateDns{Dns: dnsCfg, AntiTracker: antiTracker, FailIfTestFailed: failIfTestFailed}
 	var resp types.EmptyResp
 	if err := c.sendRecv(&req, &resp); err != nil {
 		return err
@@ -706,6 +882,97 @@ func (c *Client) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker service_types.
 	return nil
 }
 
+// GetAntiTrackerCustomLists - get status of custom AntiTracker block/allow lists
+func (c *Client) GetAntiTrackerCustomLists() (dns.DnsFilterStatus, error) {
+	if err := c.ensureConnected(); err != nil {
+		return dns.DnsFilterStatus{}, err
+	}
+
+	req := types.AntiTrackerGetCustomLists{}
+	var resp types.AntiTrackerCustomListsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return dns.DnsFilterStatus{}, err
+	}
+
+	return resp.Status, nil
+}
+
+// SetAntiTrackerCustomLists - set custom AntiTracker block/allow lists
+// 'localContent' - content of the local list files (source path -> file content)
+func (c *Client) SetAntiTrackerCustomLists(cfg dns.DnsFilterConfig, localContent map[string][]byte) (dns.DnsFilterStatus, error) {
+	if err := c.ensureConnected(); err != nil {
+		return dns.DnsFilterStatus{}, err
+	}
+
+	req := types.AntiTrackerSetCustomLists{Config: cfg, LocalContent: localContent}
+	var resp types.AntiTrackerCustomListsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return dns.DnsFilterStatus{}, err
+	}
+
+	return resp.Status, nil
+}
+
+// SetDnsAutoMode - enable/disable 'auto' DNS mode
+func (c *Client) SetDnsAutoMode(enabled bool) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SetDnsAutoMode{Enabled: enabled}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TestDnsConfig - test DNS configuration (without applying it)
+func (c *Client) TestDnsConfig(dnsCfg dns.DnsSettings) (dns.DnsTestResult, error) {
+	if err := c.ensureConnected(); err != nil {
+		return dns.DnsTestResult{}, err
+	}
+
+	req := types.TestDnsConfig{Dns: dnsCfg}
+	var resp types.TestDnsConfigResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return dns.DnsTestResult{}, err
+	}
+
+	return resp.Result, nil
+}
+
+// GetDnsStats - get DNS query statistics
+func (c *Client) GetDnsStats() (dns.DnsStats, error) {
+	if err := c.ensureConnected(); err != nil {
+		return dns.DnsStats{}, err
+	}
+
+	req := types.DnsGetStats{}
+	var resp types.DnsStatsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return dns.DnsStats{}, err
+	}
+
+	return resp.Stats, nil
+}
+
+// SetDnsStatsConfig - enable/disable DNS query statistics
+func (c *Client) SetDnsStatsConfig(cfg dns.DnsStatsConfig) (dns.DnsStats, error) {
+	if err := c.ensureConnected(); err != nil {
+		return dns.DnsStats{}, err
+	}
+
+	req := types.DnsSetStatsConfig{Config: cfg}
+	var resp types.DnsStatsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return dns.DnsStats{}, err
+	}
+
+	return resp.Stats, nil
+}
+
 // SetParanoidModePassword - set password for ParanoidMode (empty string -> disable ParanoidMode)
 func (c *Client) SetParanoidModePassword(secret string) error {
 	if err := c.ensureConnected(); err != nil {
@@ -54,6 +54,24 @@ OUT_IVPN_STAT_EXP=IVPN-OUT-STAT-EXP
 # chain for user-defined exceptios (applicable all time when firewall enabled)
 IN_IVPN_STAT_USER_EXP=IVPN-IN-STAT-USER-EXP
 OUT_IVPN_STAT_USER_EXP=IVPN-OUT-STAT-USER-EXP
+# chains for network interfaces exceptions (e.g. bridges of containers and virtual machines: docker0, br-*, virbr0)
+IN_IVPN_IF_EXP=IVPN-IN-IF-EXP
+OUT_IVPN_IF_EXP=IVPN-OUT-IF-EXP
+FORWARD_IVPN_IF_EXP=IVPN-FORWARD-IF-EXP
+# chains for applications allowed to bypass the firewall (processes in dedicated cgroup or running as defined users)
+IN_IVPN_APP_EXP=IVPN-IN-APP-EXP
+OUT_IVPN_APP_EXP=IVPN-OUT-APP-EXP
+# chains for temporary exceptions restricted by protocol/port (e.g. captive portal access); IPv4 only
+# processed before OUT_IVPN_DNS in order to allow DNS requests to the local gateway
+IN_IVPN_TMP_EXP=IVPN-IN-TMP-EXP
+OUT_IVPN_TMP_EXP=IVPN-OUT-TMP-EXP
+# chains for logging blocked packets (NFLOG); the rules are processed just before the final DROP rule
+IN_IVPN_LOG=IVPN-IN-LOG
+OUT_IVPN_LOG=IVPN-OUT-LOG
+FORWARD_IVPN_LOG=IVPN-FORWARD-LOG
+# rate limit for logging blocked packets
+LOG_LIMIT="10/second"
+LOG_LIMIT_BURST=30
 # chain for non-VPN depended exceptios: only for ICMP protocol (ping)
 IN_IVPN_ICMP_EXP=IVPN-IN-ICMP-EXP
 OUT_IVPN_ICMP_EXP=IVPN-OUT-ICMP-EXP
@@ -70,6 +88,32 @@ _splittun_packets_fwmark_value=0xca6c
 _splittun_comment="IVPN Split Tunneling"
 # Split Tunnel cgroup id
 _splittun_cgroup_classid=0x4956504e
+_splittun_cgroup_name=ivpn-exclude
+
+# ### Applications allowlist ###
+# cgroup for processes allowed to bypass the firewall
+_appallow_cgroup_classid=0x49565046
+_appallow_cgroup_name=ivpn-fw-allow
+
+# cgroup version detection (the same rule is used by the Split Tunnel script and by the daemon).
+# cgroup v2: the packets are matched by the cgroup path of the socket; cgroup v1: by the 'net_cls' class ID
+# Note! For cgroup v2 the cgroup must exist when the iptables rule is added.
+if [ -f /sys/fs/cgroup/cgroup.controllers ]; then
+  _cgroup_v2=1
+  _splittun_cgroup_folder=/sys/fs/cgroup/${_splittun_cgroup_name}
+  _splittun_cgroup_match="--path ${_splittun_cgroup_name}"
+  _appallow_cgroup_folder=/sys/fs/cgroup/${_appallow_cgroup_name}
+  _appallow_cgroup_match="--path ${_appallow_cgroup_name}"
+else
+  _cgroup_v2=0
+  _splittun_cgroup_folder=/sys/fs/cgroup/net_cls/${_splittun_cgroup_name}
+  _splittun_cgroup_match="--cgroup ${_splittun_cgroup_classid}"
+  _appallow_cgroup_folder=/sys/fs/cgroup/net_cls/${_appallow_cgroup_name}
+  _appallow_cgroup_match="--cgroup ${_appallow_cgroup_classid}"
+fi
+# Connection mark for connections initiated by the allowed processes (used to allow inbound packets of the connections)
+# Note! Only the upper 16 bits of the connection mark are used (the lower bits are in use by the Split Tunnel)
+_appallow_connmark=0x49560000/0xffff0000
 
 # returns 0 if chain exists
 function chain_exists()
@@ -175,13 +219,26 @@ function enable_firewall {
       create_chain ${IPv6BIN} ${OUT_IVPN_IF1}
       create_chain ${IPv6BIN} ${FORWARD_IVPN_IF}
 
+      create_chain ${IPv6BIN} ${IN_IVPN_STAT_EXP}
+      create_chain ${IPv6BIN} ${OUT_IVPN_STAT_EXP}
+
+      create_chain ${IPv6BIN} ${IN_IVPN_IF_EXP}
+      create_chain ${IPv6BIN} ${OUT_IVPN_IF_EXP}
+      create_chain ${IPv6BIN} ${FORWARD_IVPN_IF_EXP}
+
       create_chain ${IPv6BIN} ${IN_IVPN_STAT_USER_EXP}
       create_chain ${IPv6BIN} ${OUT_IVPN_STAT_USER_EXP}
 
+      create_chain ${IPv6BIN} ${IN_IVPN_APP_EXP}
+      create_chain ${IPv6BIN} ${OUT_IVPN_APP_EXP}
+
       # block DNS for IPv6
       #
-      # Important: Block DNS before allowing link-local and unique-localaddresses!
+      # Important: Block DNS before allowing link-local and unique-local addresses (see 'Allow LAN')!
       # It will prevent potential DNS leaking in some situations (for example, from VM to a host machine)
+      # (the allowed applications are not affected by DNS restrictions)
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_APP_EXP}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_APP_EXP}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_DNS}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p udp --dport 53 -j DROP
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p tcp --dport 53 -j DROP
@@ -190,17 +247,17 @@ function enable_firewall {
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -o lo -j ACCEPT
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -i lo -j ACCEPT
 
-      # allow link-local addresses
-      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -s FE80::/10 -j ACCEPT
-      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -d FE80::/10 -j ACCEPT
-
-      # allow unique-local addresses
-      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -s FD00::/8 -j ACCEPT
-      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -d FD00::/8 -j ACCEPT
+      # allow neighbor discovery (router/neighbor solicitation and advertisement) required for IPv6 stack functionality
+      # (hop limit 255: the packets can not be forwarded by a router, so they are restricted to the local link)
+      # Note! IPv6 LAN ranges (link-local fe80::/10, unique-local fc00::/7 and multicast) are allowed only when 'Allow LAN' is enabled (see doAllowLAN())
+      for ICMP6_TYPE in 133 134 135 136; do
+        ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -p ipv6-icmp --icmpv6-type ${ICMP6_TYPE} -m hl --hl-eq 255 -j ACCEPT
+        ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -p ipv6-icmp --icmpv6-type ${ICMP6_TYPE} -m hl --hl-eq 255 -j ACCEPT
+      done
 
-      # allow DHCP port (547out 546in)
-      # ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -p udp --dport 547 -j ACCEPT
-      # ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -p udp --dport 546 -j ACCEPT
+      # allow DHCPv6 client (547out 546in): requests only to the 'All DHCP servers' link-local multicast address
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -d FF02::1:2 -p udp --dport 547 -j ACCEPT
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -s FE80::/10 -p udp --dport 546 -j ACCEPT
 
       # IPv6: assign our chains to global (global -> IVPN_CHAIN -> IVPN_VPN_CHAIN)
 
@@ -210,8 +267,12 @@ function enable_firewall {
       ${IPv6BIN} -w ${LOCKWAITTIME} -I FORWARD -j ${FORWARD_IVPN}
 
       # Split Tunnel: Allow packets from/to cgroup (bypass IVPN firewall)
-      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (cgroup) rule for split-tunnel"
-      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (cgroup) rule for split-tunnel"  # this rule is not effective, so we use 'mark' (see the next rule)
+      # (cgroup v2: the cgroup must exist to add the rule; it is not removed by the Split Tunnel script)
+      if [ ${_cgroup_v2} -eq 1 ]; then
+        mkdir -p ${_splittun_cgroup_folder}
+      fi
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m cgroup ${_splittun_cgroup_match} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (cgroup) rule for split-tunnel"
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m cgroup ${_splittun_cgroup_match} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (cgroup) rule for split-tunnel"  # this rule is not effective, so we use 'mark' (see the next rule)
       ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m mark --mark ${_splittun_packets_fwmark_value} -m comment --comment  "${_splittun_comment}" -j ACCEPT  || echo "Failed to add INPUT (mark) rule for split-tunnel"
 
       # exceptions
@@ -220,7 +281,12 @@ function enable_firewall {
 
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF1}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_IF1}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN} -j ${FORWARD_IVPN_IF_EXP}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN} -j ${FORWARD_IVPN_IF}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_STAT_EXP}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_STAT_EXP}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF_EXP}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_IF_EXP}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_STAT_USER_EXP}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
 
@@ -233,6 +299,14 @@ function enable_firewall {
       # Note! If the packet does not match any IVPN rule - DROP it.
       # It prevents traversing packet analysis to the rest rules (if defined) and avoids any leaks
       # This will block all user-defined firewall rules!
+      # log blocked packets (if enabled)
+      create_chain ${IPv6BIN} ${OUT_IVPN_LOG}
+      create_chain ${IPv6BIN} ${IN_IVPN_LOG}
+      create_chain ${IPv6BIN} ${FORWARD_IVPN_LOG}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_LOG}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOG}
+
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j DROP
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN}  -j DROP
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN}  -j DROP
@@ -260,9 +334,19 @@ function enable_firewall {
     create_chain ${IPv4BIN} ${IN_IVPN_STAT_EXP}
     create_chain ${IPv4BIN} ${OUT_IVPN_STAT_EXP}
 
+    create_chain ${IPv4BIN} ${IN_IVPN_IF_EXP}
+    create_chain ${IPv4BIN} ${OUT_IVPN_IF_EXP}
+    create_chain ${IPv4BIN} ${FORWARD_IVPN_IF_EXP}
+
     create_chain ${IPv4BIN} ${IN_IVPN_STAT_USER_EXP}
     create_chain ${IPv4BIN} ${OUT_IVPN_STAT_USER_EXP}
 
+    create_chain ${IPv4BIN} ${IN_IVPN_APP_EXP}
+    create_chain ${IPv4BIN} ${OUT_IVPN_APP_EXP}
+
+    create_chain ${IPv4BIN} ${IN_IVPN_TMP_EXP}
+    create_chain ${IPv4BIN} ${OUT_IVPN_TMP_EXP}
+
     create_chain ${IPv4BIN} ${IN_IVPN_ICMP_EXP}
     create_chain ${IPv4BIN} ${OUT_IVPN_ICMP_EXP}
 
@@ -288,13 +372,23 @@ function enable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -I FORWARD -j ${FORWARD_IVPN}
 
     # Split Tunnel: Allow packets from/to cgroup (bypass IVPN firewall)
-    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (cgroup) rule for split-tunnel"
-    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (cgroup) rule for split-tunnel"  # this rule is not effective, so we use 'mark' (see the next rule)
+    # (cgroup v2: the cgroup must exist to add the rule; it is not removed by the Split Tunnel script)
+    if [ ${_cgroup_v2} -eq 1 ]; then
+      mkdir -p ${_splittun_cgroup_folder}
+    fi
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m cgroup ${_splittun_cgroup_match} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (cgroup) rule for split-tunnel"
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m cgroup ${_splittun_cgroup_match} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (cgroup) rule for split-tunnel"  # this rule is not effective, so we use 'mark' (see the next rule)
     ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m mark --mark ${_splittun_packets_fwmark_value} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (mark) rule for split-tunnel"
 
     # exceptions (must be processed before OUT_IVPN_DNS!)
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF0}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_IF0}
+    # allowed applications (not affected by DNS restrictions)
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_APP_EXP}
+    # temporary exceptions (not affected by DNS restrictions)
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_TMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_TMP_EXP}
 
     # block DNS by default
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_DNS}
@@ -303,10 +397,13 @@ function enable_firewall {
 
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF1}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_IF1}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN} -j ${FORWARD_IVPN_IF_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN} -j ${FORWARD_IVPN_IF}
 
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_STAT_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_STAT_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_IF_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_ICMP_EXP}
@@ -321,6 +418,14 @@ function enable_firewall {
     # Note! If the packet does not match any IVPN rule - DROP it.
     # It prevents traversing packet analysis to the rest rules (if defined) and avoids any leaks
     # This will block all user-defined firewall rules!
+    # log blocked packets (if enabled)
+    create_chain ${IPv4BIN} ${OUT_IVPN_LOG}
+    create_chain ${IPv4BIN} ${IN_IVPN_LOG}
+    create_chain ${IPv4BIN} ${FORWARD_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOG}
+
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j DROP
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN}  -j DROP
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN}  -j DROP
@@ -361,6 +466,16 @@ function disable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${FORWARD_IVPN} -j ${FORWARD_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_TMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_TMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_ICMP_EXP}
 
@@ -378,6 +493,16 @@ function disable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_USER_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${FORWARD_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${FORWARD_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_TMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_TMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_ICMP_EXP}
     # '-X' Delete a user-defined chain
@@ -394,6 +519,16 @@ function disable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_USER_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${FORWARD_IVPN_IF_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${FORWARD_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_APP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_TMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_TMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_ICMP_EXP}
 
@@ -411,6 +546,14 @@ function disable_firewall {
     ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_STAT_USER_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${FORWARD_IVPN} -j ${FORWARD_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_APP_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_APP_EXP}
 
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_IF0}
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_IF0}    
@@ -425,6 +568,14 @@ function disable_firewall {
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_STAT_USER_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_USER_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${FORWARD_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${FORWARD_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_APP_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_APP_EXP}
 
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_IF0}
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_IF0}    
@@ -439,6 +590,14 @@ function disable_firewall {
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_STAT_USER_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_USER_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${FORWARD_IVPN_IF_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${FORWARD_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_APP_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_APP_EXP}
     echo "IVPN Firewall disabled"
 }
 
@@ -502,6 +661,137 @@ function remove_exceptions {
   ${BIN} -w ${LOCKWAITTIME} -D ${OUT_CH} -d $@ -j ACCEPT
 }
 
+# Add user exceptions restricted by protocol/port/direction
+# Each rule is defined by single argument in format: DIRECTION,PROTOCOL,PORT,ADDRESS
+#   DIRECTION - 'out' (only outbound connections allowed; inbound - only established) or 'both'
+#   PROTOCOL  - 'tcp', 'udp', 'icmp' or empty (any protocol)
+#   PORT      - port number or range 'PORT_FROM:PORT_TO'; empty - any port
+function add_user_exception_rules {
+  BIN=$1
+  IN_CH=$2
+  OUT_CH=$3
+  ICMP_PROTO=$4
+  shift 4
+
+  create_chain ${BIN} ${IN_CH}
+  create_chain ${BIN} ${OUT_CH}
+
+  for RULE in "$@"; do
+    IFS=',' read -r DIRECTION PROTOCOL PORT ADDR <<< "${RULE}"
+    [ -z "${ADDR}" ] && continue
+
+    PROTO_ARGS=""
+    if [[ "${PROTOCOL}" = "icmp" ]]; then
+      PROTO_ARGS="-p ${ICMP_PROTO}"
+    elif [[ -n "${PROTOCOL}" ]]; then
+      PROTO_ARGS="-p ${PROTOCOL}"
+    fi
+
+    if [[ -z "${PORT}" ]]; then
+      ${BIN} -w ${LOCKWAITTIME} -A ${OUT_CH} -d ${ADDR} ${PROTO_ARGS} -j ACCEPT
+      if [[ "${DIRECTION}" = "out" ]]; then
+        ${BIN} -w ${LOCKWAITTIME} -A ${IN_CH} -s ${ADDR} ${PROTO_ARGS} -m state --state ESTABLISHED,RELATED -j ACCEPT
+      else
+        ${BIN} -w ${LOCKWAITTIME} -A ${IN_CH} -s ${ADDR} ${PROTO_ARGS} -j ACCEPT
+      fi
+    else
+      # outbound connections to remote port
+      ${BIN} -w ${LOCKWAITTIME} -A ${OUT_CH} -d ${ADDR} ${PROTO_ARGS} --dport ${PORT} -j ACCEPT
+      ${BIN} -w ${LOCKWAITTIME} -A ${IN_CH} -s ${ADDR} ${PROTO_ARGS} --sport ${PORT} -m state --state ESTABLISHED,RELATED -j ACCEPT
+      if [[ "${DIRECTION}" != "out" ]]; then
+        # inbound connections to local port
+        ${BIN} -w ${LOCKWAITTIME} -A ${IN_CH} -s ${ADDR} ${PROTO_ARGS} --dport ${PORT} -j ACCEPT
+        ${BIN} -w ${LOCKWAITTIME} -A ${OUT_CH} -d ${ADDR} ${PROTO_ARGS} --sport ${PORT} -m state --state ESTABLISHED,RELATED -j ACCEPT
+      fi
+    fi
+  done
+}
+
+# Allow local traffic for network interfaces (e.g. bridges of containers and virtual machines)
+# Arguments: BIN FORWARD_POLICY IFACE1 [IFACE2 ...]
+#   FORWARD_POLICY:
+#     'tunnel' - forwarded traffic from the interfaces is allowed only through VPN interface (the rules are in FORWARD_IVPN_IF chain)
+#     'block'  - forwarded traffic from/to the interfaces is blocked
+function set_interface_exceptions {
+  BIN=$1
+  POLICY=$2
+  shift 2
+
+  clean_chain ${BIN} ${IN_IVPN_IF_EXP}
+  clean_chain ${BIN} ${OUT_IVPN_IF_EXP}
+  clean_chain ${BIN} ${FORWARD_IVPN_IF_EXP}
+
+  for IFACE in "$@"; do
+    ${BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN_IF_EXP} -i ${IFACE} -j ACCEPT
+    ${BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_IF_EXP} -o ${IFACE} -j ACCEPT
+
+    # traffic between ports of the same bridge
+    ${BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_IF_EXP} -i ${IFACE} -o ${IFACE} -j ACCEPT
+    if [[ "${POLICY}" = "block" ]]; then
+      ${BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_IF_EXP} -i ${IFACE} -j DROP
+      ${BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_IF_EXP} -o ${IFACE} -j DROP
+    fi
+  done
+}
+
+# Configure logging of blocked packets
+# Arguments: BIN [NFLOG_GROUP]  (logging disabled when NFLOG_GROUP is not defined)
+function set_blocked_log {
+  BIN=$1
+  GROUP=$2
+
+  clean_chain ${BIN} ${OUT_IVPN_LOG}
+  clean_chain ${BIN} ${IN_IVPN_LOG}
+  clean_chain ${BIN} ${FORWARD_IVPN_LOG}
+
+  [ -z "${GROUP}" ] && return 0
+
+  ${BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_LOG} -m limit --limit ${LOG_LIMIT} --limit-burst ${LOG_LIMIT_BURST} -j NFLOG --nflog-group ${GROUP} --nflog-prefix "ivpn-out"
+  ${BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN_LOG} -m limit --limit ${LOG_LIMIT} --limit-burst ${LOG_LIMIT_BURST} -j NFLOG --nflog-group ${GROUP} --nflog-prefix "ivpn-in"
+  ${BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOG} -m limit --limit ${LOG_LIMIT} --limit-burst ${LOG_LIMIT_BURST} -j NFLOG --nflog-group ${GROUP} --nflog-prefix "ivpn-fwd"
+}
+
+# Prepare cgroup for processes allowed to bypass the firewall
+function init_app_allowlist_cgroup {
+  if [ ${_cgroup_v2} -eq 1 ]; then
+    if [ ! -d ${_appallow_cgroup_folder} ]; then
+      mkdir -p ${_appallow_cgroup_folder} || return 3
+    fi
+    return 0
+  fi
+  if [ ! -d /sys/fs/cgroup/net_cls ]; then
+    mkdir -p /sys/fs/cgroup/net_cls || return 1
+  fi
+  if ! mount | grep "/sys/fs/cgroup/net_cls" &>/dev/null ; then
+    mount -t cgroup -o net_cls net_cls /sys/fs/cgroup/net_cls || return 2
+  fi
+  if [ ! -d ${_appallow_cgroup_folder} ]; then
+    mkdir -p ${_appallow_cgroup_folder} || return 3
+  fi
+  echo ${_appallow_cgroup_classid} > ${_appallow_cgroup_folder}/net_cls.classid
+}
+
+# Allow communication for applications: processes in the allowlist cgroup and processes running as defined users
+# Inbound packets are allowed only for connections initiated by the allowed processes
+# Arguments: BIN [UID1 UID2 ...]
+function set_app_allowlist {
+  BIN=$1
+  shift
+
+  clean_chain ${BIN} ${OUT_IVPN_APP_EXP}
+  clean_chain ${BIN} ${IN_IVPN_APP_EXP}
+
+  ${BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN_APP_EXP} -m connmark --mark ${_appallow_connmark} -j ACCEPT
+
+  ${BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_APP_EXP} -m cgroup ${_appallow_cgroup_match} -j CONNMARK --set-xmark ${_appallow_connmark}
+  ${BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_APP_EXP} -m cgroup ${_appallow_cgroup_match} -j ACCEPT
+
+  for USER_ID in "$@"; do
+    ${BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_APP_EXP} -m owner --uid-owner ${USER_ID} -j CONNMARK --set-xmark ${_appallow_connmark}
+    ${BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_APP_EXP} -m owner --uid-owner ${USER_ID} -j ACCEPT
+  done
+}
+
 function add_direction_exception {
   IN_CH=$1
   OUT_CH=$2
@@ -590,6 +880,20 @@ function main {
       shift
       remove_exceptions ${IPv4BIN} ${IN_IVPN_STAT_EXP} ${OUT_IVPN_STAT_EXP} $@
 
+    elif [[ $1 = "-add_exceptions_static_ipv6" ]]; then
+
+      if [ -f /proc/net/if_inet6 ]; then
+        shift
+        add_exceptions ${IPv6BIN} ${IN_IVPN_STAT_EXP} ${OUT_IVPN_STAT_EXP} $@
+      fi
+
+    elif [[ $1 = "-remove_exceptions_static_ipv6" ]]; then
+
+      if [ -f /proc/net/if_inet6 ]; then
+        shift
+        remove_exceptions ${IPv6BIN} ${IN_IVPN_STAT_EXP} ${OUT_IVPN_STAT_EXP} $@
+      fi
+
     # User exceptions
     elif [[ $1 = "-set_user_exceptions_static" ]]; then
 
@@ -611,6 +915,67 @@ function main {
         add_exceptions ${IPv6BIN} ${IN_IVPN_STAT_USER_EXP} ${OUT_IVPN_STAT_USER_EXP} $@
       fi
 
+    elif [[ $1 = "-add_user_exception_rules" ]]; then
+
+      shift
+      add_user_exception_rules ${IPv4BIN} ${IN_IVPN_STAT_USER_EXP} ${OUT_IVPN_STAT_USER_EXP} icmp $@
+
+    elif [[ $1 = "-add_user_exception_rules_ipv6" ]]; then
+
+      if [ -f /proc/net/if_inet6 ]; then
+        shift
+        add_user_exception_rules ${IPv6BIN} ${IN_IVPN_STAT_USER_EXP} ${OUT_IVPN_STAT_USER_EXP} ipv6-icmp $@
+      fi
+
+    # Temporary exceptions (e.g. captive portal access)
+    elif [[ $1 = "-set_temporary_exceptions" ]]; then
+
+      get_firewall_enabled || return 0
+      shift
+      clean_chain ${IPv4BIN} ${IN_IVPN_TMP_EXP}
+      clean_chain ${IPv4BIN} ${OUT_IVPN_TMP_EXP}
+      add_user_exception_rules ${IPv4BIN} ${IN_IVPN_TMP_EXP} ${OUT_IVPN_TMP_EXP} icmp $@
+
+    # Network interfaces exceptions
+    elif [[ $1 = "-set_interface_exceptions" ]]; then
+
+      get_firewall_enabled || return 0
+
+      shift
+      POLICY=$1
+      shift
+
+      set_interface_exceptions ${IPv4BIN} ${POLICY} $@
+      if [ -f /proc/net/if_inet6 ]; then
+        set_interface_exceptions ${IPv6BIN} ${POLICY} $@
+      fi
+
+    # Applications allowlist
+    elif [[ $1 = "-set_app_allowlist" ]]; then
+
+      get_firewall_enabled || return 0
+
+      shift
+
+      init_app_allowlist_cgroup || echo "Failed to initialize cgroup for applications allowlist" >&2
+
+      set_app_allowlist ${IPv4BIN} $@
+      if [ -f /proc/net/if_inet6 ]; then
+        set_app_allowlist ${IPv6BIN} $@
+      fi
+
+    # Logging blocked packets
+    elif [[ $1 = "-set_blocked_log" ]]; then
+
+      get_firewall_enabled || return 0
+
+      GROUP=$2
+
+      set_blocked_log ${IPv4BIN} ${GROUP}
+      if [ -f /proc/net/if_inet6 ]; then
+        set_blocked_log ${IPv6BIN} ${GROUP}
+      fi
+
     # DNS rules
     elif [[ $1 = "-set_dns" ]]; then
 
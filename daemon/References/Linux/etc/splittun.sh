@@ -26,7 +26,27 @@
 # Split Tunneling cgroup parameters
 _cgroup_name=ivpn-exclude
 _cgroup_classid=0x4956504e      # Anything from 0x00000001 to 0xFFFFFFFF
-_cgroup_folder=/sys/fs/cgroup/net_cls/${_cgroup_name}
+
+# cgroup version detection.
+# cgroup v2 is used when the unified hierarchy is mounted to '/sys/fs/cgroup' (cgroup-v2-only systems: e.g. Fedora, recent Ubuntu, Arch).
+# Otherwise (cgroup v1 or hybrid hierarchy) the cgroup v1 'net_cls' controller is used.
+# NOTE! The daemon uses the same detection rule to find the list of processes in the Split Tunnel environment.
+if [ -f /sys/fs/cgroup/cgroup.controllers ]; then
+    _cgroup_v2=1
+    _cgroup_root=/sys/fs/cgroup
+    # Packets are matched by the cgroup v2 path of the socket (kernel 'socket cgroupv2' matching)
+    _cgroup_match="--path ${_cgroup_name}"
+else
+    _cgroup_v2=0
+    _cgroup_root=/sys/fs/cgroup/net_cls
+    # Packets are matched by the 'net_cls' class ID
+    _cgroup_match="--cgroup ${_cgroup_classid}"
+fi
+_cgroup_folder=${_cgroup_root}/${_cgroup_name}
+# cgroup v2: original cgroups of the processes added to Split Tunnel (one file per PID containing the cgroup path).
+# The processes are moved back to their original cgroups when removed from Split Tunnel.
+# NOTE! The daemon uses the same folder when it adds processes to the Split Tunnel cgroup.
+_cgroup_origins_folder=/run/ivpn-exclude-cgroups
 
 # Routing tabel configuration for packets coming from Split-Tunneling environment
 _routing_table_name=ivpn-exclude-tbl
@@ -37,8 +57,14 @@ POSTROUTING_mangle="IVPN_ST_POSTROUTING -t mangle"
 OUTPUT_mangle="IVPN_ST_OUTPUT -t mangle"
 PREROUTING_mangle="IVPN_ST_PREROUTING -t mangle"
 POSTROUTING_nat="IVPN_ST_POSTROUTING -t nat"
+OUTPUT_nat="IVPN_ST_OUTPUT -t nat"
 OUTPUT="IVPN_ST_OUTPUT"
 INPUT="IVPN_ST_INPUT"
+# iptables chains for rules by user account (UID/GID); see updateUserRules()
+_chain_users="IVPN_ST_USERS"
+OUTPUT_USERS_mangle="${_chain_users} -t mangle"
+POSTROUTING_USERS_nat="${_chain_users} -t nat"
+OUTPUT_USERS="${_chain_users}"
 
 # Additional parameters
 _iptables_locktime=2
@@ -58,6 +84,8 @@ _mutable_folder_fallback=/opt/ivpn/mutable      # alternate location of 'mutable
 # The problem is that each time this rule appears with the highest priority.
 # So, this rule absorbs all packets which are not marked as 0xca6c
 _packets_fwmark_value=0xca6c        # Anything from 1 to 2147483647
+# Mask of the connection mark bits used to save/restore packets mark (the upper bits are in use by the IVPN firewall)
+_connmark_mask=0x0000ffff
 
 # Paths to standard binaries
 _bin_iptables=iptables
@@ -84,6 +112,13 @@ _is_inversed=0
 #   -   "0" means the communication for splitted apps will not be blocked
 _is_inversed_blocked=0
 _is_inversed_blocked_ipv6=0
+# DNS server for the apps in Split Tunnel environment (IPv4).
+# When defined - DNS requests (port 53) from the apps are redirected (DNAT) to this server and are routed outside the VPN tunnel.
+# Note! Requests to loopback addresses (e.g. systemd-resolved stub 127.0.0.53) are not redirected: after DNAT they keep
+# the loopback source address and would be dropped as martians on the physical interface. Such requests are processed
+# by the local resolver (according to the system DNS configuration).
+# When empty - the apps are using the system DNS configuration (the VPN DNS when VPN is connected).
+_st_dns=""
 
 vercomp () {
     if [[ $1 == $2 ]]
@@ -116,7 +151,17 @@ vercomp () {
     return 0
 }
 
-function test()
+function test_cgroup_v2()
+{
+    echo "Using cgroup v2 (unified hierarchy)"
+    if ! ${_bin_iptables} -m cgroup -h 2>/dev/null | ${_bin_grep} -e "--path" &>/dev/null ; then
+        echo "ERROR: cgroup v2 path matching is not supported by '${_bin_iptables}' (cgroup match extension)" 1>&2
+        return 1
+    fi
+    return 0
+}
+
+function test_cgroup_v1()
 {
     # TODO: the real mount path have to be taken from /proc/mounts
     # It has format: <devtype> <mount path> <fstype> <options>
@@ -137,7 +182,11 @@ function test()
             return 2; 
         fi
     fi
+    return 0
+}
 
+function test()
+{
     if ! command -v ${_bin_iptables} &>/dev/null ;   then echo "ERROR: Binary Not Found (${_bin_iptables})" 1>&2; return 1; fi
     if ! command -v ${_bin_ip} &>/dev/null ;         then echo "ERROR: Binary Not Found (${_bin_ip})" 1>&2; return 1; fi    
     if ! command -v ${_bin_grep} &>/dev/null ;       then echo "ERROR: Binary Not Found (${_bin_grep})" 1>&2; return 1; fi
@@ -148,6 +197,11 @@ function test()
     if ! command -v ${_bin_awk} &>/dev/null ;        then echo "WARNING: Binary Not Found (${_bin_awk})" 1>&2; fi
     if ! command -v ${_bin_runuser} &>/dev/null ;    then echo "WARNING: Binary Not Found (${_bin_runuser})" 1>&2; fi
 
+    if [ ${_cgroup_v2} -eq 1 ]; then
+        test_cgroup_v2 || return $?
+    else
+        test_cgroup_v1 || return $?
+    fi
 
     # ###
     # -= Compare minimum required iptables version for Inverse Split Tunneling =-
@@ -186,6 +240,7 @@ function init_iptables()
     local bin_iptables=$1
     local def_inf_name=$2
     local inverse_block=$3
+    local st_dns=$4
 
     # in Inverse mode - we are inversing firewall rules:
     # 'splitted' apps use only VPN connection, all the rest apps use default connection settings (bypassing VPN)
@@ -203,34 +258,52 @@ function init_iptables()
     ${bin_iptables} -w ${LOCKWAITTIME} -N ${OUTPUT_mangle}
     ${bin_iptables} -w ${LOCKWAITTIME} -N ${PREROUTING_mangle}
     ${bin_iptables} -w ${LOCKWAITTIME} -N ${POSTROUTING_nat}
+    ${bin_iptables} -w ${LOCKWAITTIME} -N ${OUTPUT_nat}
     ${bin_iptables} -w ${LOCKWAITTIME} -N ${OUTPUT}
     ${bin_iptables} -w ${LOCKWAITTIME} -N ${INPUT}
+    ${bin_iptables} -w ${LOCKWAITTIME} -N ${OUTPUT_USERS_mangle}
+    ${bin_iptables} -w ${LOCKWAITTIME} -N ${POSTROUTING_USERS_nat}
+    ${bin_iptables} -w ${LOCKWAITTIME} -N ${OUTPUT_USERS}
 
     # Save packets mark (to be able to restore mark for incoming packets of the same connection)
-    ${bin_iptables} -w ${_iptables_locktime} -I ${POSTROUTING_mangle} -j CONNMARK --save-mark    
+    # Note! Only the lower 16 bits of the connection mark are used (the upper bits are in use by the IVPN firewall)
+    ${bin_iptables} -w ${_iptables_locktime} -I ${POSTROUTING_mangle} -j CONNMARK --save-mark --mask ${_connmark_mask}
     # Change the source IP address of packets to the IP address of the interface they're going out on
     # Do this only if default interface is defined (for example: IPv6 interface may be empty when IPv6 not configured on the system)
     if [ ! -z ${def_inf_name} ]; then
-        ${bin_iptables} -w ${_iptables_locktime} -I ${POSTROUTING_nat} -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -o ${def_inf_name} -j MASQUERADE
+        ${bin_iptables} -w ${_iptables_locktime} -I ${POSTROUTING_nat} -m cgroup ${inverseOption} ${_cgroup_match} -o ${def_inf_name} -j MASQUERADE
+    fi
+    # Add mark on packets from the cgroup
+    ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -m cgroup ${inverseOption} ${_cgroup_match} -j MARK --set-mark ${_packets_fwmark_value}
+    if [ ! -z ${st_dns} ] && [ "${bin_iptables}" = "${_bin_iptables}" ]; then
+        # Redirect DNS requests to the Split Tunnel DNS server (the requests are marked: routed outside the VPN tunnel)
+        # (requests to loopback addresses are not redirected: see the '_st_dns' description)
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_nat} -m cgroup ${inverseOption} ${_cgroup_match} ! -d 127.0.0.0/8 -p tcp --dport 53 -j DNAT --to-destination ${st_dns}:53
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_nat} -m cgroup ${inverseOption} ${_cgroup_match} ! -d 127.0.0.0/8 -p udp --dport 53 -j DNAT --to-destination ${st_dns}:53
+    else
+        # Important! Process DNS request before setting mark rule (DNS request should not be marked)
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -m cgroup ${inverseOption} ${_cgroup_match} -p tcp --dport 53 -j RETURN
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -m cgroup ${inverseOption} ${_cgroup_match} -p udp --dport 53 -j RETURN
     fi
-    # Add mark on packets of classid ${_cgroup_classid}
-    ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -j MARK --set-mark ${_packets_fwmark_value}
-    # Important! Process DNS request before setting mark rule (DNS request should not be marked)
-    ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -p tcp --dport 53 -j RETURN
-    ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -p udp --dport 53 -j RETURN
 
     # Allow packets from/to cgroup (bypass IVPN firewall)
     if [ ! -z ${def_inf_name} ]; then
-        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -j ACCEPT
-        ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -j ACCEPT   # this rule is not effective, so we use 'mark' (see the next rule)
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${inverseOption} ${_cgroup_match} -j ACCEPT
+        ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m cgroup ${inverseOption} ${_cgroup_match} -j ACCEPT   # this rule is not effective, so we use 'mark' (see the next rule)
         ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m mark --mark ${_packets_fwmark_value} -j ACCEPT
     else
         # If local interface not defined - block all packets from/to cgroup
         # (for example: IPv6 interface may be empty when IPv6 not configured on the system)
-        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -j DROP
-        ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m cgroup ${inverseOption} --cgroup ${_cgroup_classid} -j DROP   # this rule is not effective, so we use 'mark' (see the next rule)
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${inverseOption} ${_cgroup_match} -j DROP
+        ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m cgroup ${inverseOption} ${_cgroup_match} -j DROP   # this rule is not effective, so we use 'mark' (see the next rule)
         ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m mark --mark ${_packets_fwmark_value} -j DROP
     fi
+
+    if [ ! -z ${st_dns} ] && [ "${bin_iptables}" = "${_bin_ip6tables}" ]; then
+        # The Split Tunnel DNS server is IPv4: block IPv6 DNS requests (the resolver falls back to IPv4)
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${inverseOption} ${_cgroup_match} -p tcp --dport 53 -j DROP
+        ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${inverseOption} ${_cgroup_match} -p udp --dport 53 -j DROP
+    fi
     
     # Inverse mode: only 'splitted' apps use only VPN connection
     if [ ${_is_inversed} -eq 1 ]; then
@@ -241,22 +314,28 @@ function init_iptables()
         # Allow or block communication for 'splitted' apps in inverse mode
         # E.g.: If we want to block 'splitted' apps when VPN not connected -  'inverse_block' must be '1'
         if [ ${inverse_block} -eq 1 ]; then
-            ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup --cgroup ${_cgroup_classid} -j DROP
-            ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m cgroup --cgroup ${_cgroup_classid} -j DROP
+            ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -m cgroup ${_cgroup_match} -j DROP
+            ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -m cgroup ${_cgroup_match} -j DROP
         fi 
     fi 
 
+    # Rules by user account (UID/GID) have higher priority than rules for the cgroup (the chains are filled by updateUserRules())
+    ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT_mangle} -j ${_chain_users}
+    ${bin_iptables} -w ${_iptables_locktime} -I ${POSTROUTING_nat} -j ${_chain_users}
+    ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -j ${_chain_users}
+
     # Just ensure that packets from/to localhost will not be blocked            
     ${bin_iptables} -w ${_iptables_locktime} -I ${OUTPUT} -o lo -j ACCEPT
     ${bin_iptables} -w ${_iptables_locktime} -I ${INPUT}  -i lo -j ACCEPT
     
     # Restore packets mark for incoming packets
-    ${bin_iptables} -w ${_iptables_locktime} -I ${PREROUTING_mangle} -j CONNMARK --restore-mark
+    ${bin_iptables} -w ${_iptables_locktime} -I ${PREROUTING_mangle} -j CONNMARK --restore-mark --mask ${_connmark_mask}
 
     ${bin_iptables} -w ${_iptables_locktime} -I POSTROUTING -t mangle  -j ${POSTROUTING_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -I OUTPUT -t mangle  -j ${OUTPUT_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -I PREROUTING -t mangle  -j ${PREROUTING_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -I POSTROUTING -t nat  -j ${POSTROUTING_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -I OUTPUT -t nat  -j ${OUTPUT_nat}
     ${bin_iptables} -w ${_iptables_locktime} -I OUTPUT -j ${OUTPUT}
     ${bin_iptables} -w ${_iptables_locktime} -I INPUT -j ${INPUT}
 }
@@ -272,6 +351,7 @@ function clear_iptables()
     ${bin_iptables} -w ${_iptables_locktime} -D OUTPUT -t mangle  -j ${OUTPUT_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -D PREROUTING -t mangle  -j ${PREROUTING_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -D POSTROUTING -t nat  -j ${POSTROUTING_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -D OUTPUT -t nat  -j ${OUTPUT_nat}
     ${bin_iptables} -w ${_iptables_locktime} -D OUTPUT -j ${OUTPUT}
     ${bin_iptables} -w ${_iptables_locktime} -D INPUT -j ${INPUT}
     
@@ -280,16 +360,24 @@ function clear_iptables()
     ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -F ${PREROUTING_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -F ${POSTROUTING_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT_nat}
     ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT}
     ${bin_iptables} -w ${_iptables_locktime} -F ${INPUT}
+    ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT_USERS_mangle}
+    ${bin_iptables} -w ${_iptables_locktime} -F ${POSTROUTING_USERS_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT_USERS}
 
     # '-X' Delete a user-defined chains
     ${bin_iptables} -w ${_iptables_locktime} -X ${POSTROUTING_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -X ${OUTPUT_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -X ${PREROUTING_mangle}
     ${bin_iptables} -w ${_iptables_locktime} -X ${POSTROUTING_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -X ${OUTPUT_nat}
     ${bin_iptables} -w ${_iptables_locktime} -X ${OUTPUT}
     ${bin_iptables} -w ${_iptables_locktime} -X ${INPUT}
+    ${bin_iptables} -w ${_iptables_locktime} -X ${OUTPUT_USERS_mangle}
+    ${bin_iptables} -w ${_iptables_locktime} -X ${POSTROUTING_USERS_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -X ${OUTPUT_USERS}
 }
 
 function init()
@@ -333,19 +421,21 @@ function init()
     ##############################################
     if [ ! -d ${_cgroup_folder} ]; then
         mkdir -p ${_cgroup_folder}
-        echo ${_cgroup_classid} > ${_cgroup_folder}/net_cls.classid
+        if [ ${_cgroup_v2} -eq 0 ]; then
+            echo ${_cgroup_classid} > ${_cgroup_folder}/net_cls.classid
+        fi
     fi
     
     ##############################################
     # Firewall rules for packets coming from cgroup
     ##############################################       
-    init_iptables  ${_bin_iptables} ${_def_interface_name} ${_is_inversed_blocked}
+    init_iptables  ${_bin_iptables} ${_def_interface_name} ${_is_inversed_blocked} "${_st_dns}"
     if [ -f /proc/net/if_inet6 ]; then
         block=0
         if [ ! ${_is_inversed_blocked} -eq 0 ] || [ ! ${_is_inversed_blocked_ipv6} -eq 0 ]; then 
             block=1
         fi
-        init_iptables  ${_bin_ip6tables} ${_def_interface_nameIPv6} ${block}
+        init_iptables  ${_bin_ip6tables} "${_def_interface_nameIPv6}" ${block} "${_st_dns}"
     fi
 
     ##############################################
@@ -422,6 +512,74 @@ function updateRoutes()
     fi
 }
 
+# Apply split tunnel rules by user account (packets are matched by the owner of the socket: UID/GID)
+# Rule format: <action>:<type>:<id>
+#   action: 'bypass' - traffic of the user/group bypasses the VPN tunnel (uses the default connection)
+#           'vpn'    - traffic of the user/group uses only the VPN tunnel (blocked when it goes through the default interface)
+#   type:   'uid' or 'gid'
+# Example: updateUserRules bypass:uid:34 vpn:gid:1001
+function updateUserRules_iptables()
+{
+    local bin_iptables=$1
+    local def_inf_name=$2
+    shift 2
+
+    ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT_USERS_mangle}
+    ${bin_iptables} -w ${_iptables_locktime} -F ${POSTROUTING_USERS_nat}
+    ${bin_iptables} -w ${_iptables_locktime} -F ${OUTPUT_USERS}
+
+    local rule action type id match
+    for rule in "$@"; do
+        IFS=':' read -r action type id <<< "${rule}"
+        if [ "${type}" = "uid" ]; then
+            match="-m owner --uid-owner ${id}"
+        elif [ "${type}" = "gid" ]; then
+            match="-m owner --gid-owner ${id}"
+        else
+            echo "Unknown rule type: '${rule}'" 1>&2
+            continue
+        fi
+
+        if [ "${action}" = "bypass" ]; then
+            if [ -z ${def_inf_name} ]; then
+                # default interface not defined (e.g. IPv6 not configured on the system)
+                ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS} ${match} -j DROP
+                continue
+            fi
+            # Important! Process DNS request before setting mark rule (DNS request should not be marked)
+            ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS_mangle} ${match} -p tcp --dport 53 -j ACCEPT
+            ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS_mangle} ${match} -p udp --dport 53 -j ACCEPT
+            ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS_mangle} ${match} -j MARK --set-mark ${_packets_fwmark_value}
+            ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS_mangle} ${match} -j ACCEPT
+            ${bin_iptables} -w ${_iptables_locktime} -A ${POSTROUTING_USERS_nat} ${match} -o ${def_inf_name} -j MASQUERADE
+            ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS} ${match} -j ACCEPT
+        elif [ "${action}" = "vpn" ]; then
+            # Packets are not marked (they are routed to the VPN tunnel by the main routing rules)
+            ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS_mangle} ${match} -j ACCEPT
+            # Block packets which are going through the default interface (e.g. when VPN is not connected)
+            if [ ! -z ${def_inf_name} ]; then
+                ${bin_iptables} -w ${_iptables_locktime} -A ${OUTPUT_USERS} ${match} -o ${def_inf_name} -j DROP
+            fi
+        else
+            echo "Unknown rule action: '${rule}'" 1>&2
+        fi
+    done
+}
+
+function updateUserRules()
+{
+    # simple check if ST enabled
+    if [ ! -d ${_cgroup_folder} ]; then
+        echo "Split Tunneling is not enabled" 1>&2
+        return 1
+    fi
+
+    updateUserRules_iptables ${_bin_iptables} "${_def_interface_name}" "$@"
+    if [ -f /proc/net/if_inet6 ]; then
+        updateUserRules_iptables ${_bin_ip6tables} "${_def_interface_nameIPv6}" "$@"
+    fi
+}
+
 function clean()
 {
     ##############################################
@@ -439,7 +597,8 @@ function clean()
     # Remove cgroup    
     ##############################################
     # check is cgroup exists
-    if [ -d ${_cgroup_folder} ]; then
+    # cgroup v2: the cgroup is not removed: it is referenced by the firewall rules (the rules are matching the cgroup which existed when the rule was added)
+    if [ ${_cgroup_v2} -eq 0 ] && [ -d ${_cgroup_folder} ]; then
         # Note: the cgroup folder will be removed only in case
         # when no active process are in that cgroup
         rmdir ${_cgroup_folder}
@@ -514,13 +673,44 @@ function restore()
     rm -fr ${_tempDir}
 }
 
-# Move all processes from the IVPN cgroup to the main cgroup
+# cgroup v2: save the original cgroup of the process (to be able to restore it on removing from Split Tunnel)
+function saveOriginalCgroup()
+{
+    local _pid="$1"
+    if [ ${_cgroup_v2} -eq 0 ]; then
+        return 0
+    fi
+    local _origin="$( ${_bin_sed} -n 's/^0:://p' /proc/${_pid}/cgroup 2>/dev/null )"
+    if [ -z "${_origin}" ] || [ "${_origin}" == "/${_cgroup_name}" ]; then
+        return 0
+    fi
+    mkdir -p ${_cgroup_origins_folder} && echo "${_origin}" > ${_cgroup_origins_folder}/${_pid}
+}
+
+# Move the process out of the IVPN cgroup: 
+# to the original cgroup of the process (cgroup v2) or to the main cgroup
+function restoreOriginalCgroup()
+{
+    local _pid="$1"
+    if [ ${_cgroup_v2} -eq 1 ] && [ -f ${_cgroup_origins_folder}/${_pid} ]; then
+        local _origin="$( cat ${_cgroup_origins_folder}/${_pid} )"
+        rm -f ${_cgroup_origins_folder}/${_pid}
+        # the original cgroup can be already removed (or can be not able to contain processes anymore)
+        if [ ! -z "${_origin}" ] && [ -f "${_cgroup_root}${_origin}/cgroup.procs" ]; then
+            echo ${_pid} 2>/dev/null >> "${_cgroup_root}${_origin}/cgroup.procs" && return 0
+        fi
+    fi
+    echo ${_pid} >> ${_cgroup_root}/cgroup.procs
+}
+
+# Move all processes from the IVPN cgroup to their original cgroups (or to the main cgroup)
 function removeAllPids() 
 {    
     while IFS= read -r line
     do
-        echo $line >> /sys/fs/cgroup/net_cls/cgroup.procs
+        restoreOriginalCgroup $line
     done < "${_cgroup_folder}/cgroup.procs"
+    rm -fr ${_cgroup_origins_folder}
 }
 
 function removepid()
@@ -531,7 +721,7 @@ function removepid()
         exit 1
     fi   
     echo "[+] Removing PID ${_pid} from Split Tunneling group..."
-    echo ${_pid} >> /sys/fs/cgroup/net_cls/cgroup.procs
+    restoreOriginalCgroup ${_pid}
 }
 
 function addpid()
@@ -542,6 +732,7 @@ function addpid()
         exit 1
     fi   
     echo "[+] Adding PID ${_pid} to Split Tunneling group..."
+    saveOriginalCgroup ${_pid}
     echo ${_pid} >> ${_cgroup_folder}/cgroup.procs
 }
 
@@ -618,8 +809,12 @@ function info()
         echo "[*] cgroup folder NOT exists: '${_cgroup_folder}'"
     else
         echo "[*] cgroup folder exists: '${_cgroup_folder}'"
-        echo "[*] File '${_cgroup_folder}/net_cls.classid':"
-        cat ${_cgroup_folder}/net_cls.classid
+        if [ ${_cgroup_v2} -eq 0 ]; then
+            echo "[*] File '${_cgroup_folder}/net_cls.classid':"
+            cat ${_cgroup_folder}/net_cls.classid
+        else
+            echo "[*] cgroup v2 (matching by path '${_cgroup_name}')"
+        fi
     fi
     
     echo ---------------------------------
@@ -710,6 +905,7 @@ function parseInputArgs()
             -inverse) _is_inversed=1; echo "'-inverse' flag defined!";;
             -inverse_block) _is_inversed_blocked=1; echo "'-inverse_block' flag defined!";;
             -inverse_block_ipv6) _is_inversed_blocked_ipv6=1; echo "'-inverse_block_ipv6' flag defined!";;
+            -dns) _st_dns="$2"; shift;;
             *) echo "Unknown parameter: '$1'" 1>&2; exit 1;;
         esac
         shift
@@ -759,6 +955,12 @@ elif [[ $1 = "update-routes" ]] ; then
 
     updateRoutes $@  
 
+elif [[ $1 = "update-user-rules" ]] ; then
+    shift
+    detectDefRouteVars
+
+    updateUserRules "$@"
+
 elif [[ $1 = "info" ]] ; then
     shift 
     info $@  
@@ -789,7 +991,7 @@ else
     echo "Note! The script have to be started under privilaged user (sudo $0 ...)"
     echo "    $0 <command> [parameters]"
     echo "Parameters:"
-    echo "    start [-interface <inf_name>] [-gateway <gateway>] [-interface6 <inf_name_IPv6>] [-gateway6 <gateway_IPv6>] [[-inverse] [-inverse_block]]"
+    echo "    start [-interface <inf_name>] [-gateway <gateway>] [-interface6 <inf_name_IPv6>] [-gateway6 <gateway_IPv6>] [[-inverse] [-inverse_block]] [-dns <DNS_IPv4>]"
     echo "        Initialize split-tunneling functionality"
     echo "        - interface         - (optional) name of IPv4 network interface to be used for ST environment"
     echo "        - gateway           - (optional) IPv4 gateway IP to be used for ST environment"
@@ -803,6 +1005,8 @@ else
     echo "        - inverse_block_ipv6- (optional) Block IPv6 connectivity for specified apps."
     echo "                                         For example, to block IPv6 connectivity when VPN does not support IPv6."
     echo "                                         Note: This option applicable only with '-inverse' option."    
+    echo "        - dns               - (optional) DNS server (IPv4) for specified apps."
+    echo "                                         DNS requests of the apps are redirected to this server outside the VPN tunnel."
     echo "    stop"
     echo "        Uninitialize split-tunneling functionality"
     echo "    run [-u <username>] <command>"
@@ -818,6 +1022,12 @@ else
     echo "    update-routes"
     echo "        Update the routing table for packets within the split tunnel."
     echo "        Linux erases split-tunnel routing rules when the default network interface is disabled/enabled. This command restores those rules."
+    echo "    update-user-rules [<action>:<type>:<id> ...]"
+    echo "        Set split-tunneling rules by user account (packets are matched by the owner of the socket)."
+    echo "        - action          - 'bypass' (use the default connection) or 'vpn' (use only the VPN tunnel)"
+    echo "        - type            - 'uid' (user ID) or 'gid' (group ID)"
+    echo "        - id              - numeric user or group ID"
+    echo "        No arguments - remove all rules by user account."
     echo "    reset"
     echo "        Remove all processes from Split Tunneling environment"
     echo "    status"
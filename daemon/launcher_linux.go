@@ -29,6 +29,7 @@ import (
 	"log/syslog"
 	"os"
 
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/logind"
 	"github.com/ivpn/desktop-app/daemon/service"
 )
 
@@ -56,6 +57,18 @@ func doPrepareToRun() error {
 		}()
 	}
 
+	// Power events: systemd-logind sleep notifications
+	err = logind.StartSleepMonitor(func(isBeforeSleep bool) {
+		if isBeforeSleep {
+			serviceEventNotify(service.On_Power_Sleep)
+		} else {
+			serviceEventNotify(service.On_Power_WakeUp)
+		}
+	})
+	if err != nil {
+		log.Warning("Failed to start monitoring of sleep notifications: ", err)
+	}
+
 	return nil
 }
 
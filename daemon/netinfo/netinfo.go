@@ -26,6 +26,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
+	"syscall"
 
 	"github.com/ivpn/desktop-app/daemon/logger"
 )
@@ -79,6 +81,111 @@ func DefaultGatewayIP() (defGatewayIP net.IP, err error) {
 	return doDefaultGatewayIP()
 }
 
+// DefaultGatewayMAC - returns: hardware (MAC) address of the default gateway (from the ARP/neighbour table)
+func DefaultGatewayMAC() (net.HardwareAddr, error) {
+	gw, err := DefaultGatewayIP()
+	if err != nil {
+		return nil, err
+	}
+	// method should be implemented in platform-specific file
+	mac, err := doNeighborMAC(gw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain MAC address of the default gateway %s: %w", gw, err)
+	}
+	return mac, nil
+}
+
+// InterfaceGateway - returns: IPv4 gateway of the default route via the specified interface
+// (nil gateway without error means that the interface is a point-to-point link without a gateway)
+func InterfaceGateway(iface *net.Interface) (net.IP, error) {
+	if iface == nil {
+		return nil, fmt.Errorf("interface not defined")
+	}
+	// method should be implemented in platform-specific file
+	gw, err := doInterfaceGateway(iface)
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain gateway of the interface '%s': %w", iface.Name, err)
+	}
+	return gw, nil
+}
+
+// DefaultGatewayInterface - returns: local interface which is connected to the network of the default gateway
+// (the physical interface, even when the VPN routes are in use)
+func DefaultGatewayInterface() (*net.Interface, error) {
+	gw, err := DefaultGatewayIP()
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	for i := range ifaces {
+		addrs, _ := ifaces[i].Addrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.Contains(gw) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unable to find interface of the default gateway %s", gw)
+}
+
+// InterfaceBindControl - returns: function to be used as 'net.Dialer.Control'.
+// The socket is bound to the interface: the connection goes through the interface regardless of the routing table
+// (e.g. when VPN is connected).
+func InterfaceBindControl(iface *net.Interface) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if iface == nil {
+			return fmt.Errorf("interface not defined")
+		}
+		var opErr error
+		err := c.Control(func(fd uintptr) {
+			// method should be implemented in platform-specific file
+			opErr = doBindSocketToInterface(fd, iface, strings.HasSuffix(network, "6"))
+		})
+		if err != nil {
+			return err
+		}
+		return opErr
+	}
+}
+
+// IsInterfaceConnected - returns true when the interface is up, has carrier and has IPv4 address
+func IsInterfaceConnected(iface *net.Interface) bool {
+	if iface == nil || iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagRunning == 0 {
+		return false
+	}
+	addrs, _ := iface.Addrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DnsSuffixes - returns: DNS search domains of the system network configuration (e.g. received from DHCP)
+func DnsSuffixes() ([]string, error) {
+	// method should be implemented in platform-specific file
+	suffixes, err := doDnsSuffixes()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(suffixes))
+	unique := make(map[string]struct{}, len(suffixes))
+	for _, s := range suffixes {
+		s = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+		if _, exists := unique[s]; len(s) == 0 || exists {
+			continue
+		}
+		unique[s] = struct{}{}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
 func GetOutboundIP(isIPv6 bool) (net.IP, error) {
 	if isIPv6 {
 		return GetOutboundIPEx(net.ParseIP("2a00:1450:400d:80a::200e"))
@@ -25,8 +25,11 @@ package netinfo
 import (
 	"fmt"
 	"net"
+	"regexp"
+	"strings"
 	"syscall"
 
+	"github.com/ivpn/desktop-app/daemon/shell"
 	"golang.org/x/net/route"
 )
 
@@ -93,6 +96,46 @@ func doDefaultGatewayIP() (defGatewayIP net.IP, err error) {
 	return gwIP, err
 }
 
+// doInterfaceGateway - returns: gateway of the scoped default route of the interface
+func doInterfaceGateway(iface *net.Interface) (net.IP, error) {
+	// Expected output of "/sbin/route -n get -inet -ifscope en0 default" command (part):
+	//    route to: default
+	// destination: default
+	//     gateway: 192.168.1.1
+	//   interface: en0
+	var (
+		gateway     net.IP
+		isInterface bool
+	)
+	outRegexp := regexp.MustCompile(`^\s*(gateway|interface)\s*:\s*(\S+)`)
+	outParse := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		cols := outRegexp.FindStringSubmatch(text)
+		if len(cols) != 3 {
+			return
+		}
+		switch cols[1] {
+		case "gateway":
+			gateway = net.ParseIP(cols[2])
+		case "interface":
+			isInterface = cols[2] == iface.Name
+		}
+	}
+
+	if err := shell.ExecAndProcessOutput(log, outParse, "", "/sbin/route", "-n", "get", "-inet", "-ifscope", iface.Name, "default"); err != nil {
+		return nil, err
+	}
+	if !isInterface {
+		return nil, fmt.Errorf("no default route via the interface")
+	}
+	if gateway != nil && gateway.To4() == nil {
+		gateway = nil // e.g. 'gateway: utun3' (point-to-point link)
+	}
+	return gateway, nil
+}
+
 // const values for parsing route message addresses
 // https://man.openbsd.org/rtrequest.9
 const (
@@ -157,3 +200,69 @@ func isRouteMatch(m *route.RouteMessage, dst net.IPNet) bool {
 	}
 	return routeDst.String() == dst.String()
 }
+
+// doNeighborMAC - returns: hardware address of the host from the local network (from ARP table)
+func doNeighborMAC(ip net.IP) (net.HardwareAddr, error) {
+	// Expected output of "/usr/sbin/arp -n 192.168.1.1" command:
+	// ? (192.168.1.1) at a4:2b:b0:11:22:33 on en0 ifscope [ethernet]
+	var mac net.HardwareAddr
+	outRegexp := regexp.MustCompile(`\(([0-9a-fA-F.:]+)\) at ([0-9a-fA-F:]+)`)
+	outParse := func(text string, isError bool) {
+		if isError || mac != nil {
+			return
+		}
+		cols := outRegexp.FindStringSubmatch(text)
+		if len(cols) != 3 || !ip.Equal(net.ParseIP(cols[1])) {
+			return
+		}
+		// 'arp' omits leading zeros (e.g. "a4:2b:b0:1:2:3")
+		octets := strings.Split(cols[2], ":")
+		for i, o := range octets {
+			if len(o) == 1 {
+				octets[i] = "0" + o
+			}
+		}
+		mac, _ = net.ParseMAC(strings.Join(octets, ":"))
+	}
+
+	if err := shell.ExecAndProcessOutput(log, outParse, "", "/usr/sbin/arp", "-n", ip.String()); err != nil {
+		return nil, err
+	}
+	if mac == nil {
+		return nil, fmt.Errorf("no ARP entry for %s", ip)
+	}
+	return mac, nil
+}
+
+// doDnsSuffixes - returns: DNS search domains (from 'scutil --dns')
+func doDnsSuffixes() ([]string, error) {
+	// Expected output of "/usr/sbin/scutil --dns" command (part):
+	// resolver #1
+	//   search domain[0] : corp.example.com
+	//   nameserver[0] : 192.168.1.1
+	// Note: 'domain' entries are not used: they belong to the scoped resolvers (e.g. "local", "254.169.in-addr.arpa")
+	var ret []string
+	outRegexp := regexp.MustCompile(`^\s*search domain\[[0-9]+\]\s*:\s*(\S+)`)
+	outParse := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		if cols := outRegexp.FindStringSubmatch(text); len(cols) == 2 {
+			ret = append(ret, cols[1])
+		}
+	}
+
+	if err := shell.ExecAndProcessOutput(log, outParse, "", "/usr/sbin/scutil", "--dns"); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// doBindSocketToInterface - binds the socket to the interface (IP_BOUND_IF / IPV6_BOUND_IF)
+func doBindSocketToInterface(fd uintptr, iface *net.Interface, isIPv6 bool) error {
+	const ipv6BoundIf = 125 // IPV6_BOUND_IF
+	if isIPv6 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6BoundIf, iface.Index)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_BOUND_IF, iface.Index)
+}
@@ -23,10 +23,15 @@
 package netinfo
 
 import (
+	"bufio"
 	"fmt"
 	"net"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/ivpn/desktop-app/daemon/shell"
 )
@@ -83,3 +88,212 @@ func doDefaultGatewayIP() (defGatewayIP net.IP, err error) {
 
 	return defGatewayIP, retErr
 }
+
+// doInterfaceGateway - returns: gateway of the default route via the interface (from '/proc/net/route')
+func doInterfaceGateway(iface *net.Interface) (net.IP, error) {
+	// Example of '/proc/net/route' content (addresses are in network byte order, hex):
+	//
+	// Iface   Destination  Gateway   Flags  RefCnt  Use  Metric  Mask      MTU  Window  IRTT
+	// enp0s3  00000000     0101A8C0  0003   0       0    100     00000000  0    0       0
+	// wwan0   00000000     00000000  0001   0       0    700     00000000  0    0       0
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		isFound bool
+		gateway net.IP
+		metric  int
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		cols := strings.Fields(scanner.Text())
+		if len(cols) < 8 || cols[0] != iface.Name || cols[1] != "00000000" || cols[7] != "00000000" {
+			continue
+		}
+		gw, err := strconv.ParseUint(cols[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		m, _ := strconv.Atoi(cols[6])
+		if isFound && m >= metric {
+			continue
+		}
+		isFound, metric, gateway = true, m, nil
+		if gw != 0 {
+			gateway = net.IPv4(byte(gw), byte(gw>>8), byte(gw>>16), byte(gw>>24))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !isFound {
+		return nil, fmt.Errorf("no default route via the interface")
+	}
+	return gateway, nil
+}
+
+// doNeighborMAC - returns: hardware address of the host from the local network (from ARP table '/proc/net/arp')
+func doNeighborMAC(ip net.IP) (net.HardwareAddr, error) {
+	// Example of '/proc/net/arp' content:
+	//
+	// IP address       HW type     Flags       HW address            Mask     Device
+	// 192.168.1.1      0x1         0x2         a4:2b:b0:11:22:33     *        enp0s3
+	file, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		cols := strings.Fields(scanner.Text())
+		if len(cols) < 4 || !ip.Equal(net.ParseIP(cols[0])) {
+			continue
+		}
+		if cols[2] == "0x0" {
+			continue // incomplete entry
+		}
+		mac, err := net.ParseMAC(cols[3])
+		if err != nil {
+			return nil, err
+		}
+		return mac, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no ARP entry for %s", ip)
+}
+
+// doDnsSuffixes - returns: DNS search domains ('search' and 'domain' entries of resolv.conf)
+func doDnsSuffixes() ([]string, error) {
+	files := []string{
+		"/run/systemd/resolve/resolv.conf", // systemd-resolved: search domains of all links
+		"/etc/resolv.conf.ivpnsave",        // original configuration (when 'resolv.conf' is modified by the daemon)
+		"/etc/resolv.conf",
+	}
+
+	for _, fpath := range files {
+		file, err := os.Open(fpath)
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+
+		var ret []string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			cols := strings.Fields(scanner.Text())
+			if len(cols) < 2 || (cols[0] != "search" && cols[0] != "domain") {
+				continue
+			}
+			ret = append(ret, cols[1:]...)
+		}
+		return ret, scanner.Err()
+	}
+
+	return nil, fmt.Errorf("DNS configuration not found")
+}
+
+// DnsServers - returns: non-loopback DNS servers of the system network configuration ('nameserver' entries of resolv.conf)
+// Note: when the daemon changed DNS configuration by systemd-resolved, the result contains also the VPN DNS (if VPN connected)
+func DnsServers() ([]net.IP, error) {
+	files := []string{
+		"/etc/resolv.conf.ivpnsave",        // original configuration (when 'resolv.conf' is modified by the daemon)
+		"/run/systemd/resolve/resolv.conf", // systemd-resolved: DNS servers of all links ('/etc/resolv.conf' contains only the local stub resolver)
+		"/etc/resolv.conf",
+	}
+
+	for _, fpath := range files {
+		if ret := dnsServersFromFile(fpath); len(ret) > 0 {
+			return ret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("DNS servers not found")
+}
+
+// dnsServersFromFile returns non-loopback DNS servers from the resolv.conf file ('nameserver' entries)
+func dnsServersFromFile(fpath string) []net.IP {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var ret []net.IP
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		cols := strings.Fields(scanner.Text())
+		if len(cols) < 2 || cols[0] != "nameserver" {
+			continue
+		}
+		if ip := net.ParseIP(cols[1]); ip != nil && !ip.IsLoopback() {
+			ret = append(ret, ip)
+		}
+	}
+	return ret
+}
+
+// DefaultRouteInterfaces - returns names of the interfaces used by default routes (IPv4 and IPv6) of the main routing table
+// (from '/proc/net/route' and '/proc/net/ipv6_route')
+func DefaultRouteInterfaces() ([]string, error) {
+	// Example of '/proc/net/ipv6_route' content (destination, prefix length, source, prefix length, next hop, metric, refcnt, use, flags, interface):
+	//
+	// 00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000064 00000001 00000000 00000003 enp0s3
+	// 00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo
+	const rtfReject = 0x0200
+
+	names := make(map[string]struct{})
+	readFile := func(fpath string, isDefaultRoute func(cols []string) (iface string, ok bool)) error {
+		file, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if iface, ok := isDefaultRoute(strings.Fields(scanner.Text())); ok && iface != "lo" {
+				names[iface] = struct{}{}
+			}
+		}
+		return scanner.Err()
+	}
+
+	err := readFile("/proc/net/route", func(cols []string) (string, bool) {
+		if len(cols) < 8 || cols[1] != "00000000" || cols[7] != "00000000" {
+			return "", false
+		}
+		return cols[0], true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// IPv6 can be disabled in the system: ignore errors
+	readFile("/proc/net/ipv6_route", func(cols []string) (string, bool) {
+		if len(cols) < 10 || cols[0] != strings.Repeat("0", 32) || cols[1] != "00" {
+			return "", false
+		}
+		if flags, err := strconv.ParseUint(cols[8], 16, 32); err != nil || flags&rtfReject != 0 {
+			return "", false
+		}
+		return cols[9], true
+	})
+
+	ret := make([]string, 0, len(names))
+	for n := range names {
+		ret = append(ret, n)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// doBindSocketToInterface - binds the socket to the interface (SO_BINDTODEVICE)
+func doBindSocketToInterface(fd uintptr, iface *net.Interface, isIPv6 bool) error {
+	return syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface.Name)
+}
@@ -26,7 +26,10 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"path/filepath"
+	"regexp"
 
+	"github.com/ivpn/desktop-app/daemon/shell"
 	"golang.org/x/sys/windows"
 	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
@@ -100,3 +103,101 @@ func DefaultGatewayEx(isIpv6 bool) (defGatewayIP net.IP, inf *net.Interface, err
 	}
 	return bestNextHop, &bestIf, nil
 }
+
+// doInterfaceGateway - returns: gateway of the default route via the interface
+func doInterfaceGateway(iface *net.Interface) (net.IP, error) {
+	routes, err := winipcfg.GetIPForwardTable2(winipcfg.AddressFamily(windows.AF_INET))
+	if err != nil {
+		return nil, err
+	}
+
+	const NOMETRIC = ^uint32(0)
+	var (
+		bestMetric  uint32 = NOMETRIC
+		bestNextHop net.IP
+	)
+	for _, route := range routes {
+		if route.DestinationPrefix.PrefixLength != 0 || route.InterfaceIndex != uint32(iface.Index) {
+			continue // skip non-default routes and routes of other interfaces
+		}
+		if route.Metric < bestMetric {
+			bestMetric = route.Metric
+			bestNextHop = route.NextHop.Addr().AsSlice()
+		}
+	}
+
+	if bestMetric == NOMETRIC {
+		return nil, fmt.Errorf("no default route via the interface")
+	}
+	if bestNextHop.IsUnspecified() {
+		return nil, nil // on-link (point-to-point) default route
+	}
+	return bestNextHop, nil
+}
+
+// doNeighborMAC - returns: hardware address of the host from the local network (from ARP table)
+func doNeighborMAC(ip net.IP) (net.HardwareAddr, error) {
+	// Expected output of "arp -a 192.168.1.1" command:
+	//
+	// Interface: 192.168.1.248 --- 0x5
+	//   Internet Address      Physical Address      Type
+	//   192.168.1.1           a4-2b-b0-11-22-33     dynamic
+	var mac net.HardwareAddr
+	outRegexp := regexp.MustCompile(`^\s*([0-9.]+)\s+([0-9a-fA-F]{2}(?:-[0-9a-fA-F]{2}){5})\s`)
+	outParse := func(text string, isError bool) {
+		if isError || mac != nil {
+			return
+		}
+		cols := outRegexp.FindStringSubmatch(text)
+		if len(cols) != 3 || !ip.Equal(net.ParseIP(cols[1])) {
+			return
+		}
+		mac, _ = net.ParseMAC(cols[2])
+	}
+
+	sysDir, err := windows.GetSystemDirectory()
+	if err != nil {
+		return nil, err
+	}
+	if err := shell.ExecAndProcessOutput(log, outParse, "", filepath.Join(sysDir, "ARP.EXE"), "-a", ip.String()); err != nil {
+		return nil, err
+	}
+	if mac == nil {
+		return nil, fmt.Errorf("no ARP entry for %s", ip)
+	}
+	return mac, nil
+}
+
+// doDnsSuffixes - returns: DNS suffixes of the active network adapters (connection-specific suffix and suffix search list)
+func doDnsSuffixes() ([]string, error) {
+	adapters, err := winipcfg.GetAdaptersAddresses(windows.AF_UNSPEC, winipcfg.GAAFlagSkipAnycast|winipcfg.GAAFlagSkipMulticast|winipcfg.GAAFlagSkipDNSServer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network adapters: %w", err)
+	}
+
+	var ret []string
+	for _, a := range adapters {
+		if a.OperStatus != winipcfg.IfOperStatusUp {
+			continue
+		}
+		if suffix := a.DNSSuffix(); len(suffix) > 0 {
+			ret = append(ret, suffix)
+		}
+		for s := a.FirstDNSSuffix; s != nil; s = s.Next {
+			ret = append(ret, s.String())
+		}
+	}
+	return ret, nil
+}
+
+// doBindSocketToInterface - binds the socket to the interface (IP_UNICAST_IF / IPV6_UNICAST_IF)
+func doBindSocketToInterface(fd uintptr, iface *net.Interface, isIPv6 bool) error {
+	const ipUnicastIf = 31 // IP_UNICAST_IF and IPV6_UNICAST_IF
+	if isIPv6 {
+		return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IPV6, ipUnicastIf, iface.Index)
+	}
+	// IPv4: the interface index must be in network byte order
+	idx := uint32(iface.Index)
+	idxNetworkOrder := int(idx>>24 | (idx>>8)&0xff00 | (idx<<8)&0xff0000 | idx<<24)
+	return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, ipUnicastIf, idxNetworkOrder)
+}
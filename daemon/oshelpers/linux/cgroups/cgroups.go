@@ -0,0 +1,82 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package cgroups
+
+// Helpers for Linux control groups.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Root is the mount point of the cgroup hierarchy
+const Root = "/sys/fs/cgroup"
+
+// IsV2 returns true when the cgroup v2 unified hierarchy is mounted to '/sys/fs/cgroup' (cgroup-v2-only systems).
+// Otherwise (cgroup v1 or hybrid hierarchy) the cgroup v1 controllers must be used.
+func IsV2() bool {
+	_, err := os.Stat(filepath.Join(Root, "cgroup.controllers"))
+	return err == nil
+}
+
+// ProcessPathV2 returns the cgroup v2 path of the process (relative to the cgroup root; e.g. '/user.slice/user-1000.slice/session-2.scope')
+func ProcessPathV2(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	path := parseProcessPathV2(data)
+	if len(path) == 0 {
+		return "", fmt.Errorf("cgroup v2 path not found for PID %d", pid)
+	}
+	return path, nil
+}
+
+// parseProcessPathV2 returns the path from the cgroup v2 entry of '/proc/<PID>/cgroup' ("0::<path>")
+func parseProcessPathV2(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok && strings.HasPrefix(path, "/") {
+			return path
+		}
+	}
+	return ""
+}
+
+// ProcsFileV2 returns the path to the 'cgroup.procs' file of the cgroup v2 group
+func ProcsFileV2(path string) string {
+	return filepath.Join(Root, path, "cgroup.procs")
+}
+
+// MoveProcess moves the process to the group (by writing PID to the 'cgroup.procs' file of the group)
+func MoveProcess(procsFile string, pid int) error {
+	return os.WriteFile(procsFile, []byte(strconv.Itoa(pid)), 0644)
+}
@@ -0,0 +1,48 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package cgroups
+
+import "testing"
+
+func TestParseProcessPathV2(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"v2 only", "0::/user.slice/user-1000.slice/session-2.scope\n", "/user.slice/user-1000.slice/session-2.scope"},
+		{"root", "0::/\n", "/"},
+		{"hybrid", "12:net_cls,net_prio:/\n1:name=systemd:/user.slice\n0::/user.slice/app.scope\n", "/user.slice/app.scope"},
+		{"v1 only", "12:net_cls,net_prio:/ivpn-exclude\n1:name=systemd:/user.slice\n", ""},
+		{"no trailing newline", "0::/system.slice/sshd.service", "/system.slice/sshd.service"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := parseProcessPathV2([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: unexpected result '%s' (expected '%s')", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package logind
+
+// Monitor of systemd-logind sleep notifications.
+// The 'PrepareForSleep' D-Bus signal (org.freedesktop.login1.Manager) is sent before the system goes to sleep (argument 'true')
+// and after the system resumed (argument 'false').
+// https://www.freedesktop.org/software/systemd/man/org.freedesktop.login1.html
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ivpn/desktop-app/daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("logind")
+}
+
+const (
+	login1Name      = "org.freedesktop.login1"
+	login1Path      = "/org/freedesktop/login1"
+	login1Interface = "org.freedesktop.login1.Manager"
+	signalName      = "PrepareForSleep"
+	// delay before reconnecting to the system bus (if the connection was unexpectedly closed)
+	restartDelay = time.Second * 10
+)
+
+// StartSleepMonitor starts monitoring of the sleep notifications in a separate goroutine.
+// onEvent is called with 'isBeforeSleep=true' before the system goes to sleep and with 'isBeforeSleep=false' after the system resumed.
+func StartSleepMonitor(onEvent func(isBeforeSleep bool)) error {
+	if onEvent == nil {
+		return fmt.Errorf("event handler not defined")
+	}
+
+	signals, err := subscribe()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		log.Info("Sleep monitor started")
+		defer log.Info("Sleep monitor stopped")
+
+		for {
+			for sig := range signals {
+				if isBeforeSleep, ok := parseSignal(sig); ok {
+					onEvent(isBeforeSleep)
+				}
+			}
+
+			// the channel is closed when the connection to the system bus is closed
+			for {
+				log.Warning(fmt.Sprintf("Connection to the system bus closed. Reconnecting in %v ...", restartDelay))
+				time.Sleep(restartDelay)
+				if signals, err = subscribe(); err == nil {
+					break
+				}
+				log.Warning(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// subscribe connects to the system bus and subscribes to the 'PrepareForSleep' signal
+func subscribe() (chan *dbus.Signal, error) {
+	// private connection: the connection is closed when not in use anymore (the shared connection is never closed)
+	conn, err := dbus.SystemBusPrivate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the system bus: %w", err)
+	}
+	if err = conn.Auth(nil); err == nil {
+		err = conn.Hello()
+	}
+	if err == nil {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchSender(login1Name),
+			dbus.WithMatchObjectPath(login1Path),
+			dbus.WithMatchInterface(login1Interface),
+			dbus.WithMatchMember(signalName))
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to logind sleep notifications: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	return signals, nil
+}
+
+// parseSignal returns the argument of the 'PrepareForSleep' signal
+func parseSignal(sig *dbus.Signal) (isBeforeSleep bool, ok bool) {
+	if sig == nil || sig.Path != login1Path || sig.Name != login1Interface+"."+signalName || len(sig.Body) != 1 {
+		return false, false
+	}
+	isBeforeSleep, ok = sig.Body[0].(bool)
+	return isBeforeSleep, ok
+}
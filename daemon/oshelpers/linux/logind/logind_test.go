@@ -0,0 +1,54 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package logind
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		name              string
+		sig               *dbus.Signal
+		wantIsBeforeSleep bool
+		wantOk            bool
+	}{
+		{"before sleep", &dbus.Signal{Path: login1Path, Name: "org.freedesktop.login1.Manager.PrepareForSleep", Body: []interface{}{true}}, true, true},
+		{"resumed", &dbus.Signal{Path: login1Path, Name: "org.freedesktop.login1.Manager.PrepareForSleep", Body: []interface{}{false}}, false, true},
+		{"another signal", &dbus.Signal{Path: login1Path, Name: "org.freedesktop.login1.Manager.PrepareForShutdown", Body: []interface{}{true}}, false, false},
+		{"another object", &dbus.Signal{Path: "/org/freedesktop/login1/session/c1", Name: "org.freedesktop.login1.Manager.PrepareForSleep", Body: []interface{}{true}}, false, false},
+		{"bad argument type", &dbus.Signal{Path: login1Path, Name: "org.freedesktop.login1.Manager.PrepareForSleep", Body: []interface{}{"true"}}, false, false},
+		{"no arguments", &dbus.Signal{Path: login1Path, Name: "org.freedesktop.login1.Manager.PrepareForSleep"}, false, false},
+		{"nil", nil, false, false},
+	}
+	for _, tt := range tests {
+		if isBeforeSleep, ok := parseSignal(tt.sig); isBeforeSleep != tt.wantIsBeforeSleep || ok != tt.wantOk {
+			t.Errorf("%s: unexpected result %v, %v", tt.name, isBeforeSleep, ok)
+		}
+	}
+}
@@ -117,3 +117,13 @@ func IsDelAddr(msg *syscall.NetlinkMessage) bool {
 	}
 	return false
 }
+
+// IsNewLink checking message type for syscall.RTM_NEWLINK
+func IsNewLink(msg *syscall.NetlinkMessage) bool {
+	return msg.Header.Type == syscall.RTM_NEWLINK
+}
+
+// IsDelLink checking message type for syscall.RTM_DELLINK
+func IsDelLink(msg *syscall.NetlinkMessage) bool {
+	return msg.Header.Type == syscall.RTM_DELLINK
+}
@@ -33,9 +33,10 @@ import (
 )
 
 var (
-	mutex              sync.RWMutex
-	globalListener     *Listener
-	globalEvtReceivers []chan<- struct{}
+	mutex                  sync.RWMutex
+	globalListener         *Listener
+	globalEvtReceivers     []chan<- struct{}
+	globalLinkEvtReceivers []chan<- struct{}
 )
 
 var log *logger.Logger
@@ -44,6 +45,7 @@ func init() {
 	log = logger.NewLogger("netlnk")
 }
 
+// RegisterLanChangeListener registers channel to be notified about network address changes (RTM_NEWADDR/RTM_DELADDR)
 func RegisterLanChangeListener(onChange chan<- struct{}) error {
 	if onChange == nil {
 		return nil
@@ -52,6 +54,49 @@ func RegisterLanChangeListener(onChange chan<- struct{}) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	if err := startGlobalListener(); err != nil {
+		return err
+	}
+
+	globalEvtReceivers = append(globalEvtReceivers, onChange)
+	log.Info("New listener registered")
+
+	return nil
+}
+
+// RegisterLinkChangeListener registers channel to be notified about network interfaces changes (RTM_NEWLINK/RTM_DELLINK)
+func RegisterLinkChangeListener(onChange chan<- struct{}) error {
+	if onChange == nil {
+		return nil
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := startGlobalListener(); err != nil {
+		return err
+	}
+
+	globalLinkEvtReceivers = append(globalLinkEvtReceivers, onChange)
+	log.Info("New link listener registered")
+
+	return nil
+}
+
+// notifyReceivers sends notification to all receivers (non-blocking)
+// Must be called under 'mutex' lock (read lock is enough)
+func notifyReceivers(receivers []chan<- struct{}) {
+	for _, c := range receivers {
+		select {
+		case c <- struct{}{}: // notified
+		default: // channel is full
+		}
+	}
+}
+
+// startGlobalListener starts netlink listener (if not started yet)
+// Must be called under 'mutex' lock
+func startGlobalListener() error {
 	var err error
 	if globalListener == nil {
 		globalListener, err = CreateListener()
@@ -77,34 +122,32 @@ func RegisterLanChangeListener(onChange chan<- struct{}) error {
 				}
 
 				isChanged := false
+				isLinkChanged := false
 				for i := range msgs {
 					m := msgs[i]
 					if IsNewAddr(&m) || IsDelAddr(&m) {
 						isChanged = true
-						break
+					} else if IsNewLink(&m) || IsDelLink(&m) {
+						isLinkChanged = true
 					}
 				}
 
-				if isChanged {
+				if isChanged || isLinkChanged {
 					func() { // using anonymous function to unlock mutex correctly
 						mutex.RLock()
 						defer mutex.RUnlock()
 
 						// notify all receivers about network change
-						for _, c := range globalEvtReceivers {
-							select {
-							case c <- struct{}{}: // notified
-							default: // channel is full
-							}
+						if isChanged {
+							notifyReceivers(globalEvtReceivers)
+						}
+						if isLinkChanged {
+							notifyReceivers(globalLinkEvtReceivers)
 						}
 					}()
 				}
 			}
 		}()
 	}
-
-	globalEvtReceivers = append(globalEvtReceivers, onChange)
-	log.Info("New listener registered")
-
 	return nil
 }
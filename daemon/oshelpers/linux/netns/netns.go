@@ -0,0 +1,182 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package netns
+
+// Helpers for named network namespaces (compatible with 'ip netns': namespaces are bind-mounted to '/var/run/netns/<name>').
+// Note: requires CAP_SYS_ADMIN privileges.
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	netnsRunDir = "/var/run/netns"
+	netnsEtcDir = "/etc/netns"
+)
+
+// Path returns path to the namespace file
+func Path(name string) string {
+	return filepath.Join(netnsRunDir, name)
+}
+
+// IsExists returns true if the named network namespace exists
+func IsExists(name string) bool {
+	_, err := os.Stat(Path(name))
+	return err == nil
+}
+
+// Create creates named network namespace (if not exists) and brings up its loopback interface
+func Create(name string) error {
+	if !IsExists(name) {
+		if err := shell.Exec(nil, "ip", "netns", "add", name); err != nil {
+			return fmt.Errorf("failed to create network namespace '%s': %w", name, err)
+		}
+	}
+	if err := shell.Exec(nil, "ip", "-n", name, "link", "set", "lo", "up"); err != nil {
+		return fmt.Errorf("failed to initialize loopback interface in network namespace '%s': %w", name, err)
+	}
+	return nil
+}
+
+// Delete removes named network namespace and its configuration files (e.g. '/etc/netns/<name>/resolv.conf').
+// Processes running in the namespace keep running (the namespace is destroyed by the kernel when the last process exits)
+func Delete(name string) error {
+	os.RemoveAll(filepath.Join(netnsEtcDir, name))
+	if !IsExists(name) {
+		return nil
+	}
+	if err := shell.Exec(nil, "ip", "netns", "delete", name); err != nil {
+		return fmt.Errorf("failed to delete network namespace '%s': %w", name, err)
+	}
+	return nil
+}
+
+// SetDns pins DNS servers for processes started in the namespace by 'ip netns exec'
+// ('/etc/netns/<name>/resolv.conf' is bind-mounted over '/etc/resolv.conf' for such processes)
+// Empty list removes the configuration.
+func SetDns(name string, servers []string) error {
+	dir := filepath.Join(netnsEtcDir, name)
+	file := filepath.Join(dir, "resolv.conf")
+	if len(servers) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	content := "# Generated by IVPN daemon (VPN-only network namespace)\n"
+	for _, s := range servers {
+		content += "nameserver " + s + "\n"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", dir, err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to save '%s': %w", file, err)
+	}
+	return nil
+}
+
+// Do executes function 'fn' in the context of the named network namespace
+// (e.g. to access network interfaces of the namespace). The function is executed on the locked OS thread.
+func Do(name string, fn func() error) (retErr error) {
+	nsFile, err := os.Open(Path(name))
+	if err != nil {
+		return fmt.Errorf("network namespace '%s' not available: %w", name, err)
+	}
+	defer nsFile.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origFile, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid()))
+	if err != nil {
+		return fmt.Errorf("failed to get current network namespace: %w", err)
+	}
+	defer origFile.Close()
+
+	if err := unix.Setns(int(nsFile.Fd()), unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("failed to enter network namespace '%s': %w", name, err)
+	}
+	defer func() {
+		if err := unix.Setns(int(origFile.Fd()), unix.CLONE_NEWNET); err != nil {
+			// the thread is in unknown state: do not return it to the Go scheduler
+			// (locked thread terminates when the goroutine exits without unlocking)
+			runtime.LockOSThread()
+			retErr = fmt.Errorf("failed to restore network namespace: %w", err)
+		}
+	}()
+
+	return fn()
+}
+
+// Command returns the command which runs the binary in the named network namespace ('ip netns exec')
+// with the credentials of the specified user (the privileges are dropped by 'setpriv' after entering the namespace).
+// The binaries executed with root privileges ('ip', 'setpriv') get the environment of the current process only;
+// the environment 'env' (in format "NAME=VALUE") is applied for the binary after the privileges are dropped.
+func Command(name string, uid, gid uint32, groups []uint32, env []string, binary string, args ...string) (*exec.Cmd, error) {
+	setprivPath, err := exec.LookPath("setpriv")
+	if err != nil {
+		return nil, fmt.Errorf("'setpriv' utility not found: %w", err)
+	}
+	envPath, err := exec.LookPath("env")
+	if err != nil {
+		return nil, fmt.Errorf("'env' utility not found: %w", err)
+	}
+	if strings.Contains(binary, "=") {
+		return nil, fmt.Errorf("bad binary name '%s'", binary)
+	}
+
+	cmdArgs := []string{"netns", "exec", name, setprivPath, fmt.Sprintf("--reuid=%d", uid), fmt.Sprintf("--regid=%d", gid)}
+	if len(groups) > 0 {
+		groupsStr := make([]string, 0, len(groups))
+		for _, g := range groups {
+			groupsStr = append(groupsStr, strconv.FormatUint(uint64(g), 10))
+		}
+		cmdArgs = append(cmdArgs, "--groups="+strings.Join(groupsStr, ","))
+	} else {
+		cmdArgs = append(cmdArgs, "--clear-groups")
+	}
+	cmdArgs = append(cmdArgs, "--reset-env", "--", envPath, "--")
+	for _, e := range env {
+		if strings.Index(e, "=") > 0 {
+			cmdArgs = append(cmdArgs, e)
+		}
+	}
+	cmdArgs = append(cmdArgs, binary)
+	cmdArgs = append(cmdArgs, args...)
+
+	return exec.Command("ip", cmdArgs...), nil
+}
@@ -0,0 +1,296 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package nflog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reader provides possibility to read packets logged by iptables NFLOG target (netfilter netlink 'ULOG' subsystem)
+//
+//	r, err := nflog.Open(group, 128)
+//	if err != nil {
+//		...
+//	}
+//	defer r.Close()
+//	for {
+//		packets, err := r.Read()
+//		...
+//	}
+type Reader struct {
+	fd       int
+	group    uint16
+	mutex    sync.Mutex
+	isClosed bool
+}
+
+// Packet - information about logged packet
+type Packet struct {
+	Family      uint8  // syscall.AF_INET or syscall.AF_INET6
+	Prefix      string // log prefix (defined by '--nflog-prefix')
+	InIfIndex   uint32 // input interface index (0 - not defined)
+	OutIfIndex  uint32 // output interface index (0 - not defined)
+	HasUID      bool
+	UID         uint32 // UID of the socket owner (if available: locally generated packets)
+	Payload     []byte // packet data (starting from network header), truncated by 'copyRange'
+	ReceiveTime time.Time
+}
+
+// netfilter netlink constants (linux/netfilter/nfnetlink.h; linux/netfilter/nfnetlink_log.h)
+const (
+	nfnlSubsysUlog = 4
+
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind   = 1
+	nfulnlCfgCmdPfBind = 3
+
+	nfulnlCopyPacket = 2
+
+	nfulaIfindexIndev  = 4
+	nfulaIfindexOutdev = 5
+	nfulaPayload       = 9
+	nfulaPrefix        = 10
+	nfulaUid           = 11
+
+	nlaTypeMask = 0x3fff // remove NLA_F_NESTED and NLA_F_NET_BYTEORDER flags
+
+	readTimeout = time.Second
+)
+
+// ErrClosed returned by Read() when reader was closed
+var ErrClosed = errors.New("NFLOG reader closed")
+
+// Open creates netlink socket and binds it to the NFLOG group
+// 'copyRange' - number of bytes of the packet to be copied (0 - whole packet)
+func Open(group uint16, copyRange uint32) (*Reader, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("socket initialization error: %w", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("socket binding error: %w", err)
+	}
+
+	syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, 1024*1024)
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set socket timeout: %w", err)
+	}
+
+	r := &Reader{fd: fd, group: group}
+
+	// Binding to protocol families is required only for old kernels (< 3.17); errors are ignored
+	r.sendConfig(syscall.AF_INET, 0, nfulaCfgCmd, []byte{nfulnlCfgCmdPfBind})
+	r.sendConfig(syscall.AF_INET6, 0, nfulaCfgCmd, []byte{nfulnlCfgCmdPfBind})
+
+	if err := r.sendConfig(syscall.AF_UNSPEC, group, nfulaCfgCmd, []byte{nfulnlCfgCmdBind}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind to NFLOG group %d: %w", group, err)
+	}
+
+	// struct nfulnl_msg_config_mode { __be32 copy_range; __u8 copy_mode; __u8 _pad; }
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode, copyRange)
+	mode[4] = nfulnlCopyPacket
+	if err := r.sendConfig(syscall.AF_UNSPEC, group, nfulaCfgMode, mode); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to set NFLOG copy mode: %w", err)
+	}
+
+	return r, nil
+}
+
+// Close closes the socket (the kernel unbinds the socket from the NFLOG group automatically)
+func (r *Reader) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.isClosed {
+		return nil
+	}
+	r.isClosed = true
+	return syscall.Close(r.fd)
+}
+
+// Read waits for logged packets.
+// Returns empty list (and no error) when no packets received during read timeout;
+// returns ErrClosed when the reader was closed.
+func (r *Reader) Read() ([]Packet, error) {
+	buf := make([]byte, 64*1024)
+	for {
+		r.mutex.Lock()
+		isClosed := r.isClosed
+		r.mutex.Unlock()
+		if isClosed {
+			return nil, ErrClosed
+		}
+
+		n, _, err := syscall.Recvfrom(r.fd, buf, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR {
+				return nil, nil // timeout
+			}
+			if err == syscall.ENOBUFS {
+				continue // some messages were lost (socket buffer overflow)
+			}
+			return nil, fmt.Errorf("NFLOG read error: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("NFLOG parse error: %w", err)
+		}
+
+		now := time.Now()
+		packets := make([]Packet, 0, len(msgs))
+		for _, m := range msgs {
+			if m.Header.Type != (nfnlSubsysUlog<<8)|nfulnlMsgPacket {
+				continue
+			}
+			if p, ok := parsePacket(m.Data); ok {
+				p.ReceiveTime = now
+				packets = append(packets, p)
+			}
+		}
+		return packets, nil
+	}
+}
+
+// parsePacket parses NFULNL_MSG_PACKET message data: struct nfgenmsg + attributes
+func parsePacket(data []byte) (p Packet, ok bool) {
+	if len(data) < 4 {
+		return p, false
+	}
+	p.Family = data[0]
+
+	attrs := data[4:]
+	for len(attrs) >= 4 {
+		attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:4]) & nlaTypeMask
+		if attrLen < 4 || attrLen > len(attrs) {
+			break
+		}
+		value := attrs[4:attrLen]
+
+		switch attrType {
+		case nfulaIfindexIndev:
+			if len(value) >= 4 {
+				p.InIfIndex = binary.BigEndian.Uint32(value)
+			}
+		case nfulaIfindexOutdev:
+			if len(value) >= 4 {
+				p.OutIfIndex = binary.BigEndian.Uint32(value)
+			}
+		case nfulaUid:
+			if len(value) >= 4 {
+				p.UID = binary.BigEndian.Uint32(value)
+				p.HasUID = true
+			}
+		case nfulaPrefix:
+			// null-terminated string
+			for i, c := range value {
+				if c == 0 {
+					value = value[:i]
+					break
+				}
+			}
+			p.Prefix = string(value)
+		case nfulaPayload:
+			p.Payload = append([]byte{}, value...)
+		}
+
+		// attributes are aligned to 4 bytes
+		alignedLen := (attrLen + 3) &^ 3
+		if alignedLen > len(attrs) {
+			break
+		}
+		attrs = attrs[alignedLen:]
+	}
+	return p, len(p.Payload) > 0
+}
+
+// sendConfig sends NFULNL_MSG_CONFIG message and waits for acknowledgement
+// (must be called only during initialization: before reading packets)
+func (r *Reader) sendConfig(family uint8, resId uint16, attrType uint16, attrValue []byte) error {
+	attrLen := 4 + len(attrValue)
+	msgLen := syscall.NLMSG_HDRLEN + 4 + ((attrLen + 3) &^ 3)
+
+	msg := make([]byte, msgLen)
+	// struct nlmsghdr (host byte order)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(msgLen))
+	binary.NativeEndian.PutUint16(msg[4:6], (nfnlSubsysUlog<<8)|nfulnlMsgConfig)
+	binary.NativeEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(msg[8:12], uint32(time.Now().UnixNano()))
+	// struct nfgenmsg
+	msg[16] = family
+	msg[17] = 0 // NFNETLINK_V0
+	binary.BigEndian.PutUint16(msg[18:20], resId)
+	// attribute
+	binary.NativeEndian.PutUint16(msg[20:22], uint16(attrLen))
+	binary.NativeEndian.PutUint16(msg[22:24], attrType)
+	copy(msg[24:], attrValue)
+
+	if err := syscall.Sendto(r.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	// wait for acknowledgement
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := syscall.Recvfrom(r.fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Type != syscall.NLMSG_ERROR {
+				continue // skip packets which may be received before acknowledgement
+			}
+			if len(m.Data) >= 4 {
+				if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return syscall.Errno(-errno)
+				}
+			}
+			return nil
+		}
+	}
+}
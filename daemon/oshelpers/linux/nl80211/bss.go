@@ -0,0 +1,208 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package nl80211
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Security - security type of the wireless network
+type Security string
+
+const (
+	SecurityUnknown    Security = ""
+	SecurityOpen       Security = "open"
+	SecurityOWE        Security = "owe" // Opportunistic Wireless Encryption (Enhanced Open): encrypted, but no authentication
+	SecurityWEP        Security = "wep"
+	SecurityWPA        Security = "wpa"
+	SecurityWPA2       Security = "wpa2"
+	SecurityWPA3       Security = "wpa3"
+	SecurityEnterprise Security = "enterprise" // WPA/WPA2/WPA3 with 802.1X authentication
+)
+
+// BSS status (enum nl80211_bss_status)
+const (
+	bssStatusAuthenticated = 0
+	bssStatusAssociated    = 1
+	bssStatusIbssJoined    = 2
+)
+
+// information elements (IEEE 802.11)
+const (
+	ieSsid   = 0
+	ieRsn    = 48
+	ieVendor = 221
+
+	capabilityPrivacy = 0x0010
+)
+
+// BSS - wireless network (access point) from scan results
+type BSS struct {
+	BSSID        net.HardwareAddr
+	SSID         string
+	Frequency    uint32 // MHz
+	SignalMbm    int32  // signal strength (mBm = 100 * dBm)
+	Security     Security
+	IsAssociated bool // the interface is connected to this BSS
+}
+
+func parseBss(data []byte) (bss BSS, ok bool) {
+	var ies []byte
+	var capability uint16
+	for _, a := range parseAttrs(data) {
+		switch a.typ {
+		case bssAttrBssid:
+			bss.BSSID = net.HardwareAddr(append([]byte{}, a.data...))
+		case bssAttrFrequency:
+			if len(a.data) >= 4 {
+				bss.Frequency = binary.NativeEndian.Uint32(a.data)
+			}
+		case bssAttrCapability:
+			if len(a.data) >= 2 {
+				capability = binary.NativeEndian.Uint16(a.data)
+			}
+		case bssAttrInfoElements:
+			ies = a.data
+		case bssAttrSignalMbm:
+			if len(a.data) >= 4 {
+				bss.SignalMbm = int32(binary.NativeEndian.Uint32(a.data))
+			}
+		case bssAttrStatus:
+			if len(a.data) >= 4 {
+				status := binary.NativeEndian.Uint32(a.data)
+				bss.IsAssociated = status == bssStatusAssociated || status == bssStatusIbssJoined
+			}
+		}
+	}
+	if len(bss.BSSID) == 0 {
+		return bss, false
+	}
+
+	bss.SSID, bss.Security = parseInformationElements(ies, capability)
+	return bss, true
+}
+
+// parseInformationElements returns SSID and security type of the network
+func parseInformationElements(ies []byte, capability uint16) (ssid string, security Security) {
+	var rsn, wpa []byte
+	for len(ies) >= 2 {
+		id, l := ies[0], int(ies[1])
+		if 2+l > len(ies) {
+			break
+		}
+		value := ies[2 : 2+l]
+		switch id {
+		case ieSsid:
+			ssid = string(value)
+		case ieRsn:
+			rsn = value
+		case ieVendor:
+			// WPA (v1): OUI 00:50:F2, type 1
+			if len(value) >= 4 && value[0] == 0x00 && value[1] == 0x50 && value[2] == 0xF2 && value[3] == 0x01 {
+				wpa = value[4:]
+			}
+		}
+		ies = ies[2+l:]
+	}
+
+	switch {
+	case rsn != nil:
+		security = securityFromAkmSuites(parseAkmSuites(rsn), SecurityWPA2)
+	case wpa != nil:
+		security = securityFromAkmSuites(parseAkmSuites(wpa), SecurityWPA)
+	case capability&capabilityPrivacy != 0:
+		security = SecurityWEP
+	default:
+		security = SecurityOpen
+	}
+	return ssid, security
+}
+
+// parseAkmSuites returns list of AKM (authentication and key management) suites from RSN (or WPA) element:
+// version(2), group cipher(4), pairwise cipher count(2), pairwise ciphers(4*n), AKM count(2), AKM suites(4*n) ...
+// Each suite is returned as 4-bytes value: OUI(3) + type(1)
+func parseAkmSuites(ie []byte) []uint32 {
+	const offsetPairwiseCount = 2 + 4
+	if len(ie) < offsetPairwiseCount+2 {
+		return nil
+	}
+	pairwiseCount := int(binary.LittleEndian.Uint16(ie[offsetPairwiseCount:]))
+	offsetAkmCount := offsetPairwiseCount + 2 + 4*pairwiseCount
+	if len(ie) < offsetAkmCount+2 {
+		return nil
+	}
+	akmCount := int(binary.LittleEndian.Uint16(ie[offsetAkmCount:]))
+
+	var ret []uint32
+	for i, offset := 0, offsetAkmCount+2; i < akmCount && offset+4 <= len(ie); i, offset = i+1, offset+4 {
+		ret = append(ret, binary.BigEndian.Uint32(ie[offset:]))
+	}
+	return ret
+}
+
+// securityFromAkmSuites returns security type according to the strongest AKM suite.
+// 'pskSecurity' - security type to return for PSK authentication (WPA or WPA2)
+func securityFromAkmSuites(suites []uint32, pskSecurity Security) Security {
+	if len(suites) == 0 {
+		return pskSecurity
+	}
+
+	ret := SecurityUnknown
+	rank := func(s Security) int {
+		switch s {
+		case SecurityEnterprise:
+			return 4
+		case SecurityWPA3:
+			return 3
+		case SecurityWPA, SecurityWPA2:
+			return 2
+		case SecurityOWE:
+			return 1
+		}
+		return 0
+	}
+
+	for _, s := range suites {
+		cur := SecurityUnknown
+		switch s {
+		case 0x000FAC01, 0x000FAC03, 0x000FAC05, 0x000FAC0B, 0x000FAC0C, 0x000FAC0D, // 802.1X (including FT, SHA256 and Suite-B)
+			0x0050F201: // WPA (v1) 802.1X
+			cur = SecurityEnterprise
+		case 0x000FAC02, 0x000FAC04, 0x000FAC06, // PSK (including FT and SHA256)
+			0x0050F202: // WPA (v1) PSK
+			cur = pskSecurity
+		case 0x000FAC08, 0x000FAC09, 0x000FAC18, 0x000FAC19: // SAE (including FT and SAE-EXT-KEY)
+			cur = SecurityWPA3
+		case 0x000FAC12: // OWE
+			cur = SecurityOWE
+		}
+		if rank(cur) > rank(ret) {
+			ret = cur
+		}
+	}
+	return ret
+}
@@ -0,0 +1,142 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package nl80211
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// informationElement returns encoded information element: id(1), length(1), value
+func informationElement(id byte, value []byte) []byte {
+	return append([]byte{id, byte(len(value))}, value...)
+}
+
+// rsnElement returns RSN (or WPA) element body with the AKM suites (CCMP ciphers)
+func rsnElement(akmSuites ...uint32) []byte {
+	b := []byte{0x01, 0x00, 0x00, 0x0F, 0xAC, 0x04}   // version 1; group cipher: CCMP
+	b = append(b, 0x01, 0x00, 0x00, 0x0F, 0xAC, 0x04) // 1 pairwise cipher: CCMP
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(akmSuites)))
+	for _, s := range akmSuites {
+		b = binary.BigEndian.AppendUint32(b, s)
+	}
+	return b
+}
+
+// wpaElement returns vendor-specific WPA (v1) element body
+func wpaElement(akmSuites ...uint32) []byte {
+	return append([]byte{0x00, 0x50, 0xF2, 0x01}, rsnElement(akmSuites...)...)
+}
+
+func TestParseAkmSuites(t *testing.T) {
+	tests := []struct {
+		name string
+		ie   []byte
+		want []uint32
+	}{
+		{"PSK", rsnElement(0x000FAC02), []uint32{0x000FAC02}},
+		{"PSK and SAE", rsnElement(0x000FAC02, 0x000FAC08), []uint32{0x000FAC02, 0x000FAC08}},
+		{"no AKM suites", rsnElement(), nil},
+		{"truncated AKM suite", rsnElement(0x000FAC02, 0x000FAC08)[:len(rsnElement(0x000FAC02, 0x000FAC08))-1], []uint32{0x000FAC02}},
+		{"AKM count missing", rsnElement()[:13], nil},
+		{"version and group cipher only", rsnElement()[:6], nil},
+		{"empty", nil, nil},
+		{"pairwise count exceeds data", []byte{0x01, 0x00, 0x00, 0x0F, 0xAC, 0x04, 0xFF, 0x00, 0x00, 0x0F, 0xAC, 0x04}, nil},
+	}
+	for _, tt := range tests {
+		if got := parseAkmSuites(tt.ie); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: unexpected result %x (expected %x)", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseInformationElements(t *testing.T) {
+	ssid := informationElement(ieSsid, []byte("Home"))
+	tests := []struct {
+		name         string
+		ies          []byte
+		capability   uint16
+		wantSsid     string
+		wantSecurity Security
+	}{
+		{"open", ssid, 0, "Home", SecurityOpen},
+		{"WEP", ssid, capabilityPrivacy, "Home", SecurityWEP},
+		{"WPA", append(append([]byte{}, ssid...), informationElement(ieVendor, wpaElement(0x0050F202))...), capabilityPrivacy, "Home", SecurityWPA},
+		{"WPA enterprise", append(append([]byte{}, ssid...), informationElement(ieVendor, wpaElement(0x0050F201))...), capabilityPrivacy, "Home", SecurityEnterprise},
+		{"WPA2", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement(0x000FAC02))...), capabilityPrivacy, "Home", SecurityWPA2},
+		{"WPA2 without AKM suites", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement())...), capabilityPrivacy, "Home", SecurityWPA2},
+		{"WPA2/WPA3 transition", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement(0x000FAC02, 0x000FAC08))...), capabilityPrivacy, "Home", SecurityWPA3},
+		{"WPA3", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement(0x000FAC08))...), capabilityPrivacy, "Home", SecurityWPA3},
+		{"enterprise", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement(0x000FAC05))...), capabilityPrivacy, "Home", SecurityEnterprise},
+		{"OWE", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement(0x000FAC12))...), capabilityPrivacy, "Home", SecurityOWE},
+		{"unknown AKM suite", append(append([]byte{}, ssid...), informationElement(ieRsn, rsnElement(0x000FAC7F))...), capabilityPrivacy, "Home", SecurityUnknown},
+		{"RSN preferred over WPA", append(append(append([]byte{}, ssid...), informationElement(ieVendor, wpaElement(0x0050F202))...), informationElement(ieRsn, rsnElement(0x000FAC02))...), capabilityPrivacy, "Home", SecurityWPA2},
+		{"other vendor element", append(append([]byte{}, ssid...), informationElement(ieVendor, []byte{0x00, 0x50, 0xF2, 0x04, 0x10})...), 0, "Home", SecurityOpen},
+		{"hidden network", informationElement(ieSsid, nil), 0, "", SecurityOpen},
+		{"truncated element", append(append([]byte{}, ssid...), ieRsn, 40, 0x01), capabilityPrivacy, "Home", SecurityWEP},
+		{"empty", nil, 0, "", SecurityOpen},
+	}
+	for _, tt := range tests {
+		gotSsid, gotSecurity := parseInformationElements(tt.ies, tt.capability)
+		if gotSsid != tt.wantSsid || gotSecurity != tt.wantSecurity {
+			t.Errorf("%s: unexpected result '%s', '%s' (expected '%s', '%s')", tt.name, gotSsid, gotSecurity, tt.wantSsid, tt.wantSecurity)
+		}
+	}
+}
+
+func TestParseBss(t *testing.T) {
+	bssid := []byte{0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+	ies := append(informationElement(ieSsid, []byte("Home")), informationElement(ieRsn, rsnElement(0x000FAC02))...)
+	capability := make([]byte, 2)
+	binary.NativeEndian.PutUint16(capability, capabilityPrivacy)
+
+	var data []byte
+	for _, a := range []attr{
+		{typ: bssAttrBssid, data: bssid},
+		attrUint32(bssAttrFrequency, 2412),
+		{typ: bssAttrCapability, data: capability},
+		{typ: bssAttrInfoElements, data: ies},
+		attrUint32(bssAttrSignalMbm, uint32(0xFFFFEC78)), // -5000 mBm
+		attrUint32(bssAttrStatus, bssStatusAssociated),
+	} {
+		data = append(data, a.encode()...)
+	}
+
+	bss, ok := parseBss(data)
+	if !ok {
+		t.Fatalf("BSS not parsed")
+	}
+	if !bytes.Equal(bss.BSSID, bssid) || bss.SSID != "Home" || bss.Frequency != 2412 || bss.SignalMbm != -5000 || bss.Security != SecurityWPA2 || !bss.IsAssociated {
+		t.Errorf("unexpected result: %+v", bss)
+	}
+
+	// BSSID is required
+	if _, ok := parseBss(attrUint32(bssAttrFrequency, 2412).encode()); ok {
+		t.Errorf("BSS without BSSID parsed")
+	}
+}
@@ -0,0 +1,462 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+// Package nl80211 is a minimal pure-Go client of the Linux nl80211 (generic netlink) interface.
+// It provides information about wireless interfaces, scan results (BSS) and notifications about
+// connection changes, without dependency on wireless-tools (libiw) or libnl.
+package nl80211
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// generic netlink constants (linux/genetlink.h)
+const (
+	genlIdCtrl       = 0x10
+	ctrlCmdGetFamily = 3
+
+	ctrlAttrFamilyId   = 1
+	ctrlAttrFamilyName = 2
+	ctrlAttrMcastGrps  = 7
+
+	ctrlAttrMcastGrpName = 1
+	ctrlAttrMcastGrpId   = 2
+
+	solNetlink             = 270
+	netlinkAddMembership   = 1
+	nlaTypeMask            = 0x3fff // remove NLA_F_NESTED and NLA_F_NET_BYTEORDER flags
+	genlHdrLen             = 4
+	familyName             = "nl80211"
+	defaultReadTimeout     = time.Second
+	defaultResponseTimeout = 5 * time.Second
+)
+
+// nl80211 commands (linux/nl80211.h)
+const (
+	CmdGetInterface   = 5
+	CmdNewInterface   = 7
+	CmdDelInterface   = 8
+	CmdGetScan        = 32
+	CmdTriggerScan    = 33
+	CmdNewScanResults = 34
+	CmdScanAborted    = 35
+	CmdAuthenticate   = 37
+	CmdAssociate      = 38
+	CmdDeauthenticate = 39
+	CmdDisassociate   = 40
+	CmdConnect        = 46
+	CmdRoam           = 47
+	CmdDisconnect     = 48
+)
+
+// nl80211 attributes (linux/nl80211.h)
+const (
+	attrIfIndex   = 3
+	attrIfName    = 4
+	attrIfType    = 5
+	attrMac       = 6
+	attrBss       = 47
+	attrSsid      = 52
+	ifTypeStation = 2
+
+	bssAttrBssid        = 1
+	bssAttrFrequency    = 2
+	bssAttrCapability   = 5
+	bssAttrInfoElements = 6
+	bssAttrSignalMbm    = 7
+	bssAttrStatus       = 9
+)
+
+// Multicast groups of nl80211 family
+const (
+	GroupConfig = "config" // interfaces added/removed
+	GroupScan   = "scan"   // scan results
+	GroupMlme   = "mlme"   // connection events (connect, disconnect, roam ...)
+)
+
+// ErrFamilyNotFound returned when nl80211 is not available (e.g. no wireless drivers loaded)
+var ErrFamilyNotFound = errors.New("nl80211 generic netlink family not found")
+
+// Interface - wireless network interface
+type Interface struct {
+	Index        int
+	Name         string
+	HardwareAddr net.HardwareAddr
+	IsStation    bool   // interface works in 'managed' (station) mode
+	SSID         string // SSID of the connected network (reported only by new kernels; empty when not connected)
+}
+
+// Event - notification received from nl80211 multicast group
+type Event struct {
+	Cmd     uint8
+	IfIndex int // 0 - not defined
+}
+
+// Conn - generic netlink socket bound to nl80211 family
+type Conn struct {
+	fd       int
+	familyId uint16
+	groups   map[string]uint32
+	seq      uint32
+	mutex    sync.Mutex
+	isClosed bool
+}
+
+// Dial creates generic netlink socket and resolves nl80211 family
+func Dial() (*Conn, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, fmt.Errorf("socket initialization error: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("socket binding error: %w", err)
+	}
+	syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, 1024*1024)
+	tv := syscall.NsecToTimeval(defaultReadTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set socket timeout: %w", err)
+	}
+
+	c := &Conn{fd: fd, seq: uint32(time.Now().Unix())}
+	if err := c.resolveFamily(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the socket
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.isClosed {
+		return nil
+	}
+	c.isClosed = true
+	return syscall.Close(c.fd)
+}
+
+// JoinGroups subscribes the socket to nl80211 multicast groups (see GroupMlme, GroupScan, GroupConfig).
+// The events can be received by ReadEvents(). The socket should not be used for requests after that.
+func (c *Conn) JoinGroups(names ...string) error {
+	for _, name := range names {
+		id, ok := c.groups[name]
+		if !ok {
+			return fmt.Errorf("nl80211 multicast group '%s' not found", name)
+		}
+		if err := syscall.SetsockoptInt(c.fd, solNetlink, netlinkAddMembership, int(id)); err != nil {
+			return fmt.Errorf("failed to join nl80211 multicast group '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ReadEvents waits for events from the joined multicast groups.
+// Returns empty list (and no error) when no events received during read timeout.
+func (c *Conn) ReadEvents() ([]Event, error) {
+	msgs, err := c.receive()
+	if err != nil {
+		if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR {
+			return nil, nil // timeout
+		}
+		if err == syscall.ENOBUFS {
+			// some events were lost (socket buffer overflow): report it as unknown event
+			return []Event{{}}, nil
+		}
+		return nil, err
+	}
+
+	var events []Event
+	for _, m := range msgs {
+		if m.Header.Type != c.familyId || len(m.Data) < genlHdrLen {
+			continue
+		}
+		evt := Event{Cmd: m.Data[0]}
+		for _, a := range parseAttrs(m.Data[genlHdrLen:]) {
+			if a.typ == attrIfIndex && len(a.data) >= 4 {
+				evt.IfIndex = int(binary.NativeEndian.Uint32(a.data))
+			}
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// Interfaces returns list of wireless network interfaces
+func (c *Conn) Interfaces() ([]Interface, error) {
+	msgs, err := c.request(CmdGetInterface, syscall.NLM_F_DUMP, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wireless interfaces: %w", err)
+	}
+
+	var ret []Interface
+	for _, data := range msgs {
+		var iface Interface
+		for _, a := range parseAttrs(data) {
+			switch a.typ {
+			case attrIfIndex:
+				if len(a.data) >= 4 {
+					iface.Index = int(binary.NativeEndian.Uint32(a.data))
+				}
+			case attrIfName:
+				iface.Name = cString(a.data)
+			case attrIfType:
+				if len(a.data) >= 4 {
+					iface.IsStation = binary.NativeEndian.Uint32(a.data) == ifTypeStation
+				}
+			case attrMac:
+				iface.HardwareAddr = net.HardwareAddr(append([]byte{}, a.data...))
+			case attrSsid:
+				iface.SSID = string(a.data)
+			}
+		}
+		if iface.Index > 0 {
+			ret = append(ret, iface)
+		}
+	}
+	return ret, nil
+}
+
+// ScanResults returns the last known scan results (BSS list) of the interface.
+// The list contains the BSS the interface is currently connected to (see BSS.IsAssociated).
+func (c *Conn) ScanResults(ifIndex int) ([]BSS, error) {
+	msgs, err := c.request(CmdGetScan, syscall.NLM_F_DUMP, []attr{attrUint32(attrIfIndex, uint32(ifIndex))})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan results: %w", err)
+	}
+
+	var ret []BSS
+	for _, data := range msgs {
+		for _, a := range parseAttrs(data) {
+			if a.typ != attrBss {
+				continue
+			}
+			if bss, ok := parseBss(a.data); ok {
+				ret = append(ret, bss)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// TriggerScan requests the interface to scan for networks (requires CAP_NET_ADMIN).
+// The completion is notified by CmdNewScanResults (or CmdScanAborted) event in GroupScan.
+func (c *Conn) TriggerScan(ifIndex int) error {
+	if _, err := c.request(CmdTriggerScan, syscall.NLM_F_ACK, []attr{attrUint32(attrIfIndex, uint32(ifIndex))}); err != nil {
+		return fmt.Errorf("failed to trigger scan: %w", err)
+	}
+	return nil
+}
+
+// resolveFamily obtains nl80211 family ID and IDs of its multicast groups
+func (c *Conn) resolveFamily() error {
+	c.familyId = genlIdCtrl
+	msgs, err := c.request(ctrlCmdGetFamily, 0, []attr{attrString(ctrlAttrFamilyName, familyName)})
+	if err != nil {
+		if err == syscall.ENOENT {
+			return ErrFamilyNotFound
+		}
+		return fmt.Errorf("failed to resolve nl80211 family: %w", err)
+	}
+
+	c.familyId = 0
+	c.groups = make(map[string]uint32)
+	for _, data := range msgs {
+		for _, a := range parseAttrs(data) {
+			switch a.typ {
+			case ctrlAttrFamilyId:
+				if len(a.data) >= 2 {
+					c.familyId = binary.NativeEndian.Uint16(a.data)
+				}
+			case ctrlAttrMcastGrps:
+				for _, grp := range parseAttrs(a.data) {
+					var name string
+					var id uint32
+					for _, ga := range parseAttrs(grp.data) {
+						switch ga.typ {
+						case ctrlAttrMcastGrpName:
+							name = cString(ga.data)
+						case ctrlAttrMcastGrpId:
+							if len(ga.data) >= 4 {
+								id = binary.NativeEndian.Uint32(ga.data)
+							}
+						}
+					}
+					if len(name) > 0 {
+						c.groups[name] = id
+					}
+				}
+			}
+		}
+	}
+	if c.familyId == 0 {
+		return ErrFamilyNotFound
+	}
+	return nil
+}
+
+// request sends generic netlink request and returns payloads (attributes) of all response messages
+func (c *Conn) request(cmd uint8, flags uint16, attrs []attr) ([][]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.isClosed {
+		return nil, fmt.Errorf("connection closed")
+	}
+
+	c.seq++
+	seq := c.seq
+
+	payload := []byte{cmd, 1, 0, 0} // struct genlmsghdr {cmd, version, reserved}
+	for _, a := range attrs {
+		payload = append(payload, a.encode()...)
+	}
+	msg := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(payload))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(syscall.NLMSG_HDRLEN+len(payload)))
+	binary.NativeEndian.PutUint16(msg[4:6], c.familyId)
+	binary.NativeEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|flags)
+	binary.NativeEndian.PutUint32(msg[8:12], seq)
+	msg = append(msg, payload...)
+
+	if err := syscall.Sendto(c.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	isMultipart := flags&syscall.NLM_F_DUMP != 0
+	deadline := time.Now().Add(defaultResponseTimeout)
+
+	var ret [][]byte
+	for time.Now().Before(deadline) {
+		msgs, err := c.receive()
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return ret, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+						return nil, syscall.Errno(-errno)
+					}
+				}
+				return ret, nil // acknowledgement
+			default:
+				if len(m.Data) >= genlHdrLen {
+					ret = append(ret, m.Data[genlHdrLen:])
+				}
+				if !isMultipart && flags&syscall.NLM_F_ACK == 0 {
+					return ret, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("response timeout")
+}
+
+func (c *Conn) receive() ([]syscall.NetlinkMessage, error) {
+	buf := make([]byte, 64*1024)
+	n, _, err := syscall.Recvfrom(c.fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n < syscall.NLMSG_HDRLEN {
+		return nil, nil
+	}
+	return syscall.ParseNetlinkMessage(buf[:n])
+}
+
+//---------------------------------------------------------------------
+// netlink attributes
+
+type attr struct {
+	typ  uint16
+	data []byte
+}
+
+func attrUint32(typ uint16, v uint32) attr {
+	data := make([]byte, 4)
+	binary.NativeEndian.PutUint32(data, v)
+	return attr{typ: typ, data: data}
+}
+
+func attrString(typ uint16, v string) attr {
+	return attr{typ: typ, data: append([]byte(v), 0)}
+}
+
+func (a attr) encode() []byte {
+	l := 4 + len(a.data)
+	ret := make([]byte, (l+3)&^3)
+	binary.NativeEndian.PutUint16(ret[0:2], uint16(l))
+	binary.NativeEndian.PutUint16(ret[2:4], a.typ)
+	copy(ret[4:], a.data)
+	return ret
+}
+
+func parseAttrs(data []byte) []attr {
+	var ret []attr
+	for len(data) >= 4 {
+		l := int(binary.NativeEndian.Uint16(data[0:2]))
+		if l < 4 || l > len(data) {
+			break
+		}
+		ret = append(ret, attr{typ: binary.NativeEndian.Uint16(data[2:4]) & nlaTypeMask, data: data[4:l]})
+
+		// attributes are aligned to 4 bytes
+		alignedLen := (l + 3) &^ 3
+		if alignedLen > len(data) {
+			break
+		}
+		data = data[alignedLen:]
+	}
+	return ret
+}
+
+// cString returns string value of the null-terminated string
+func cString(data []byte) string {
+	for i, c := range data {
+		if c == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
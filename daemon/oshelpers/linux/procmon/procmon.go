@@ -0,0 +1,211 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package procmon
+
+// Process events monitor based on the netlink process connector (NETLINK_CONNECTOR, CN_IDX_PROC).
+// https://www.kernel.org/doc/html/latest/driver-api/connector.html
+// Note: requires CAP_NET_ADMIN privileges.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+const (
+	netlinkConnector = 11 // NETLINK_CONNECTOR
+	cnIdxProc        = 1  // CN_IDX_PROC
+	cnValProc        = 1  // CN_VAL_PROC
+
+	procCnMcastListen = 1 // PROC_CN_MCAST_LISTEN
+	procCnMcastIgnore = 2 // PROC_CN_MCAST_IGNORE
+
+	procEventExec = 0x00000002 // PROC_EVENT_EXEC
+
+	sizeofCnMsg = 20 // struct cn_msg (without data)
+
+	// Receive timeout of the socket. Closing (or shutdown) of the netlink socket does not interrupt the blocked 'recvfrom' call,
+	// so the reading goroutine checks the 'stopped' flag at least once per this interval.
+	readTimeoutSec = 1
+)
+
+// Monitor notifies about new executed processes (exec() system call)
+//
+// Usage example:
+//
+//	m, err := Start(func(pid int) {
+//		fmt.Println("Executed:", pid)
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	...
+//	m.Stop()
+type Monitor struct {
+	fd      int
+	stopped bool
+	closed  bool
+	mutex   sync.Mutex
+}
+
+// Start creates process connector socket and starts listening for the exec events.
+// The 'onExec' callback is called (from the separate goroutine) with the PID of the process which executed new binary.
+func Start(onExec func(pid int)) (*Monitor, error) {
+	if onExec == nil {
+		return nil, fmt.Errorf("callback function not defined")
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkConnector)
+	if err != nil {
+		return nil, fmt.Errorf("socket initialization error: %w", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("socket binding error: %w", err)
+	}
+
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &syscall.Timeval{Sec: readTimeoutSec}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set socket timeout: %w", err)
+	}
+
+	if err := sendControl(fd, procCnMcastListen); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to subscribe to process events: %w", err)
+	}
+
+	m := &Monitor{fd: fd}
+	go m.readLoop(onExec)
+	return m, nil
+}
+
+// Stop stops the monitoring.
+// The reading goroutine exits and closes the socket within the socket receive timeout.
+func (m *Monitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	if !m.closed {
+		sendControl(m.fd, procCnMcastIgnore)
+	}
+}
+
+func (m *Monitor) close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.closed {
+		m.closed = true
+		syscall.Close(m.fd)
+	}
+}
+
+func (m *Monitor) isStopped() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stopped
+}
+
+func (m *Monitor) readLoop(onExec func(pid int)) {
+	defer m.close()
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(m.fd, buf, 0)
+		if m.isStopped() {
+			return
+		}
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR || err == syscall.ENOBUFS {
+				continue // EAGAIN: receive timeout; ENOBUFS: some events were lost (too many events)
+			}
+			return
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, msg := range msgs {
+			if pid, ok := parseExecEvent(msg.Data); ok {
+				onExec(pid)
+			}
+		}
+	}
+}
+
+// parseExecEvent returns PID of the process from PROC_EVENT_EXEC message
+func parseExecEvent(data []byte) (pid int, ok bool) {
+	// struct cn_msg { struct cb_id { u32 idx; u32 val; } id; u32 seq; u32 ack; u16 len; u16 flags; u8 data[]; }
+	if len(data) < sizeofCnMsg {
+		return 0, false
+	}
+	idx := binary.NativeEndian.Uint32(data[0:4])
+	val := binary.NativeEndian.Uint32(data[4:8])
+	if idx != cnIdxProc || val != cnValProc {
+		return 0, false
+	}
+
+	// struct proc_event { u32 what; u32 cpu; u64 timestamp_ns; union { struct exec_proc_event { pid process_pid; pid process_tgid; } } }
+	ev := data[sizeofCnMsg:]
+	if len(ev) < 24 {
+		return 0, false
+	}
+	if binary.NativeEndian.Uint32(ev[0:4]) != procEventExec {
+		return 0, false
+	}
+	tgid := binary.NativeEndian.Uint32(ev[20:24])
+	return int(tgid), tgid > 0
+}
+
+// sendControl sends PROC_CN_MCAST_LISTEN/PROC_CN_MCAST_IGNORE operation to the process connector
+func sendControl(fd int, op uint32) error {
+	const msgLen = syscall.NLMSG_HDRLEN + sizeofCnMsg + 4
+	b := make([]byte, msgLen)
+
+	// struct nlmsghdr
+	binary.NativeEndian.PutUint32(b[0:4], msgLen)
+	binary.NativeEndian.PutUint16(b[4:6], syscall.NLMSG_DONE)
+	binary.NativeEndian.PutUint32(b[12:16], uint32(os.Getpid()))
+
+	// struct cn_msg
+	cn := b[syscall.NLMSG_HDRLEN:]
+	binary.NativeEndian.PutUint32(cn[0:4], cnIdxProc)
+	binary.NativeEndian.PutUint32(cn[4:8], cnValProc)
+	binary.NativeEndian.PutUint16(cn[16:18], 4) // data length
+
+	// enum proc_cn_mcast_op
+	binary.NativeEndian.PutUint32(cn[sizeofCnMsg:], op)
+
+	return syscall.Sendto(fd, b, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
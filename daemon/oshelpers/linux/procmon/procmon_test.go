@@ -0,0 +1,63 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package procmon
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func execEventMessage(idx, val, what, tgid uint32) []byte {
+	b := make([]byte, sizeofCnMsg+24)
+	binary.NativeEndian.PutUint32(b[0:4], idx)
+	binary.NativeEndian.PutUint32(b[4:8], val)
+	ev := b[sizeofCnMsg:]
+	binary.NativeEndian.PutUint32(ev[0:4], what)
+	binary.NativeEndian.PutUint32(ev[16:20], tgid+1) // process_pid (thread ID)
+	binary.NativeEndian.PutUint32(ev[20:24], tgid)
+	return b
+}
+
+func TestParseExecEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantPid int
+		wantOk  bool
+	}{
+		{"exec", execEventMessage(cnIdxProc, cnValProc, procEventExec, 1234), 1234, true},
+		{"fork event", execEventMessage(cnIdxProc, cnValProc, 0x00000001, 1234), 0, false},
+		{"another connector", execEventMessage(cnIdxProc+1, cnValProc, procEventExec, 1234), 0, false},
+		{"zero PID", execEventMessage(cnIdxProc, cnValProc, procEventExec, 0), 0, false},
+		{"truncated event", execEventMessage(cnIdxProc, cnValProc, procEventExec, 1234)[:sizeofCnMsg+20], 0, false},
+		{"truncated header", make([]byte, sizeofCnMsg-1), 0, false},
+	}
+	for _, tt := range tests {
+		if pid, ok := parseExecEvent(tt.data); pid != tt.wantPid || ok != tt.wantOk {
+			t.Errorf("%s: unexpected result %d, %v", tt.name, pid, ok)
+		}
+	}
+}
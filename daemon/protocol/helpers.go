@@ -97,9 +97,10 @@ func (p *Protocol) createConnectedResponse(state vpn.StateInfo) *types.Connected
 		ClientIPv6:      ipv6,
 		ServerIP:        state.ServerIP.String(),
 		ServerPort:      state.ServerPort,
+		ServerHostname:  state.ServerHostname,
 		VpnType:         state.VpnType,
 		ExitHostname:    state.ExitHostname,
-		Dns:             types.DnsStatus{Dns: manualDns, AntiTrackerStatus: p._service.GetAntiTrackerStatus()},
+		Dns:             p.createDnsStatus(manualDns),
 		IsTCP:           state.IsTCP,
 		Mtu:             state.Mtu,
 		V2RayProxy:      state.V2RayProxy,
@@ -110,3 +111,11 @@ func (p *Protocol) createConnectedResponse(state vpn.StateInfo) *types.Connected
 
 	return ret
 }
+
+func (p *Protocol) createDnsStatus(dnsCfg dns.DnsSettings) types.DnsStatus {
+	return types.DnsStatus{
+		Dns:               dnsCfg,
+		AntiTrackerStatus: p._service.GetAntiTrackerStatus(),
+		AutoDnsStatus:     p._service.GetDnsAutoStatus(),
+	}
+}
@@ -41,9 +41,11 @@ import (
 	"github.com/ivpn/desktop-app/daemon/protocol/eaa"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
+	"github.com/ivpn/desktop-app/daemon/splittun"
 	"github.com/ivpn/desktop-app/daemon/vpn"
 	"github.com/ivpn/desktop-app/daemon/wifiNotifier"
 )
@@ -80,12 +82,17 @@ type Service interface {
 	DetectAccessiblePorts(portsToTest []api_types.PortInfo) (retPorts []api_types.PortInfo, err error)
 
 	KillSwitchState() (status service_types.KillSwitchStatus, err error)
+	KillSwitchGetRules() (firewall.RuleSet, error)
 	SetKillSwitchState(bool) error
 	SetKillSwitchIsPersistent(isPersistant bool) error
 	SetKillSwitchAllowLANMulticast(isAllowLanMulticast bool) error
 	SetKillSwitchAllowLAN(isAllowLan bool) error
 	SetKillSwitchAllowAPIServers(isAllowAPIServers bool) error
 	SetKillSwitchUserExceptions(exceptions string, ignoreParsingErrors bool) error
+	SetKillSwitchInterfaceExceptions(cfg firewall.InterfaceExceptions) error
+	SetKillSwitchAppAllowlist(cfg firewall.AppAllowlist) error
+	SetKillSwitchBlockedPacketsLogging(enable bool) error
+	GetKillSwitchBlockedPackets(clear bool) firewall.BlockedPacketsInfo
 
 	GetConnectionParams() service_types.ConnectionParams
 	SetConnectionParams(params service_types.ConnectionParams) error
@@ -93,9 +100,14 @@ type Service interface {
 
 	SplitTunnelling_SetConfig(isEnabled, isInversed, isAnyDns, isAllowWhenNoVpn, reset bool) error
 	SplitTunnelling_GetStatus() (types.SplitTunnelStatus, error)
-	SplitTunnelling_AddApp(exec string) (cmdToExecute string, isAlreadyRunning bool, err error)
+	SplitTunnelling_AddApp(exec string, isPersistent bool) (cmdToExecute string, isAlreadyRunning bool, err error)
 	SplitTunnelling_RemoveApp(pid int, exec string) (err error)
 	SplitTunnelling_AddedPidInfo(pid int, exec string, cmdToExecute string) error
+	SplitTunnelling_SetRoutes(routes []string, isInversed bool) error
+	SplitTunnelling_SetUserRules(rules []splittun.UserRule) error
+	SplitTunnelling_SetDnsPolicy(policy splittun.DnsPolicy, customDns string) error
+	SplitTunnelling_SetNetnsMode(enable bool) error
+	SplitTunnelling_NetnsExec(client, daemon net.Addr, args []string) (pid int, err error)
 
 	GetInstalledApps(extraArgsJSON string) ([]oshelpers.AppInfo, error)
 	GetBinaryIcon(binaryPath string) (string, error)
@@ -110,6 +122,13 @@ type Service interface {
 	SetManualDNS(dns dns.DnsSettings, antiTracker service_types.AntiTrackerMetadata) (changedDns dns.DnsSettings, retErr error)
 	GetManualDNSStatus() dns.DnsSettings
 	GetAntiTrackerStatus() service_types.AntiTrackerMetadata
+	GetAntiTrackerCustomLists() dns.DnsFilterStatus
+	SetAntiTrackerCustomLists(cfg dns.DnsFilterConfig, localContent map[string][]byte) (dns.DnsFilterStatus, error)
+	TestDnsConfig(dnsCfg dns.DnsSettings, probeName string, timeout time.Duration) dns.DnsTestResult
+	SetDnsAutoMode(enabled bool) error
+	GetDnsAutoStatus() service_types.DnsAutoStatus
+	GetDnsStats() dns.DnsStats
+	SetDnsStatsConfig(cfg dns.DnsStatsConfig) (dns.DnsStats, error)
 
 	IsCanConnectMultiHop() error
 	Connect(params service_types.ConnectionParams) error
@@ -406,8 +425,11 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			"APIRequest",
 			"WiFiAvailableNetworks",
 			"KillSwitchGetStatus",
+			"KillSwitchGetRules",
+			"KillSwitchGetBlockedPackets",
 			"SplitTunnelGetStatus",
 			"GetDnsPredefinedConfigs",
+			"AntiTrackerGetCustomLists",
 			"AccountStatus":
 			return true
 		}
@@ -620,6 +642,13 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 				&types.KillSwitchStatusResp{KillSwitchStatus: status}, reqCmd.Idx)
 		}
 
+	case "KillSwitchGetRules":
+		if rules, err := p._service.KillSwitchGetRules(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.KillSwitchRulesResp{Rules: rules}, reqCmd.Idx)
+		}
+
 	case "KillSwitchSetEnabled":
 		var req types.KillSwitchSetEnabled
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -673,6 +702,56 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		}
 		// all clients will be notified in case of successful change by OnKillSwitchStateChanged() handler
 
+	case "KillSwitchSetInterfaceExceptions":
+		var req types.KillSwitchSetInterfaceExceptions
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchInterfaceExceptions(req.InterfaceExceptions); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		}
+		// all clients will be notified in case of successful change by OnKillSwitchStateChanged() handler
+
+	case "KillSwitchSetAppAllowlist":
+		var req types.KillSwitchSetAppAllowlist
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchAppAllowlist(req.AppAllowlist); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		}
+		// all clients will be notified in case of successful change by OnKillSwitchStateChanged() handler
+
+	case "KillSwitchSetBlockedPacketsLogging":
+		var req types.KillSwitchSetBlockedPacketsLogging
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchBlockedPacketsLogging(req.IsEnabled); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		}
+
+	case "KillSwitchGetBlockedPackets":
+		var req types.KillSwitchGetBlockedPackets
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.KillSwitchBlockedPacketsResp{Info: p._service.GetKillSwitchBlockedPackets(req.Clear)}, req.Idx)
+
 	case "KillSwitchSetIsPersistent":
 		var req types.KillSwitchSetIsPersistent
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -784,7 +863,7 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		// 	<execute shell command: types.SplitTunnelAddAppCmdResp.CmdToExecute and get PID>
 		//  SplitTunnelAddedPidInfo	->
 		// 							<-	types.EmptyResp (success)
-		cmdToExecute, isAlreadyRunning, err := p._service.SplitTunnelling_AddApp(req.Exec)
+		cmdToExecute, isAlreadyRunning, err := p._service.SplitTunnelling_AddApp(req.Exec, req.IsPersistent)
 		if err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
 			break
@@ -833,6 +912,71 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		}
 		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
 
+	case "SplitTunnelSetRoutes":
+		var req types.SplitTunnelSetRoutes
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SplitTunnelling_SetRoutes(req.Routes, req.IsInversed); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
+
+	case "SplitTunnelSetUserRules":
+		var req types.SplitTunnelSetUserRules
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SplitTunnelling_SetUserRules(req.Rules); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
+
+	case "SplitTunnelSetDnsPolicy":
+		var req types.SplitTunnelSetDnsPolicy
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SplitTunnelling_SetDnsPolicy(req.Policy, req.CustomDns); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
+
+	case "SplitTunnelSetNetns":
+		var req types.SplitTunnelSetNetns
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SplitTunnelling_SetNetnsMode(req.IsEnabled); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
+
+	case "SplitTunnelNetnsExec":
+		var req types.SplitTunnelNetnsExec
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		pid, err := p._service.SplitTunnelling_NetnsExec(conn.RemoteAddr(), conn.LocalAddr(), req.Args)
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.SplitTunnelNetnsExecResp{Pid: pid}, reqCmd.Idx)
+
 	case "GenerateDiagnostics":
 		if log, log0, extraInfo, err := p._service.GetDiagnosticLogs(); err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
@@ -859,6 +1003,15 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			req.Dns.DnsHost = getSingleField(req.Dns.DnsHost)
 			req.Dns.DohTemplate = getSingleField(req.Dns.DohTemplate)
 
+			if req.FailIfTestFailed && !req.AntiTracker.Enabled && !req.Dns.IsEmpty() {
+				if res := p._service.TestDnsConfig(req.Dns, "", 0); res.IsSkipped {
+					log.Info("DNS configuration test skipped: ", res.Error)
+				} else if !res.IsOk {
+					p.sendErrorResponse(conn, reqCmd, fmt.Errorf("DNS configuration not applied (test failed: %s)", res.Error))
+					break
+				}
+			}
+
 			_, err := p._service.SetManualDNS(req.Dns, req.AntiTracker)
 			if err != nil {
 				log.ErrorTrace(err)
@@ -867,8 +1020,23 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 				p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx) // notify: request processed
 			}
 			// notify current DNS status
-			p.notifyClients(&types.SetAlternateDNSResp{Dns: types.DnsStatus{Dns: p._service.GetManualDNSStatus(), AntiTrackerStatus: p._service.GetAntiTrackerStatus()}})
+			p.notifyClients(&types.SetAlternateDNSResp{Dns: p.createDnsStatus(p._service.GetManualDNSStatus())})
+		}
+	case "SetDnsAutoMode":
+		var req types.SetDnsAutoMode
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetDnsAutoMode(req.Enabled); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
 		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx) // notify: request processed
+		// notify current DNS status
+		p.OnDnsChanged()
+
 	case "GetDnsPredefinedConfigs":
 		cfgs, err := dns.GetPredefinedDnsConfigurations()
 		if err != nil {
@@ -878,6 +1046,59 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			p.sendResponse(conn, &types.DnsPredefinedConfigsResp{DnsConfigs: cfgs}, reqCmd.Idx)
 		}
 
+	case "AntiTrackerGetCustomLists":
+		p.sendResponse(conn, &types.AntiTrackerCustomListsResp{Status: p._service.GetAntiTrackerCustomLists()}, reqCmd.Idx)
+
+	case "AntiTrackerSetCustomLists":
+		var req types.AntiTrackerSetCustomLists
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		status, err := p._service.SetAntiTrackerCustomLists(req.Config, req.LocalContent)
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.AntiTrackerCustomListsResp{Status: status}, reqCmd.Idx)
+
+	case "TestDnsConfig":
+		var req types.TestDnsConfig
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		req.Dns.DnsHost = strings.TrimSpace(req.Dns.DnsHost)
+		req.Dns.DohTemplate = strings.TrimSpace(req.Dns.DohTemplate)
+
+		timeout := time.Duration(0) // default timeout
+		if req.TimeoutMs > 0 {
+			timeout = dns.DnsTestMaxTimeout
+			if req.TimeoutMs < int(dns.DnsTestMaxTimeout.Milliseconds()) {
+				timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+			}
+		}
+		res := p._service.TestDnsConfig(req.Dns, req.ProbeName, timeout)
+		p.sendResponse(conn, &types.TestDnsConfigResp{Result: res}, req.Idx)
+
+	case "DnsGetStats":
+		p.sendResponse(conn, &types.DnsStatsResp{Stats: p._service.GetDnsStats()}, reqCmd.Idx)
+
+	case "DnsSetStatsConfig":
+		var req types.DnsSetStatsConfig
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		stats, err := p._service.SetDnsStatsConfig(req.Config)
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.DnsStatsResp{Stats: stats}, reqCmd.Idx)
+
 	case "PauseConnection":
 		var req types.PauseConnection
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -26,6 +26,7 @@ import (
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
+	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/wifiNotifier"
 )
 
@@ -63,11 +64,22 @@ func (p *Protocol) OnKillSwitchStateChanged() {
 	}
 }
 
+// OnDnsChanged - DNS configuration change handler (e.g. DNS provider changed by 'auto' DNS mode)
+func (p *Protocol) OnDnsChanged() {
+	if p._service == nil {
+		return
+	}
+
+	p.notifyClients(&types.SetAlternateDNSResp{Dns: p.createDnsStatus(p._service.GetManualDNSStatus())})
+}
+
 // OnWiFiChanged - handler of WiFi status change. Notifying clients.
 func (p *Protocol) OnWiFiChanged(info wifiNotifier.WifiInfo, err error) {
 	msg := &types.WiFiCurrentNetworkResp{
 		SSID:              info.SSID,
 		IsInsecureNetwork: info.IsInsecure,
+		BSSID:             info.BSSID,
+		Security:          string(info.Security),
 	}
 	if err != nil {
 		msg.Error = err.Error()
@@ -75,6 +87,11 @@ func (p *Protocol) OnWiFiChanged(info wifiNotifier.WifiInfo, err error) {
 	p.notifyClients(msg)
 }
 
+// OnCaptivePortalStatusChanged - captive portal detected (or the temporary firewall exception for the portal is closed)
+func (p *Protocol) OnCaptivePortalStatusChanged(status service_types.CaptivePortalStatus) {
+	p.notifyClients(&types.CaptivePortalStatusResp{Status: status})
+}
+
 // OnPingStatus - servers ping status
 func (p *Protocol) OnPingStatus(retMap map[string]int) {
 	var results []types.PingResultType
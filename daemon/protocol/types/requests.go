@@ -25,6 +25,7 @@ package types
 import (
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/vpn"
@@ -109,11 +110,43 @@ type KillSwitchSetAllowLAN struct {
 // KillSwitchSetUserExceptions set ip masks to exclude from firewall blocking rules
 type KillSwitchSetUserExceptions struct {
 	CommandBase
-	// Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	// Firewall exceptions: comma separated list of IP addresses (masks) in format: [DIRECTION:][PROTOCOL[:PORT[-PORT]]@]x.x.x.x[/xx]
 	UserExceptions     string
 	FailOnParsingError bool
 }
 
+// KillSwitchSetInterfaceExceptions set network interfaces (name patterns) to exclude from firewall blocking rules (Linux only)
+type KillSwitchSetInterfaceExceptions struct {
+	RequestBase
+	InterfaceExceptions firewall.InterfaceExceptions
+}
+
+// KillSwitchSetAppAllowlist set applications allowed to communicate when firewall is enabled (Linux only)
+type KillSwitchSetAppAllowlist struct {
+	RequestBase
+	AppAllowlist firewall.AppAllowlist
+}
+
+// KillSwitchGetRules request structured description of the active firewall rules
+// Response: KillSwitchRulesResp
+type KillSwitchGetRules struct {
+	RequestBase
+}
+
+// KillSwitchSetBlockedPacketsLogging enable/disable logging of packets blocked by firewall (Linux only)
+type KillSwitchSetBlockedPacketsLogging struct {
+	RequestBase
+	IsEnabled bool
+}
+
+// KillSwitchGetBlockedPackets request info about packets blocked by firewall
+// Response: KillSwitchBlockedPacketsResp
+type KillSwitchGetBlockedPackets struct {
+	RequestBase
+	// Clear collected info after reading
+	Clear bool
+}
+
 type KillSwitchSetAllowApiServers struct {
 	RequestBase
 	IsAllowApiServers bool
@@ -155,6 +188,22 @@ type SetAlternateDns struct {
 	RequestBase
 	AntiTracker service_types.AntiTrackerMetadata
 	Dns         dns.DnsSettings // If 'AntiTracker' is enabled - his parameter will be ignored
+	// If true - the DNS configuration is tested before applying (see TestDnsConfig); not applied if the test failed
+	FailIfTestFailed bool
+}
+
+// SetDnsAutoMode request to enable/disable 'auto' DNS mode (the fastest predefined encrypted DNS provider is selected automatically)
+type SetDnsAutoMode struct {
+	RequestBase
+	Enabled bool
+}
+
+// TestDnsConfig request to test DNS configuration (without applying it)
+type TestDnsConfig struct {
+	RequestBase
+	Dns       dns.DnsSettings
+	ProbeName string // (optional) domain name to resolve
+	TimeoutMs int    // (optional) test timeout (limited by dns.DnsTestMaxTimeout)
 }
 
 // GetDnsPredefinedConfigs request to get list of predefined DoH/DoT configurations (if exists)
@@ -162,6 +211,31 @@ type GetDnsPredefinedConfigs struct {
 	RequestBase
 }
 
+// AntiTrackerGetCustomLists request to get status of custom AntiTracker block/allow lists
+type AntiTrackerGetCustomLists struct {
+	RequestBase
+}
+
+// AntiTrackerSetCustomLists request to set custom AntiTracker block/allow lists (enforced locally)
+type AntiTrackerSetCustomLists struct {
+	RequestBase
+	Config dns.DnsFilterConfig
+	// Content of the local source files (source path -> file content).
+	// Local files are read by the client (the daemon does not read local files on behalf of the client)
+	LocalContent map[string][]byte
+}
+
+// DnsGetStats request to get DNS query statistics
+type DnsGetStats struct {
+	RequestBase
+}
+
+// DnsSetStatsConfig request to enable/disable DNS query statistics
+type DnsSetStatsConfig struct {
+	RequestBase
+	Config dns.DnsStatsConfig
+}
+
 // WiFiAvailableNetworks - get list of available WIFI networks
 type WiFiAvailableNetworks struct {
 	RequestBase
@@ -30,6 +30,7 @@ import (
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/v2r"
@@ -213,6 +214,24 @@ type KillSwitchStatusResp struct {
 	service_types.KillSwitchStatus
 }
 
+// CaptivePortalStatusResp - notification about captive portal detection status change
+type CaptivePortalStatusResp struct {
+	CommandBase
+	Status service_types.CaptivePortalStatus
+}
+
+// KillSwitchRulesResp returns structured description of the active firewall rules
+type KillSwitchRulesResp struct {
+	CommandBase
+	Rules firewall.RuleSet
+}
+
+// KillSwitchBlockedPacketsResp returns info about packets blocked by firewall
+type KillSwitchBlockedPacketsResp struct {
+	CommandBase
+	Info firewall.BlockedPacketsInfo
+}
+
 // KillSwitchGetIsPestistentResp returns kill-switch persistance status
 type KillSwitchGetIsPestistentResp struct {
 	CommandBase
@@ -230,6 +249,7 @@ type DiagnosticsGeneratedResp struct {
 type DnsStatus struct {
 	Dns               dns.DnsSettings
 	AntiTrackerStatus service_types.AntiTrackerMetadata
+	AutoDnsStatus     service_types.DnsAutoStatus
 }
 
 // SetAlternateDNSResp returns status of changing DNS
@@ -244,6 +264,24 @@ type DnsPredefinedConfigsResp struct {
 	DnsConfigs []dns.DnsSettings
 }
 
+// AntiTrackerCustomListsResp status of custom AntiTracker block/allow lists
+type AntiTrackerCustomListsResp struct {
+	CommandBase
+	Status dns.DnsFilterStatus
+}
+
+// TestDnsConfigResp result of DNS configuration test
+type TestDnsConfigResp struct {
+	CommandBase
+	Result dns.DnsTestResult
+}
+
+// DnsStatsResp DNS query statistics
+type DnsStatsResp struct {
+	CommandBase
+	Stats dns.DnsStats
+}
+
 // ConnectedResp notifying about established connection
 type ConnectedResp struct {
 	CommandBase
@@ -253,6 +291,7 @@ type ConnectedResp struct {
 	ClientIPv6      string
 	ServerIP        string
 	ServerPort      int
+	ServerHostname  string // entry hostname (e.g. "us-tx1.wg.ivpn.net")
 	ExitHostname    string // multi-hop exit hostname (e.g. "us-tx1.wg.ivpn.net")
 	Dns             DnsStatus
 	IsTCP           bool
@@ -341,6 +380,8 @@ type WiFiCurrentNetworkResp struct {
 	ResponseBase
 	SSID              string
 	IsInsecureNetwork bool
+	BSSID             string // MAC address of the access point (not reported on all platforms)
+	Security          string // security type of the network: open, owe, wep, wpa, wpa2, wpa3, enterprise (not reported on all platforms)
 }
 
 func (wi WiFiCurrentNetworkResp) GetSSID() string {
@@ -97,6 +97,77 @@ type SplitTunnelStatus struct {
 	// Information about active applications running in Split-Tunnel environment
 	// (applicable for Linux)
 	RunningApps []splittun.RunningApp
+
+	// Destination-based split tunnelling (independent of IsEnabled)
+	Routes           []string // IPv4 networks (CIDR) or domain names routed outside the VPN tunnel
+	IsRoutesInversed bool     // Inverse mode: only 'Routes' destinations use VPN tunnel
+	AppliedRoutes    []string // Routes currently applied to the routing table (e.g. "192.0.2.0/24 via 192.168.1.1")
+
+	// Split Tunnel rules by user account (applicable for Linux; effective when Split Tunnel is enabled)
+	UserRules []splittun.UserRule
+
+	// DNS resolver for the apps in Split Tunnel environment (applicable for Linux; not applicable for Inverse Split Tunnel)
+	DnsPolicy splittun.DnsPolicy
+	DnsCustom string // DNS server defined by user (for splittun.DnsPolicyCustom)
+	DnsServer string // DNS server currently in use by the apps (empty - the apps use the system DNS configuration)
+
+	// "VPN-only" network namespace mode (applicable for Linux and WireGuard)
+	IsNetnsMode bool
+	NetnsName   string // name of the network namespace (e.g. to use with 'ip netns exec')
+}
+
+// SplitTunnelSetRoutes (request) sets the configuration of destination-based split tunnelling
+// Expected response: types.EmptyResp (success)
+type SplitTunnelSetRoutes struct {
+	RequestBase
+	// IPv4 networks in CIDR notation, IPv4 addresses or domain names (resolved by the daemon and refreshed on TTL expiry).
+	// Empty list disables destination-based split tunnelling.
+	Routes []string
+	// false - the destinations are routed outside the VPN tunnel (and allowed by the firewall)
+	// true  - only the destinations use VPN tunnel; all other traffic is routed outside the VPN tunnel (the firewall must be disabled)
+	IsInversed bool
+}
+
+// SplitTunnelSetUserRules (request) sets Split Tunnel rules by user account (Linux).
+// All traffic of the user (UID) or the group (GID) bypasses the VPN tunnel or uses only the VPN tunnel,
+// no matter how its processes start. The rules are effective when Split Tunnel is enabled.
+// Empty list removes all rules.
+// Expected response: types.EmptyResp (success)
+type SplitTunnelSetUserRules struct {
+	RequestBase
+	Rules []splittun.UserRule
+}
+
+// SplitTunnelSetDnsPolicy (request) sets DNS resolver for the apps in Split Tunnel environment (Linux)
+// DNS requests (port 53) of the apps are redirected to the selected DNS server and sent outside the VPN tunnel.
+// Expected response: types.EmptyResp (success)
+type SplitTunnelSetDnsPolicy struct {
+	RequestBase
+	Policy    splittun.DnsPolicy
+	CustomDns string // IPv4 address of DNS server (applicable for splittun.DnsPolicyCustom)
+}
+
+// SplitTunnelSetNetns (request) enables/disables "VPN-only" network namespace mode (Linux, WireGuard).
+// The WireGuard interface is moved to the dedicated network namespace; applications started in the namespace
+// have network access only through the VPN tunnel. The mode can be changed only when VPN is disconnected.
+// Expected response: types.EmptyResp (success)
+type SplitTunnelSetNetns struct {
+	RequestBase
+	IsEnabled bool
+}
+
+// SplitTunnelNetnsExec (request) runs the command in "VPN-only" network namespace.
+// The command is started with the credentials, environment, working directory and standard input/output of the client process.
+// Expected response: types.SplitTunnelNetnsExecResp
+type SplitTunnelNetnsExec struct {
+	RequestBase
+	Args []string // command and its arguments
+}
+
+// SplitTunnelNetnsExecResp (response) contains PID of the process started in the network namespace
+type SplitTunnelNetnsExecResp struct {
+	CommandBase
+	Pid int
 }
 
 // SplitTunnelAddApp (request) add application to SplitTunneling
@@ -119,7 +190,11 @@ type SplitTunnelAddApp struct {
 	RequestBase
 	// Windows: full path to the app binary
 	// Linux: command to be executed in ST environment (e.g. binary + arguments)
+	//        or full path to the app binary (when IsPersistent)
 	Exec string
+	// (applicable for Linux) Add persistent rule by executable path (Exec is a full path to the binary).
+	// All processes started from the binary are added to ST environment automatically; no command execution required.
+	IsPersistent bool
 }
 
 // SplitTunnelAddAppCmdResp (response) contains shell command which have to be executed in user space environment
@@ -152,6 +227,7 @@ type SplitTunnelRemoveApp struct {
 	RequestBase
 	// (applicable for Linux) PID of the running process in ST environment
 	Pid int
-	// (applicable for Windows) full path to the app binary to be excluded from ST
+	// full path to the app binary to be excluded from ST
+	// (Linux: removes persistent rule by executable path; used when Pid is not defined)
 	Exec string
 }
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"path/filepath"
 	"strings"
 
 	"github.com/ivpn/desktop-app/daemon/logger"
@@ -268,6 +269,60 @@ func UpdateDnsIfWrongSettings() error {
 	return implUpdateDnsIfWrongSettings()
 }
 
+// isDnscryptProxyRequired returns 'true' when DNS requests have to be processed by the local dnscrypt-proxy
+// 'isEncryptionRequired' - when the DNS encryption has to be performed by dnscrypt-proxy (e.g. DoH is not supported natively by OS)
+func isDnscryptProxyRequired(dnsCfg DnsSettings, isEncryptionRequired bool) bool {
+	return isEncryptionRequired || isFilterActive(dnsCfg)
+}
+
+// dnscryptProxyFirewallDns returns DNS configuration which must be allowed by firewall when dnscrypt-proxy is in use.
+// For plain DNS the dnscrypt-proxy forwards requests directly to the DNS server (port 53), so it must be allowed by firewall;
+// for encrypted DNS - only the local dnscrypt-proxy (localhost) is in use.
+func dnscryptProxyFirewallDns(originalDnsCfg DnsSettings) DnsSettings {
+	if originalDnsCfg.Encryption == EncryptionNone {
+		return originalDnsCfg
+	}
+	return DnsSettings{DnsHost: "127.0.0.1"}
+}
+
+// dnscryptProxyUpstream returns the stamp of the upstream server for dnscrypt-proxy (empty for plain DNS)
+// and the plain-DNS server to forward all requests to (empty when forwarding is not in use).
+func dnscryptProxyUpstream(dnsCfg DnsSettings) (stampStr string, forwardTo string, err error) {
+	switch dnsCfg.Encryption {
+	case EncryptionDnsOverHttps:
+		stamp := dnscryptproxy.ServerStamp{Proto: dnscryptproxy.StampProtoTypeDoH}
+		//stamp.Props |= dnscryptproxy.ServerInformalPropertyDNSSEC
+		//stamp.Props |= dnscryptproxy.ServerInformalPropertyNoLog
+		//stamp.Props |= dnscryptproxy.ServerInformalPropertyNoFilter
+
+		stamp.ServerAddrStr = dnsCfg.DnsHost
+
+		u, err := url.Parse(dnsCfg.DohTemplate)
+		if err != nil {
+			return "", "", err
+		}
+
+		if u.Scheme != "https" {
+			return "", "", fmt.Errorf("bad template URL scheme: " + u.Scheme)
+		}
+		stamp.ProviderName = u.Host
+		stamp.Path = u.Path
+		return stamp.String(), "", nil
+
+	case EncryptionNone:
+		// Plain DNS is not supported by dnscrypt-proxy as an upstream server:
+		// dnscrypt-proxy runs in offline mode (no remote servers) and all requests are forwarded
+		// to the plain DNS server by the forwarding rule.
+		ip := net.ParseIP(strings.TrimSpace(dnsCfg.DnsHost))
+		if ip == nil {
+			return "", "", fmt.Errorf("bad DNS host '%s'", dnsCfg.DnsHost)
+		}
+		return "", net.JoinHostPort(ip.String(), "53"), nil
+	}
+
+	return "", "", fmt.Errorf("unsupported DNS encryption type")
+}
+
 func dnscryptProxyProcessStart(dnsCfg DnsSettings) (retErr error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -284,10 +339,6 @@ func dnscryptProxyProcessStart(dnsCfg DnsSettings) (retErr error) {
 		}
 	}()
 
-	if dnsCfg.Encryption != EncryptionDnsOverHttps {
-		return fmt.Errorf("unsupported DNS encryption type")
-	}
-
 	binPath, configPathTemplate, configPathMutable, logfile := platform.DnsCryptProxyInfo()
 	if len(binPath) == 0 || len(configPathTemplate) == 0 || len(configPathMutable) == 0 {
 		return fmt.Errorf("configuration not defined")
@@ -295,35 +346,39 @@ func dnscryptProxyProcessStart(dnsCfg DnsSettings) (retErr error) {
 
 	// Configure + start dnscrypt-proxy
 
-	stamp := dnscryptproxy.ServerStamp{Proto: dnscryptproxy.StampProtoTypeDoH}
-	//stamp.Props |= dnscryptproxy.ServerInformalPropertyDNSSEC
-	//stamp.Props |= dnscryptproxy.ServerInformalPropertyNoLog
-	//stamp.Props |= dnscryptproxy.ServerInformalPropertyNoFilter
-
-	stamp.ServerAddrStr = dnsCfg.DnsHost
+	options := filterConfigOptions()
+	options.QueryLogFile = statsQueryLogOption()
 
-	u, err := url.Parse(dnsCfg.DohTemplate)
+	if dnsCfg.Encryption == EncryptionNone && len(options.BlockedNamesFile) == 0 {
+		return fmt.Errorf("local DNS filtering is not active")
+	}
+	stampStr, forwardTo, err := dnscryptProxyUpstream(dnsCfg)
 	if err != nil {
 		return err
 	}
-
-	if u.Scheme != "https" {
-		return fmt.Errorf("bad template URL scheme: " + u.Scheme)
+	if len(forwardTo) > 0 {
+		options.ForwardingRulesFile = filepath.Join(filepath.Dir(configPathMutable), "dnscrypt-proxy-forwarding-rules.txt")
+		if err := dnscryptproxy.SaveForwardingRulesFile(forwardTo, options.ForwardingRulesFile); err != nil {
+			return err
+		}
 	}
-	stamp.ProviderName = u.Host
-	stamp.Path = u.Path
 
 	// generate dnscrypt-proxy configuration
-	if err = dnscryptproxy.SaveConfigFile(stamp.String(), configPathTemplate, configPathMutable); err != nil {
+	if err := dnscryptproxy.SaveConfigFile(stampStr, configPathTemplate, configPathMutable, options); err != nil {
 		return err
 	}
 
-	dnscryptproxy.Init(binPath, configPathMutable, logfile)
+	var queryLogHandler func(line string)
+	if options.QueryLogFile == dnscryptproxy.QueryLogStdout {
+		queryLogHandler = statsProcessQueryLogLine
+	}
+	dnscryptproxy.Init(binPath, configPathMutable, logfile, queryLogHandler)
 
-	if err = dnscryptproxy.Start(); err != nil {
+	if err := dnscryptproxy.Start(); err != nil {
 		dnscryptproxy.Stop()
 		return err
 	}
+	statsSetQueryLogInUse(len(options.QueryLogFile) > 0)
 
 	return nil
 }
@@ -72,11 +72,13 @@ func implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirew
 	}()
 
 	dnscryptproxy.Stop()
-	// start encrypted DNS configuration (if required)
-	if dnsCfg.Encryption != EncryptionNone {
+	dnsForFirewall := dnsCfg
+	// start encrypted DNS configuration or local DNS filtering (if required)
+	if isDnscryptProxyRequired(dnsCfg, dnsCfg.Encryption != EncryptionNone) {
 		if err := dnscryptProxyProcessStart(dnsCfg); err != nil {
 			return DnsSettings{}, err
 		}
+		dnsForFirewall = dnscryptProxyFirewallDns(dnsCfg)
 		// the local DNS must be configured to the dnscrypt-proxy (localhost)
 		dnsCfg = DnsSettings{DnsHost: "127.0.0.1"}
 	}
@@ -86,7 +88,7 @@ func implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirew
 		return DnsSettings{}, fmt.Errorf("set manual DNS: Failed to change DNS: %w", err)
 	}
 
-	return dnsCfg, nil
+	return dnsForFirewall, nil
 }
 
 // DeleteManual - reset manual DNS configuration to default (DHCP)
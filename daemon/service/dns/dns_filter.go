@@ -0,0 +1,226 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns/dnscryptproxy"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+)
+
+// DnsFilterConfig - configuration of the local domain names filtering (custom AntiTracker lists).
+// The filtering is performed by the local dnscrypt-proxy instance,
+// so it works on top of any DNS configuration (IVPN AntiTracker DNS or custom DNS).
+type DnsFilterConfig struct {
+	Enabled bool
+	// Sources of the domain names to block: local file paths or https URLs.
+	// (the content of local files is provided by the client, see SetFilterConfig())
+	// Supported formats: hosts file (e.g. "0.0.0.0 example.com") or plain list of domains (one domain per line)
+	BlockLists []string
+	// Sources of the domain names to allow (the same format as BlockLists).
+	// Allowed names bypass the block lists.
+	AllowLists []string
+}
+
+func (c DnsFilterConfig) Equal(x DnsFilterConfig) bool {
+	return c.Enabled == x.Enabled &&
+		strings.Join(c.BlockLists, "\n") == strings.Join(x.BlockLists, "\n") &&
+		strings.Join(c.AllowLists, "\n") == strings.Join(x.AllowLists, "\n")
+}
+
+// DnsFilterStatus - current state of the local domain names filtering
+type DnsFilterStatus struct {
+	Config            DnsFilterConfig
+	BlockedNamesCount int
+	AllowedNamesCount int
+}
+
+// IsActive returns 'true' when DNS requests have to be filtered locally
+func (s DnsFilterStatus) IsActive() bool {
+	return s.Config.Enabled && s.BlockedNamesCount > 0
+}
+
+var (
+	filterMutex  sync.Mutex
+	filterStatus DnsFilterStatus
+)
+
+// SetFilterConfig - apply configuration of the local domain names filtering.
+// The names from all sources are merged into the names files in use by dnscrypt-proxy.
+// 'isReloadSources':
+//
+//	true  - read (download) all sources again;
+//	false - use previously generated names files (if exist). Useful on daemon start (no network requests).
+//
+// 'localContent' - content of the local source files (source path -> file content) read by the client.
+// The content is saved by the daemon, so the names files can be generated again on daemon start
+// (when 'localContent' is nil, the saved content is in use).
+//
+// Note: the new configuration is taken into account on the next DNS change
+func SetFilterConfig(cfg DnsFilterConfig, localContent map[string][]byte, isReloadSources bool) (status DnsFilterStatus, retErr error) {
+	filterMutex.Lock()
+	defer filterMutex.Unlock()
+
+	blockedFile, allowedFile := filterNamesFiles()
+	if len(blockedFile) == 0 {
+		return DnsFilterStatus{}, fmt.Errorf("dnscrypt-proxy configuration not defined")
+	}
+
+	localContentFile := filterLocalContentFile()
+
+	if !cfg.Enabled {
+		os.Remove(blockedFile)
+		os.Remove(allowedFile)
+		os.Remove(localContentFile)
+		filterStatus = DnsFilterStatus{Config: cfg}
+		return filterStatus, nil
+	}
+
+	if len(cfg.BlockLists) == 0 {
+		return DnsFilterStatus{}, fmt.Errorf("no block lists defined")
+	}
+
+	status = DnsFilterStatus{Config: cfg}
+	if !isReloadSources {
+		// use previously generated files
+		blockedCnt, errB := dnscryptproxy.CountNamesFile(blockedFile)
+		allowedCnt, errA := dnscryptproxy.CountNamesFile(allowedFile)
+		if errB == nil && (errA == nil || len(cfg.AllowLists) == 0) {
+			status.BlockedNamesCount = blockedCnt
+			status.AllowedNamesCount = allowedCnt
+			filterStatus = status
+			return filterStatus, nil
+		}
+	}
+
+	isLocalContentProvided := localContent != nil
+	if !isLocalContentProvided {
+		localContent = loadFilterLocalContent(localContentFile)
+	}
+	// keep only the content of the local sources in use
+	usedLocalContent := make(map[string][]byte)
+	for _, src := range append(append([]string{}, cfg.BlockLists...), cfg.AllowLists...) {
+		if dnscryptproxy.IsNamesSourceURL(src) {
+			continue
+		}
+		content, ok := localContent[src]
+		if !ok {
+			return DnsFilterStatus{}, fmt.Errorf("content of the local list '%s' is not available (the lists must be applied again by the client)", src)
+		}
+		usedLocalContent[src] = content
+	}
+
+	var err error
+	if status.BlockedNamesCount, err = dnscryptproxy.SaveNamesFile(cfg.BlockLists, localContent, blockedFile); err != nil {
+		return DnsFilterStatus{}, fmt.Errorf("failed to prepare block lists: %w", err)
+	}
+	if status.AllowedNamesCount, err = dnscryptproxy.SaveNamesFile(cfg.AllowLists, localContent, allowedFile); err != nil {
+		return DnsFilterStatus{}, fmt.Errorf("failed to prepare allow lists: %w", err)
+	}
+	if isLocalContentProvided {
+		if err := saveFilterLocalContent(localContentFile, usedLocalContent); err != nil {
+			log.Warning(fmt.Sprintf("failed to save content of the local lists: %v", err))
+		}
+	}
+
+	log.Info(fmt.Sprintf("Local DNS filtering configured (blocked names: %d; allowed names: %d)", status.BlockedNamesCount, status.AllowedNamesCount))
+	filterStatus = status
+	return filterStatus, nil
+}
+
+// GetFilterStatus - returns current state of the local domain names filtering
+func GetFilterStatus() DnsFilterStatus {
+	filterMutex.Lock()
+	defer filterMutex.Unlock()
+	return filterStatus
+}
+
+// isFilterActive returns 'true' when DNS requests have to be processed by the local dnscrypt-proxy to be filtered
+func isFilterActive(dnsCfg DnsSettings) bool {
+	return !dnsCfg.IsEmpty() && GetFilterStatus().IsActive()
+}
+
+// filterConfigOptions - returns dnscrypt-proxy configuration options for the local domain names filtering
+func filterConfigOptions() dnscryptproxy.ConfigOptions {
+	status := GetFilterStatus()
+	if !status.IsActive() {
+		return dnscryptproxy.ConfigOptions{}
+	}
+	blockedFile, allowedFile := filterNamesFiles()
+	ret := dnscryptproxy.ConfigOptions{BlockedNamesFile: blockedFile}
+	if status.AllowedNamesCount > 0 {
+		ret.AllowedNamesFile = allowedFile
+	}
+	return ret
+}
+
+// filterNamesFiles - paths to the names files (located in the same folder as dnscrypt-proxy mutable configuration)
+func filterNamesFiles() (blockedNamesFile, allowedNamesFile string) {
+	_, _, configPathMutable, _ := platform.DnsCryptProxyInfo()
+	if len(configPathMutable) == 0 {
+		return "", ""
+	}
+	dir := filepath.Dir(configPathMutable)
+	return filepath.Join(dir, "dnscrypt-proxy-blocked-names.txt"), filepath.Join(dir, "dnscrypt-proxy-allowed-names.txt")
+}
+
+// filterLocalContentFile returns path to the file with saved content of the local sources (see SetFilterConfig())
+func filterLocalContentFile() string {
+	_, _, configPathMutable, _ := platform.DnsCryptProxyInfo()
+	if len(configPathMutable) == 0 {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPathMutable), "dnscrypt-proxy-local-lists.json")
+}
+
+func saveFilterLocalContent(file string, localContent map[string][]byte) error {
+	if len(localContent) == 0 {
+		os.Remove(file)
+		return nil
+	}
+	data, err := json.Marshal(localContent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600) // read only for owner
+}
+
+// loadFilterLocalContent returns saved content of the local sources (empty map when not available)
+func loadFilterLocalContent(file string) map[string][]byte {
+	ret := make(map[string][]byte)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ret
+	}
+	if err := json.Unmarshal(data, &ret); err != nil {
+		log.Warning(fmt.Sprintf("failed to parse saved content of the local lists: %v", err))
+		return make(map[string][]byte)
+	}
+	return ret
+}
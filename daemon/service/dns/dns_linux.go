@@ -159,13 +159,17 @@ func implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirew
 		return dnsCfg, nil
 	}
 
-	// start encrypted DNS configuration (if required)
-	if !dnsCfg.IsEmpty() && dnsCfg.Encryption != EncryptionNone {
+	// start encrypted DNS configuration or local DNS filtering (if required)
+	if !dnsCfg.IsEmpty() && isDnscryptProxyRequired(dnsCfg, dnsCfg.Encryption != EncryptionNone) {
 		if err := dnscryptProxyProcessStart(dnsCfg); err != nil {
 			return DnsSettings{}, err
 		}
+		dnsForFirewall := dnscryptProxyFirewallDns(dnsCfg)
 		// the local DNS must be configured to the dnscrypt-proxy (localhost)
-		dnsCfg = DnsSettings{DnsHost: "127.0.0.1"}
+		if _, err := f_implSetManual(DnsSettings{DnsHost: "127.0.0.1"}, localInterfaceIP); err != nil {
+			return DnsSettings{}, err
+		}
+		return dnsForFirewall, nil
 	}
 
 	return f_implSetManual(dnsCfg, localInterfaceIP)
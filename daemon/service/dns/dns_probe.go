@@ -0,0 +1,343 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// DnsTestDefaultProbeName - domain name to resolve when testing DNS configuration
+	DnsTestDefaultProbeName = "www.ivpn.net"
+	// DnsTestDefaultTimeout - default timeout for DNS configuration test
+	DnsTestDefaultTimeout = 5 * time.Second
+	// DnsTestMaxTimeout - maximum allowed timeout for DNS configuration test
+	DnsTestMaxTimeout = 30 * time.Second
+
+	dnsPortPlain = "53"
+	dnsPortDoT   = "853"
+	dnsPortDoH   = "443"
+)
+
+// EncryptedServerPort returns TCP port of the encrypted DNS server (DoH/DoT); 0 - for plain DNS or bad configuration
+func EncryptedServerPort(dnsCfg DnsSettings) int {
+	port := ""
+	switch dnsCfg.Encryption {
+	case EncryptionDnsOverTls:
+		port = dnsPortDoT
+	case EncryptionDnsOverHttps:
+		u, err := url.Parse(strings.TrimSpace(dnsCfg.DohTemplate))
+		if err != nil {
+			return 0
+		}
+		if port = u.Port(); len(port) == 0 {
+			port = dnsPortDoH
+		}
+	}
+	ret, _ := strconv.Atoi(port)
+	return ret
+}
+
+// DnsTestResult - result of the DNS configuration test
+type DnsTestResult struct {
+	IsOk bool
+	// The test was not performed (e.g. the request would be blocked by the firewall); the reason is in Error
+	IsSkipped   bool
+	LatencyMs   int64    // time spent to resolve the probe name
+	ProbeName   string   // domain name which was resolved
+	ResolvedIPs []string // resolved addresses of the probe name
+	// TLS certificate details (DoH/DoT)
+	TlsServerName  string
+	TlsCertSubject string
+	TlsCertIssuer  string
+	// Error details (empty when IsOk)
+	Error string
+}
+
+// TestConfig - validate DNS configuration by resolving probe name through the DNS server (plain, DoH or DoT).
+// For encrypted DNS, the TLS certificate of the server is verified against the host name from the template.
+// Note: the request is sent directly to the DNS server (not through the local DNS configuration),
+// so it can be blocked by the firewall (e.g. plain DNS requests to non-VPN DNS servers)
+func TestConfig(dnsCfg DnsSettings, probeName string, timeout time.Duration) (ret DnsTestResult) {
+	probeName = strings.TrimSpace(probeName)
+	if len(probeName) == 0 {
+		probeName = DnsTestDefaultProbeName
+	}
+	if timeout <= 0 {
+		timeout = DnsTestDefaultTimeout
+	} else if timeout > DnsTestMaxTimeout {
+		timeout = DnsTestMaxTimeout
+	}
+	ret.ProbeName = probeName
+
+	defer func() {
+		if len(ret.Error) > 0 {
+			log.Info(fmt.Sprintf("DNS test failed (%s): %s", dnsCfg.InfoString(), ret.Error))
+		} else {
+			log.Info(fmt.Sprintf("DNS test passed (%s): %dms", dnsCfg.InfoString(), ret.LatencyMs))
+		}
+	}()
+
+	if dnsCfg.IsEmpty() {
+		ret.Error = "DNS server address not defined"
+		return ret
+	}
+
+	query, queryId, err := probeBuildQuery(probeName)
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var response []byte
+	var certState *tls.ConnectionState
+	start := time.Now()
+
+	ret.TlsServerName, response, certState, err = probeExchange(ctx, dnsCfg, query)
+	ret.LatencyMs = time.Since(start).Milliseconds()
+
+	if certState != nil && len(certState.PeerCertificates) > 0 {
+		ret.TlsCertSubject = certState.PeerCertificates[0].Subject.String()
+		ret.TlsCertIssuer = certState.PeerCertificates[0].Issuer.String()
+	}
+
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+
+	if ret.ResolvedIPs, _, err = probeParseResponse(response, queryId); err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+
+	ret.IsOk = true
+	return ret
+}
+
+func probeBuildQuery(probeName string) (query []byte, id uint16, err error) {
+	return probeBuildQueryType(probeName, dnsmessage.TypeA)
+}
+
+func probeBuildQueryType(probeName string, qType dnsmessage.Type) (query []byte, id uint16, err error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(probeName, ".") + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad probe name '%s': %w", probeName, err)
+	}
+
+	id = uint16(rand.Intn(0xffff))
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qType, Class: dnsmessage.ClassINET}},
+	}
+	query, err = msg.Pack()
+	return query, id, err
+}
+
+// probeParseResponse returns addresses from the DNS response and the minimal TTL of the address records (in seconds)
+func probeParseResponse(response []byte, queryId uint16) (ips []string, ttl uint32, err error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(response); err != nil {
+		return nil, 0, fmt.Errorf("bad DNS response: %w", err)
+	}
+	if msg.Header.ID != queryId {
+		return nil, 0, fmt.Errorf("bad DNS response: unexpected message ID")
+	}
+	if msg.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("DNS server responded with error: %s", msg.Header.RCode.String())
+	}
+
+	for _, a := range msg.Answers {
+		switch r := a.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(r.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(r.AAAA[:]).String())
+		default:
+			continue
+		}
+		if len(ips) == 1 || a.Header.TTL < ttl {
+			ttl = a.Header.TTL
+		}
+	}
+	if len(ips) == 0 {
+		return nil, 0, fmt.Errorf("DNS server returned no addresses")
+	}
+	return ips, ttl, nil
+}
+
+// probeExchange sends the query directly to the DNS server (plain, DoH or DoT) and returns the response
+func probeExchange(ctx context.Context, dnsCfg DnsSettings, query []byte) (tlsServerName string, response []byte, certState *tls.ConnectionState, err error) {
+	switch dnsCfg.Encryption {
+	case EncryptionNone:
+		response, err = probeExchangePlain(ctx, dnsCfg.DnsHost, query)
+		return "", response, nil, err
+	case EncryptionDnsOverHttps:
+		return probeExchangeDoH(ctx, dnsCfg, query)
+	case EncryptionDnsOverTls:
+		return probeExchangeDoT(ctx, dnsCfg, query)
+	default:
+		return "", nil, nil, fmt.Errorf("unsupported DNS encryption type")
+	}
+}
+
+func probeExchangePlain(ctx context.Context, host string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(host, dnsPortPlain))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func probeExchangeDoH(ctx context.Context, dnsCfg DnsSettings, query []byte) (tlsServerName string, response []byte, certState *tls.ConnectionState, err error) {
+	u, err := url.Parse(strings.TrimSpace(dnsCfg.DohTemplate))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("bad DoH template: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", nil, nil, fmt.Errorf("bad DoH template: unexpected URL scheme '%s' (expected 'https')", u.Scheme)
+	}
+	if len(u.Hostname()) == 0 {
+		return "", nil, nil, fmt.Errorf("bad DoH template: host not defined")
+	}
+	if len(strings.Trim(u.Path, "/")) == 0 {
+		return "", nil, nil, fmt.Errorf("bad DoH template: path not defined (e.g. '/dns-query')")
+	}
+	tlsServerName = u.Hostname()
+	port := u.Port()
+	if len(port) == 0 {
+		port = dnsPortDoH
+	}
+
+	// connect directly to the DNS server IP (do not resolve the template host name)
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(dnsCfg.DnsHost, port))
+		},
+		TLSClientConfig:   &tls.Config{ServerName: tlsServerName},
+		ForceAttemptHTTP2: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(query))
+	if err != nil {
+		return tlsServerName, nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return tlsServerName, nil, nil, err
+	}
+	defer resp.Body.Close()
+	certState = resp.TLS
+
+	if resp.StatusCode != http.StatusOK {
+		return tlsServerName, nil, certState, fmt.Errorf("DoH server responded with HTTP status: %s (check the template path)", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/dns-message") {
+		return tlsServerName, nil, certState, fmt.Errorf("unexpected DoH response content type: '%s' (check the template path)", ct)
+	}
+
+	response, err = io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return tlsServerName, response, certState, err
+}
+
+func probeExchangeDoT(ctx context.Context, dnsCfg DnsSettings, query []byte) (tlsServerName string, response []byte, certState *tls.ConnectionState, err error) {
+	// DoT template can be a host name or URI (e.g. 'tls://dns.example.com')
+	tlsServerName = strings.TrimSpace(dnsCfg.DohTemplate)
+	if strings.Contains(tlsServerName, "://") {
+		u, err := url.Parse(tlsServerName)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("bad DoT template: %w", err)
+		}
+		tlsServerName = u.Hostname()
+	}
+	if len(tlsServerName) == 0 {
+		return "", nil, nil, fmt.Errorf("bad DoT template: host not defined")
+	}
+
+	d := tls.Dialer{Config: &tls.Config{ServerName: tlsServerName}}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(dnsCfg.DnsHost, dnsPortDoT))
+	if err != nil {
+		return tlsServerName, nil, nil, err
+	}
+	defer conn.Close()
+	tlsConn := conn.(*tls.Conn)
+	state := tlsConn.ConnectionState()
+	certState = &state
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// DNS over TCP: message is prefixed with two-byte length field
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return tlsServerName, nil, certState, err
+	}
+
+	lenBuf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, lenBuf); err != nil {
+		return tlsServerName, nil, certState, err
+	}
+	response = make([]byte, binary.BigEndian.Uint16(lenBuf))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return tlsServerName, nil, certState, err
+	}
+	return tlsServerName, response, certState, nil
+}
@@ -0,0 +1,104 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ResolveHost resolves addresses of the host using the DNS server (plain, DoH or DoT).
+// IPv4 addresses are always requested; IPv6 addresses (AAAA records) are requested only when 'withIPv6' is true.
+// The request is sent directly to the DNS server, bypassing the system DNS configuration.
+// Returns resolved addresses and the time-to-live of the DNS records.
+func ResolveHost(dnsCfg DnsSettings, host string, timeout time.Duration, withIPv6 bool) (ips []net.IP, ttl time.Duration, err error) {
+	host = strings.TrimSpace(host)
+	if dnsCfg.IsEmpty() {
+		return nil, 0, fmt.Errorf("DNS server address not defined")
+	}
+	if timeout <= 0 {
+		timeout = DnsTestDefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ips, ttl, err = resolveHostType(ctx, dnsCfg, host, dnsmessage.TypeA)
+	if err != nil {
+		err = fmt.Errorf("failed to resolve '%s' (%s): %w", host, dnsCfg.InfoString(), err)
+	}
+
+	if withIPv6 {
+		ips6, ttl6, err6 := resolveHostType(ctx, dnsCfg, host, dnsmessage.TypeAAAA)
+		if err6 == nil && len(ips6) > 0 {
+			if len(ips) == 0 || ttl6 < ttl {
+				ttl = ttl6
+			}
+			ips = append(ips, ips6...)
+			err = nil
+		}
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(ips) == 0 {
+		return nil, 0, fmt.Errorf("failed to resolve '%s' (%s): no addresses", host, dnsCfg.InfoString())
+	}
+	return ips, ttl, nil
+}
+
+// resolveHostType returns addresses of the requested type (A or AAAA) from the DNS server response
+func resolveHostType(ctx context.Context, dnsCfg DnsSettings, host string, qType dnsmessage.Type) (ips []net.IP, ttl time.Duration, err error) {
+	query, queryId, err := probeBuildQueryType(host, qType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, response, _, err := probeExchange(ctx, dnsCfg, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ipsStr, ttlSec, err := probeParseResponse(response, queryId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, ipStr := range ipsStr {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			continue
+		}
+		if isIPv4 := ip.To4() != nil; isIPv4 != (qType == dnsmessage.TypeA) {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return ips, time.Duration(ttlSec) * time.Second, nil
+}
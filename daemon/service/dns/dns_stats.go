@@ -0,0 +1,186 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns/dnscryptproxy"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+)
+
+const (
+	statsProcessInterval = 30 * time.Second
+	// the query log file is truncated when its size exceeds this limit
+	statsQueryLogMaxSize = 1024 * 1024
+)
+
+// DnsStatsConfig - configuration of DNS query statistics.
+// The statistics is collected from the query log of the local dnscrypt-proxy
+// (so it is available only when dnscrypt-proxy is in use: encrypted DNS or custom AntiTracker lists)
+type DnsStatsConfig struct {
+	Enabled bool
+	// Strict privacy: domain names are not collected (only counters) and the query log is not written to disk
+	// (the records are received from the standard output of dnscrypt-proxy and processed in memory).
+	// Statistics is not available in this mode when dnscrypt-proxy is running as a service (Windows).
+	StrictPrivacy bool
+}
+
+// DnsStats - DNS query statistics
+type DnsStats struct {
+	Config DnsStatsConfig
+	// IsActive is 'true' when query logging is active (dnscrypt-proxy is running with query log enabled)
+	IsActive bool
+	Stats    dnscryptproxy.QueryStats
+}
+
+var (
+	statsMutex           sync.Mutex
+	statsConfig          DnsStatsConfig
+	statsReader          *dnscryptproxy.QueryLogReader
+	statsStopChan        chan struct{}
+	statsIsQueryLogInUse bool // query log enabled for currently running dnscrypt-proxy
+)
+
+// SetStatsConfig - apply configuration of DNS query statistics.
+// Collected statistics is erased when configuration changed.
+// Note: the new configuration is taken into account on the next DNS change
+func SetStatsConfig(cfg DnsStatsConfig) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	if cfg == statsConfig && (statsStopChan != nil) == cfg.Enabled {
+		return
+	}
+
+	if statsStopChan != nil {
+		close(statsStopChan)
+		statsStopChan = nil
+	}
+
+	statsConfig = cfg
+	statsReader = nil
+
+	logFile := statsQueryLogFile()
+	if len(logFile) == 0 {
+		return
+	}
+	// erase old query log data
+	os.Remove(logFile)
+
+	if !cfg.Enabled {
+		return
+	}
+
+	statsReader = dnscryptproxy.NewQueryLogReader(logFile, cfg.StrictPrivacy)
+	statsStopChan = make(chan struct{})
+	if !cfg.StrictPrivacy {
+		// strict privacy: the records are processed on receiving (see statsProcessQueryLogLine())
+		go statsProcessingLoop(statsStopChan, statsProcessInterval)
+	}
+}
+
+// GetStats - returns DNS query statistics
+func GetStats() DnsStats {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	ret := DnsStats{Config: statsConfig, IsActive: statsIsQueryLogInUse && dnscryptproxy.IsRunning()}
+	if statsReader == nil {
+		return ret
+	}
+
+	statsProcess()
+	ret.Stats = statsReader.Stats()
+	return ret
+}
+
+// statsQueryLogOption returns path to the query log file which must be configured for dnscrypt-proxy (empty if not required)
+func statsQueryLogOption() string {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	if !statsConfig.Enabled {
+		return ""
+	}
+	if statsConfig.StrictPrivacy {
+		if !dnscryptproxy.IsQueryLogStdoutSupported() {
+			return ""
+		}
+		return dnscryptproxy.QueryLogStdout
+	}
+	return statsQueryLogFile()
+}
+
+// statsProcessQueryLogLine processes query log record received from the standard output of dnscrypt-proxy
+func statsProcessQueryLogLine(line string) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	if statsReader != nil && statsConfig.StrictPrivacy {
+		statsReader.ProcessLine(line)
+	}
+}
+
+func statsSetQueryLogInUse(isInUse bool) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	statsIsQueryLogInUse = isInUse
+}
+
+func statsProcessingLoop(stopChan chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			statsMutex.Lock()
+			statsProcess()
+			statsMutex.Unlock()
+		}
+	}
+}
+
+// statsProcess - process new query log records (statsMutex must be locked)
+func statsProcess() {
+	if statsReader == nil || statsConfig.StrictPrivacy {
+		return
+	}
+	if err := statsReader.Process(false, statsQueryLogMaxSize); err != nil {
+		log.Warning("failed to process dnscrypt-proxy query log: ", err)
+	}
+}
+
+func statsQueryLogFile() string {
+	_, _, configPathMutable, _ := platform.DnsCryptProxyInfo()
+	if len(configPathMutable) == 0 {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPathMutable), "dnscrypt-proxy-query.log")
+}
@@ -0,0 +1,135 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns/dnscryptproxy"
+)
+
+func TestDnscryptProxyUpstream(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           DnsSettings
+		wantErr       bool
+		wantAddr      string
+		wantProvider  string
+		wantForwardTo string
+	}{
+		{
+			name:          "plain DNS IPv4",
+			cfg:           DnsSettings{DnsHost: " 10.0.254.1 ", Encryption: EncryptionNone},
+			wantForwardTo: "10.0.254.1:53",
+		},
+		{
+			name:          "plain DNS IPv6",
+			cfg:           DnsSettings{DnsHost: "2001:db8::1", Encryption: EncryptionNone},
+			wantForwardTo: "[2001:db8::1]:53",
+		},
+		{
+			name:         "DoH",
+			cfg:          DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionDnsOverHttps, DohTemplate: "https://dns.quad9.net/dns-query"},
+			wantAddr:     "9.9.9.9:443",
+			wantProvider: "dns.quad9.net",
+		},
+		{name: "plain DNS bad host", cfg: DnsSettings{DnsHost: "dns.example.com", Encryption: EncryptionNone}, wantErr: true},
+		{name: "DoH bad scheme", cfg: DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionDnsOverHttps, DohTemplate: "http://dns.quad9.net/dns-query"}, wantErr: true},
+		{name: "DoT", cfg: DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionDnsOverTls}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stampStr, forwardTo, err := dnscryptProxyUpstream(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("error expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if forwardTo != tt.wantForwardTo {
+				t.Errorf("unexpected forwarding server: '%s'", forwardTo)
+			}
+			if len(tt.wantAddr) == 0 {
+				// plain DNS: no remote server in use (dnscrypt-proxy in offline mode)
+				if len(stampStr) > 0 {
+					t.Errorf("unexpected stamp: '%s'", stampStr)
+				}
+				return
+			}
+			stamp, err := dnscryptproxy.NewServerStampFromString(stampStr)
+			if err != nil {
+				t.Fatalf("bad stamp '%s': %v", stampStr, err)
+			}
+			if stamp.Proto != dnscryptproxy.StampProtoTypeDoH || stamp.ServerAddrStr != tt.wantAddr || stamp.ProviderName != tt.wantProvider {
+				t.Errorf("unexpected stamp: %+v", stamp)
+			}
+		})
+	}
+}
+
+func TestEncryptedServerPort(t *testing.T) {
+	tests := []struct {
+		cfg  DnsSettings
+		want int
+	}{
+		{DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionDnsOverHttps, DohTemplate: "https://dns.quad9.net/dns-query"}, 443},
+		{DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionDnsOverHttps, DohTemplate: "https://dns.example.com:8443/dns-query"}, 8443},
+		{DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionDnsOverTls, DohTemplate: "dns.quad9.net"}, 853},
+		{DnsSettings{DnsHost: "9.9.9.9", Encryption: EncryptionNone}, 0},
+	}
+	for _, tt := range tests {
+		if got := EncryptedServerPort(tt.cfg); got != tt.want {
+			t.Errorf("EncryptedServerPort(%+v) = %d; expected %d", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestFilterLocalContent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "local-lists.json")
+
+	if content := loadFilterLocalContent(file); content == nil || len(content) != 0 {
+		t.Errorf("unexpected content when nothing saved: %v", content)
+	}
+
+	content := map[string][]byte{"/home/user/block.txt": []byte("0.0.0.0 ads.example.com\n"), "/home/user/allow.txt": []byte("example.com\n")}
+	if err := saveFilterLocalContent(file, content); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loadFilterLocalContent(file); !reflect.DeepEqual(loaded, content) {
+		t.Errorf("unexpected loaded content: %v", loaded)
+	}
+
+	// saving empty content removes the file
+	if err := saveFilterLocalContent(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loadFilterLocalContent(file); len(loaded) != 0 {
+		t.Errorf("unexpected loaded content: %v", loaded)
+	}
+}
@@ -204,11 +204,13 @@ func implSetManual(dnsCfg DnsSettings, localVpnInterfaceIP net.IP) (dnsInfoForFi
 	var notVpnInterfacesToUpdate []net.IPNet
 	var err error
 
-	// start encrypted DNS configuration (if required)
-	if dnsCfg.Encryption != EncryptionNone && !fIsCanUseNativeDnsOverHttps() {
+	dnsForFirewall := dnsCfg
+	// start encrypted DNS configuration or local DNS filtering (if required)
+	if isDnscryptProxyRequired(dnsCfg, dnsCfg.Encryption != EncryptionNone && !fIsCanUseNativeDnsOverHttps()) {
 		if err := dnscryptProxyProcessStart(dnsCfg); err != nil {
 			return DnsSettings{}, err
 		}
+		dnsForFirewall = dnscryptProxyFirewallDns(dnsCfg)
 		// the local DNS must be configured to the dnscrypt-proxy (localhost)
 		dnsCfg = DnsSettings{DnsHost: "127.0.0.1"}
 	} else {
@@ -270,6 +272,9 @@ func implSetManual(dnsCfg DnsSettings, localVpnInterfaceIP net.IP) (dnsInfoForFi
 	// save last changed DNS address
 	_lastDNS = dnsCfg
 
+	if _lastDNS.Ip().Equal(net.ParseIP("127.0.0.1")) {
+		return dnsForFirewall, retErr
+	}
 	return _lastDNS, retErr
 }
 
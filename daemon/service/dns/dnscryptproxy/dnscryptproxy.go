@@ -45,18 +45,26 @@ var (
 	_dnsCryptObj      iDnsCryptProxy = nil
 )
 
-func Init(theBinaryPath, configFilePath, logFilePath string) error {
+// Init initializes dnscrypt-proxy object.
+// 'queryLogHandler' - (optional) receives query log records written to the standard output (see QueryLogStdout)
+func Init(theBinaryPath, configFilePath, logFilePath string, queryLogHandler func(line string)) error {
 	if err := Stop(); err != nil {
 		return err
 	}
 
 	_dnsCryptObjMutex.Lock()
 	defer _dnsCryptObjMutex.Unlock()
-	_dnsCryptObj = implInit(theBinaryPath, configFilePath, logFilePath)
+	_dnsCryptObj = implInit(theBinaryPath, configFilePath, logFilePath, queryLogHandler)
 
 	return nil
 }
 
+// IsQueryLogStdoutSupported returns 'true' when query log records can be received from the standard output
+// of dnscrypt-proxy (see QueryLogStdout). Not supported when dnscrypt-proxy is running as a service (Windows).
+func IsQueryLogStdoutSupported() bool {
+	return implIsQueryLogStdoutSupported()
+}
+
 // Start - asynchronously start
 func Start() (err error) {
 	_dnsCryptObjMutex.Lock()
@@ -95,3 +103,10 @@ func Stop() (err error) {
 	}
 	return ret
 }
+
+// IsRunning returns 'true' when dnscrypt-proxy is initialized and not stopped
+func IsRunning() bool {
+	_dnsCryptObjMutex.Lock()
+	defer _dnsCryptObjMutex.Unlock()
+	return _dnsCryptObj != nil
+}
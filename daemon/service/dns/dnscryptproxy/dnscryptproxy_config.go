@@ -30,10 +30,30 @@ import (
 
 const configSvrName = "ivpnmanualconfig"
 
+// ConfigOptions - optional dnscrypt-proxy configuration parameters
+type ConfigOptions struct {
+	// Path to the file with blocked domain names (empty - names blocking disabled)
+	BlockedNamesFile string
+	// Path to the file with allowed domain names (empty - no allow-list)
+	AllowedNamesFile string
+	// Path to the forwarding rules file (empty - forwarding not in use)
+	// It is in use to forward all requests to plain-DNS upstream server (which is not supported as a 'static' server).
+	// When the server stamp is not defined, dnscrypt-proxy runs in offline mode (only forwarding rules are in use).
+	ForwardingRulesFile string
+	// Path to the query log file (empty - query logging disabled)
+	QueryLogFile string
+}
+
 // SaveConfigFile - update template file 'configFileTemplate's with required data
 // and save result into 'configFileOut'
 // The implementation is very simple and based in replacing specific lines in template.
-func SaveConfigFile(dnsSvrStamp, configFileTemplate, configFileOut string) error {
+// 'dnsSvrStamp' can be empty only when 'options.ForwardingRulesFile' defined (offline mode: no remote servers in use).
+func SaveConfigFile(dnsSvrStamp, configFileTemplate, configFileOut string, options ConfigOptions) error {
+	isOfflineMode := len(dnsSvrStamp) == 0
+	if isOfflineMode && len(options.ForwardingRulesFile) == 0 {
+		return fmt.Errorf("DNS server stamp not defined")
+	}
+
 	if _, err := os.Stat(configFileTemplate); err != nil {
 		return err
 	}
@@ -48,25 +68,68 @@ func SaveConfigFile(dnsSvrStamp, configFileTemplate, configFileOut string) error
 	isUpdated_server_names := false
 	isUpdated_static_myserver := false
 	isUpdated_stamp := false
+	isUpdated_blocked_names := false
+	isUpdated_allowed_names := false
+	isUpdated_forwarding_rules := false
+	isUpdated_query_log := false
+	isUpdated_offline_mode := false
 
+	section := ""
 	for i, line := range lines {
 		line = strings.TrimSpace(line)
 
-		if strings.HasPrefix(line, "# server_names = ") {
+		if strings.HasPrefix(line, "[") {
+			section = line
+		}
+
+		if isOfflineMode {
+			if strings.HasPrefix(line, "# offline_mode = ") {
+				lines[i] = "offline_mode = true"
+				isUpdated_offline_mode = true
+				continue
+			}
+		} else if strings.HasPrefix(line, "# server_names = ") {
 			lines[i] = fmt.Sprintf("server_names = ['%s']", configSvrName)
 			isUpdated_server_names = true
+			continue
 		} else if strings.HasPrefix(line, "# [static.'myserver']") {
 			lines[i] = fmt.Sprintf("[static.'%s']", configSvrName)
 			isUpdated_static_myserver = true
+			continue
 		} else if strings.HasPrefix(line, "#") && strings.Contains(line, "stamp =") {
 			lines[i] = fmt.Sprintf("stamp = '%s'", dnsSvrStamp)
 			isUpdated_stamp = true
+			continue
+		}
+
+		if len(options.BlockedNamesFile) > 0 && strings.HasPrefix(line, "# blocked_names_file = ") {
+			lines[i] = fmt.Sprintf("blocked_names_file = '%s'", options.BlockedNamesFile)
+			isUpdated_blocked_names = true
+		} else if len(options.AllowedNamesFile) > 0 && strings.HasPrefix(line, "# allowed_names_file = ") {
+			lines[i] = fmt.Sprintf("allowed_names_file = '%s'", options.AllowedNamesFile)
+			isUpdated_allowed_names = true
+		} else if len(options.ForwardingRulesFile) > 0 && strings.HasPrefix(line, "# forwarding_rules = ") {
+			lines[i] = fmt.Sprintf("forwarding_rules = '%s'", options.ForwardingRulesFile)
+			isUpdated_forwarding_rules = true
+		} else if len(options.QueryLogFile) > 0 && section == "[query_log]" && strings.HasPrefix(line, "# file = ") {
+			lines[i] = fmt.Sprintf("file = '%s'", options.QueryLogFile)
+			isUpdated_query_log = true
 		}
 	}
 
-	if !isUpdated_server_names || !isUpdated_static_myserver || !isUpdated_stamp {
+	if isOfflineMode {
+		if !isUpdated_offline_mode {
+			return fmt.Errorf("failed to update configuration from template file")
+		}
+	} else if !isUpdated_server_names || !isUpdated_static_myserver || !isUpdated_stamp {
 		return fmt.Errorf("failed to update configuration from template file")
 	}
+	if (len(options.BlockedNamesFile) > 0 && !isUpdated_blocked_names) ||
+		(len(options.AllowedNamesFile) > 0 && !isUpdated_allowed_names) ||
+		(len(options.ForwardingRulesFile) > 0 && !isUpdated_forwarding_rules) ||
+		(len(options.QueryLogFile) > 0 && !isUpdated_query_log) {
+		return fmt.Errorf("failed to update configuration from template file (extra options)")
+	}
 
 	output := strings.Join(lines, "\n")
 	err = os.WriteFile(configFileOut, []byte(output), 0600) // read only for owner
@@ -76,3 +139,14 @@ func SaveConfigFile(dnsSvrStamp, configFileTemplate, configFileOut string) error
 
 	return nil
 }
+
+// SaveForwardingRulesFile - save forwarding rules file which forwards all DNS requests to the plain-DNS server 'dnsHost'
+// ('dnsHost' format: IP address with optional port, e.g. "10.0.254.1", "10.0.254.1:53", "[2001:db8::1]:53")
+func SaveForwardingRulesFile(dnsHost, outFile string) error {
+	if len(strings.TrimSpace(dnsHost)) == 0 {
+		return fmt.Errorf("DNS host not defined")
+	}
+	// '.' - matches all domains
+	rules := fmt.Sprintf(". %s\n", strings.TrimSpace(dnsHost))
+	return os.WriteFile(outFile, []byte(rules), 0600)
+}
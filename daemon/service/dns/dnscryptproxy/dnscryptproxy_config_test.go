@@ -0,0 +1,98 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dnscryptproxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfigTemplate = "../../../References/common/etc/dnscrypt-proxy-template.toml"
+
+func TestSaveConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "dnscrypt-proxy.toml")
+	rulesFile := filepath.Join(dir, "forwarding-rules.txt")
+
+	if err := SaveForwardingRulesFile(" 10.0.254.1 ", rulesFile); err != nil {
+		t.Fatal(err)
+	}
+	if rules, err := os.ReadFile(rulesFile); err != nil || string(rules) != ". 10.0.254.1\n" {
+		t.Errorf("unexpected forwarding rules: '%s' (%v)", rules, err)
+	}
+
+	stamp := ServerStamp{Proto: StampProtoTypeDoH, ServerAddrStr: "9.9.9.9", ProviderName: "dns.quad9.net", Path: "/dns-query"}
+	options := ConfigOptions{BlockedNamesFile: filepath.Join(dir, "blocked.txt")}
+	if err := SaveConfigFile(stamp.String(), testConfigTemplate, out, options); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := string(data)
+	for _, expected := range []string{
+		"server_names = ['" + configSvrName + "']",
+		"[static.'" + configSvrName + "']",
+		"stamp = '" + stamp.String() + "'",
+		"blocked_names_file = '" + options.BlockedNamesFile + "'",
+	} {
+		if !strings.Contains(cfg, "\n"+expected+"\n") {
+			t.Errorf("configuration does not contain: %s", expected)
+		}
+	}
+
+	// plain-DNS upstream: offline mode, all requests are forwarded
+	options = ConfigOptions{BlockedNamesFile: filepath.Join(dir, "blocked.txt"), ForwardingRulesFile: rulesFile}
+	if err := SaveConfigFile("", testConfigTemplate, out, options); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(out); err != nil {
+		t.Fatal(err)
+	}
+	cfg = string(data)
+	for _, expected := range []string{
+		"offline_mode = true",
+		"forwarding_rules = '" + rulesFile + "'",
+		"blocked_names_file = '" + options.BlockedNamesFile + "'",
+	} {
+		if !strings.Contains(cfg, "\n"+expected+"\n") {
+			t.Errorf("configuration (offline mode) does not contain: %s", expected)
+		}
+	}
+	for _, unexpected := range []string{"server_names = ", "[static.'" + configSvrName + "']"} {
+		if strings.Contains(cfg, "\n"+unexpected) {
+			t.Errorf("configuration (offline mode) contains: %s", unexpected)
+		}
+	}
+
+	if err := SaveConfigFile("", testConfigTemplate, out, ConfigOptions{}); err == nil {
+		t.Error("error expected when no upstream defined")
+	}
+	if err := SaveForwardingRulesFile(" ", rulesFile); err == nil {
+		t.Error("error expected for empty DNS host")
+	}
+}
@@ -0,0 +1,202 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dnscryptproxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maximum size of data to be read from one names source
+	namesSourceMaxSize = 64 * 1024 * 1024
+	// timeout for downloading names source from the URL
+	namesSourceDownloadTimeout = 30 * time.Second
+)
+
+// hosts-file entries which must not be treated as domains to block
+var namesIgnored = map[string]struct{}{
+	"localhost":             {},
+	"localhost.localdomain": {},
+	"local":                 {},
+	"broadcasthost":         {},
+	"ip6-localhost":         {},
+	"ip6-loopback":          {},
+	"ip6-localnet":          {},
+	"ip6-mcastprefix":       {},
+	"ip6-allnodes":          {},
+	"ip6-allrouters":        {},
+	"ip6-allhosts":          {},
+}
+
+// SaveNamesFile - read domain names from the sources, normalize them and save into 'outFile'
+// in format of dnscrypt-proxy names file (one pattern per line).
+// The source can be a path to a local file or https URL.
+// Local files are never read here: their content must be provided in 'localContent' (source path -> file content)
+// by the client, which reads the files with its own permissions.
+// Supported source formats: hosts file (e.g. "0.0.0.0 example.com") or plain list of domains (one domain per line).
+// Returns number of unique names saved.
+func SaveNamesFile(sources []string, localContent map[string][]byte, outFile string) (namesCount int, err error) {
+	names := make(map[string]struct{})
+
+	for _, src := range sources {
+		src = strings.TrimSpace(src)
+		if len(src) == 0 {
+			continue
+		}
+		if err := readNamesSource(src, localContent, names); err != nil {
+			return 0, fmt.Errorf("failed to read names from '%s': %w", src, err)
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	output := strings.Join(sorted, "\n")
+	if len(output) > 0 {
+		output += "\n"
+	}
+	if err := os.WriteFile(outFile, []byte(output), 0600); err != nil {
+		return 0, err
+	}
+
+	return len(sorted), nil
+}
+
+// CountNamesFile - returns number of names in the names file
+func CountNamesFile(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	cnt := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) > 0 {
+			cnt++
+		}
+	}
+	return cnt, scanner.Err()
+}
+
+// IsNamesSourceURL returns 'true' when the names source is URL (otherwise it is a path to the local file)
+func IsNamesSourceURL(src string) bool {
+	// note: Windows path (e.g. "C:\file.txt") is parsed as URL with one-letter scheme
+	u, err := url.Parse(src)
+	return err == nil && len(u.Scheme) > 1
+}
+
+func readNamesSource(src string, localContent map[string][]byte, names map[string]struct{}) error {
+	var reader io.Reader
+
+	if IsNamesSourceURL(src) {
+		u, _ := url.Parse(src)
+		if u.Scheme != "https" {
+			return fmt.Errorf("unsupported URL scheme '%s' (only https URLs are allowed)", u.Scheme)
+		}
+		client := &http.Client{Timeout: namesSourceDownloadTimeout}
+		resp, err := client.Get(src)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+		}
+		reader = resp.Body
+	} else {
+		content, ok := localContent[src]
+		if !ok {
+			return fmt.Errorf("content of the local file not provided")
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(reader, namesSourceMaxSize))
+	for scanner.Scan() {
+		for _, n := range parseNamesLine(scanner.Text()) {
+			names[n] = struct{}{}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseNamesLine - parse one line of hosts file or plain domains list
+func parseNamesLine(line string) []string {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		line = line[:idx]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// hosts file format: "<IP> <domain> [<domain>...]"
+	if net.ParseIP(fields[0]) != nil {
+		fields = fields[1:]
+	} else {
+		fields = fields[:1]
+	}
+
+	ret := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if n, ok := normalizeName(f); ok {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+func normalizeName(name string) (string, bool) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) == 0 || len(name) > 253 {
+		return "", false
+	}
+	if _, ignored := namesIgnored[name]; ignored {
+		return "", false
+	}
+	if net.ParseIP(name) != nil {
+		return "", false
+	}
+	for _, c := range name {
+		isAllowed := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '.' || c == '-' || c == '_' || c == '*' || c == '='
+		if !isAllowed {
+			return "", false
+		}
+	}
+	return name, true
+}
@@ -0,0 +1,100 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dnscryptproxy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseNamesLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"0.0.0.0 ads.example.com", []string{"ads.example.com"}},
+		{"127.0.0.1 a.example.com B.Example.COM. # comment", []string{"a.example.com", "b.example.com"}},
+		{":: ipv6.example.com", []string{"ipv6.example.com"}},
+		{"tracker.example.net", []string{"tracker.example.net"}},
+		{"tracker.example.net extra fields", []string{"tracker.example.net"}},
+		{"*.example.org", []string{"*.example.org"}},
+		{"127.0.0.1 localhost", []string{}},
+		{"0.0.0.0 0.0.0.0", []string{}},
+		{"bad/name.com", []string{}},
+		{"# comment only", nil},
+		{"   ", nil},
+	}
+	for _, tt := range tests {
+		if got := parseNamesLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNamesLine(%q) = %v; expected %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestIsNamesSourceURL(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"https://example.com/hosts", true},
+		{"http://example.com/hosts", true},
+		{"file:///etc/shadow", true},
+		{"/home/user/hosts.txt", false},
+		{"hosts.txt", false},
+		{`C:\Users\user\hosts.txt`, false},
+	}
+	for _, tt := range tests {
+		if got := IsNamesSourceURL(tt.src); got != tt.want {
+			t.Errorf("IsNamesSourceURL(%q) = %v; expected %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestSaveNamesFile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "names.txt")
+
+	localFile := filepath.Join(dir, "hosts")
+	// the file exists, but the daemon must not read local files: only the content provided by the client is in use
+	if err := os.WriteFile(localFile, []byte("0.0.0.0 from-disk.example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	content := map[string][]byte{localFile: []byte("0.0.0.0 b.example.com\n0.0.0.0 a.example.com\nb.example.com\n")}
+
+	cnt, err := SaveNamesFile([]string{localFile}, content, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if cnt != 2 || string(data) != "a.example.com\nb.example.com\n" {
+		t.Errorf("unexpected result (%d): %q", cnt, data)
+	}
+
+	for _, src := range []string{localFile, "http://example.com/hosts", "file://" + localFile, "ftp://example.com/hosts"} {
+		if _, err := SaveNamesFile([]string{src}, nil, out); err == nil {
+			t.Errorf("error expected for source '%s'", src)
+		}
+	}
+}
@@ -42,13 +42,18 @@ type startedCmd struct {
 }
 
 type dnsCryptProxy struct {
-	binaryPath     string
-	configFilePath string
-	proc           *startedCmd
+	binaryPath      string
+	configFilePath  string
+	queryLogHandler func(line string)
+	proc            *startedCmd
 }
 
-func implInit(theBinaryPath, configFilePath, logFilePath string) *dnsCryptProxy {
-	return &dnsCryptProxy{binaryPath: theBinaryPath, configFilePath: configFilePath}
+func implInit(theBinaryPath, configFilePath, logFilePath string, queryLogHandler func(line string)) *dnsCryptProxy {
+	return &dnsCryptProxy{binaryPath: theBinaryPath, configFilePath: configFilePath, queryLogHandler: queryLogHandler}
+}
+
+func implIsQueryLogStdoutSupported() bool {
+	return true
 }
 
 // Start - asynchronously start
@@ -88,6 +93,11 @@ func (p *dnsCryptProxy) start() (command *startedCmd, err error) {
 	// output example:
 	// 	[NOTICE] [ivpnmanualconfig] OK
 	outputParseFunc := func(text string, isError bool) {
+		if !isError && p.queryLogHandler != nil && IsQueryLogRecord(text) {
+			// query log record (the query log is written to stdout): not logged
+			p.queryLogHandler(text)
+			return
+		}
 		log.Info("[OUT] ", text)
 		// check if dnscrypt-proxy ready to use
 		if strings.Contains(text, "[NOTICE] Now listening to") {
@@ -0,0 +1,219 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dnscryptproxy
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// QueryLogStdout - query log "file" which makes dnscrypt-proxy write query log records to its standard output.
+	// It is in use to process the records in memory (domain names never reach the disk; see QueryLogReader.ProcessLine())
+	QueryLogStdout = "/dev/stdout"
+
+	// maximum number of unique blocked domains to keep in memory
+	queryStatsMaxBlockedNames = 10000
+	// number of top blocked domains in statistics
+	queryStatsTopBlockedCount = 10
+)
+
+// DomainCounter - number of queries for the domain
+type DomainCounter struct {
+	Domain string
+	Count  int
+}
+
+// QueryStats - statistics collected from the dnscrypt-proxy query log
+type QueryStats struct {
+	Total   int // total number of queries
+	Blocked int // number of blocked queries (by local filters)
+	Failed  int // number of failed queries (upstream errors, timeouts)
+	Cached  int // number of queries answered locally (e.g. from cache)
+
+	// Top blocked domains (empty when domain names collecting disabled)
+	TopBlocked []DomainCounter
+
+	// Upstream latency (only for queries forwarded to the upstream server)
+	AvgLatencyMs int
+	MaxLatencyMs int
+}
+
+// QueryLogReader - incrementally reads dnscrypt-proxy query log (TSV format) and collects statistics.
+// Query log line example:
+//
+//	[2023-05-08 12:00:00]	127.0.0.1	example.com	A	PASS	12ms	ivpnmanualconfig
+type QueryLogReader struct {
+	file         string
+	offset       int64
+	isNoNames    bool // do not collect domain names (strict privacy)
+	stats        QueryStats
+	blockedNames map[string]int
+	latencyCount int
+	latencySumMs int64
+}
+
+// NewQueryLogReader creates query log reader.
+// 'isNoNames' - do not collect domain names (only counters)
+func NewQueryLogReader(queryLogFile string, isNoNames bool) *QueryLogReader {
+	return &QueryLogReader{file: queryLogFile, isNoNames: isNoNames, blockedNames: make(map[string]int)}
+}
+
+// Process reads new records from the query log file and updates statistics.
+// When 'isTruncate' is true or the file size exceeds 'maxFileSize' - the file is truncated after processing.
+func (r *QueryLogReader) Process(isTruncate bool, maxFileSize int64) error {
+	f, err := os.OpenFile(r.file, os.O_RDWR, 0600)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < r.offset {
+		// file was truncated or rotated
+		r.offset = 0
+	}
+
+	if _, err := f.Seek(r.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// incomplete line will be processed next time
+			break
+		}
+		r.offset += int64(len(line))
+		r.processLine(line)
+	}
+
+	if isTruncate || (maxFileSize > 0 && r.offset > maxFileSize) {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		r.offset = 0
+	}
+	return nil
+}
+
+// ProcessLine updates statistics by the query log record received not from the file
+// (e.g. query log records written by dnscrypt-proxy to the standard output; see QueryLogStdout)
+func (r *QueryLogReader) ProcessLine(line string) {
+	r.processLine(line)
+}
+
+// IsQueryLogRecord returns 'true' when the line looks like a query log record (TSV format).
+// It allows to distinguish query log records from other output of dnscrypt-proxy.
+func IsQueryLogRecord(line string) bool {
+	return strings.HasPrefix(line, "[") && strings.Count(line, "\t") >= 5
+}
+
+// Stats returns collected statistics
+func (r *QueryLogReader) Stats() QueryStats {
+	ret := r.stats
+	if r.latencyCount > 0 {
+		ret.AvgLatencyMs = int(r.latencySumMs / int64(r.latencyCount))
+	}
+
+	ret.TopBlocked = make([]DomainCounter, 0, len(r.blockedNames))
+	for n, c := range r.blockedNames {
+		ret.TopBlocked = append(ret.TopBlocked, DomainCounter{Domain: n, Count: c})
+	}
+	sort.Slice(ret.TopBlocked, func(i, j int) bool {
+		if ret.TopBlocked[i].Count == ret.TopBlocked[j].Count {
+			return ret.TopBlocked[i].Domain < ret.TopBlocked[j].Domain
+		}
+		return ret.TopBlocked[i].Count > ret.TopBlocked[j].Count
+	})
+	if len(ret.TopBlocked) > queryStatsTopBlockedCount {
+		ret.TopBlocked = ret.TopBlocked[:queryStatsTopBlockedCount]
+	}
+	return ret
+}
+
+func (r *QueryLogReader) processLine(line string) {
+	// [time] client_ip qname qtype return_code duration server
+	cols := strings.Split(strings.TrimSpace(line), "\t")
+	if len(cols) < 6 {
+		return
+	}
+	qname := strings.ToLower(strings.TrimSuffix(cols[2], "."))
+	retCode := cols[4]
+	duration := cols[5]
+	server := ""
+	if len(cols) > 6 {
+		server = cols[6]
+	}
+
+	r.stats.Total++
+
+	switch retCode {
+	case "REJECT", "DROP":
+		r.stats.Blocked++
+		if !r.isNoNames {
+			if _, exists := r.blockedNames[qname]; exists || len(r.blockedNames) < queryStatsMaxBlockedNames {
+				r.blockedNames[qname]++
+			}
+		}
+		return
+	case "SERVER_ERROR", "SERVER_TIMEOUT", "RESPONSE_ERROR", "NETWORK_ERROR", "PARSE_ERROR", "NOT_READY":
+		r.stats.Failed++
+		return
+	case "FORWARD":
+		// forwarded by the forwarding rule to the plain-DNS upstream server (the server column is '-')
+		r.addLatency(duration)
+		return
+	}
+
+	if len(server) == 0 || server == "-" {
+		// not forwarded to the upstream server (e.g. answered from cache)
+		if retCode == "PASS" {
+			r.stats.Cached++
+		}
+		return
+	}
+	r.addLatency(duration)
+}
+
+func (r *QueryLogReader) addLatency(duration string) {
+	if d, err := time.ParseDuration(duration); err == nil {
+		ms := int(d.Milliseconds())
+		r.latencyCount++
+		r.latencySumMs += int64(ms)
+		if ms > r.stats.MaxLatencyMs {
+			r.stats.MaxLatencyMs = ms
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dnscryptproxy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueryLogReaderProcessLine(t *testing.T) {
+	lines := []string{
+		"[2023-05-08 12:00:00]\t127.0.0.1\texample.com\tA\tPASS\t12ms\tivpnmanualconfig",
+		"[2023-05-08 12:00:01]\t127.0.0.1\texample.com\tA\tPASS\t0ms\t-",
+		"[2023-05-08 12:00:02]\t127.0.0.1\tAds.Example.com.\tA\tREJECT\t0ms\t-",
+		"[2023-05-08 12:00:03]\t127.0.0.1\tads.example.com\tAAAA\tREJECT\t0ms\t-",
+		"[2023-05-08 12:00:04]\t127.0.0.1\ttracker.example.net\tA\tDROP\t0ms\t-",
+		"[2023-05-08 12:00:05]\t127.0.0.1\texample.org\tA\tSERVER_TIMEOUT\t2000ms\tivpnmanualconfig",
+		"[2023-05-08 12:00:06]\t127.0.0.1\texample.org\tA\tFORWARD\t30ms\t-",
+		"[2023-05-08 12:00:07]\t127.0.0.1\texample.org\tA\tFORWARD\t6ms\t-",
+		"bad line",
+	}
+	want := QueryStats{
+		Total:        8,
+		Blocked:      3,
+		Failed:       1,
+		Cached:       1,
+		TopBlocked:   []DomainCounter{{"ads.example.com", 2}, {"tracker.example.net", 1}},
+		AvgLatencyMs: 16, // (12 + 30 + 6) / 3
+		MaxLatencyMs: 30,
+	}
+
+	r := NewQueryLogReader("", false)
+	for _, l := range lines {
+		r.ProcessLine(l)
+	}
+	if got := r.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected statistics: %+v (expected %+v)", got, want)
+	}
+
+	// strict privacy: no domain names collected
+	r = NewQueryLogReader("", true)
+	for _, l := range lines {
+		r.ProcessLine(l)
+	}
+	want.TopBlocked = []DomainCounter{}
+	if got := r.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected statistics (strict privacy): %+v (expected %+v)", got, want)
+	}
+}
+
+func TestQueryLogReaderProcess(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.log")
+	r := NewQueryLogReader(file, false)
+	if err := r.Process(false, 0); err != nil {
+		t.Fatal("no error expected when query log not exists: ", err)
+	}
+
+	content := "[2023-05-08 12:00:00]\t127.0.0.1\texample.com\tA\tPASS\t12ms\tivpnmanualconfig\n" +
+		"[2023-05-08 12:00:01]\t127.0.0.1\tads.example.com\tA\tREJECT\t0ms\t-\n" +
+		"[2023-05-08 12:00:02]\t127.0.0.1\texample.org\tA\tFORW" // incomplete line
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Process(false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s := r.Stats(); s.Total != 2 || s.Blocked != 1 {
+		t.Errorf("unexpected statistics: %+v", s)
+	}
+
+	// the rest of the incomplete line is processed on the next call; the file is truncated
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("ARD\t6ms\t-\n")
+	f.Close()
+	if err := r.Process(true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s := r.Stats(); s.Total != 3 || s.MaxLatencyMs != 12 || s.AvgLatencyMs != 9 {
+		t.Errorf("unexpected statistics: %+v", s)
+	}
+	if data, err := os.ReadFile(file); err != nil || len(data) != 0 {
+		t.Errorf("query log file not truncated: '%s' (%v)", data, err)
+	}
+}
+
+func TestIsQueryLogRecord(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"[2023-05-08 12:00:00]\t127.0.0.1\texample.com\tA\tPASS\t12ms\tivpnmanualconfig", true},
+		{"[2023-05-08 12:00:00]\t127.0.0.1\texample.com\tA\tFORWARD\t12ms", true},
+		{"[2023-05-08 12:00:00] [NOTICE] Now listening to 127.0.0.1:53 [UDP]", false},
+		{"[2023-05-08 12:00:00] [FATAL] " + strings.Repeat("x", 10), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsQueryLogRecord(tt.line); got != tt.want {
+			t.Errorf("'%s': unexpected result %v", tt.line, got)
+		}
+	}
+}
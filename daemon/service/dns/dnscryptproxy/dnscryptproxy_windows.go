@@ -45,10 +45,15 @@ type dnsCryptProxy struct {
 	logFilePath    string
 }
 
-func implInit(theBinaryPath, configFilePath, logFilePath string) *dnsCryptProxy {
+func implInit(theBinaryPath, configFilePath, logFilePath string, queryLogHandler func(line string)) *dnsCryptProxy {
+	// 'queryLogHandler' not in use: dnscrypt-proxy is running as a service (its standard output is not available)
 	return &dnsCryptProxy{binaryPath: theBinaryPath, configFilePath: configFilePath, logFilePath: logFilePath}
 }
 
+func implIsQueryLogStdoutSupported() bool {
+	return false
+}
+
 // Start - asynchronously start
 func (p *dnsCryptProxy) implStart() (retErr error) {
 	defer func() {
@@ -52,6 +52,10 @@ var (
 
 	// List of IP masks that are allowed for any communication
 	userExceptions []net.IPNet
+	// List of user exceptions restricted by protocol, port or direction (applicable only for Linux)
+	userExceptionRules []UserException
+	// List of host names defined as user exceptions (they are resolved and allowed by the service)
+	userExceptionHosts []string
 
 	stateAllowLan          bool
 	stateAllowLanMulticast bool
@@ -196,6 +200,7 @@ func ClientDisconnected() error {
 		connectedClientInterfaceIP = nil
 		connectedClientInterfaceIPv6 = nil
 		log.Info("Client disconnected")
+		exceptionHostsRemoveNonPersistent()
 		err := implClientDisconnected()
 		if err != nil {
 			log.Error(err)
@@ -218,6 +223,8 @@ func AddHostsToExceptions(IPs []net.IP, onlyForICMP bool, isPersistent bool) err
 	err := implAddHostsToExceptions(IPs, onlyForICMP, isPersistent)
 	if err != nil {
 		log.Error("Failed to add hosts to exceptions:", err)
+	} else {
+		exceptionHostsUpdate(IPs, onlyForICMP, isPersistent, true)
 	}
 
 	return err
@@ -227,6 +234,8 @@ func RemoveHostsFromExceptions(IPs []net.IP, onlyForICMP bool, isPersistent bool
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	exceptionHostsUpdate(IPs, onlyForICMP, isPersistent, false)
+
 	err := implRemoveHostsFromExceptions(IPs, onlyForICMP, isPersistent)
 	if err != nil {
 		log.Error("Failed to remove hosts from exceptions:", err)
@@ -305,44 +314,75 @@ func OnChangeDNS(newDnsCfg *dns.DnsSettings) error {
 
 // SetUserExceptions set ip/mask to be excluded from FW block
 // Parameters:
-//   - exceptions - comma separated list of IP addresses in format: x.x.x.x[/xx]
+//   - exceptions - comma separated list of exceptions in format: [DIRECTION:][PROTOCOL[:PORT[-PORT]]@]x.x.x.x[/xx]
+//     (e.g.: "192.0.2.0/24, tcp:22@192.0.2.0/24, udp:51820@198.51.100.7, icmp@10.0.0.0/8, out@10.0.0.0/8")
+//     Restrictions by protocol, port or direction are applicable only for Linux (see IsUserExceptionRulesSupported()).
+//     Host names (e.g. "git.example.com") are not resolved here: they are available by GetUserExceptionHosts()
+//   - ignoreParseErrors - skip exceptions which can not be parsed (or are not supported on this platform) instead of returning error
 func SetUserExceptions(exceptions string, ignoreParseErrors bool) error {
-	userExceptions = []net.IPNet{}
+	newExceptions, newExceptionRules, newExceptionHosts, err := parseUserExceptions(exceptions, ignoreParseErrors)
+	if err != nil {
+		return err
+	}
+
+	userExceptions = newExceptions
+	userExceptionRules = newExceptionRules
+	userExceptionHosts = newExceptionHosts
+
+	return implOnUserExceptionsUpdated()
+}
+
+// ValidateUserExceptions checks the list of exceptions (see SetUserExceptions) without applying it
+func ValidateUserExceptions(exceptions string) error {
+	_, _, _, err := parseUserExceptions(exceptions, false)
+	return err
+}
+
+// IsUserExceptionRulesSupported returns 'true' when exceptions restricted by protocol, port or direction are supported on this platform
+func IsUserExceptionRulesSupported() bool {
+	return implIsUserExceptionRulesSupported()
+}
+
+// parseUserExceptions parses the list of exceptions (see SetUserExceptions).
+// The separators are compatible with previous versions (any character except letters, digits and '/', '.', ':'),
+// except '@' and '-' which are part of the exception format (e.g. "tcp:8000-8080@192.0.2.1", "git-server.example.com").
+func parseUserExceptions(exceptions string, ignoreParseErrors bool) (nets []net.IPNet, rules []UserException, hosts []string, err error) {
+	nets = []net.IPNet{}
+	rules = []UserException{}
+	hosts = []string{}
 
 	splitFunc := func(c rune) bool {
-		return !unicode.IsLetter(c) && !unicode.IsNumber(c) && c != rune('/') && c != rune('.') && c != rune(':')
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c) && !strings.ContainsRune("/.:@-", c)
 	}
 	exceptionsArr := strings.FieldsFunc(exceptions, splitFunc)
 	for _, exp := range exceptionsArr {
-		exp = strings.TrimSpace(exp)
-
-		var err error
-		var n *net.IPNet
+		if IsUserExceptionHost(exp) {
+			hosts = append(hosts, strings.ToLower(strings.TrimSuffix(exp, ".")))
+			continue
+		}
 
-		if strings.Contains(exp, "/") {
-			_, n, err = net.ParseCIDR(exp)
-		} else {
-			addr := net.ParseIP(exp)
-			if addr == nil {
-				err = fmt.Errorf("%s not a IP address", exp)
-			} else {
-				if addr.To4() == nil {
-					// IPv6 single address
-					_, n, err = net.ParseCIDR(addr.String() + "/128")
-				} else {
-					// IPv4 single address
-					_, n, err = net.ParseCIDR(addr.String() + "/32")
-				}
-			}
+		e, err := ParseUserException(exp)
+		if err == nil && !e.IsHostWide() && !implIsUserExceptionRulesSupported() {
+			err = fmt.Errorf("'%s': exceptions restricted by protocol, port or direction are not supported on this platform", exp)
 		}
 		if err != nil {
 			if !ignoreParseErrors {
-				return fmt.Errorf("unable to parse firewall exceptions ('%s'): %w", exceptions, err)
+				return nil, nil, nil, fmt.Errorf("unable to parse firewall exception %w", err)
 			}
+			log.Warning(fmt.Sprintf("ignoring firewall exception %s", err))
 			continue
 		}
-		userExceptions = append(userExceptions, *n)
+
+		if e.IsHostWide() {
+			nets = append(nets, e.Network)
+		} else {
+			rules = append(rules, e)
+		}
 	}
+	return nets, rules, hosts, nil
+}
 
-	return implOnUserExceptionsUpdated()
+// GetUserExceptionHosts returns host names defined in user exceptions (see SetUserExceptions)
+func GetUserExceptionHosts() []string {
+	return append([]string{}, userExceptionHosts...)
 }
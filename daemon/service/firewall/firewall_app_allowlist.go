@@ -0,0 +1,126 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AppAllowlist - applications which are allowed to communicate when the firewall is enabled
+// (even when VPN is not connected). Applicable only for Linux.
+//
+// The running processes of the applications are placed to the dedicated cgroup
+// which traffic bypasses the firewall. Processes running as the defined users bypass the firewall too.
+// Inbound traffic is allowed only for connections initiated by the allowed processes.
+type AppAllowlist struct {
+	Apps []string // absolute paths to application binaries
+	Uids []int    // user IDs
+}
+
+// IsEmpty returns true when no applications and users defined
+func (a AppAllowlist) IsEmpty() bool {
+	return len(a.Apps) == 0 && len(a.Uids) == 0
+}
+
+// Normalize returns configuration with cleaned and sorted values (duplicates removed)
+func (a AppAllowlist) Normalize() AppAllowlist {
+	ret := AppAllowlist{}
+
+	uniqueApps := make(map[string]struct{}, len(a.Apps))
+	for _, app := range a.Apps {
+		app = strings.TrimSpace(app)
+		if len(app) == 0 {
+			continue
+		}
+		app = filepath.Clean(app)
+		if _, exists := uniqueApps[app]; exists {
+			continue
+		}
+		uniqueApps[app] = struct{}{}
+		ret.Apps = append(ret.Apps, app)
+	}
+	sort.Strings(ret.Apps)
+
+	uniqueUids := make(map[int]struct{}, len(a.Uids))
+	for _, uid := range a.Uids {
+		if _, exists := uniqueUids[uid]; exists {
+			continue
+		}
+		uniqueUids[uid] = struct{}{}
+		ret.Uids = append(ret.Uids, uid)
+	}
+	sort.Ints(ret.Uids)
+
+	return ret
+}
+
+// Validate checks configuration
+func (a AppAllowlist) Validate() error {
+	for _, app := range a.Apps {
+		app = strings.TrimSpace(app)
+		if len(app) > 0 && !filepath.IsAbs(app) {
+			return fmt.Errorf("bad application path '%s': absolute path expected", app)
+		}
+	}
+	for _, uid := range a.Uids {
+		if uid < 0 {
+			return fmt.Errorf("bad user ID %d", uid)
+		}
+		if uid == 0 {
+			// most of system services are running as root: it would disable the firewall for them
+			return fmt.Errorf("it is not allowed to add the root user (UID 0) to the firewall allowlist")
+		}
+	}
+	return nil
+}
+
+var appAllowlist AppAllowlist
+
+// SetAppAllowlist - allow communication for the applications (processes) when the firewall is enabled
+func SetAppAllowlist(cfg AppAllowlist) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	appAllowlist = cfg.Normalize()
+	log.Info(fmt.Sprintf("Applications allowlist: apps=%v uids=%v", appAllowlist.Apps, appAllowlist.Uids))
+
+	err := implOnAppAllowlistUpdated()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// GetAppAllowlist returns current configuration of the applications allowlist
+func GetAppAllowlist() AppAllowlist {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return appAllowlist
+}
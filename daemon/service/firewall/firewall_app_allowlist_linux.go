@@ -0,0 +1,308 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/cgroups"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/procmon"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+const (
+	// cgroup for processes allowed to bypass the firewall (must be the same as in firewall.sh)
+	appAllowlistCgroupName = "ivpn-fw-allow"
+	// cgroup v1 ('net_cls' controller) root
+	appAllowlistCgroupV1Root = "/sys/fs/cgroup/net_cls"
+	// how often to look for processes of allowed applications (in addition to the process exec monitor;
+	// e.g. to move back the processes which are not allowed anymore)
+	appAllowlistScanInterval = time.Minute
+	// how often to look for new processes of allowed applications when the process exec monitor is not available
+	appAllowlistScanIntervalNoMonitor = 3 * time.Second
+)
+
+var (
+	appAllowlistMutex    sync.Mutex
+	appAllowlistStopChan chan struct{}
+	appAllowlistMonitor  *procmon.Monitor
+
+	// executable paths of the allowed applications (used by the process exec monitor)
+	appAllowlistExecsMutex sync.Mutex
+	appAllowlistExecs      map[string]struct{}
+
+	// cgroup v2: original cgroups of the processes moved to the allowlist cgroup (map[<PID>]<cgroup path>).
+	// The processes are moved back to the original cgroups when removed from the allowlist.
+	appAllowlistOriginsMutex sync.Mutex
+	appAllowlistOrigins      = map[int]string{}
+)
+
+// appAllowlistCgroupProcs returns the 'cgroup.procs' file of the allowlist cgroup
+func appAllowlistCgroupProcs() string {
+	if cgroups.IsV2() {
+		return cgroups.ProcsFileV2("/" + appAllowlistCgroupName)
+	}
+	return filepath.Join(appAllowlistCgroupV1Root, appAllowlistCgroupName, "cgroup.procs")
+}
+
+// appAllowlistAddProcess moves the process to the allowlist cgroup (cgroup v2: remembers the original cgroup of the process)
+func appAllowlistAddProcess(procsFile string, pid int) error {
+	if cgroups.IsV2() {
+		if origin, err := cgroups.ProcessPathV2(pid); err == nil && cgroups.ProcsFileV2(origin) != procsFile {
+			appAllowlistOriginsMutex.Lock()
+			appAllowlistOrigins[pid] = origin
+			appAllowlistOriginsMutex.Unlock()
+		}
+	}
+	return cgroups.MoveProcess(procsFile, pid)
+}
+
+// appAllowlistRemoveProcess moves the process out of the allowlist cgroup:
+// to the original cgroup of the process (cgroup v2) or to the root cgroup
+func appAllowlistRemoveProcess(pid int) error {
+	if !cgroups.IsV2() {
+		return cgroups.MoveProcess(filepath.Join(appAllowlistCgroupV1Root, "cgroup.procs"), pid)
+	}
+
+	appAllowlistOriginsMutex.Lock()
+	origin, ok := appAllowlistOrigins[pid]
+	delete(appAllowlistOrigins, pid)
+	appAllowlistOriginsMutex.Unlock()
+
+	// the original cgroup can be already removed (or can be not able to contain processes anymore)
+	if ok && cgroups.MoveProcess(cgroups.ProcsFileV2(origin), pid) == nil {
+		return nil
+	}
+	return cgroups.MoveProcess(cgroups.ProcsFileV2("/"), pid)
+}
+
+// implOnAppAllowlistUpdated() called when 'appAllowlist' value were updated. Necessary to update firewall rules.
+func implOnAppAllowlistUpdated() error {
+	appAllowlistMutex.Lock()
+	defer appAllowlistMutex.Unlock()
+
+	appAllowlistStopMonitoring()
+
+	if !curStateEnabled {
+		return nil
+	}
+
+	uids := make([]string, 0, len(appAllowlist.Uids))
+	for _, uid := range appAllowlist.Uids {
+		uids = append(uids, strconv.Itoa(uid))
+	}
+	log.Info("-set_app_allowlist ", strings.Join(uids, " "))
+	if err := shell.Exec(nil, platform.FirewallScript(), append([]string{"-set_app_allowlist"}, uids...)...); err != nil {
+		return fmt.Errorf("failed to apply applications allowlist: %w", err)
+	}
+
+	apps := appAllowlist.Apps
+	if len(apps) > 0 {
+		// the applications can be started later: new processes are moved to the allowlist cgroup on exec() event
+		appAllowlistExecsMutex.Lock()
+		appAllowlistExecs = appAllowlistExecutables(apps)
+		appAllowlistExecsMutex.Unlock()
+
+		if m, err := procmon.Start(appAllowlistOnProcessExec); err != nil {
+			log.Warning(fmt.Sprintf("failed to start process exec monitor (looking for new processes of allowed applications every %v): %s", appAllowlistScanIntervalNoMonitor, err))
+		} else {
+			appAllowlistMonitor = m
+		}
+	}
+
+	if err := appAllowlistUpdateProcesses(apps); err != nil {
+		return err
+	}
+
+	if len(apps) > 0 {
+		// fallback: periodically check all running processes
+		scanInterval := appAllowlistScanInterval
+		if appAllowlistMonitor == nil {
+			scanInterval = appAllowlistScanIntervalNoMonitor
+		}
+		stop := make(chan struct{})
+		appAllowlistStopChan = stop
+		go func() {
+			ticker := time.NewTicker(scanInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if err := appAllowlistUpdateProcesses(apps); err != nil {
+						log.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// appAllowlistStop stops looking for new processes of allowed applications (e.g. when firewall disabled)
+func appAllowlistStop() {
+	appAllowlistMutex.Lock()
+	defer appAllowlistMutex.Unlock()
+	appAllowlistStopMonitoring()
+}
+
+func appAllowlistStopMonitoring() {
+	if appAllowlistMonitor != nil {
+		appAllowlistMonitor.Stop()
+		appAllowlistMonitor = nil
+	}
+	appAllowlistExecsMutex.Lock()
+	appAllowlistExecs = nil
+	appAllowlistExecsMutex.Unlock()
+
+	if appAllowlistStopChan != nil {
+		close(appAllowlistStopChan)
+		appAllowlistStopChan = nil
+	}
+}
+
+// appAllowlistOnProcessExec is called by process exec monitor:
+// the process of allowed application is moved to the allowlist cgroup before it opens connections
+func appAllowlistOnProcessExec(pid int) {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return // process already finished (or kernel thread)
+	}
+
+	appAllowlistExecsMutex.Lock()
+	_, isAllowed := appAllowlistExecs[exe]
+	appAllowlistExecsMutex.Unlock()
+	if !isAllowed {
+		return
+	}
+
+	if err := appAllowlistAddProcess(appAllowlistCgroupProcs(), pid); err != nil {
+		log.Error(fmt.Sprintf("failed to add process %d (%s) to firewall allowlist: %s", pid, exe, err))
+	} else {
+		log.Info(fmt.Sprintf("Process %d (%s) added to firewall allowlist", pid, exe))
+	}
+}
+
+// appAllowlistExecutables returns executable paths of the applications (the paths and the paths with resolved symlinks)
+func appAllowlistExecutables(apps []string) map[string]struct{} {
+	allowed := make(map[string]struct{}, len(apps))
+	for _, app := range apps {
+		allowed[app] = struct{}{}
+		if resolved, err := filepath.EvalSymlinks(app); err == nil {
+			allowed[resolved] = struct{}{}
+		}
+	}
+	return allowed
+}
+
+// appAllowlistUpdateProcesses moves running processes of the applications to the allowlist cgroup
+// and moves back the processes which are not allowed anymore
+func appAllowlistUpdateProcesses(apps []string) error {
+	allowed := appAllowlistExecutables(apps)
+
+	procsFile := appAllowlistCgroupProcs()
+
+	// processes which are already in the allowlist cgroup
+	inCgroup := make(map[int]struct{})
+	if data, err := os.ReadFile(procsFile); err == nil {
+		for _, l := range strings.Fields(string(data)) {
+			if pid, err := strconv.Atoi(l); err == nil {
+				inCgroup[pid] = struct{}{}
+			}
+		}
+		// forget the original cgroups of the finished processes
+		appAllowlistOriginsMutex.Lock()
+		for pid := range appAllowlistOrigins {
+			if _, ok := inCgroup[pid]; !ok {
+				delete(appAllowlistOrigins, pid)
+			}
+		}
+		appAllowlistOriginsMutex.Unlock()
+	} else if len(apps) > 0 {
+		return fmt.Errorf("failed to read allowlist cgroup: %w", err)
+	}
+
+	procDirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, procDir := range procDirs {
+		pid, err := strconv.Atoi(filepath.Base(procDir))
+		if err != nil {
+			continue
+		}
+		exe, err := os.Readlink(filepath.Join(procDir, "exe"))
+		if err != nil {
+			continue
+		}
+		_, isAllowed := allowed[exe]
+		_, isInCgroup := inCgroup[pid]
+
+		if isAllowed && !isInCgroup {
+			if err := appAllowlistAddProcess(procsFile, pid); err != nil {
+				log.Error(fmt.Sprintf("failed to add process %d (%s) to firewall allowlist: %s", pid, exe, err))
+			} else {
+				log.Info(fmt.Sprintf("Process %d (%s) added to firewall allowlist", pid, exe))
+			}
+		} else if !isAllowed && isInCgroup && !isChildOfAllowlistedProcess(pid, inCgroup) {
+			// the application removed from allowlist
+			if err := appAllowlistRemoveProcess(pid); err == nil {
+				log.Info(fmt.Sprintf("Process %d (%s) removed from firewall allowlist", pid, exe))
+			}
+		}
+	}
+	return nil
+}
+
+// isChildOfAllowlistedProcess returns true if the parent process is in the allowlist cgroup
+// (child processes are inheriting cgroup of the parent; they must stay in the cgroup)
+func isChildOfAllowlistedProcess(pid int, inCgroup map[int]struct{}) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// format: "pid (comm) state ppid ..." (comm can contain spaces)
+	stat := string(data)
+	idx := strings.LastIndex(stat, ")")
+	if idx < 0 {
+		return false
+	}
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 2 {
+		return false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return false
+	}
+	_, ok := inCgroup[ppid]
+	return ok
+}
@@ -0,0 +1,155 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Logging of packets blocked by the firewall (Linux only).
+// The packets are aggregated by direction/protocol/remote address/port/process.
+
+const (
+	blockedPacketsMaxEntries = 256
+)
+
+// BlockedPacketsEntry - aggregated information about blocked packets
+type BlockedPacketsEntry struct {
+	Direction  string // "out", "in" or "forward"
+	Protocol   string // "tcp", "udp", "icmp", "icmpv6" or protocol number
+	RemoteIP   string
+	RemotePort int    // 0 - not applicable
+	LocalPort  int    // 0 - not applicable (for outgoing packets: the last seen local port)
+	Process    string // process which sent the packet (empty if not detected)
+	UID        int    // UID of the socket owner (-1 if not detected)
+	Interface  string // network interface
+	Count      uint64 // number of logged packets (the logging is rate-limited, so it is not the exact number of blocked packets)
+	FirstSeen  int64  // Unix time
+	LastSeen   int64  // Unix time
+}
+
+// BlockedPacketsInfo - information about blocked packets
+type BlockedPacketsInfo struct {
+	IsEnabled  bool
+	TotalCount uint64
+	Entries    []BlockedPacketsEntry // sorted by LastSeen (the latest is the first)
+}
+
+type blockedPacketsLog struct {
+	mutex      sync.Mutex
+	isEnabled  bool
+	totalCount uint64
+	entries    map[string]*BlockedPacketsEntry
+}
+
+var blockedPackets blockedPacketsLog
+
+// SetBlockedPacketsLogging enables/disables logging of blocked packets
+func SetBlockedPacketsLogging(enable bool) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Logging blocked packets: %t", enable))
+
+	if err := implSetBlockedPacketsLogging(enable); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	blockedPackets.mutex.Lock()
+	defer blockedPackets.mutex.Unlock()
+	blockedPackets.isEnabled = enable
+	return nil
+}
+
+// GetBlockedPackets returns information about blocked packets
+func GetBlockedPackets() BlockedPacketsInfo {
+	blockedPackets.mutex.Lock()
+	defer blockedPackets.mutex.Unlock()
+
+	ret := BlockedPacketsInfo{
+		IsEnabled:  blockedPackets.isEnabled,
+		TotalCount: blockedPackets.totalCount,
+		Entries:    make([]BlockedPacketsEntry, 0, len(blockedPackets.entries)),
+	}
+	for _, e := range blockedPackets.entries {
+		ret.Entries = append(ret.Entries, *e)
+	}
+	sort.Slice(ret.Entries, func(i, j int) bool { return ret.Entries[i].LastSeen > ret.Entries[j].LastSeen })
+	return ret
+}
+
+// ClearBlockedPackets erases information about blocked packets
+func ClearBlockedPackets() {
+	blockedPackets.mutex.Lock()
+	defer blockedPackets.mutex.Unlock()
+	blockedPackets.entries = nil
+	blockedPackets.totalCount = 0
+}
+
+// blockedPacketsAdd adds information about blocked packet
+func blockedPacketsAdd(p BlockedPacketsEntry, t time.Time) {
+	key := fmt.Sprintf("%s|%s|%s|%d|%s", p.Direction, p.Protocol, p.RemoteIP, p.RemotePort, p.Process)
+	if p.Direction != "out" {
+		// for incoming packets the local port is important (e.g. the port of local service)
+		key = fmt.Sprintf("%s|%d", key, p.LocalPort)
+	}
+
+	blockedPackets.mutex.Lock()
+	defer blockedPackets.mutex.Unlock()
+
+	if blockedPackets.entries == nil {
+		blockedPackets.entries = make(map[string]*BlockedPacketsEntry)
+	}
+	blockedPackets.totalCount++
+
+	if e, ok := blockedPackets.entries[key]; ok {
+		e.Count++
+		e.LastSeen = t.Unix()
+		e.LocalPort = p.LocalPort
+		if p.UID >= 0 {
+			e.UID = p.UID
+		}
+		return
+	}
+
+	if len(blockedPackets.entries) >= blockedPacketsMaxEntries {
+		// remove the oldest entry
+		var oldestKey string
+		var oldest int64
+		for k, e := range blockedPackets.entries {
+			if len(oldestKey) == 0 || e.LastSeen < oldest {
+				oldestKey, oldest = k, e.LastSeen
+			}
+		}
+		delete(blockedPackets.entries, oldestKey)
+	}
+
+	p.Count = 1
+	p.FirstSeen = t.Unix()
+	p.LastSeen = p.FirstSeen
+	blockedPackets.entries[key] = &p
+}
@@ -0,0 +1,306 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/nflog"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+const (
+	// NFLOG group which is used by firewall rules to log blocked packets
+	blockedPacketsNflogGroup = 4956
+	// number of bytes to copy from the packet (enough for IP and TCP/UDP headers)
+	blockedPacketsCopyRange = 128
+	// process detection: max number of lookups per second and the cache lifetime
+	processLookupMaxPerSecond = 5
+	processLookupCacheTTL     = 30 * time.Second
+)
+
+var blockedPacketsReader *nflog.Reader
+
+func implSetBlockedPacketsLogging(enable bool) error {
+	if enable && blockedPacketsReader == nil {
+		r, err := nflog.Open(blockedPacketsNflogGroup, blockedPacketsCopyRange)
+		if err != nil {
+			return fmt.Errorf("failed to start reading blocked packets: %w", err)
+		}
+		blockedPacketsReader = r
+		go blockedPacketsReadLoop(r)
+	} else if !enable && blockedPacketsReader != nil {
+		blockedPacketsReader.Close()
+		blockedPacketsReader = nil
+	}
+
+	return applyBlockedPacketsLogRules()
+}
+
+// applyBlockedPacketsLogRules updates firewall rules for logging blocked packets
+// (must be called each time when the firewall is enabled)
+func applyBlockedPacketsLogRules() error {
+	if !curStateEnabled {
+		return nil
+	}
+
+	group := ""
+	if blockedPacketsReader != nil {
+		group = strconv.Itoa(blockedPacketsNflogGroup)
+	}
+	log.Info("-set_blocked_log ", group)
+	return shell.Exec(nil, platform.FirewallScript(), "-set_blocked_log", group)
+}
+
+func blockedPacketsReadLoop(r *nflog.Reader) {
+	log.Info("Blocked packets logging started")
+	defer log.Info("Blocked packets logging stopped")
+
+	processes := newSocketProcessLookup()
+	for {
+		packets, err := r.Read()
+		if err != nil {
+			if !errors.Is(err, nflog.ErrClosed) {
+				log.Error(err)
+			}
+			return
+		}
+
+		for _, p := range packets {
+			e, isIPv6, ok := parseBlockedPacket(p.Prefix, p.Payload)
+			if !ok {
+				continue
+			}
+
+			e.UID = -1
+			if p.HasUID {
+				e.UID = int(p.UID)
+			}
+
+			ifIndex := p.OutIfIndex
+			if e.Direction == "in" {
+				ifIndex = p.InIfIndex
+			}
+			if ifIndex > 0 {
+				if iface, err := net.InterfaceByIndex(int(ifIndex)); err == nil {
+					e.Interface = iface.Name
+				}
+			}
+
+			if e.Direction == "out" && e.LocalPort > 0 {
+				e.Process = processes.find(e.Protocol, isIPv6, e.LocalPort)
+			}
+
+			blockedPacketsAdd(e, p.ReceiveTime)
+		}
+	}
+}
+
+// parseBlockedPacket parses packet data (starting from IP header)
+func parseBlockedPacket(prefix string, payload []byte) (e BlockedPacketsEntry, isIPv6 bool, ok bool) {
+	switch prefix {
+	case "ivpn-out":
+		e.Direction = "out"
+	case "ivpn-in":
+		e.Direction = "in"
+	case "ivpn-fwd":
+		e.Direction = "forward"
+	default:
+		return e, false, false
+	}
+
+	if len(payload) < 1 {
+		return e, false, false
+	}
+
+	var proto byte
+	var src, dst net.IP
+	var l4 []byte
+
+	switch payload[0] >> 4 {
+	case 4:
+		if len(payload) < 20 {
+			return e, false, false
+		}
+		ihl := int(payload[0]&0x0f) * 4
+		if ihl < 20 || len(payload) < ihl {
+			return e, false, false
+		}
+		proto = payload[9]
+		src, dst = net.IP(payload[12:16]), net.IP(payload[16:20])
+		l4 = payload[ihl:]
+	case 6:
+		if len(payload) < 40 {
+			return e, false, false
+		}
+		isIPv6 = true
+		proto = payload[6] // extension headers are not processed
+		src, dst = net.IP(payload[8:24]), net.IP(payload[24:40])
+		l4 = payload[40:]
+	default:
+		return e, false, false
+	}
+
+	remote := dst
+	if e.Direction == "in" {
+		remote = src
+	}
+	e.RemoteIP = remote.String()
+
+	switch proto {
+	case syscall.IPPROTO_TCP, syscall.IPPROTO_UDP:
+		e.Protocol = "tcp"
+		if proto == syscall.IPPROTO_UDP {
+			e.Protocol = "udp"
+		}
+		if len(l4) >= 4 {
+			srcPort := int(binary.BigEndian.Uint16(l4[0:2]))
+			dstPort := int(binary.BigEndian.Uint16(l4[2:4]))
+			if e.Direction == "in" {
+				e.RemotePort, e.LocalPort = srcPort, dstPort
+			} else {
+				e.RemotePort, e.LocalPort = dstPort, srcPort
+			}
+		}
+	case syscall.IPPROTO_ICMP:
+		e.Protocol = "icmp"
+	case syscall.IPPROTO_ICMPV6:
+		e.Protocol = "icmpv6"
+	default:
+		e.Protocol = strconv.Itoa(int(proto))
+	}
+
+	return e, isIPv6, true
+}
+
+// socketProcessLookup detects the process which owns local socket (by protocol and local port)
+type socketProcessLookup struct {
+	cache         map[string]socketProcessCacheEntry
+	lookupsPeriod time.Time
+	lookupsCount  int
+}
+
+type socketProcessCacheEntry struct {
+	name    string
+	expires time.Time
+}
+
+func newSocketProcessLookup() *socketProcessLookup {
+	return &socketProcessLookup{cache: make(map[string]socketProcessCacheEntry)}
+}
+
+func (l *socketProcessLookup) find(protocol string, isIPv6 bool, localPort int) string {
+	now := time.Now()
+	key := fmt.Sprintf("%s|%t|%d", protocol, isIPv6, localPort)
+	if c, ok := l.cache[key]; ok && now.Before(c.expires) {
+		return c.name
+	}
+
+	// limit number of lookups (scanning /proc is expensive)
+	if now.Sub(l.lookupsPeriod) >= time.Second {
+		l.lookupsPeriod = now
+		l.lookupsCount = 0
+	}
+	if l.lookupsCount >= processLookupMaxPerSecond {
+		return ""
+	}
+	l.lookupsCount++
+
+	// remove expired entries
+	for k, c := range l.cache {
+		if now.After(c.expires) {
+			delete(l.cache, k)
+		}
+	}
+
+	name := ""
+	if inode := findSocketInode(protocol, isIPv6, localPort); len(inode) > 0 {
+		name = findSocketProcess(inode)
+	}
+	l.cache[key] = socketProcessCacheEntry{name: name, expires: now.Add(processLookupCacheTTL)}
+	return name
+}
+
+// findSocketInode returns inode of the socket (from /proc/net/{tcp,udp}[6]) bound to local port
+func findSocketInode(protocol string, isIPv6 bool, localPort int) string {
+	file := "/proc/net/" + protocol
+	if isIPv6 {
+		file += "6"
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	portSuffix := fmt.Sprintf(":%04X", localPort)
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		if strings.HasSuffix(fields[1], portSuffix) && fields[9] != "0" {
+			return fields[9]
+		}
+	}
+	return ""
+}
+
+// findSocketProcess returns name of the process which has opened socket with defined inode
+func findSocketProcess(inode string) string {
+	link := "socket:[" + inode + "]"
+	procDirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, procDir := range procDirs {
+		fds, err := os.ReadDir(filepath.Join(procDir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(procDir, "fd", fd.Name())); err == nil && target == link {
+				comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+				if err != nil {
+					return ""
+				}
+				return strings.TrimSpace(string(comm))
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+)
+
+// ipv4Packet returns IPv4 packet: header (20 bytes) + l4 data
+func ipv4Packet(proto byte, src, dst string, l4 []byte) []byte {
+	b := make([]byte, 20, 20+len(l4))
+	b[0] = 0x45 // version 4; IHL 5
+	b[9] = proto
+	copy(b[12:16], net.ParseIP(src).To4())
+	copy(b[16:20], net.ParseIP(dst).To4())
+	return append(b, l4...)
+}
+
+// ipv6Packet returns IPv6 packet: header (40 bytes) + l4 data
+func ipv6Packet(proto byte, src, dst string, l4 []byte) []byte {
+	b := make([]byte, 40, 40+len(l4))
+	b[0] = 0x60 // version 6
+	b[6] = proto
+	copy(b[8:24], net.ParseIP(src).To16())
+	copy(b[24:40], net.ParseIP(dst).To16())
+	return append(b, l4...)
+}
+
+// ports returns the beginning of the TCP/UDP header
+func ports(src, dst uint16) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b[0:2], src)
+	binary.BigEndian.PutUint16(b[2:4], dst)
+	return b
+}
+
+func TestParseBlockedPacket(t *testing.T) {
+	ipv4WithOptions := ipv4Packet(syscall.IPPROTO_UDP, "10.0.0.2", "8.8.8.8", append(make([]byte, 4), ports(5353, 53)...))
+	ipv4WithOptions[0] = 0x46 // IHL 6 (4 bytes of options)
+
+	tests := []struct {
+		name       string
+		prefix     string
+		payload    []byte
+		want       BlockedPacketsEntry
+		wantIsIPv6 bool
+		wantOk     bool
+	}{
+		{"tcp out", "ivpn-out", ipv4Packet(syscall.IPPROTO_TCP, "10.0.0.2", "1.1.1.1", ports(40000, 443)),
+			BlockedPacketsEntry{Direction: "out", Protocol: "tcp", RemoteIP: "1.1.1.1", RemotePort: 443, LocalPort: 40000}, false, true},
+		{"udp in", "ivpn-in", ipv4Packet(syscall.IPPROTO_UDP, "1.1.1.1", "10.0.0.2", ports(53, 40000)),
+			BlockedPacketsEntry{Direction: "in", Protocol: "udp", RemoteIP: "1.1.1.1", RemotePort: 53, LocalPort: 40000}, false, true},
+		{"forward", "ivpn-fwd", ipv4Packet(syscall.IPPROTO_TCP, "172.17.0.2", "1.1.1.1", ports(40000, 80)),
+			BlockedPacketsEntry{Direction: "forward", Protocol: "tcp", RemoteIP: "1.1.1.1", RemotePort: 80, LocalPort: 40000}, false, true},
+		{"IPv4 options", "ivpn-out", ipv4WithOptions,
+			BlockedPacketsEntry{Direction: "out", Protocol: "udp", RemoteIP: "8.8.8.8", RemotePort: 53, LocalPort: 5353}, false, true},
+		{"icmp", "ivpn-out", ipv4Packet(syscall.IPPROTO_ICMP, "10.0.0.2", "1.1.1.1", []byte{8, 0, 0, 0}),
+			BlockedPacketsEntry{Direction: "out", Protocol: "icmp", RemoteIP: "1.1.1.1"}, false, true},
+		{"other protocol", "ivpn-out", ipv4Packet(47, "10.0.0.2", "1.1.1.1", nil),
+			BlockedPacketsEntry{Direction: "out", Protocol: "47", RemoteIP: "1.1.1.1"}, false, true},
+		{"truncated ports", "ivpn-out", ipv4Packet(syscall.IPPROTO_TCP, "10.0.0.2", "1.1.1.1", []byte{0x01, 0xbb}),
+			BlockedPacketsEntry{Direction: "out", Protocol: "tcp", RemoteIP: "1.1.1.1"}, false, true},
+		{"IPv6 tcp out", "ivpn-out", ipv6Packet(syscall.IPPROTO_TCP, "fd00::2", "2001:db8::1", ports(40000, 443)),
+			BlockedPacketsEntry{Direction: "out", Protocol: "tcp", RemoteIP: "2001:db8::1", RemotePort: 443, LocalPort: 40000}, true, true},
+		{"IPv6 icmpv6 in", "ivpn-in", ipv6Packet(syscall.IPPROTO_ICMPV6, "2001:db8::1", "fd00::2", []byte{128, 0, 0, 0}),
+			BlockedPacketsEntry{Direction: "in", Protocol: "icmpv6", RemoteIP: "2001:db8::1"}, true, true},
+
+		{"unknown prefix", "other", ipv4Packet(syscall.IPPROTO_TCP, "10.0.0.2", "1.1.1.1", ports(40000, 443)), BlockedPacketsEntry{}, false, false},
+		{"empty payload", "ivpn-out", nil, BlockedPacketsEntry{}, false, false},
+		{"truncated IPv4 header", "ivpn-out", ipv4Packet(syscall.IPPROTO_TCP, "10.0.0.2", "1.1.1.1", nil)[:19], BlockedPacketsEntry{}, false, false},
+		{"bad IHL", "ivpn-out", append([]byte{0x44}, ipv4Packet(syscall.IPPROTO_TCP, "10.0.0.2", "1.1.1.1", nil)[1:]...), BlockedPacketsEntry{}, false, false},
+		{"IHL exceeds packet", "ivpn-out", append([]byte{0x4F}, ipv4Packet(syscall.IPPROTO_TCP, "10.0.0.2", "1.1.1.1", nil)[1:]...), BlockedPacketsEntry{}, false, false},
+		{"truncated IPv6 header", "ivpn-out", ipv6Packet(syscall.IPPROTO_TCP, "fd00::2", "2001:db8::1", nil)[:39], BlockedPacketsEntry{}, false, false},
+		{"unknown IP version", "ivpn-out", append([]byte{0x55}, make([]byte, 39)...), BlockedPacketsEntry{}, false, false},
+	}
+	for _, tt := range tests {
+		got, isIPv6, ok := parseBlockedPacket(tt.prefix, tt.payload)
+		if ok != tt.wantOk || isIPv6 != tt.wantIsIPv6 {
+			t.Errorf("%s: unexpected result: isIPv6=%v ok=%v", tt.name, isIPv6, ok)
+			continue
+		}
+		if ok && got != tt.want {
+			t.Errorf("%s: unexpected entry %+v (expected %+v)", tt.name, got, tt.want)
+		}
+	}
+}
@@ -192,12 +192,70 @@ func implOnChangeDNS(addr net.IP, isInternal bool) error {
 	return shell.Exec(nil, platform.FirewallScript(), "-set_dns", fmt.Sprint(isLAN), dnsVal)
 }
 
+// ICMP-only exceptions are not supported: the hosts are allowed for all protocols
+const isIcmpOnlyExceptionsSupported = false
+
+// implCheckRules - checking the rules present in the system is not implemented for this platform
+func implCheckRules(rs RuleSet) RuleSetConsistency {
+	return RuleSetConsistency{}
+}
+
+// implOnAppAllowlistUpdated() called when 'appAllowlist' value were updated
+func implOnAppAllowlistUpdated() error {
+	if !appAllowlist.IsEmpty() {
+		return fmt.Errorf("applications allowlist for the firewall is not supported on this platform")
+	}
+	return nil
+}
+
+func implSetBlockedPacketsLogging(enable bool) error {
+	if enable {
+		return fmt.Errorf("logging blocked packets is not supported on this platform")
+	}
+	return nil
+}
+
+// implOnInterfaceExceptionsUpdated() called when 'interfaceExceptions' value were updated.
+// Interface exceptions are not supported on this platform.
+func implOnInterfaceExceptionsUpdated() error {
+	if !interfaceExceptions.IsEmpty() {
+		return fmt.Errorf("interface exceptions are not supported on this platform")
+	}
+	return nil
+}
+
+func implIsUserExceptionRulesSupported() bool {
+	return false
+}
+
+func implIsTemporaryExceptionsSupported() bool {
+	return false
+}
+
+// implOnTemporaryExceptionsUpdated() called when 'temporaryExceptions' value were updated.
+// Temporary exceptions are not supported on this platform (exceptions restricted by protocol/port are not implemented).
+func implOnTemporaryExceptionsUpdated() error {
+	if len(temporaryExceptions) > 0 {
+		temporaryExceptions = nil
+		return fmt.Errorf("temporary exceptions are not supported on this platform")
+	}
+	return nil
+}
+
 // implOnUserExceptionsUpdated() called when 'userExceptions' value were updated. Necessary to update firewall rules.
 func implOnUserExceptionsUpdated() error {
+
 	var expMasks []string
 	for _, mask := range userExceptions {
 		expMasks = append(expMasks, mask.String())
 	}
+	for _, mask := range routeExceptions {
+		expMasks = append(expMasks, mask.String())
+	}
+	// resolver exceptions: the host is allowed for any communication (exceptions restricted by port are not implemented)
+	for _, r := range resolverExceptions {
+		expMasks = append(expMasks, r.Network.String())
+	}
 
 	return applySetUserExceptions(expMasks)
 }
@@ -0,0 +1,207 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ForwardPolicy defines how the firewall processes traffic forwarded from the interfaces allowed by InterfaceExceptions
+type ForwardPolicy string
+
+const (
+	// ForwardPolicyTunnel - forwarded traffic is allowed only through the VPN tunnel (blocked when VPN is disconnected)
+	ForwardPolicyTunnel ForwardPolicy = "tunnel"
+	// ForwardPolicyBlock - forwarded traffic is blocked (only local traffic between host and the interface is allowed)
+	ForwardPolicyBlock ForwardPolicy = "block"
+)
+
+// InterfaceExceptions - firewall exceptions defined by network interface name patterns
+// (e.g. bridges of containers and virtual machines: "docker0", "br-*", "virbr*", "cni-podman*")
+// Local traffic between host and the matching interfaces is allowed when firewall is enabled.
+// (applicable only for Linux)
+type InterfaceExceptions struct {
+	Patterns      []string      // interface name patterns (shell glob syntax)
+	ForwardPolicy ForwardPolicy // policy for traffic forwarded from the interfaces (default: ForwardPolicyTunnel)
+}
+
+// uplinkInterfaceNameSamples - typical names of physical network interfaces (ethernet, wifi, mobile broadband).
+// The patterns which are matching any of them are not allowed: it would allow the traffic through the uplink interface.
+var uplinkInterfaceNameSamples = []string{"eth0", "eno1", "ens33", "enp0s3", "enx001122334455", "wlan0", "wlp2s0", "wlx001122334455", "wwan0", "wwp0s20f0u6"}
+
+// IsEmpty returns true when no interface patterns defined
+func (ie InterfaceExceptions) IsEmpty() bool {
+	return len(ie.Patterns) == 0
+}
+
+// Normalize returns configuration with trimmed and sorted patterns (duplicates removed) and with default values
+func (ie InterfaceExceptions) Normalize() InterfaceExceptions {
+	ret := InterfaceExceptions{ForwardPolicy: ie.ForwardPolicy}
+	if ret.ForwardPolicy == "" {
+		ret.ForwardPolicy = ForwardPolicyTunnel
+	}
+
+	unique := make(map[string]struct{}, len(ie.Patterns))
+	for _, p := range ie.Patterns {
+		p = strings.TrimSpace(p)
+		if _, exists := unique[p]; len(p) == 0 || exists {
+			continue
+		}
+		unique[p] = struct{}{}
+		ret.Patterns = append(ret.Patterns, p)
+	}
+	sort.Strings(ret.Patterns)
+	return ret
+}
+
+// Validate checks configuration
+func (ie InterfaceExceptions) Validate() error {
+	switch ie.ForwardPolicy {
+	case "", ForwardPolicyTunnel, ForwardPolicyBlock:
+	default:
+		return fmt.Errorf("unknown forward policy '%s' (expected: %s, %s)", ie.ForwardPolicy, ForwardPolicyTunnel, ForwardPolicyBlock)
+	}
+
+	for _, p := range ie.Patterns {
+		p = strings.TrimSpace(p)
+		if len(p) == 0 {
+			continue
+		}
+		if len(p) > 15 && !strings.ContainsAny(p, "*?[") {
+			return fmt.Errorf("bad interface name '%s': name is too long", p)
+		}
+		if strings.ContainsAny(p, " /,") {
+			return fmt.Errorf("bad interface name pattern '%s'", p)
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("bad interface name pattern '%s': %w", p, err)
+		}
+		if strings.ContainsAny(p, "*?[") {
+			for _, name := range uplinkInterfaceNameSamples {
+				if ok, _ := filepath.Match(p, name); ok {
+					return fmt.Errorf("bad interface name pattern '%s': the pattern matches physical network interfaces (e.g. '%s')", p, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Equal returns true when configurations are equal
+func (ie InterfaceExceptions) Equal(x InterfaceExceptions) bool {
+	a, b := ie.Normalize(), x.Normalize()
+	if a.ForwardPolicy != b.ForwardPolicy || len(a.Patterns) != len(b.Patterns) {
+		return false
+	}
+	for i := range a.Patterns {
+		if a.Patterns[i] != b.Patterns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	interfaceExceptions InterfaceExceptions
+	// interfaces which are currently allowed (matching interfaceExceptions patterns)
+	interfaceExceptionsActive []string
+)
+
+// SetInterfaceExceptions - allow local traffic for the network interfaces which names are matching patterns
+func SetInterfaceExceptions(cfg InterfaceExceptions) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	interfaceExceptions = cfg.Normalize()
+	log.Info(fmt.Sprintf("Interface exceptions: %v (forward policy: %s)", interfaceExceptions.Patterns, interfaceExceptions.ForwardPolicy))
+
+	err := implOnInterfaceExceptionsUpdated()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// GetInterfaceExceptions returns current configuration of interface exceptions and list of interfaces which are currently allowed
+func GetInterfaceExceptions() (cfg InterfaceExceptions, activeInterfaces []string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return interfaceExceptions, append([]string{}, interfaceExceptionsActive...)
+}
+
+// getInterfacesMatchingPatterns returns names of existing network interfaces which are matching the patterns
+// The protected interfaces (e.g. default route interface or VPN interface) are never allowed: they are returned in 'skipped' list.
+func getInterfacesMatchingPatterns(patterns []string, protected []string) (ifaceNames []string, skipped []string, err error) {
+	if len(patterns) == 0 {
+		return nil, nil, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	names := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	ifaceNames, skipped = filterInterfaceNames(names, patterns, protected)
+	return ifaceNames, skipped, nil
+}
+
+// filterInterfaceNames returns sorted list of names which are matching any of the patterns
+// (the protected names which are matching the patterns are returned in 'skipped' list)
+func filterInterfaceNames(names []string, patterns []string, protected []string) (ret []string, skipped []string) {
+	isProtected := func(name string) bool {
+		for _, p := range protected {
+			if p == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range names {
+		for _, p := range patterns {
+			if ok, _ := filepath.Match(p, name); ok {
+				if isProtected(name) {
+					skipped = append(skipped, name)
+				} else {
+					ret = append(ret, name)
+				}
+				break
+			}
+		}
+	}
+	sort.Strings(ret)
+	sort.Strings(skipped)
+	return ret, skipped
+}
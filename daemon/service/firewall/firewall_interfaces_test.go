@@ -0,0 +1,137 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterfaceExceptionsValidate(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		policy   ForwardPolicy
+		isValid  bool
+	}{
+		{nil, "", true},
+		{[]string{"docker0", "br-*", "virbr*", "cni-podman*"}, "", true},
+		{[]string{"[ab]r0", "veth?"}, ForwardPolicyTunnel, true},
+		{[]string{"docker0", "", "  "}, ForwardPolicyBlock, true},
+		{[]string{" docker0 "}, "", true},
+		{[]string{"verylongname012"}, "", true},
+		{[]string{"verylongname0123*"}, "", true},
+
+		{[]string{"docker0"}, "allow", false},
+		{[]string{"docker0"}, "Tunnel", false},
+		{[]string{"verylongname0123"}, "", false},
+		{[]string{"br 0"}, "", false},
+		{[]string{"a/b"}, "", false},
+		{[]string{"a,b"}, "", false},
+		{[]string{"br-["}, "", false},
+		{[]string{"docker0", "br\\"}, "", false},
+		{[]string{"*"}, "", false},
+		{[]string{"e*"}, "", false},
+		{[]string{"en*"}, "", false},
+		{[]string{"wl*"}, "", false},
+		{[]string{"eth?"}, "", false},
+		{[]string{"docker0", "ww*"}, "", false},
+	}
+	for _, tt := range tests {
+		err := InterfaceExceptions{Patterns: tt.patterns, ForwardPolicy: tt.policy}.Validate()
+		if tt.isValid && err != nil {
+			t.Errorf("%q (policy '%s'): unexpected error: %v", tt.patterns, tt.policy, err)
+		}
+		if !tt.isValid && err == nil {
+			t.Errorf("%q (policy '%s'): error expected", tt.patterns, tt.policy)
+		}
+	}
+}
+
+func TestInterfaceExceptionsNormalize(t *testing.T) {
+	tests := []struct {
+		in   InterfaceExceptions
+		want InterfaceExceptions
+	}{
+		{InterfaceExceptions{}, InterfaceExceptions{ForwardPolicy: ForwardPolicyTunnel}},
+		{InterfaceExceptions{Patterns: []string{"", " "}}, InterfaceExceptions{ForwardPolicy: ForwardPolicyTunnel}},
+		{
+			InterfaceExceptions{Patterns: []string{"virbr*", " docker0", "br-*", "docker0 ", "virbr*"}, ForwardPolicy: ForwardPolicyBlock},
+			InterfaceExceptions{Patterns: []string{"br-*", "docker0", "virbr*"}, ForwardPolicy: ForwardPolicyBlock},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Normalize(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: unexpected result %+v (expected %+v)", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInterfaceExceptionsEqual(t *testing.T) {
+	tests := []struct {
+		a, b  InterfaceExceptions
+		equal bool
+	}{
+		{InterfaceExceptions{}, InterfaceExceptions{ForwardPolicy: ForwardPolicyTunnel}, true},
+		{InterfaceExceptions{Patterns: []string{"br-*", "docker0"}}, InterfaceExceptions{Patterns: []string{" docker0", "br-*", "br-*"}}, true},
+		{InterfaceExceptions{Patterns: []string{"docker0"}}, InterfaceExceptions{Patterns: []string{"docker0"}, ForwardPolicy: ForwardPolicyBlock}, false},
+		{InterfaceExceptions{Patterns: []string{"docker0"}}, InterfaceExceptions{Patterns: []string{"docker0", "br-*"}}, false},
+		{InterfaceExceptions{Patterns: []string{"docker0"}}, InterfaceExceptions{Patterns: []string{"docker1"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.equal {
+			t.Errorf("%+v == %+v: unexpected result %v", tt.a, tt.b, got)
+		}
+		if got := tt.b.Equal(tt.a); got != tt.equal {
+			t.Errorf("%+v == %+v: unexpected result %v", tt.b, tt.a, got)
+		}
+	}
+}
+
+func TestFilterInterfaceNames(t *testing.T) {
+	names := []string{"lo", "eth0", "wlan0", "docker0", "br-1a2b3c", "virbr0", "veth12ab", "wgivpn"}
+	tests := []struct {
+		patterns  []string
+		protected []string
+		want      []string
+		skipped   []string
+	}{
+		{nil, nil, nil, nil},
+		{[]string{"docker0"}, nil, []string{"docker0"}, nil},
+		{[]string{"br-*", "virbr*"}, nil, []string{"br-1a2b3c", "virbr0"}, nil},
+		{[]string{"*br*", "docker?"}, nil, []string{"br-1a2b3c", "docker0", "virbr0"}, nil},
+		{[]string{"tun*"}, nil, nil, nil},
+		// default route interface and VPN interface are never allowed
+		{[]string{"*"}, []string{"eth0", "wgivpn"}, []string{"br-1a2b3c", "docker0", "lo", "veth12ab", "virbr0", "wlan0"}, []string{"eth0", "wgivpn"}},
+		{[]string{"eth0", "docker0"}, []string{"eth0"}, []string{"docker0"}, []string{"eth0"}},
+		{[]string{"wg*"}, []string{"eth0", "wgivpn"}, nil, []string{"wgivpn"}},
+	}
+	for _, tt := range tests {
+		got, skipped := filterInterfaceNames(names, tt.patterns, tt.protected)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: unexpected result %q (expected %q)", tt.patterns, got, tt.want)
+		}
+		if !reflect.DeepEqual(skipped, tt.skipped) {
+			t.Errorf("%q: unexpected skipped interfaces %q (expected %q)", tt.patterns, skipped, tt.skipped)
+		}
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 	"github.com/ivpn/desktop-app/daemon/shell"
 )
@@ -41,6 +42,7 @@ var (
 	allowedForICMP map[string]struct{} // IP addresses allowed for ICMP
 
 	curAllowedLanIPs          []string // IP addresses allowed for LAN
+	curAllowedLanIPv6         []string // IPv6 addresses allowed for LAN
 	curStateAllowLAN          bool     // Allow LAN is enabled
 	curStateAllowLanMulticast bool     // Allow Multicast is enabled
 	curStateEnabled           bool     // Firewall is enabled
@@ -53,9 +55,49 @@ func init() {
 }
 
 func implInitialize() error {
+	// Monitor network interfaces changes: the interface exceptions must be re-applied when new interface appears
+	// (e.g. bridge interface created by docker/libvirt)
+	onLinkChange := make(chan struct{}, 1)
+	if err := netlink.RegisterLinkChangeListener(onLinkChange); err != nil {
+		log.Warning(fmt.Sprintf("interface exceptions will not be updated on network interfaces changes: %v", err))
+		return nil
+	}
+	go func() {
+		var timerDelay *time.Timer
+		for range onLinkChange {
+			if timerDelay != nil {
+				timerDelay.Stop()
+			}
+			// We can receive many 'link change' events in a short period of time
+			// but we update rules not more often than once per second.
+			timerDelay = time.AfterFunc(time.Second, onNetworkInterfacesChanged)
+		}
+	}()
 	return nil
 }
 
+func onNetworkInterfacesChanged() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if interfaceExceptions.IsEmpty() && len(interfaceExceptionsActive) == 0 {
+		return
+	}
+
+	ifaces, _, err := getInterfacesMatchingPatterns(interfaceExceptions.Patterns, getProtectedInterfaces())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if strings.Join(ifaces, ",") == strings.Join(interfaceExceptionsActive, ",") {
+		return // nothing changed
+	}
+
+	if err := implOnInterfaceExceptionsUpdated(); err != nil {
+		log.Error(err)
+	}
+}
+
 func implGetEnabled() (bool, error) {
 	err := shell.Exec(nil, platform.FirewallScript(), "-status")
 
@@ -88,8 +130,10 @@ func implSetEnabled(isEnabled bool) error {
 
 	// disable FW ...
 	curAllowedLanIPs = nil // forget allowed LAN IP addresses
+	curAllowedLanIPv6 = nil
 	isPersistant = false
 	allowedForICMP = nil
+	appAllowlistStop()
 	return shell.Exec(nil, platform.FirewallScript(), "-disable")
 }
 
@@ -161,6 +205,16 @@ func implClientConnected(clientLocalIPAddress net.IP, clientLocalIPv6Address net
 		return fmt.Errorf("failed to add rule for current connection directions: %w", err)
 	}
 
+	// The VPN interface must not be allowed by interface exceptions
+	for _, iface := range interfaceExceptionsActive {
+		if iface == inf.Name {
+			if err := implOnInterfaceExceptionsUpdated(); err != nil {
+				log.Error(err)
+			}
+			break
+		}
+	}
+
 	// Connection already established. The rule for VPN interface is defined.
 	// Removing host IP from exceptions
 	return removeHostsFromExceptions([]string{serverIP.String()}, false, false)
@@ -205,25 +259,39 @@ func doAllowLAN(isAllowLAN, isAllowLanMulticast bool) error {
 	}
 	curAllowedLanIPs = nil
 
+	if len(curAllowedLanIPv6) > 0 {
+		if err := applyIPv6StaticExceptions("-remove_exceptions_static_ipv6", curAllowedLanIPv6); err != nil {
+			log.Warning("failed to erase 'Allow LAN' rules (IPv6)")
+		}
+	}
+	curAllowedLanIPv6 = nil
+
 	if !isAllowLAN {
 		return nil // LAN NOT ALLOWED
 	}
 
-	// LAN ALLOWED
+	// LAN ALLOWED (+ multicast, if enabled)
+	const ipV6 = true
+	curAllowedLanIPs = ipNetListToStrings(getLanRanges(!ipV6, isAllowLanMulticast))
+	curAllowedLanIPv6 = ipNetListToStrings(getLanRanges(ipV6, isAllowLanMulticast))
 
-	// TODO: implement LAN access also for IPv6 addresses
-	const ipV4 = false
-	localRanges := ipNetListToStrings(filterIPNetList(netinfo.GetNonRoutableLocalAddrRanges(), ipV4))
-	multicastRanges := ipNetListToStrings(filterIPNetList(netinfo.GetMulticastAddresses(), ipV4))
-
-	curAllowedLanIPs = localRanges
-	if isAllowLanMulticast {
-		// allow LAN + multicast
-		curAllowedLanIPs = append(curAllowedLanIPs, multicastRanges...)
+	// allow LAN
+	err := addHostsToExceptions(curAllowedLanIPs, persistant, notOnlyForICMP)
+	if errIPv6 := applyIPv6StaticExceptions("-add_exceptions_static_ipv6", curAllowedLanIPv6); err == nil {
+		err = errIPv6
 	}
+	return err
+}
 
-	// allow LAN
-	return addHostsToExceptions(curAllowedLanIPs, persistant, notOnlyForICMP)
+// applyIPv6StaticExceptions adds/removes IPv6 addresses (masks) to/from static exceptions
+// (scriptCommand: "-add_exceptions_static_ipv6" or "-remove_exceptions_static_ipv6")
+func applyIPv6StaticExceptions(scriptCommand string, masks []string) error {
+	if len(masks) == 0 {
+		return nil
+	}
+	ipList := strings.Join(masks, ",")
+	log.Info(scriptCommand, " ", ipList)
+	return shell.Exec(nil, platform.FirewallScript(), scriptCommand, ipList)
 }
 
 // implAddHostsToExceptions - allow communication with this hosts
@@ -269,6 +337,46 @@ func implOnChangeDNS(addr net.IP, isInternal bool) error {
 	return shell.Exec(nil, platform.FirewallScript(), "-set_dns", addrStr)
 }
 
+// getProtectedInterfaces returns names of the interfaces which must not be allowed by interface exceptions:
+// the interfaces of default routes (IPv4 and IPv6) and the VPN interface
+func getProtectedInterfaces() []string {
+	ret, err := netinfo.DefaultRouteInterfaces()
+	if err != nil {
+		log.Warning(fmt.Sprintf("failed to get default route interfaces: %v", err))
+	}
+	if connectedClientInterfaceIP != nil {
+		if inf, err := netinfo.InterfaceByIPAddr(connectedClientInterfaceIP); err == nil {
+			ret = append(ret, inf.Name)
+		}
+	}
+	return ret
+}
+
+// implOnInterfaceExceptionsUpdated() called when 'interfaceExceptions' value were updated
+// (or when network interfaces changed). Necessary to update firewall rules.
+func implOnInterfaceExceptionsUpdated() error {
+	ifaces, skipped, err := getInterfacesMatchingPatterns(interfaceExceptions.Patterns, getProtectedInterfaces())
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		log.Warning(fmt.Sprintf("Interface exceptions: ignoring default route or VPN interfaces %v (matching patterns %v)", skipped, interfaceExceptions.Patterns))
+	}
+	interfaceExceptionsActive = ifaces
+
+	if !curStateEnabled {
+		return nil
+	}
+
+	policy := interfaceExceptions.ForwardPolicy
+	if policy == "" {
+		policy = ForwardPolicyTunnel
+	}
+
+	log.Info("-set_interface_exceptions ", policy, " ", strings.Join(ifaces, " "))
+	return shell.Exec(nil, platform.FirewallScript(), append([]string{"-set_interface_exceptions", string(policy)}, ifaces...)...)
+}
+
 // implOnUserExceptionsUpdated() called when 'userExceptions' value were updated. Necessary to update firewall rules.
 func implOnUserExceptionsUpdated() error {
 
@@ -293,7 +401,28 @@ func implOnUserExceptionsUpdated() error {
 			log.Info(scriptCommand, " ", ipList)
 		}
 
-		return shell.Exec(nil, platform.FirewallScript(), scriptCommand, ipList)
+		if err := shell.Exec(nil, platform.FirewallScript(), scriptCommand, ipList); err != nil {
+			return err
+		}
+
+		// exceptions restricted by protocol/port/direction
+		var rules []string
+		for _, r := range append(append([]UserException{}, userExceptionRules...), resolverExceptions...) {
+			if r.IsIPv6() == isIpv4 {
+				continue
+			}
+			rules = append(rules, fmt.Sprintf("%s,%s,%s,%s", r.Direction, r.Protocol, r.PortString(), r.Network.String()))
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+
+		scriptCommand = "-add_user_exception_rules"
+		if !isIpv4 {
+			scriptCommand = "-add_user_exception_rules_ipv6"
+		}
+		log.Info(scriptCommand, " ", strings.Join(rules, " "))
+		return shell.Exec(nil, platform.FirewallScript(), append([]string{scriptCommand}, rules...)...)
 	}
 
 	err := applyFunc(false)
@@ -304,6 +433,32 @@ func implOnUserExceptionsUpdated() error {
 	return err
 }
 
+func implIsUserExceptionRulesSupported() bool {
+	return true
+}
+
+func implIsTemporaryExceptionsSupported() bool {
+	return true
+}
+
+// implOnTemporaryExceptionsUpdated() called when 'temporaryExceptions' value were updated. Necessary to update firewall rules.
+func implOnTemporaryExceptionsUpdated() error {
+	if !curStateEnabled {
+		return nil
+	}
+
+	var rules []string
+	for _, r := range temporaryExceptions {
+		if r.IsIPv6() {
+			continue
+		}
+		rules = append(rules, fmt.Sprintf("%s,%s,%s,%s", r.Direction, r.Protocol, r.PortString(), r.Network.String()))
+	}
+
+	log.Info("-set_temporary_exceptions ", strings.Join(rules, " "))
+	return shell.Exec(nil, platform.FirewallScript(), append([]string{"-set_temporary_exceptions"}, rules...)...)
+}
+
 func implSingleDnsRuleOff() (retErr error) {
 	return shell.Exec(log, platform.FirewallScript(), "-only_dns_off")
 }
@@ -413,6 +568,24 @@ func reApplyExceptions() error {
 		log.Error(err)
 	}
 
+	if errIf := implOnInterfaceExceptionsUpdated(); errIf != nil {
+		log.Error(errIf)
+	}
+
+	if len(temporaryExceptions) > 0 {
+		if errTmp := implOnTemporaryExceptionsUpdated(); errTmp != nil {
+			log.Error(errTmp)
+		}
+	}
+
+	if errLog := applyBlockedPacketsLogRules(); errLog != nil {
+		log.Error(errLog)
+	}
+
+	if errApps := implOnAppAllowlistUpdated(); errApps != nil {
+		log.Error(errApps)
+	}
+
 	return err
 }
 
@@ -526,7 +699,7 @@ func getAllowedIpExceptions() (prioritized, persistant []string) {
 
 func getUserExceptions(ipv4, ipv6 bool) []net.IPNet {
 	ret := []net.IPNet{}
-	for _, e := range userExceptions {
+	for _, e := range append(append([]net.IPNet{}, userExceptions...), routeExceptions...) {
 		isIPv6 := e.IP.To4() == nil
 		isIPv4 := !isIPv6
 
@@ -0,0 +1,61 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"reflect"
+	"strings"
+)
+
+// List of DNS resolvers which are temporarily allowed to resolve host names of the user exceptions (see GetUserExceptionHosts)
+// and other hosts temporarily required by the daemon (e.g. network reachability probes).
+// The exceptions are restricted by protocol/port (DoH/DoT port of the resolver) on Linux;
+// on other platforms the resolver host is allowed for any communication. They are not saved in preferences.
+var resolverExceptions []UserException
+
+// SetResolverExceptions - replace the list of resolver exceptions. Call with empty list to remove all resolver exceptions.
+func SetResolverExceptions(rules []UserException) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if reflect.DeepEqual(resolverExceptions, rules) || (len(resolverExceptions) == 0 && len(rules) == 0) {
+		return nil
+	}
+	resolverExceptions = append([]UserException{}, rules...)
+
+	if len(resolverExceptions) > 0 {
+		var rulesStr []string
+		for _, r := range resolverExceptions {
+			rulesStr = append(rulesStr, r.String())
+		}
+		log.Info("Resolver exceptions: ", strings.Join(rulesStr, " "))
+	} else {
+		log.Info("Resolver exceptions: removed")
+	}
+
+	err := implOnUserExceptionsUpdated()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"net"
+	"reflect"
+	"strings"
+)
+
+// List of networks routed outside the VPN tunnel (destination-based split tunnelling). They are not saved in preferences.
+var routeExceptions []net.IPNet
+
+// SetRouteExceptions - replace the list of networks which are routed outside the VPN tunnel (destination-based split tunnelling).
+// The networks are allowed for any communication. Call with empty list to remove all route exceptions.
+func SetRouteExceptions(networks []net.IPNet) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if reflect.DeepEqual(routeExceptions, networks) || (len(routeExceptions) == 0 && len(networks) == 0) {
+		return nil
+	}
+	routeExceptions = append([]net.IPNet{}, networks...)
+
+	if len(routeExceptions) > 0 {
+		log.Info("Split tunnel route exceptions: ", strings.Join(ipNetListToStrings(routeExceptions), " "))
+	} else {
+		log.Info("Split tunnel route exceptions: removed")
+	}
+
+	err := implOnUserExceptionsUpdated()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// GetRouteExceptions returns current list of route exceptions
+func GetRouteExceptions() []net.IPNet {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return append([]net.IPNet{}, routeExceptions...)
+}
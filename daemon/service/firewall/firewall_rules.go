@@ -0,0 +1,262 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"net"
+	"sort"
+)
+
+// HostCategory - the reason why the host is allowed by the firewall
+type HostCategory string
+
+const (
+	HostCategoryVpnServer   HostCategory = "vpn_server"  // VPN server of the current connection
+	HostCategoryApi         HostCategory = "api"         // IVPN API servers
+	HostCategoryPing        HostCategory = "ping"        // servers pinging
+	HostCategoryUser        HostCategory = "user"        // user exceptions
+	HostCategoryLan         HostCategory = "lan"         // 'Allow LAN' configuration
+	HostCategoryIcmpOnly    HostCategory = "icmp_only"   // only ICMP protocol allowed (Linux only)
+	HostCategoryPrioritized HostCategory = "prioritized" // allowed until disconnection; not affected by DNS restrictions
+	HostCategorySplitRoute  HostCategory = "split_route" // routed outside the VPN tunnel (destination-based split tunnelling)
+)
+
+// RuleSetHost - host (or network) allowed by the firewall
+type RuleSetHost struct {
+	Address    string // IP address or network in CIDR notation
+	Categories []HostCategory
+}
+
+// HasCategory returns true if the host belongs to the category
+func (h RuleSetHost) HasCategory(c HostCategory) bool {
+	for _, hc := range h.Categories {
+		if hc == c {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleSetVpnServer - VPN server of the current connection
+type RuleSetVpnServer struct {
+	Address  string
+	Port     int
+	Protocol string // "udp" or "tcp"
+}
+
+// RuleSetDns - DNS restriction
+type RuleSetDns struct {
+	Dns            string // DNS configuration in use (empty if not defined)
+	AllowedAddress string // the only address allowed for plain DNS requests (port 53); empty - all plain DNS requests are blocked
+}
+
+// RuleSetConsistency - result of comparing the expected rules with the rules present in the kernel
+type RuleSetConsistency struct {
+	IsChecked    bool     // false - the check is not supported on this platform
+	IsConsistent bool     // true - all expected rules are present in the kernel
+	Issues       []string // description of detected inconsistencies
+}
+
+// RuleSet - structured description of the active firewall rules
+type RuleSet struct {
+	IsEnabled           bool
+	IsPersistent        bool
+	IsAllowLAN          bool
+	IsAllowLANMulticast bool
+
+	VpnServer       *RuleSetVpnServer // nil if not connected
+	ClientAddresses []string          // local addresses of the VPN tunnel
+	Dns             RuleSetDns
+	Hosts           []RuleSetHost
+	// user exceptions restricted by protocol, port or direction (see UserException)
+	UserExceptionRules []string
+	// temporary exceptions (e.g. captive portal access; see SetTemporaryExceptions)
+	TemporaryExceptions []string
+	// network interfaces allowed by the firewall (see SetInterfaceExceptions)
+	Interfaces []string
+
+	Consistency RuleSetConsistency
+}
+
+// exceptionHostInfo - the way the host was allowed by AddHostsToExceptions()
+type exceptionHostInfo struct {
+	isPersistent  bool
+	isPrioritized bool
+	isIcmpOnly    bool
+}
+
+func (i exceptionHostInfo) isEmpty() bool {
+	return !i.isPersistent && !i.isPrioritized && !i.isIcmpOnly
+}
+
+// exceptionHosts - hosts allowed by AddHostsToExceptions() (used only for the rules inspection)
+var exceptionHosts = map[string]exceptionHostInfo{}
+
+func exceptionHostsUpdate(IPs []net.IP, onlyForICMP bool, isPersistent bool, isAdd bool) {
+	for _, ip := range IPs {
+		if ip == nil {
+			continue
+		}
+		key := ip.String()
+		info := exceptionHosts[key]
+		switch {
+		case onlyForICMP:
+			info.isIcmpOnly = isAdd
+		case isPersistent:
+			info.isPersistent = isAdd
+		default:
+			info.isPrioritized = isAdd
+		}
+
+		if info.isEmpty() {
+			delete(exceptionHosts, key)
+		} else {
+			exceptionHosts[key] = info
+		}
+	}
+}
+
+// exceptionHostsRemoveNonPersistent forgets all hosts allowed until disconnection
+func exceptionHostsRemoveNonPersistent() {
+	for key, info := range exceptionHosts {
+		info.isPrioritized = false
+		if info.isEmpty() {
+			delete(exceptionHosts, key)
+		} else {
+			exceptionHosts[key] = info
+		}
+	}
+}
+
+// GetRules returns structured description of the active firewall rules
+// and the result of their consistency check with the rules present in the kernel
+func GetRules() (RuleSet, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	isEnabled, err := implGetEnabled()
+	if err != nil {
+		return RuleSet{}, err
+	}
+
+	rs := RuleSet{
+		IsEnabled:           isEnabled,
+		IsAllowLAN:          stateAllowLan,
+		IsAllowLANMulticast: stateAllowLanMulticast,
+		Interfaces:          append([]string{}, interfaceExceptionsActive...),
+	}
+
+	isConnected := connectedClientInterfaceIP != nil
+	if isConnected {
+		rs.ClientAddresses = append(rs.ClientAddresses, connectedClientInterfaceIP.String())
+		if connectedClientInterfaceIPv6 != nil {
+			rs.ClientAddresses = append(rs.ClientAddresses, connectedClientInterfaceIPv6.String())
+		}
+		if connectedHostIP != nil {
+			protocol := "udp"
+			if connectedIsTCP {
+				protocol = "tcp"
+			}
+			rs.VpnServer = &RuleSetVpnServer{Address: connectedHostIP.String(), Port: connectedHostPort, Protocol: protocol}
+		}
+	}
+
+	if dnsConfig != nil {
+		rs.Dns.Dns = dnsConfig.InfoString()
+	}
+	if addr, _ := getDnsIP(); addr != nil {
+		rs.Dns.AllowedAddress = addr.String()
+	}
+
+	// hosts
+	hosts := make(map[string][]HostCategory)
+	addHost := func(addr string, c HostCategory) {
+		for _, existing := range hosts[addr] {
+			if existing == c {
+				return
+			}
+		}
+		hosts[addr] = append(hosts[addr], c)
+	}
+
+	if rs.VpnServer != nil {
+		addHost(rs.VpnServer.Address, HostCategoryVpnServer)
+	}
+	for addr, info := range exceptionHosts {
+		if info.isPrioritized {
+			addHost(addr, HostCategoryPrioritized)
+		}
+		if info.isIcmpOnly {
+			addHost(addr, HostCategoryPing)
+			if isIcmpOnlyExceptionsSupported {
+				addHost(addr, HostCategoryIcmpOnly)
+			}
+		}
+		if info.isPersistent && len(hosts[addr]) == 0 {
+			hosts[addr] = []HostCategory{}
+		}
+	}
+	for _, n := range userExceptions {
+		addHost(n.String(), HostCategoryUser)
+	}
+	for _, n := range routeExceptions {
+		addHost(n.String(), HostCategorySplitRoute)
+	}
+	if stateAllowLan {
+		for _, isIPv6 := range []bool{false, true} {
+			for _, n := range getLanRanges(isIPv6, stateAllowLanMulticast) {
+				addHost(n.String(), HostCategoryLan)
+			}
+		}
+	}
+
+	for addr, categories := range hosts {
+		rs.Hosts = append(rs.Hosts, RuleSetHost{Address: addr, Categories: categories})
+	}
+	sort.Slice(rs.Hosts, func(i, j int) bool { return rs.Hosts[i].Address < rs.Hosts[j].Address })
+
+	for _, r := range userExceptionRules {
+		rs.UserExceptionRules = append(rs.UserExceptionRules, r.String())
+	}
+	for _, r := range temporaryExceptions {
+		rs.TemporaryExceptions = append(rs.TemporaryExceptions, r.String())
+	}
+
+	rs.Consistency = implCheckRules(rs)
+	return rs, nil
+}
+
+// hostToNetString converts IP address (or network) to CIDR notation (e.g. "192.0.2.1" => "192.0.2.1/32")
+func hostToNetString(addr string) string {
+	if _, n, err := net.ParseCIDR(addr); err == nil {
+		return n.String()
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
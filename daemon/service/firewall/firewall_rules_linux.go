@@ -0,0 +1,205 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+const isIcmpOnlyExceptionsSupported = true
+
+// Chain names (must be the same as in firewall.sh)
+const (
+	chainOut        = "IVPN-OUT"
+	chainIn         = "IVPN-IN"
+	chainOutIf0     = "IVPN-OUT-VPN0"
+	chainOutIf1     = "IVPN-OUT-VPN"
+	chainOutDns     = "IVPN-OUT-DNS"
+	chainOutStatExp = "IVPN-OUT-STAT-EXP"
+	chainOutUserExp = "IVPN-OUT-STAT-USER-EXP"
+	chainOutIcmpExp = "IVPN-OUT-ICMP-EXP"
+)
+
+// iptablesRules - rules (in 'iptables -S' format) present in the kernel
+type iptablesRules []string
+
+func getIptablesRules(bin string) (iptablesRules, error) {
+	outText, outErrText, _, isBufferTooSmall, err := shell.ExecAndGetOutput(nil, 1024*1024, "", bin, "-w", "2", "-S")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get '%s' rules: %w (%s)", bin, err, strings.TrimSpace(outErrText))
+	}
+	if isBufferTooSmall {
+		return nil, fmt.Errorf("failed to get '%s' rules: too many rules", bin)
+	}
+	return strings.Split(outText, "\n"), nil
+}
+
+// has returns true if exists a rule which starts with the prefix
+func (r iptablesRules) has(prefix string) bool {
+	for _, l := range r {
+		if strings.HasPrefix(strings.TrimSpace(l), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedDestinations returns the destinations of simple rules '-A CHAIN -d ADDR -j ACCEPT' of the chain
+func (r iptablesRules) acceptedDestinations(chain string) []string {
+	var ret []string
+	for _, l := range r {
+		f := strings.Fields(l)
+		if len(f) == 6 && f[0] == "-A" && f[1] == chain && f[2] == "-d" && f[4] == "-j" && f[5] == "ACCEPT" {
+			ret = append(ret, f[3])
+		}
+	}
+	return ret
+}
+
+// implCheckRules compares the expected rule set with the rules present in iptables/ip6tables
+func implCheckRules(rs RuleSet) RuleSetConsistency {
+	ret := RuleSetConsistency{IsChecked: true}
+	addIssue := func(format string, a ...any) {
+		ret.Issues = append(ret.Issues, fmt.Sprintf(format, a...))
+	}
+
+	if rs.IsEnabled != curStateEnabled {
+		addIssue("firewall state in the kernel (enabled=%t) differs from the expected state (enabled=%t)", rs.IsEnabled, curStateEnabled)
+	}
+	if !rs.IsEnabled {
+		ret.IsConsistent = len(ret.Issues) == 0
+		return ret
+	}
+
+	rules4, err := getIptablesRules("iptables")
+	if err != nil {
+		addIssue("%v", err)
+		return ret
+	}
+	var rules6 iptablesRules
+	if _, err := os.Stat("/proc/net/if_inet6"); err == nil {
+		if rules6, err = getIptablesRules("ip6tables"); err != nil {
+			addIssue("%v", err)
+			return ret
+		}
+	}
+	// main chains
+	for _, jump := range []string{"-A OUTPUT -j " + chainOut, "-A INPUT -j " + chainIn} {
+		if !rules4.has(jump) {
+			addIssue("missing rule '%s'", jump)
+		}
+		if rules6 != nil && !rules6.has(jump) {
+			addIssue("missing rule '%s' (IPv6)", jump)
+		}
+	}
+
+	// VPN connection
+	if rs.VpnServer != nil {
+		prefix := fmt.Sprintf("-A %s -d %s -p %s", chainOutIf0, hostToNetString(rs.VpnServer.Address), rs.VpnServer.Protocol)
+		if !rules4.has(prefix) {
+			addIssue("missing rule for VPN server %s", rs.VpnServer.Address)
+		}
+		if !rules4.has("-A " + chainOutIf1 + " -o ") {
+			addIssue("missing rule for VPN interface")
+		}
+	}
+
+	// DNS
+	if rs.Dns.AllowedAddress != "" {
+		if !rules4.has(fmt.Sprintf("-A %s ! -d %s -p udp", chainOutDns, hostToNetString(rs.Dns.AllowedAddress))) {
+			addIssue("missing DNS rule for %s", rs.Dns.AllowedAddress)
+		}
+	} else if !rules4.has(fmt.Sprintf("-A %s -p udp -m udp --dport 53 -j DROP", chainOutDns)) {
+		addIssue("missing rule blocking DNS requests")
+	}
+
+	// Hosts: expected addresses for each chain (according to the internal state)
+	mutexInternal.Lock()
+	expected := map[string][]string{
+		chainOutIf0:     {},
+		chainOutStatExp: append([]string{}, curAllowedLanIPv6...),
+		chainOutUserExp: ipNetListToStrings(append(append([]net.IPNet{}, userExceptions...), routeExceptions...)),
+	}
+	for _, r := range userExceptionRules {
+		if r.Protocol == "" {
+			// rules restricted only by direction (e.g. "out@10.0.0.0/8")
+			expected[chainOutUserExp] = append(expected[chainOutUserExp], r.Network.String())
+		}
+	}
+	for ip, isPersistent := range allowedHosts {
+		if isPersistent {
+			expected[chainOutStatExp] = append(expected[chainOutStatExp], ip)
+		} else {
+			expected[chainOutIf0] = append(expected[chainOutIf0], ip)
+		}
+	}
+	icmpHosts := make([]string, 0, len(allowedForICMP))
+	for ip := range allowedForICMP {
+		icmpHosts = append(icmpHosts, ip)
+	}
+	mutexInternal.Unlock()
+
+	for chain, addrs := range expected {
+		expectedSet := make(map[string]struct{}, len(addrs))
+		for _, addr := range addrs {
+			netStr := hostToNetString(addr)
+			expectedSet[netStr] = struct{}{}
+
+			rules := rules4
+			if ip, _, err := net.ParseCIDR(netStr); err == nil && ip.To4() == nil {
+				if rules6 == nil {
+					continue
+				}
+				rules = rules6
+			}
+			if !rules.has(fmt.Sprintf("-A %s -d %s -j ACCEPT", chain, netStr)) {
+				addIssue("missing rule in chain %s for %s", chain, addr)
+			}
+		}
+
+		for _, rules := range []iptablesRules{rules4, rules6} {
+			for _, netStr := range rules.acceptedDestinations(chain) {
+				if _, ok := expectedSet[netStr]; !ok {
+					addIssue("unexpected rule in chain %s for %s", chain, netStr)
+				}
+			}
+		}
+	}
+
+	for _, ip := range icmpHosts {
+		if !rules4.has(fmt.Sprintf("-A %s -d %s -p icmp", chainOutIcmpExp, hostToNetString(ip))) {
+			addIssue("missing rule in chain %s for %s", chainOutIcmpExp, ip)
+		}
+	}
+
+	ret.IsConsistent = len(ret.Issues) == 0
+	return ret
+}
@@ -0,0 +1,77 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"strings"
+)
+
+// List of temporary exceptions (e.g. access to captive portal). They are not saved in preferences.
+var temporaryExceptions []UserException
+
+// SetTemporaryExceptions - replace the list of temporary exceptions.
+// The exceptions are intended for short-term access to local network services while the firewall is enabled
+// (e.g. login page of a captive portal). Call with empty list to remove all temporary exceptions.
+// Exceptions restricted by protocol/port are supported (IPv4 only); they are not affected by DNS restrictions.
+// Note: supported only on Linux (see IsTemporaryExceptionsSupported())
+func SetTemporaryExceptions(rules []UserException) error {
+	for _, r := range rules {
+		if r.IsIPv6() {
+			return fmt.Errorf("temporary exception '%s': IPv6 is not supported", r.String())
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	temporaryExceptions = append([]UserException{}, rules...)
+
+	if len(temporaryExceptions) > 0 {
+		var rulesStr []string
+		for _, r := range temporaryExceptions {
+			rulesStr = append(rulesStr, r.String())
+		}
+		log.Info("Temporary exceptions: ", strings.Join(rulesStr, " "))
+	} else {
+		log.Info("Temporary exceptions: removed")
+	}
+
+	err := implOnTemporaryExceptionsUpdated()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// IsTemporaryExceptionsSupported returns 'true' when temporary exceptions are supported on this platform
+func IsTemporaryExceptionsSupported() bool {
+	return implIsTemporaryExceptionsSupported()
+}
+
+// GetTemporaryExceptions returns current list of temporary exceptions
+func GetTemporaryExceptions() []UserException {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return append([]UserException{}, temporaryExceptions...)
+}
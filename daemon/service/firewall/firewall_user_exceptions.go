@@ -0,0 +1,257 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// UserExceptionDirection defines which traffic direction is allowed by a user exception
+type UserExceptionDirection string
+
+const (
+	// UserExceptionDirectionBoth - inbound and outbound connections are allowed (default)
+	UserExceptionDirectionBoth UserExceptionDirection = "both"
+	// UserExceptionDirectionOut - only outbound connections are allowed; inbound traffic is allowed only for established connections
+	UserExceptionDirectionOut UserExceptionDirection = "out"
+)
+
+// UserException - firewall exception defined by user.
+// Format (the part before '@' is optional):
+//
+//	[DIRECTION:][PROTOCOL[:PORT[-PORT]]]@ADDRESS[/MASK]
+//
+// Examples:
+//
+//	192.0.2.0/24
+//	tcp:22@192.0.2.0/24
+//	udp:51820@198.51.100.7
+//	icmp@10.0.0.0/8
+//	out:tcp:8000-8080@192.0.2.1
+//	out@10.0.0.0/8
+type UserException struct {
+	Network   net.IPNet
+	Protocol  string // "" (any protocol), "tcp", "udp" or "icmp"
+	PortFrom  int    // 0 - any port
+	PortTo    int    // equal to PortFrom when single port defined
+	Direction UserExceptionDirection
+}
+
+// IsHostWide returns true when the exception allows any communication with the network (no protocol/port/direction restrictions)
+func (e UserException) IsHostWide() bool {
+	return e.Protocol == "" && e.PortFrom == 0 && e.Direction == UserExceptionDirectionBoth
+}
+
+// IsIPv6 returns true when exception is defined for IPv6 network
+func (e UserException) IsIPv6() bool {
+	return e.Network.IP.To4() == nil
+}
+
+// PortString returns port (or port range) in format "PORT" or "PORT_FROM:PORT_TO" (iptables notation); empty string when port not defined
+func (e UserException) PortString() string {
+	if e.PortFrom <= 0 {
+		return ""
+	}
+	if e.PortTo > e.PortFrom {
+		return fmt.Sprintf("%d:%d", e.PortFrom, e.PortTo)
+	}
+	return strconv.Itoa(e.PortFrom)
+}
+
+// String returns exception in the same format as it can be parsed by ParseUserException()
+func (e UserException) String() string {
+	if e.IsHostWide() {
+		return e.Network.String()
+	}
+
+	var spec []string
+	if e.Direction != UserExceptionDirectionBoth {
+		spec = append(spec, string(e.Direction))
+	}
+	if e.Protocol != "" {
+		spec = append(spec, e.Protocol)
+	}
+	if e.PortFrom > 0 {
+		if e.PortTo > e.PortFrom {
+			spec = append(spec, fmt.Sprintf("%d-%d", e.PortFrom, e.PortTo))
+		} else {
+			spec = append(spec, strconv.Itoa(e.PortFrom))
+		}
+	}
+	return strings.Join(spec, ":") + "@" + e.Network.String()
+}
+
+// IsUserExceptionHost returns true when the exception is defined as a host name (e.g. "git.example.com")
+func IsUserExceptionHost(exp string) bool {
+	exp = strings.TrimSuffix(strings.TrimSpace(exp), ".")
+	if len(exp) == 0 || len(exp) > 253 || net.ParseIP(exp) != nil || !strings.Contains(exp, ".") {
+		return false
+	}
+
+	hasLetter := false
+	for _, label := range strings.Split(exp, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+				hasLetter = true
+			case (c >= '0' && c <= '9') || c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return hasLetter
+}
+
+// ParseUserException parses single firewall exception definition (see UserException for format description)
+func ParseUserException(exp string) (UserException, error) {
+	ret := UserException{Direction: UserExceptionDirectionBoth}
+
+	exp = strings.TrimSpace(exp)
+	if len(exp) == 0 {
+		return ret, fmt.Errorf("empty exception")
+	}
+
+	addrStr := exp
+	if idx := strings.Index(exp, "@"); idx >= 0 {
+		addrStr = exp[idx+1:]
+		if strings.Contains(addrStr, "@") {
+			return ret, fmt.Errorf("'%s': unexpected '@' symbol", exp)
+		}
+		if err := parseUserExceptionSpec(exp[:idx], &ret); err != nil {
+			return ret, fmt.Errorf("'%s': %w", exp, err)
+		}
+	}
+
+	n, err := parseUserExceptionNetwork(addrStr)
+	if err != nil {
+		return ret, fmt.Errorf("'%s': %w", exp, err)
+	}
+	ret.Network = *n
+
+	return ret, nil
+}
+
+// parseUserExceptionSpec parses the part of exception located before '@': [DIRECTION:][PROTOCOL[:PORT[-PORT]]]
+func parseUserExceptionSpec(spec string, e *UserException) error {
+	spec = strings.TrimSpace(spec)
+	if len(spec) == 0 {
+		return fmt.Errorf("protocol or direction must be defined before '@'")
+	}
+
+	fields := strings.Split(strings.ToLower(spec), ":")
+	idx := 0
+
+	switch UserExceptionDirection(fields[idx]) {
+	case UserExceptionDirectionOut, UserExceptionDirectionBoth:
+		e.Direction = UserExceptionDirection(fields[idx])
+		idx++
+	}
+
+	if idx < len(fields) {
+		switch fields[idx] {
+		case "tcp", "udp", "icmp":
+			e.Protocol = fields[idx]
+			idx++
+		default:
+			return fmt.Errorf("unknown protocol or direction '%s' (expected: tcp, udp, icmp, out, both)", fields[idx])
+		}
+	}
+
+	if idx < len(fields) {
+		if e.Protocol == "icmp" {
+			return fmt.Errorf("port is not applicable for icmp protocol")
+		}
+		from, to, err := parseUserExceptionPorts(fields[idx])
+		if err != nil {
+			return err
+		}
+		e.PortFrom, e.PortTo = from, to
+		idx++
+	}
+
+	if idx < len(fields) {
+		return fmt.Errorf("unexpected '%s'", strings.Join(fields[idx:], ":"))
+	}
+	return nil
+}
+
+func parseUserExceptionPorts(portsStr string) (from, to int, err error) {
+	parsePort := func(s string) (int, error) {
+		p, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("bad port number '%s'", s)
+		}
+		if p < 1 || p > 65535 {
+			return 0, fmt.Errorf("port %d is out of range (1-65535)", p)
+		}
+		return p, nil
+	}
+
+	fromStr, toStr, isRange := strings.Cut(portsStr, "-")
+	if from, err = parsePort(fromStr); err != nil {
+		return 0, 0, err
+	}
+	to = from
+	if isRange {
+		if to, err = parsePort(toStr); err != nil {
+			return 0, 0, err
+		}
+		if to < from {
+			return 0, 0, fmt.Errorf("bad port range '%s'", portsStr)
+		}
+	}
+	return from, to, nil
+}
+
+func parseUserExceptionNetwork(addrStr string) (*net.IPNet, error) {
+	addrStr = strings.TrimSpace(addrStr)
+	if len(addrStr) == 0 {
+		return nil, fmt.Errorf("address not defined")
+	}
+
+	if strings.Contains(addrStr, "/") {
+		_, n, err := net.ParseCIDR(addrStr)
+		if err != nil {
+			return nil, fmt.Errorf("%s not a valid subnet (CIDR notation expected)", addrStr)
+		}
+		return n, nil
+	}
+
+	addr := net.ParseIP(addrStr)
+	if addr == nil {
+		return nil, fmt.Errorf("%s not a IP address", addrStr)
+	}
+	if addr.To4() == nil {
+		// IPv6 single address
+		return &net.IPNet{IP: addr, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	// IPv4 single address
+	return &net.IPNet{IP: addr.To4(), Mask: net.CIDRMask(32, 32)}, nil
+}
@@ -0,0 +1,171 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import "testing"
+
+func TestParseUserException(t *testing.T) {
+	tests := []struct {
+		exp     string
+		want    string // expected result of String(); empty - error expected
+		network string
+		proto   string
+		from    int
+		to      int
+		dir     UserExceptionDirection
+	}{
+		{"192.0.2.0/24", "192.0.2.0/24", "192.0.2.0/24", "", 0, 0, UserExceptionDirectionBoth},
+		{" 192.0.2.7 ", "192.0.2.7/32", "192.0.2.7/32", "", 0, 0, UserExceptionDirectionBoth},
+		{"192.0.2.7/24", "192.0.2.0/24", "192.0.2.0/24", "", 0, 0, UserExceptionDirectionBoth},
+		{"2001:db8::1", "2001:db8::1/128", "2001:db8::1/128", "", 0, 0, UserExceptionDirectionBoth},
+		{"tcp:22@192.0.2.0/24", "tcp:22@192.0.2.0/24", "192.0.2.0/24", "tcp", 22, 22, UserExceptionDirectionBoth},
+		{"UDP:51820@198.51.100.7", "udp:51820@198.51.100.7/32", "198.51.100.7/32", "udp", 51820, 51820, UserExceptionDirectionBoth},
+		{"icmp@10.0.0.0/8", "icmp@10.0.0.0/8", "10.0.0.0/8", "icmp", 0, 0, UserExceptionDirectionBoth},
+		{"out:tcp:8000-8080@192.0.2.1", "out:tcp:8000-8080@192.0.2.1/32", "192.0.2.1/32", "tcp", 8000, 8080, UserExceptionDirectionOut},
+		{"out@10.0.0.0/8", "out@10.0.0.0/8", "10.0.0.0/8", "", 0, 0, UserExceptionDirectionOut},
+		{"both:tcp@10.0.0.0/8", "tcp@10.0.0.0/8", "10.0.0.0/8", "tcp", 0, 0, UserExceptionDirectionBoth},
+		{"both@10.0.0.0/8", "10.0.0.0/8", "10.0.0.0/8", "", 0, 0, UserExceptionDirectionBoth},
+		{"tcp:443-443@2001:db8::/32", "tcp:443@2001:db8::/32", "2001:db8::/32", "tcp", 443, 443, UserExceptionDirectionBoth},
+
+		{"", "", "", "", 0, 0, ""},
+		{"@10.0.0.0/8", "", "", "", 0, 0, ""},
+		{"tcp@", "", "", "", 0, 0, ""},
+		{"tcp@10.0.0.0/33", "", "", "", 0, 0, ""},
+		{"tcp@host.example.com", "", "", "", 0, 0, ""},
+		{"tcp@10.0.0.1@10.0.0.2", "", "", "", 0, 0, ""},
+		{"sctp:22@10.0.0.1", "", "", "", 0, 0, ""},
+		{"in:tcp@10.0.0.1", "", "", "", 0, 0, ""},
+		{"icmp:8@10.0.0.1", "", "", "", 0, 0, ""},
+		{"tcp:0@10.0.0.1", "", "", "", 0, 0, ""},
+		{"tcp:65536@10.0.0.1", "", "", "", 0, 0, ""},
+		{"tcp:http@10.0.0.1", "", "", "", 0, 0, ""},
+		{"tcp:8080-8000@10.0.0.1", "", "", "", 0, 0, ""},
+		{"tcp:22:23@10.0.0.1", "", "", "", 0, 0, ""},
+		{"out:out@10.0.0.1", "", "", "", 0, 0, ""},
+	}
+	for _, tt := range tests {
+		e, err := ParseUserException(tt.exp)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("'%s': error expected (parsed as '%s')", tt.exp, e)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %v", tt.exp, err)
+			continue
+		}
+		if e.Network.String() != tt.network || e.Protocol != tt.proto || e.PortFrom != tt.from || e.PortTo != tt.to || e.Direction != tt.dir {
+			t.Errorf("'%s': unexpected result %+v", tt.exp, e)
+		}
+		if e.String() != tt.want {
+			t.Errorf("'%s': unexpected string '%s' (expected '%s')", tt.exp, e.String(), tt.want)
+		}
+		// the string representation must be parsed to the same exception
+		if e2, err := ParseUserException(e.String()); err != nil || e2.String() != e.String() {
+			t.Errorf("'%s': string '%s' parsed as '%s' (%v)", tt.exp, e.String(), e2, err)
+		}
+	}
+}
+
+func TestUserExceptionPortString(t *testing.T) {
+	tests := []struct {
+		exp  string
+		want string
+	}{
+		{"tcp@10.0.0.1", ""},
+		{"tcp:22@10.0.0.1", "22"},
+		{"udp:8000-8080@10.0.0.1", "8000:8080"},
+	}
+	for _, tt := range tests {
+		e, err := ParseUserException(tt.exp)
+		if err != nil {
+			t.Fatalf("'%s': unexpected error: %v", tt.exp, err)
+		}
+		if got := e.PortString(); got != tt.want {
+			t.Errorf("'%s': unexpected port string '%s' (expected '%s')", tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestIsUserExceptionHost(t *testing.T) {
+	tests := []struct {
+		exp  string
+		want bool
+	}{
+		{"git.example.com", true},
+		{"git.example.com.", true},
+		{"my-host.example", true},
+		{"1password.com", true},
+		{"localhost", false},
+		{"10.0.0.1", false},
+		{"2001:db8::1", false},
+		{"10.0.0.0/8", false},
+		{"1.2.3.4.5", false},
+		{"-bad.example.com", false},
+		{"bad-.example.com", false},
+		{"bad..example.com", false},
+		{"under_score.example.com", false},
+		{"tcp:22@example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsUserExceptionHost(tt.exp); got != tt.want {
+			t.Errorf("'%s': unexpected result %v", tt.exp, got)
+		}
+	}
+}
+
+func TestParseUserExceptions(t *testing.T) {
+	// separators are compatible with previous versions ('@' and '-' are part of the exception format)
+	nets, rules, hosts, err := parseUserExceptions("192.0.2.0/24,198.51.100.1; 10.0.0.0/8 2001:db8::1|git-server.example.com\ttcp:8000-8080@192.0.2.1", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nets) != 4 || nets[0].String() != "192.0.2.0/24" || nets[1].String() != "198.51.100.1/32" || nets[2].String() != "10.0.0.0/8" || nets[3].String() != "2001:db8::1/128" {
+		t.Errorf("unexpected networks %v", nets)
+	}
+	if len(hosts) != 1 || hosts[0] != "git-server.example.com" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if implIsUserExceptionRulesSupported() {
+		if len(rules) != 1 || rules[0].String() != "tcp:8000-8080@192.0.2.1/32" {
+			t.Errorf("unexpected rules %v", rules)
+		}
+	} else if len(rules) != 0 {
+		t.Errorf("restricted rules must be ignored on this platform: %v", rules)
+	}
+
+	// restricted rules are rejected on platforms which can not apply them
+	_, _, _, err = parseUserExceptions("192.0.2.0/24, tcp:22@192.0.2.1", false)
+	if implIsUserExceptionRulesSupported() != (err == nil) {
+		t.Errorf("unexpected result for restricted rule: %v", err)
+	}
+
+	if _, _, _, err = parseUserExceptions("192.0.2.0/24, 10.0.0.0/33", false); err == nil {
+		t.Errorf("error expected for invalid exception")
+	}
+	if nets, _, _, err = parseUserExceptions("192.0.2.0/24, 10.0.0.0/33", true); err != nil || len(nets) != 1 {
+		t.Errorf("invalid exception must be ignored: %v %v", nets, err)
+	}
+}
@@ -225,8 +225,59 @@ func implOnChangeDNS(addr net.IP, isInternal bool) error {
 	return reEnable()
 }
 
+// ICMP-only exceptions are not supported: the hosts are allowed for all protocols
+const isIcmpOnlyExceptionsSupported = false
+
+// implCheckRules - checking the rules present in the system is not implemented for this platform
+func implCheckRules(rs RuleSet) RuleSetConsistency {
+	return RuleSetConsistency{}
+}
+
+// implOnAppAllowlistUpdated() called when 'appAllowlist' value were updated
+func implOnAppAllowlistUpdated() error {
+	if !appAllowlist.IsEmpty() {
+		return fmt.Errorf("applications allowlist for the firewall is not supported on this platform")
+	}
+	return nil
+}
+
+func implSetBlockedPacketsLogging(enable bool) error {
+	if enable {
+		return fmt.Errorf("logging blocked packets is not supported on this platform")
+	}
+	return nil
+}
+
+// implOnInterfaceExceptionsUpdated() called when 'interfaceExceptions' value were updated.
+// Interface exceptions are not supported on this platform.
+func implOnInterfaceExceptionsUpdated() error {
+	if !interfaceExceptions.IsEmpty() {
+		return fmt.Errorf("interface exceptions are not supported on this platform")
+	}
+	return nil
+}
+
+func implIsUserExceptionRulesSupported() bool {
+	return false
+}
+
+func implIsTemporaryExceptionsSupported() bool {
+	return false
+}
+
+// implOnTemporaryExceptionsUpdated() called when 'temporaryExceptions' value were updated.
+// Temporary exceptions are not supported on this platform (exceptions restricted by protocol/port are not implemented).
+func implOnTemporaryExceptionsUpdated() error {
+	if len(temporaryExceptions) > 0 {
+		temporaryExceptions = nil
+		return fmt.Errorf("temporary exceptions are not supported on this platform")
+	}
+	return nil
+}
+
 // implOnUserExceptionsUpdated() called when 'userExceptions' value were updated. Necessary to update firewall rules.
 func implOnUserExceptionsUpdated() error {
+
 	enabled, err := implGetEnabled()
 	if err != nil {
 		return fmt.Errorf("failed to get info if firewall is on: %w", err)
@@ -602,7 +653,13 @@ func doRemoveClientIPFilters() (retErr error) {
 
 func getUserExceptions(ipv4, ipv6 bool) []net.IPNet {
 	ret := []net.IPNet{}
-	for _, e := range userExceptions {
+	nets := append([]net.IPNet{}, userExceptions...)
+	nets = append(nets, routeExceptions...)
+	// resolver exceptions: the host is allowed for any communication (exceptions restricted by port are not implemented)
+	for _, r := range resolverExceptions {
+		nets = append(nets, r.Network)
+	}
+	for _, e := range nets {
 		isIPv6 := e.IP.To4() == nil
 		isIPv4 := !isIPv6
 
@@ -22,7 +22,11 @@
 
 package firewall
 
-import "net"
+import (
+	"net"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+)
 
 // ipNetListToStrings - convert list of net.IPNet to list of strings (IPNet.String())
 func ipNetListToStrings(ipnetList []net.IPNet) []string {
@@ -45,3 +49,16 @@ func filterIPNetList(ipnetList []net.IPNet, isIPv6 bool) []net.IPNet {
 	}
 	return result
 }
+
+// getLanRanges returns list of address ranges which must be allowed when 'Allow LAN' is enabled:
+//   - IPv4: private ranges (RFC 1918) and auto-IP range (RFC 3927)
+//   - IPv6: unique local addresses (fc00::/7) and link-local addresses (fe80::/10)
+//
+// Multicast ranges (224.0.0.0/4; ff00::/8) are included only when 'isAllowLanMulticast' is true
+func getLanRanges(isIPv6 bool, isAllowLanMulticast bool) []net.IPNet {
+	ranges := filterIPNetList(netinfo.GetNonRoutableLocalAddrRanges(), isIPv6)
+	if isAllowLanMulticast {
+		ranges = append(ranges, filterIPNetList(netinfo.GetMulticastAddresses(), isIPv6)...)
+	}
+	return ranges
+}
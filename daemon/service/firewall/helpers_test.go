@@ -0,0 +1,129 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"net"
+	"testing"
+)
+
+func parseCIDRs(t *testing.T, cidrs ...string) []net.IPNet {
+	ret := make([]net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, *n)
+	}
+	return ret
+}
+
+func isInRanges(ranges []net.IPNet, ip string) bool {
+	addr := net.ParseIP(ip)
+	for _, r := range ranges {
+		if r.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFilterIPNetList(t *testing.T) {
+	list := parseCIDRs(t, "10.0.0.0/8", "fc00::/7", "192.168.0.0/16", "fe80::/10")
+
+	ipv4 := ipNetListToStrings(filterIPNetList(list, false))
+	if len(ipv4) != 2 || ipv4[0] != "10.0.0.0/8" || ipv4[1] != "192.168.0.0/16" {
+		t.Errorf("unexpected IPv4 ranges: %v", ipv4)
+	}
+
+	ipv6 := ipNetListToStrings(filterIPNetList(list, true))
+	if len(ipv6) != 2 || ipv6[0] != "fc00::/7" || ipv6[1] != "fe80::/10" {
+		t.Errorf("unexpected IPv6 ranges: %v", ipv6)
+	}
+
+	if ret := filterIPNetList(nil, true); len(ret) != 0 {
+		t.Errorf("expected empty list, got: %v", ret)
+	}
+}
+
+func TestGetLanRanges(t *testing.T) {
+	tests := []struct {
+		name             string
+		isIPv6           bool
+		isAllowMulticast bool
+		allowed          []string
+		blocked          []string
+	}{
+		{
+			name:    "IPv4",
+			isIPv6:  false,
+			allowed: []string{"10.1.2.3", "172.16.0.1", "192.168.1.10", "169.254.1.1"},
+			blocked: []string{"8.8.8.8", "224.0.0.251", "fe80::1", "fd12:3456::1"},
+		},
+		{
+			name:             "IPv4 with multicast",
+			isIPv6:           false,
+			isAllowMulticast: true,
+			allowed:          []string{"192.168.1.10", "224.0.0.251", "239.255.255.250"},
+			blocked:          []string{"8.8.8.8", "ff02::fb"},
+		},
+		{
+			name:    "IPv6",
+			isIPv6:  true,
+			allowed: []string{"fe80::1", "fe80::abcd:1234:5678:9abc", "fc00::1", "fd12:3456:789a::1"},
+			blocked: []string{"2001:db8::1", "2a00:1450::200e", "ff02::fb", "ff05::1:3", "192.168.1.10"},
+		},
+		{
+			name:             "IPv6 with multicast",
+			isIPv6:           true,
+			isAllowMulticast: true,
+			allowed:          []string{"fe80::1", "fd12:3456:789a::1", "ff02::fb", "ff05::1:3"},
+			blocked:          []string{"2001:db8::1", "224.0.0.251"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges := getLanRanges(tc.isIPv6, tc.isAllowMulticast)
+			if len(ranges) == 0 {
+				t.Fatal("no ranges returned")
+			}
+			for _, r := range ranges {
+				if isIPv6 := r.IP.To4() == nil; isIPv6 != tc.isIPv6 {
+					t.Errorf("unexpected address family of range %s", r.String())
+				}
+			}
+			for _, ip := range tc.allowed {
+				if !isInRanges(ranges, ip) {
+					t.Errorf("%s expected to be allowed", ip)
+				}
+			}
+			for _, ip := range tc.blocked {
+				if isInRanges(ranges, ip) {
+					t.Errorf("%s expected to be blocked", ip)
+				}
+			}
+		})
+	}
+}
@@ -78,11 +78,13 @@ type IServiceEventsReceiver interface {
 	OnSessionStatus(sessionToken string, sessionData preferences.SessionMutableData)
 	OnKillSwitchStateChanged()
 	OnWiFiChanged(wifiNotifier.WifiInfo, error)
+	OnCaptivePortalStatusChanged(service_types.CaptivePortalStatus)
 	OnPingStatus(retMap map[string]int)
 	OnServersUpdated(*api_types.ServersInfoResponse)
 	OnSplitTunnelStatusChanged()
 	OnVpnStateChanged(state vpn.StateInfo)
 	OnVpnPauseChanged()
+	OnDnsChanged()
 
 	// called by a service when new connection is required (e.g. requested by 'trusted-wifi' functionality or 'auto-connect' on launch)
 	RegisterConnectionRequest(params service_types.ConnectionParams) error
@@ -37,8 +37,11 @@ import (
 	"github.com/ivpn/desktop-app/daemon/helpers"
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
+	"github.com/ivpn/desktop-app/daemon/splittun"
 	"github.com/ivpn/desktop-app/daemon/version"
 )
 
@@ -82,9 +85,19 @@ type Preferences struct {
 	IsFwAllowLAN             bool
 	IsFwAllowLANMulticast    bool
 	IsFwAllowApiServers      bool
-	FwUserExceptions         string // Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	FwUserExceptions         string // Firewall exceptions: comma separated list of IP addresses (masks) in format: [DIRECTION:][PROTOCOL[:PORT[-PORT]]@]x.x.x.x[/xx]
 	IsStopOnClientDisconnect bool
 
+	// Firewall exceptions for network interfaces (e.g. bridges of containers and virtual machines). Linux only.
+	FwInterfaceExceptions firewall.InterfaceExceptions
+	// Log packets blocked by firewall (Linux only)
+	FwLogBlockedPackets bool
+	// Applications allowed to communicate when firewall is enabled (Linux only)
+	FwAppAllowlist firewall.AppAllowlist
+	// Detect captive portals (by probing a known HTTP endpoint outside the tunnel) when firewall is enabled and VPN is not connected.
+	// Access to the portal is temporarily allowed by the firewall.
+	IsCaptivePortalDetection bool
+
 	// IsAutoconnectOnLaunch: if 'true' - daemon will perform automatic connection (see 'IsAutoconnectOnLaunchDaemon' for details)
 	IsAutoconnectOnLaunch bool
 	// IsAutoconnectOnLaunchDaemon:
@@ -101,6 +114,16 @@ type Preferences struct {
 	SplitTunnelInversed       bool // Inverse Split Tunnel: only 'splitted' apps use VPN tunnel (applicable only when IsSplitTunnel=true)
 	SplitTunnelAnyDns         bool // (only for Inverse Split Tunnel) When false: Allow only DNS servers specified by the IVPN application
 	SplitTunnelAllowWhenNoVpn bool // (only for Inverse Split Tunnel) Allow connectivity for Split Tunnel apps when VPN is disabled
+	// destination-based split-tunnelling (independent of 'IsSplitTunnel')
+	SplitTunnelRoutes         []string // IPv4 networks (CIDR) or domain names routed outside the VPN tunnel
+	SplitTunnelRoutesInversed bool     // Inverse mode: only 'SplitTunnelRoutes' destinations use VPN tunnel
+	// (Linux) Split Tunnel rules by user account (UID/GID); applicable when 'IsSplitTunnel' is enabled
+	SplitTunnelUsers []splittun.UserRule
+	// (Linux) DNS resolver for the apps in Split Tunnel environment (not applicable for Inverse Split Tunnel)
+	SplitTunnelDnsPolicy splittun.DnsPolicy
+	SplitTunnelDnsCustom string // IPv4 address of DNS server (applicable for splittun.DnsPolicyCustom)
+	// (Linux, WireGuard) "VPN-only" network namespace mode: the WireGuard interface is moved to the dedicated network namespace
+	SplitTunnelNetns bool
 
 	// last known account status
 	Session SessionStatus
@@ -111,6 +134,11 @@ type Preferences struct {
 
 	LastConnectionParams service_types.ConnectionParams
 	WiFiControl          WiFiParams
+
+	// Custom AntiTracker block/allow lists (enforced locally by dnscrypt-proxy)
+	AntiTrackerCustomLists dns.DnsFilterConfig
+	// DNS query statistics (collected from the local dnscrypt-proxy query log)
+	DnsQueryStats dns.DnsStatsConfig
 }
 
 type SessionMutableData struct {
@@ -140,6 +168,12 @@ func (p *Preferences) IsInverseSplitTunneling() bool {
 	return p.SplitTunnelInversed
 }
 
+// IsInverseSplitTunnelRoutes returns 'true' when only destinations from 'SplitTunnelRoutes' use VPN tunnel
+// (the rest of the traffic is routed outside the VPN tunnel)
+func (p *Preferences) IsInverseSplitTunnelRoutes() bool {
+	return p.SplitTunnelRoutesInversed && len(p.SplitTunnelRoutes) > 0
+}
+
 // SetSession save account credentials
 func (p *Preferences) SetSession(accountInfo AccountStatus,
 	accountID string,
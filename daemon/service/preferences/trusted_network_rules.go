@@ -0,0 +1,138 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package preferences
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NetworkRuleType defines the network property used to identify a (wired) network
+type NetworkRuleType string
+
+const (
+	NetworkRuleGatewayMAC    NetworkRuleType = "gateway_mac"    // MAC address of the default gateway (e.g. "a4:2b:b0:11:22:33")
+	NetworkRuleDnsSuffix     NetworkRuleType = "dns_suffix"     // DHCP domain / DNS search suffix (e.g. "corp.example.com"); sub-domains are matching too
+	NetworkRuleHostReachable NetworkRuleType = "host_reachable" // internal host accepting TCP connections ("IP:port", e.g. "10.0.0.5:443"); only for untrusted networks
+)
+
+// NetworkRule is a trust rule for the network identified by its properties (not by WiFi name).
+// It allows to apply 'trusted-network' actions for wired networks.
+type NetworkRule struct {
+	Type      NetworkRuleType `json:"type"`
+	Value     string          `json:"value"`
+	IsTrusted bool            `json:"isTrusted"`
+}
+
+// Validate checks the network rule
+func (r NetworkRule) Validate() error {
+	value := strings.TrimSpace(r.Value)
+	if len(value) == 0 {
+		return fmt.Errorf("network rule value not defined")
+	}
+	switch r.Type {
+	case NetworkRuleGatewayMAC:
+		if _, err := net.ParseMAC(value); err != nil {
+			return fmt.Errorf("bad gateway MAC address '%s': %w", r.Value, err)
+		}
+	case NetworkRuleDnsSuffix:
+		if strings.ContainsAny(value, " /:@") {
+			return fmt.Errorf("bad DNS suffix '%s'", r.Value)
+		}
+	case NetworkRuleHostReachable:
+		host, port, err := net.SplitHostPort(value)
+		if err != nil {
+			return fmt.Errorf("bad host address '%s' (expected 'host:port'): %w", r.Value, err)
+		}
+		if len(host) == 0 || len(port) == 0 {
+			return fmt.Errorf("bad host address '%s' (expected 'host:port')", r.Value)
+		}
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("bad host address '%s' (expected IP address)", r.Value)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			return fmt.Errorf("bad host port '%s'", r.Value)
+		}
+		// The reachability of the host can be spoofed by any network (e.g. by an attacker's access point):
+		// it can not be a proof that the network is trusted
+		if r.IsTrusted {
+			return fmt.Errorf("'%s' rule can only define untrusted networks", NetworkRuleHostReachable)
+		}
+	default:
+		return fmt.Errorf("unknown network rule type '%s' (expected: %s, %s, %s)", r.Type, NetworkRuleGatewayMAC, NetworkRuleDnsSuffix, NetworkRuleHostReachable)
+	}
+	return nil
+}
+
+// Normalize returns the rule with the value in canonical form
+func (r NetworkRule) Normalize() NetworkRule {
+	ret := r
+	ret.Value = strings.TrimSpace(r.Value)
+	switch r.Type {
+	case NetworkRuleGatewayMAC:
+		if mac, err := net.ParseMAC(ret.Value); err == nil {
+			ret.Value = mac.String()
+		}
+	case NetworkRuleDnsSuffix:
+		ret.Value = strings.ToLower(strings.Trim(ret.Value, "."))
+	}
+	return ret
+}
+
+// Key returns string which identifies the rule (rules with the same key are duplicates)
+func (r NetworkRule) Key() string {
+	r = r.Normalize()
+	return fmt.Sprintf("%s:%s", r.Type, r.Value)
+}
+
+// MatchesGatewayMAC returns true for 'gateway_mac' rule with the same MAC address
+func (r NetworkRule) MatchesGatewayMAC(mac string) bool {
+	if r.Type != NetworkRuleGatewayMAC {
+		return false
+	}
+	m, err := net.ParseMAC(strings.TrimSpace(mac))
+	if err != nil {
+		return false
+	}
+	return r.Normalize().Value == m.String()
+}
+
+// MatchesDnsSuffix returns true for 'dns_suffix' rule when one of the suffixes is equal to the rule value (or is its sub-domain)
+func (r NetworkRule) MatchesDnsSuffix(suffixes []string) bool {
+	if r.Type != NetworkRuleDnsSuffix {
+		return false
+	}
+	value := r.Normalize().Value
+	if len(value) == 0 {
+		return false
+	}
+	for _, s := range suffixes {
+		s = strings.ToLower(strings.Trim(strings.TrimSpace(s), "."))
+		if s == value || strings.HasSuffix(s, "."+value) {
+			return true
+		}
+	}
+	return false
+}
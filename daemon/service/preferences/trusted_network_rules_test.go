@@ -0,0 +1,135 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package preferences
+
+import "testing"
+
+func TestNetworkRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    NetworkRule
+		wantErr bool
+	}{
+		{"gateway MAC", NetworkRule{Type: NetworkRuleGatewayMAC, Value: "A4:2B:B0:11:22:33"}, false},
+		{"gateway MAC with dashes", NetworkRule{Type: NetworkRuleGatewayMAC, Value: " a4-2b-b0-11-22-33 "}, false},
+		{"bad gateway MAC", NetworkRule{Type: NetworkRuleGatewayMAC, Value: "a4:2b:b0:11:22"}, true},
+		{"DNS suffix", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "corp.example.com"}, false},
+		{"DNS suffix with dots", NetworkRule{Type: NetworkRuleDnsSuffix, Value: ".corp.example.com."}, false},
+		{"DNS suffix with space", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "corp example.com"}, true},
+		{"DNS suffix with slash", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "corp.example.com/24"}, true},
+		{"DNS suffix with port", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "corp.example.com:53"}, true},
+		{"DNS suffix with user", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "user@corp.example.com"}, true},
+		{"host", NetworkRule{Type: NetworkRuleHostReachable, Value: "10.0.0.5:443"}, false},
+		{"host name", NetworkRule{Type: NetworkRuleHostReachable, Value: "intranet.corp:80"}, true},
+		{"host with bad port", NetworkRule{Type: NetworkRuleHostReachable, Value: "10.0.0.5:https"}, true},
+		{"trusted host", NetworkRule{Type: NetworkRuleHostReachable, Value: "10.0.0.5:443", IsTrusted: true}, true},
+		{"IPv6 host", NetworkRule{Type: NetworkRuleHostReachable, Value: "[fd00::5]:443"}, false},
+		{"host without port", NetworkRule{Type: NetworkRuleHostReachable, Value: "10.0.0.5"}, true},
+		{"host with empty port", NetworkRule{Type: NetworkRuleHostReachable, Value: "10.0.0.5:"}, true},
+		{"empty host", NetworkRule{Type: NetworkRuleHostReachable, Value: ":443"}, true},
+		{"empty value", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "  "}, true},
+		{"unknown type", NetworkRule{Type: "ssid", Value: "Home"}, true},
+		{"no type", NetworkRule{Value: "Home"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected result: %v", tt.name, err)
+		}
+	}
+}
+
+func TestNetworkRuleNormalizeAndKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  NetworkRule
+		value string
+		key   string
+	}{
+		{"gateway MAC", NetworkRule{Type: NetworkRuleGatewayMAC, Value: " A4-2B-B0-11-22-33 "}, "a4:2b:b0:11:22:33", "gateway_mac:a4:2b:b0:11:22:33"},
+		{"bad gateway MAC", NetworkRule{Type: NetworkRuleGatewayMAC, Value: " A4-2B "}, "A4-2B", "gateway_mac:A4-2B"},
+		{"DNS suffix", NetworkRule{Type: NetworkRuleDnsSuffix, Value: " .Corp.Example.COM. "}, "corp.example.com", "dns_suffix:corp.example.com"},
+		{"host", NetworkRule{Type: NetworkRuleHostReachable, Value: " Intranet.corp:80 "}, "Intranet.corp:80", "host_reachable:Intranet.corp:80"},
+	}
+	for _, tt := range tests {
+		r := tt.rule
+		r.IsTrusted = true
+		n := r.Normalize()
+		if n.Value != tt.value || n.Type != tt.rule.Type || !n.IsTrusted {
+			t.Errorf("%s: unexpected result %+v (expected value '%s')", tt.name, n, tt.value)
+		}
+		if key := tt.rule.Key(); key != tt.key {
+			t.Errorf("%s: unexpected key '%s' (expected '%s')", tt.name, key, tt.key)
+		}
+		if tt.rule.Key() != r.Key() {
+			t.Errorf("%s: key depends on trust status", tt.name)
+		}
+	}
+}
+
+func TestNetworkRuleMatchesGatewayMAC(t *testing.T) {
+	rule := NetworkRule{Type: NetworkRuleGatewayMAC, Value: "A4-2B-B0-11-22-33"}
+	tests := []struct {
+		name  string
+		rule  NetworkRule
+		mac   string
+		match bool
+	}{
+		{"same", rule, "a4:2b:b0:11:22:33", true},
+		{"other format", rule, " A4:2B:B0:11:22:33 ", true},
+		{"dotted format", rule, "a42b.b011.2233", true},
+		{"other MAC", rule, "a4:2b:b0:11:22:34", false},
+		{"empty MAC", rule, "", false},
+		{"bad MAC", rule, "a4:2b:b0", false},
+		{"other rule type", NetworkRule{Type: NetworkRuleDnsSuffix, Value: "a4:2b:b0:11:22:33"}, "a4:2b:b0:11:22:33", false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.MatchesGatewayMAC(tt.mac); got != tt.match {
+			t.Errorf("%s: unexpected result %v", tt.name, got)
+		}
+	}
+}
+
+func TestNetworkRuleMatchesDnsSuffix(t *testing.T) {
+	rule := NetworkRule{Type: NetworkRuleDnsSuffix, Value: "Corp.Example.com."}
+	tests := []struct {
+		name     string
+		rule     NetworkRule
+		suffixes []string
+		match    bool
+	}{
+		{"same", rule, []string{"corp.example.com"}, true},
+		{"case and dots", rule, []string{" .CORP.example.com. "}, true},
+		{"sub-domain", rule, []string{"lan", "office.corp.example.com"}, true},
+		{"parent domain", rule, []string{"example.com"}, false},
+		{"not a sub-domain", rule, []string{"mycorp.example.com"}, false},
+		{"other domain", rule, []string{"corp.example.org"}, false},
+		{"no suffixes", rule, nil, false},
+		{"empty rule value", NetworkRule{Type: NetworkRuleDnsSuffix, Value: " . "}, []string{"", "."}, false},
+		{"other rule type", NetworkRule{Type: NetworkRuleHostReachable, Value: "corp.example.com"}, []string{"corp.example.com"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.MatchesDnsSuffix(tt.suffixes); got != tt.match {
+			t.Errorf("%s: unexpected result %v", tt.name, got)
+		}
+	}
+}
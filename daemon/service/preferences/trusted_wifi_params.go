@@ -22,9 +22,140 @@
 
 package preferences
 
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// WiFiSSIDMatch defines how the SSID of the WiFi network rule is compared with the network name
+type WiFiSSIDMatch string
+
+const (
+	WiFiSSIDMatchExact WiFiSSIDMatch = ""      // exact match (default)
+	WiFiSSIDMatchGlob  WiFiSSIDMatch = "glob"  // shell-like pattern: '*' - any sequence of characters; '?' - any single character (e.g. "Starbucks*")
+	WiFiSSIDMatchRegex WiFiSSIDMatch = "regex" // regular expression; must match the whole network name (e.g. "Guest-[0-9]+")
+)
+
 type WiFiNetwork struct {
-	SSID      string `json:"ssid"`
-	IsTrusted bool   `json:"isTrusted"`
+	SSID      string        `json:"ssid"`
+	IsTrusted bool          `json:"isTrusted"`
+	SSIDMatch WiFiSSIDMatch `json:"ssidMatch,omitempty"`
+	// BSSIDs - MAC addresses of the access points. When defined, the rule is applied only if both SSID and BSSID are matching.
+	BSSIDs []string `json:"bssids,omitempty"`
+}
+
+// Validate checks the network rule
+func (n WiFiNetwork) Validate() error {
+	if len(n.SSID) == 0 {
+		return fmt.Errorf("WiFi network name not defined")
+	}
+	switch n.SSIDMatch {
+	case WiFiSSIDMatchExact:
+	case WiFiSSIDMatchGlob, WiFiSSIDMatchRegex:
+		if _, err := n.ssidRegexp(); err != nil {
+			return fmt.Errorf("bad WiFi network name pattern '%s': %w", n.SSID, err)
+		}
+	default:
+		return fmt.Errorf("unknown WiFi network name match type '%s' (expected: %s, %s)", n.SSIDMatch, WiFiSSIDMatchGlob, WiFiSSIDMatchRegex)
+	}
+	for _, b := range n.BSSIDs {
+		if _, err := net.ParseMAC(strings.TrimSpace(b)); err != nil {
+			return fmt.Errorf("bad BSSID '%s': %w", b, err)
+		}
+	}
+	return nil
+}
+
+// Normalize returns the rule with BSSIDs in canonical form (lower case, sorted, without duplicates)
+func (n WiFiNetwork) Normalize() WiFiNetwork {
+	ret := n
+	ret.BSSIDs = nil
+	unique := make(map[string]struct{}, len(n.BSSIDs))
+	for _, b := range n.BSSIDs {
+		mac, err := net.ParseMAC(strings.TrimSpace(b))
+		if err != nil {
+			continue
+		}
+		if _, exists := unique[mac.String()]; !exists {
+			unique[mac.String()] = struct{}{}
+			ret.BSSIDs = append(ret.BSSIDs, mac.String())
+		}
+	}
+	sort.Strings(ret.BSSIDs)
+	return ret
+}
+
+// Key returns string which identifies the rule (rules with the same key are duplicates)
+func (n WiFiNetwork) Key() string {
+	n = n.Normalize()
+	return fmt.Sprintf("%s:%s@%s", n.SSIDMatch, n.SSID, strings.Join(n.BSSIDs, ","))
+}
+
+// IsPattern returns true when the SSID is defined as pattern (glob or regular expression)
+func (n WiFiNetwork) IsPattern() bool {
+	return n.SSIDMatch == WiFiSSIDMatchGlob || n.SSIDMatch == WiFiSSIDMatchRegex
+}
+
+// Matches returns true when the rule is applicable for the network.
+// If the rule defines BSSIDs, the network BSSID must be known and be one of them.
+func (n WiFiNetwork) Matches(ssid, bssid string) bool {
+	if !n.MatchesSSID(ssid) {
+		return false
+	}
+
+	if len(n.BSSIDs) == 0 {
+		return true
+	}
+	mac, err := net.ParseMAC(strings.TrimSpace(bssid))
+	if err != nil {
+		return false // BSSID of the network is not known
+	}
+	for _, b := range n.BSSIDs {
+		if m, err := net.ParseMAC(strings.TrimSpace(b)); err == nil && m.String() == mac.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesSSID returns true when the network name matches the rule (BSSIDs of the rule are not checked)
+func (n WiFiNetwork) MatchesSSID(ssid string) bool {
+	if len(ssid) == 0 {
+		return false
+	}
+
+	switch n.SSIDMatch {
+	case WiFiSSIDMatchExact:
+		return n.SSID == ssid
+	case WiFiSSIDMatchGlob, WiFiSSIDMatchRegex:
+		re, err := n.ssidRegexp()
+		return err == nil && re.MatchString(ssid)
+	default:
+		return false
+	}
+}
+
+// ssidRegexp returns regular expression which matches the whole network name
+func (n WiFiNetwork) ssidRegexp() (*regexp.Regexp, error) {
+	expr := n.SSID
+	if n.SSIDMatch == WiFiSSIDMatchGlob {
+		var sb strings.Builder
+		for _, c := range n.SSID {
+			switch c {
+			case '*':
+				sb.WriteString(".*")
+			case '?':
+				sb.WriteString(".")
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		expr = sb.String()
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
 }
 
 type WiFiParams struct {
@@ -38,6 +169,9 @@ type WiFiParams struct {
 	TrustedNetworksControl    bool          `json:"trustedNetworksControl"`
 	DefaultTrustStatusTrusted *bool         `json:"defaultTrustStatusTrusted"` // nil - no trust action
 	Networks                  []WiFiNetwork `json:"networks"`
+	// NetworkRules - trust rules for networks identified by gateway MAC, DNS suffix or internal host reachability (e.g. wired networks).
+	// They are applied when there are no WiFi rules for the current network.
+	NetworkRules []NetworkRule `json:"networkRules,omitempty"`
 
 	Actions struct {
 		UnTrustedConnectVpn     bool `json:"unTrustedConnectVpn"`
@@ -0,0 +1,132 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package preferences
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWiFiNetworkValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		net     WiFiNetwork
+		wantErr bool
+	}{
+		{"exact", WiFiNetwork{SSID: "Home"}, false},
+		{"empty SSID", WiFiNetwork{SSID: ""}, true},
+		{"glob", WiFiNetwork{SSID: "Starbucks*", SSIDMatch: WiFiSSIDMatchGlob}, false},
+		{"glob with regexp characters", WiFiNetwork{SSID: "Cafe (Guest)[1]?", SSIDMatch: WiFiSSIDMatchGlob}, false},
+		{"regex", WiFiNetwork{SSID: "Guest-[0-9]+", SSIDMatch: WiFiSSIDMatchRegex}, false},
+		{"bad regex", WiFiNetwork{SSID: "Guest-[0-9", SSIDMatch: WiFiSSIDMatchRegex}, true},
+		{"unknown match type", WiFiNetwork{SSID: "Home", SSIDMatch: "prefix"}, true},
+		{"BSSIDs", WiFiNetwork{SSID: "Home", BSSIDs: []string{"AA:BB:CC:DD:EE:FF", " aa-bb-cc-dd-ee-01 ", "0123.4567.89ab"}}, false},
+		{"bad BSSID", WiFiNetwork{SSID: "Home", BSSIDs: []string{"aa:bb:cc:dd:ee"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.net.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected result: %v", tt.name, err)
+		}
+	}
+}
+
+func TestWiFiNetworkNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		bssids []string
+		want   []string
+	}{
+		{"no BSSIDs", nil, nil},
+		{"canonical form", []string{"AA-BB-CC-DD-EE-FF", " 0123.4567.89AB "}, []string{"01:23:45:67:89:ab", "aa:bb:cc:dd:ee:ff"}},
+		{"duplicates", []string{"aa:bb:cc:dd:ee:ff", "AA:BB:CC:DD:EE:FF", "aa-bb-cc-dd-ee-ff"}, []string{"aa:bb:cc:dd:ee:ff"}},
+		{"bad BSSID skipped", []string{"bad", "aa:bb:cc:dd:ee:ff"}, []string{"aa:bb:cc:dd:ee:ff"}},
+	}
+	for _, tt := range tests {
+		n := WiFiNetwork{SSID: "Home", IsTrusted: true, SSIDMatch: WiFiSSIDMatchGlob, BSSIDs: tt.bssids}
+		got := n.Normalize()
+		if !reflect.DeepEqual(got.BSSIDs, tt.want) {
+			t.Errorf("%s: unexpected BSSIDs %v (expected %v)", tt.name, got.BSSIDs, tt.want)
+		}
+		if got.SSID != n.SSID || got.IsTrusted != n.IsTrusted || got.SSIDMatch != n.SSIDMatch {
+			t.Errorf("%s: rule parameters changed: %+v", tt.name, got)
+		}
+	}
+
+	a := WiFiNetwork{SSID: "Home", BSSIDs: []string{"AA:BB:CC:DD:EE:FF", "01:23:45:67:89:ab"}}
+	b := WiFiNetwork{SSID: "Home", BSSIDs: []string{"01-23-45-67-89-AB", "aa:bb:cc:dd:ee:ff"}}
+	if a.Key() != b.Key() {
+		t.Errorf("keys of the same rules are different: '%s' and '%s'", a.Key(), b.Key())
+	}
+	if c := (WiFiNetwork{SSID: "Home", SSIDMatch: WiFiSSIDMatchGlob}); c.Key() == (WiFiNetwork{SSID: "Home"}).Key() {
+		t.Errorf("keys of the rules with different match type are equal: '%s'", c.Key())
+	}
+}
+
+func TestWiFiNetworkMatches(t *testing.T) {
+	bssids := []string{"AA:BB:CC:DD:EE:FF", "01-23-45-67-89-ab"}
+	tests := []struct {
+		name  string
+		net   WiFiNetwork
+		ssid  string
+		bssid string
+		want  bool
+	}{
+		{"exact", WiFiNetwork{SSID: "Home"}, "Home", "", true},
+		{"exact: case sensitive", WiFiNetwork{SSID: "Home"}, "home", "", false},
+		{"exact: prefix only", WiFiNetwork{SSID: "Home"}, "Home-5G", "", false},
+		{"empty SSID", WiFiNetwork{SSID: "Home"}, "", "", false},
+
+		{"glob *", WiFiNetwork{SSID: "Starbucks*", SSIDMatch: WiFiSSIDMatchGlob}, "Starbucks WiFi", "", true},
+		{"glob * empty", WiFiNetwork{SSID: "Starbucks*", SSIDMatch: WiFiSSIDMatchGlob}, "Starbucks", "", true},
+		{"glob ?", WiFiNetwork{SSID: "Net-?", SSIDMatch: WiFiSSIDMatchGlob}, "Net-1", "", true},
+		{"glob ? single char", WiFiNetwork{SSID: "Net-?", SSIDMatch: WiFiSSIDMatchGlob}, "Net-12", "", false},
+		{"glob whole name", WiFiNetwork{SSID: "Starbucks*", SSIDMatch: WiFiSSIDMatchGlob}, "My Starbucks", "", false},
+		{"glob quotes regexp characters", WiFiNetwork{SSID: "Cafe.(1)", SSIDMatch: WiFiSSIDMatchGlob}, "Cafe.(1)", "", true},
+		{"glob '.' is not a wildcard", WiFiNetwork{SSID: "Cafe.(1)", SSIDMatch: WiFiSSIDMatchGlob}, "CafeX(1)", "", false},
+
+		{"regex", WiFiNetwork{SSID: "Guest-[0-9]+", SSIDMatch: WiFiSSIDMatchRegex}, "Guest-42", "", true},
+		{"regex whole name", WiFiNetwork{SSID: "Guest-[0-9]+", SSIDMatch: WiFiSSIDMatchRegex}, "Guest-42x", "", false},
+		{"regex whole name (alternation)", WiFiNetwork{SSID: "Home|Office", SSIDMatch: WiFiSSIDMatchRegex}, "Office-2", "", false},
+		{"regex alternation", WiFiNetwork{SSID: "Home|Office", SSIDMatch: WiFiSSIDMatchRegex}, "Office", "", true},
+		{"bad regex", WiFiNetwork{SSID: "Guest-[0-9", SSIDMatch: WiFiSSIDMatchRegex}, "Guest-[0-9", "", false},
+		{"unknown match type", WiFiNetwork{SSID: "Home", SSIDMatch: "prefix"}, "Home", "", false},
+
+		{"BSSID", WiFiNetwork{SSID: "Home", BSSIDs: bssids}, "Home", "aa:bb:cc:dd:ee:ff", true},
+		{"BSSID: other form", WiFiNetwork{SSID: "Home", BSSIDs: bssids}, "Home", "01:23:45:67:89:AB", true},
+		{"BSSID: not in list", WiFiNetwork{SSID: "Home", BSSIDs: bssids}, "Home", "aa:bb:cc:dd:ee:00", false},
+		{"BSSID: unknown", WiFiNetwork{SSID: "Home", BSSIDs: bssids}, "Home", "", false},
+		{"BSSID: SSID mismatch", WiFiNetwork{SSID: "Home", BSSIDs: bssids}, "Office", "aa:bb:cc:dd:ee:ff", false},
+		{"BSSID with pattern", WiFiNetwork{SSID: "Home*", SSIDMatch: WiFiSSIDMatchGlob, BSSIDs: bssids}, "Home-5G", "aa:bb:cc:dd:ee:ff", true},
+	}
+	for _, tt := range tests {
+		if got := tt.net.Matches(tt.ssid, tt.bssid); got != tt.want {
+			t.Errorf("%s: Matches(%q, %q) = %v (expected %v)", tt.name, tt.ssid, tt.bssid, got, tt.want)
+		}
+		// the network name matching does not depend on BSSIDs
+		withoutBSSIDs := tt.net
+		withoutBSSIDs.BSSIDs = nil
+		if got, want := tt.net.MatchesSSID(tt.ssid), withoutBSSIDs.Matches(tt.ssid, ""); got != want {
+			t.Errorf("%s: MatchesSSID(%q) = %v (expected %v)", tt.name, tt.ssid, got, want)
+		}
+	}
+}
@@ -135,6 +135,31 @@ type Service struct {
 	// (UI may send us new connection settings while VPN is connected, e.g., when the user changes connection settings in the UI)
 	_tmpParams      types.ConnectionParams
 	_tmpParamsMutex sync.Mutex
+
+	// Hostname of the entry host selected for the current connection (see ConnectionParams.NormalizeHosts())
+	_entryHostname      string
+	_entryHostnameMutex sync.Mutex
+
+	// 'auto' DNS mode state (see service_dns_auto.go)
+	_dnsAuto dnsAutoState
+
+	// firewall exceptions defined by host names (see service_fw_hosts.go)
+	_fwHosts fwHostsState
+
+	// destination-based split tunnelling state (see service_split_routes.go)
+	_splitRoutes splitRoutesState
+
+	// DNS resolver for the apps in Split Tunnel environment (see service_split_dns.go)
+	_splitDns splitDnsState
+
+	// suspend/resume state (see service_power.go)
+	_power powerState
+
+	// underlay interface pinning state (see service_underlay.go)
+	_underlay underlayState
+
+	// captive portal detection state (see service_captive_portal.go)
+	_captivePortal captivePortalState
 }
 
 // VpnSessionInfo - Additional information about current VPN connection
@@ -227,6 +252,13 @@ func (s *Service) init() error {
 	if err := dns.Initialize(firewall.OnChangeDNS, funcGetDnsExtraSettings); err != nil {
 		log.Error(fmt.Sprintf("failed to initialize DNS : %s", err))
 	}
+	// initialize custom AntiTracker lists (use previously generated names files; no network requests here)
+	if s._preferences.AntiTrackerCustomLists.Enabled {
+		if _, err := dns.SetFilterConfig(s._preferences.AntiTrackerCustomLists, nil, false); err != nil {
+			log.Error(fmt.Sprintf("failed to initialize custom AntiTracker lists : %s", err))
+		}
+	}
+	dns.SetStatsConfig(s._preferences.DnsQueryStats)
 
 	// initialize split-tunnel functionality
 	if err := splittun.Initialize(); err != nil {
@@ -252,6 +284,28 @@ func (s *Service) init() error {
 	if err := firewall.SetUserExceptions(s._preferences.FwUserExceptions, true); err != nil {
 		log.Error("Failed to apply firewall exceptions: ", err)
 	}
+	s.fwHosts_update()
+	s.splitRoutes_update()
+
+	if s._preferences.SplitTunnelNetns {
+		if err := s.implSplitTunnelling_SetNetnsMode(true); err != nil {
+			log.Error("Failed to initialize the network namespace: ", err)
+		}
+	}
+
+	if err := firewall.SetInterfaceExceptions(s._preferences.FwInterfaceExceptions); err != nil {
+		log.Error("Failed to apply firewall interface exceptions: ", err)
+	}
+
+	if err := firewall.SetAppAllowlist(s._preferences.FwAppAllowlist); err != nil {
+		log.Error("Failed to apply firewall applications allowlist: ", err)
+	}
+
+	if s._preferences.FwLogBlockedPackets {
+		if err := firewall.SetBlockedPacketsLogging(true); err != nil {
+			log.Error("Failed to enable logging of blocked packets: ", err)
+		}
+	}
 
 	if s._preferences.IsFwPersistant {
 		log.Info("Enabling firewal (persistant configuration)")
@@ -278,6 +332,10 @@ func (s *Service) init() error {
 		log.Error("Failed to init WiFi functionality:", err)
 	}
 
+	if err := s.initNetworkChangeMonitor(); err != nil {
+		log.Error("Failed to init network change monitor:", err)
+	}
+
 	// Start session status checker
 	go func() {
 		<-s._ipStackInitializationWaiter // Wait for IP stack initialization
@@ -382,7 +440,7 @@ func (s *Service) unInitialise() error {
 		log.Error(err)
 		updateRetErr(err)
 	}
-	if err := splittun.ApplyConfig(false, false, false, false, splittun.ConfigAddresses{}, []string{}); err != nil {
+	if err := splittun.ApplyConfig(false, false, false, false, splittun.ConfigAddresses{}, []string{}, nil, nil); err != nil {
 		log.Error(err)
 		updateRetErr(err)
 	}
@@ -678,6 +736,8 @@ func (s *Service) Pause(durationSeconds uint32) error {
 			log.Error(err)
 		}
 	}
+	// Routes of destination-based split tunnelling are not required while paused
+	s.splitRoutes_apply()
 
 	// Pause resumer: Every second checks if it is time to resume VPN connection.
 	// Info: We can not use 'time.AfterFunc()' because
@@ -736,6 +796,8 @@ func (s *Service) Resume() error {
 		return err
 	}
 
+	s.splitRoutes_apply()
+
 	// Update SplitTunnel state (if enabled)
 	prefs := s.Preferences()
 	if prefs.IsSplitTunnel {
@@ -878,6 +940,12 @@ func (s *Service) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker types.AntiTra
 		defaultParams.ManualDNS = dnsCfg
 		isChanged = true
 	}
+	if !dnsCfg.IsEmpty() && defaultParams.Metadata.DnsAuto {
+		// custom DNS replaces 'auto' DNS mode
+		defaultParams.Metadata.DnsAuto = false
+		s.dnsAuto_stop()
+		isChanged = true
+	}
 	if !defaultParams.Metadata.AntiTracker.Equal(antiTracker) {
 		at, err := s.normalizeAntiTrackerBlockListName(antiTracker)
 		if err != nil {
@@ -907,8 +975,18 @@ func (s *Service) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker types.AntiTra
 	}
 
 	if dnsCfg.IsEmpty() && !antiTracker.Enabled {
+		// 'auto' DNS mode: keep the DNS provider selected automatically
+		if autoDns := s.dnsAuto_getSelected(); !autoDns.IsEmpty() {
+			return autoDns, vpn.SetManualDNS(autoDns)
+		}
+		defer func() {
+			if s.dnsAuto_isApplicable() {
+				s.dnsAuto_start()
+			}
+		}()
 		return dns.DnsSettings{}, vpn.ResetManualDNS()
 	}
+	s.dnsAuto_stop()
 	return changedDns, vpn.SetManualDNS(changedDns)
 }
 
@@ -925,6 +1003,110 @@ func (s *Service) GetAntiTrackerStatus() types.AntiTrackerMetadata {
 	return retAtMetadata
 }
 
+// GetAntiTrackerCustomLists returns status of the custom AntiTracker block/allow lists
+func (s *Service) GetAntiTrackerCustomLists() dns.DnsFilterStatus {
+	return dns.GetFilterStatus()
+}
+
+// SetAntiTrackerCustomLists applies custom AntiTracker block/allow lists.
+// The lists are enforced locally (by dnscrypt-proxy) on top of the current DNS configuration (IVPN AntiTracker DNS or custom DNS)
+// The daemon does not read local files on behalf of the client: 'localContent' contains the content of local source files
+// (source path -> file content) read by the client; only https URLs are downloaded by the daemon.
+func (s *Service) SetAntiTrackerCustomLists(cfg dns.DnsFilterConfig, localContent map[string][]byte) (dns.DnsFilterStatus, error) {
+	cfg.BlockLists = normalizeListSources(cfg.BlockLists)
+	cfg.AllowLists = normalizeListSources(cfg.AllowLists)
+
+	status, err := dns.SetFilterConfig(cfg, localContent, true)
+	if err != nil {
+		return dns.GetFilterStatus(), err
+	}
+
+	prefs := s._preferences
+	prefs.AntiTrackerCustomLists = cfg
+	s.setPreferences(prefs)
+
+	// re-apply DNS configuration to take into account new filtering configuration
+	if err := s.reApplyDnsIfConnected(); err != nil {
+		return status, err
+	}
+
+	return status, nil
+}
+
+// TestDnsConfig tests DNS configuration (without applying it): resolves the probe name through the DNS server.
+// The test is skipped when the request would be blocked by the firewall: when the firewall is enabled,
+// requests are allowed only through the VPN tunnel and plain DNS requests only to the DNS server in use.
+func (s *Service) TestDnsConfig(dnsCfg dns.DnsSettings, probeName string, timeout time.Duration) dns.DnsTestResult {
+	if enabled, _ := firewall.GetEnabled(); enabled && !dnsCfg.IsEmpty() {
+		reason := ""
+		if !s.Connected() {
+			reason = "VPN is not connected"
+		} else if dnsCfg.Encryption == dns.EncryptionNone {
+			active, err := s.GetActiveDNS()
+			if err != nil || active.Encryption != dns.EncryptionNone || !active.Ip().Equal(dnsCfg.Ip()) {
+				reason = "plain DNS requests are allowed only to the DNS server in use"
+			}
+		}
+		if len(reason) > 0 {
+			log.Info(fmt.Sprintf("DNS test skipped (%s): firewall is enabled and %s", dnsCfg.InfoString(), reason))
+			return dns.DnsTestResult{IsSkipped: true, Error: fmt.Sprintf("the request would be blocked by the firewall (%s)", reason)}
+		}
+	}
+	return dns.TestConfig(dnsCfg, probeName, timeout)
+}
+
+// GetDnsStats returns DNS query statistics
+func (s *Service) GetDnsStats() dns.DnsStats {
+	return dns.GetStats()
+}
+
+// SetDnsStatsConfig enables/disables DNS query statistics (collected from the local dnscrypt-proxy query log)
+func (s *Service) SetDnsStatsConfig(cfg dns.DnsStatsConfig) (dns.DnsStats, error) {
+	if !cfg.Enabled {
+		cfg.StrictPrivacy = false
+	}
+
+	prefs := s._preferences
+	if prefs.DnsQueryStats == cfg {
+		return dns.GetStats(), nil
+	}
+	prefs.DnsQueryStats = cfg
+	s.setPreferences(prefs)
+
+	dns.SetStatsConfig(cfg)
+
+	// re-apply DNS configuration to enable/disable query logging
+	if err := s.reApplyDnsIfConnected(); err != nil {
+		return dns.GetStats(), err
+	}
+	return dns.GetStats(), nil
+}
+
+// reApplyDnsIfConnected re-applies current DNS configuration (if connected)
+func (s *Service) reApplyDnsIfConnected() error {
+	if !s.Connected() {
+		return nil
+	}
+	params := s.GetConnectionParams()
+	if _, err := s.SetManualDNS(params.ManualDNS, params.Metadata.AntiTracker); err != nil {
+		return fmt.Errorf("failed to apply DNS configuration: %w", err)
+	}
+	return nil
+}
+
+func normalizeListSources(sources []string) []string {
+	ret := make([]string, 0, len(sources))
+	keys := make(map[string]struct{})
+	for _, src := range sources {
+		src = strings.TrimSpace(src)
+		if _, exists := keys[src]; !exists && len(src) > 0 {
+			ret = append(ret, src)
+			keys[src] = struct{}{}
+		}
+	}
+	return ret
+}
+
 // Normze AntiTracker block list name:
 // - if antiTrackerPlusList not defined - return default value
 // - if antiTrackerPlusList defined - check if it is valid; if not valid - return default value and error
@@ -1056,6 +1238,12 @@ func (s *Service) SetKillSwitchState(isEnabled bool) error {
 	if isEnabled && s._preferences.IsInverseSplitTunneling() {
 		return fmt.Errorf("firewall cannot be enabled while Inverse Split Tunnel is active; please disable Inverse Split Tunnel first")
 	}
+	if isEnabled && s._preferences.IsInverseSplitTunnelRoutes() {
+		return fmt.Errorf("firewall cannot be enabled while Inverse Split Tunnel routes are active; please disable Inverse Split Tunnel routes first")
+	}
+	if isEnabled && s._preferences.SplitTunnelNetns {
+		return fmt.Errorf("firewall cannot be enabled while the network namespace mode is active; please disable the network namespace mode first")
+	}
 
 	err := firewall.SetEnabled(isEnabled)
 	if err == nil {
@@ -1077,6 +1265,7 @@ func (s *Service) SetKillSwitchState(isEnabled bool) error {
 func (s *Service) KillSwitchState() (status types.KillSwitchStatus, err error) {
 	prefs := s._preferences
 	enabled, isLanAllowed, _, err := firewall.GetState()
+	ifExceptions, ifExceptionsActive := firewall.GetInterfaceExceptions()
 
 	return types.KillSwitchStatus{
 		IsEnabled:         enabled,
@@ -1086,9 +1275,54 @@ func (s *Service) KillSwitchState() (status types.KillSwitchStatus, err error) {
 		IsAllowApiServers: prefs.IsFwAllowApiServers,
 		UserExceptions:    prefs.FwUserExceptions,
 		StateLanAllowed:   isLanAllowed,
+
+		UserExceptionsHosts: s.fwHosts_getStatus(),
+
+		InterfaceExceptions:       ifExceptions,
+		InterfaceExceptionsActive: ifExceptionsActive,
+
+		AppAllowlist: prefs.FwAppAllowlist,
+
+		CaptivePortal: s.captivePortal_getStatus(),
 	}, err
 }
 
+// KillSwitchGetRules returns structured description of the active firewall rules
+// (including the result of their consistency check with the rules present in the kernel)
+func (s *Service) KillSwitchGetRules() (firewall.RuleSet, error) {
+	rs, err := firewall.GetRules()
+	if err != nil {
+		return rs, err
+	}
+
+	prefs := s._preferences
+	rs.IsPersistent = prefs.IsFwPersistant
+
+	// the firewall does not know the origin of persistent exceptions: mark API servers and resolved user hosts
+	knownHosts := make(map[string][]firewall.HostCategory)
+	if prefs.IsFwAllowApiServers {
+		if svrs, err := s.ServersList(); err == nil {
+			for _, ip := range append(svrs.Config.API.IPAddresses, svrs.Config.API.IPv6Addresses...) {
+				knownHosts[ip] = append(knownHosts[ip], firewall.HostCategoryApi)
+			}
+		}
+	}
+	for _, h := range s.fwHosts_getStatus() {
+		for _, ip := range h.IPs {
+			knownHosts[ip] = append(knownHosts[ip], firewall.HostCategoryUser)
+		}
+	}
+	for i, h := range rs.Hosts {
+		for _, c := range knownHosts[h.Address] {
+			if !rs.Hosts[i].HasCategory(c) {
+				rs.Hosts[i].Categories = append(rs.Hosts[i].Categories, c)
+			}
+		}
+	}
+
+	return rs, nil
+}
+
 // SetKillSwitchIsPersistent change kill-switch value
 func (s *Service) SetKillSwitchIsPersistent(isPersistant bool) error {
 	if s.IsPaused() {
@@ -1098,6 +1332,12 @@ func (s *Service) SetKillSwitchIsPersistent(isPersistant bool) error {
 	if isPersistant && s._preferences.IsInverseSplitTunneling() {
 		return fmt.Errorf("firewall cannot be enabled while Inverse Split Tunnel is active; please disable Inverse Split Tunnel first")
 	}
+	if isPersistant && s._preferences.IsInverseSplitTunnelRoutes() {
+		return fmt.Errorf("firewall cannot be enabled while Inverse Split Tunnel routes are active; please disable Inverse Split Tunnel routes first")
+	}
+	if isPersistant && s._preferences.SplitTunnelNetns {
+		return fmt.Errorf("firewall cannot be enabled while the network namespace mode is active; please disable the network namespace mode first")
+	}
 
 	prefs := s._preferences
 	prefs.IsFwPersistant = isPersistant
@@ -1165,19 +1405,90 @@ func (s *Service) SetKillSwitchAllowAPIServers(isAllowAPIServers bool) error {
 
 // SetKillSwitchUserExceptions set ip/mask to be excluded from FW block
 // Parameters:
-//   - exceptions - comma separated list of IP addresses in format: x.x.x.x[/xx]
+//   - exceptions - comma separated list of IP addresses in format: [DIRECTION:][PROTOCOL[:PORT[-PORT]]@]x.x.x.x[/xx] (see firewall.UserException)
+//     or host names (resolved addresses are allowed and refreshed on TTL expiry; see service_fw_hosts.go)
 func (s *Service) SetKillSwitchUserExceptions(exceptions string, ignoreParsingErrors bool) error {
+	if !ignoreParsingErrors {
+		// do not save configuration which can not be applied
+		if err := firewall.ValidateUserExceptions(exceptions); err != nil {
+			return err
+		}
+	}
+
 	prefs := s._preferences
 	prefs.FwUserExceptions = exceptions
 	s.setPreferences(prefs)
 
 	err := firewall.SetUserExceptions(exceptions, ignoreParsingErrors)
 	if err == nil {
+		s.fwHosts_update()
 		s.onKillSwitchStateChanged()
 	}
 	return err
 }
 
+// SetKillSwitchInterfaceExceptions set network interfaces (name patterns) to be excluded from FW block
+// (e.g. bridges of containers and virtual machines: "docker0", "br-*", "virbr*")
+func (s *Service) SetKillSwitchInterfaceExceptions(cfg firewall.InterfaceExceptions) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg = cfg.Normalize()
+
+	prefs := s._preferences
+	prefs.FwInterfaceExceptions = cfg
+	s.setPreferences(prefs)
+
+	err := firewall.SetInterfaceExceptions(cfg)
+	if err == nil {
+		s.onKillSwitchStateChanged()
+	}
+	return err
+}
+
+// SetKillSwitchAppAllowlist set applications (binaries or user IDs) allowed to communicate when firewall is enabled
+// (e.g. package updater or corporate SSO agent; Linux only)
+func (s *Service) SetKillSwitchAppAllowlist(cfg firewall.AppAllowlist) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg = cfg.Normalize()
+
+	if err := firewall.SetAppAllowlist(cfg); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.FwAppAllowlist = cfg
+	s.setPreferences(prefs)
+
+	s.onKillSwitchStateChanged()
+	return nil
+}
+
+// SetKillSwitchBlockedPacketsLogging enable/disable logging of packets blocked by firewall (Linux only)
+func (s *Service) SetKillSwitchBlockedPacketsLogging(enable bool) error {
+	if err := firewall.SetBlockedPacketsLogging(enable); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.FwLogBlockedPackets = enable
+	s.setPreferences(prefs)
+	return nil
+}
+
+// GetKillSwitchBlockedPackets returns aggregated info about packets blocked by firewall
+// Parameters:
+//   - clear - when 'true', the collected info will be erased after reading
+func (s *Service) GetKillSwitchBlockedPackets(clear bool) firewall.BlockedPacketsInfo {
+	ret := firewall.GetBlockedPackets()
+	if clear {
+		firewall.ClearBlockedPackets()
+	}
+	return ret
+}
+
 //////////////////////////////////////////////////////////
 // PREFERENCES
 //////////////////////////////////////////////////////////
@@ -1212,6 +1523,18 @@ func (s *Service) SetPreference(key protocolTypes.ServicePreference, val string)
 			prefs.IsAutoconnectOnLaunchDaemon = val
 		}
 
+	case protocolTypes.Prefs_IsCaptivePortalDetection:
+		if val, err := strconv.ParseBool(val); err == nil {
+			if val && !firewall.IsTemporaryExceptionsSupported() {
+				return false, fmt.Errorf("captive portal detection is not supported on this platform")
+			}
+			isChanged = val != prefs.IsCaptivePortalDetection
+			prefs.IsCaptivePortalDetection = val
+			if !val {
+				defer s.captivePortal_stop()
+			}
+		}
+
 	default:
 		log.Warning(fmt.Sprintf("Preference key '%s' not supported", key))
 	}
@@ -1287,13 +1610,35 @@ func (s *Service) SetWiFiSettings(params preferences.WiFiParams) error {
 	newNets := []preferences.WiFiNetwork{}
 	keys := make(map[string]struct{})
 	for _, n := range params.Networks {
-		if _, exists := keys[n.SSID]; !exists && len(n.SSID) > 0 {
+		if len(n.SSID) == 0 {
+			continue
+		}
+		if err := n.Validate(); err != nil {
+			return err
+		}
+		n = n.Normalize()
+		if _, exists := keys[n.Key()]; !exists {
 			newNets = append(newNets, n)
-			keys[n.SSID] = struct{}{}
+			keys[n.Key()] = struct{}{}
 		}
 	}
 	params.Networks = newNets
 
+	// remove duplicate rules from network rules list
+	var newRules []preferences.NetworkRule
+	keys = make(map[string]struct{})
+	for _, r := range params.NetworkRules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		r = r.Normalize()
+		if _, exists := keys[r.Key()]; !exists {
+			newRules = append(newRules, r)
+			keys[r.Key()] = struct{}{}
+		}
+	}
+	params.NetworkRules = newRules
+
 	// Save settings
 	prefs := s._preferences
 	prefs.WiFiControl = params
@@ -1351,7 +1696,20 @@ func (s *Service) SplitTunnelling_GetStatus() (protocolTypes.SplitTunnelStatus,
 		IsAllowWhenNoVpn:            isAllowWhenNoVpn,
 		IsCanGetAppIconForBinary:    oshelpers.IsCanGetAppIconForBinary(),
 		SplitTunnelApps:             prefs.SplitTunnelApps,
-		RunningApps:                 runningProcesses}
+		RunningApps:                 runningProcesses,
+		Routes:                      prefs.SplitTunnelRoutes,
+		IsRoutesInversed:            prefs.SplitTunnelRoutesInversed,
+		AppliedRoutes:               s.splitRoutes_getStatus(),
+		UserRules:                   prefs.SplitTunnelUsers,
+		DnsPolicy:                   prefs.SplitTunnelDnsPolicy,
+		DnsCustom:                   prefs.SplitTunnelDnsCustom,
+		IsNetnsMode:                 prefs.SplitTunnelNetns}
+	if dnsIp := s.splitTunnelling_getDnsServer(); dnsIp != nil {
+		ret.DnsServer = dnsIp.String()
+	}
+	if ret.IsNetnsMode {
+		ret.NetnsName = vpnNetnsName
+	}
 
 	return ret, nil
 }
@@ -1412,9 +1770,15 @@ func (s *Service) splitTunnelling_Reset() error {
 	prefs.SplitTunnelAnyDns = false
 	prefs.SplitTunnelAllowWhenNoVpn = false
 	prefs.SplitTunnelApps = make([]string, 0)
+	prefs.SplitTunnelUsers = nil
+	prefs.SplitTunnelDnsPolicy = splittun.DnsPolicyDefault
+	prefs.SplitTunnelDnsCustom = ""
+	prefs.SplitTunnelRoutes = nil
+	prefs.SplitTunnelRoutesInversed = false
 	s.setPreferences(prefs)
 
 	splittun.Reset()
+	s.splitRoutes_update()
 
 	// Apply configuration. Function will set (prefs.IsSplitTunnel = false) in case if error.
 	return s.splitTunnelling_ApplyConfig()
@@ -1445,8 +1809,8 @@ func (s *Service) splitTunnelling_ApplyConfig() (retError error) {
 		return srverrors.ErrorNotLoggedIn{}
 	}
 
-	// Network changes detection must be disabled for Inverse SplitTunneling
-	if prefs.IsInverseSplitTunneling() {
+	// Network changes detection must be disabled for Inverse SplitTunneling (including inverse mode of split tunnel routes)
+	if prefs.IsInverseSplitTunneling() || prefs.IsInverseSplitTunnelRoutes() {
 		// If inverse SplitTunneling is enabled - stop detection of network changes (if it already started)
 		if err := s._netChangeDetector.Stop(); err != nil {
 			log.Error(fmt.Sprintf("Unable to stop network changes detection: %v", err.Error()))
@@ -1487,16 +1851,35 @@ func (s *Service) splitTunnelling_ApplyConfig() (retError error) {
 	}
 
 	// Apply Split-Tun config
-	return splittun.ApplyConfig(prefs.IsSplitTunnel, prefs.IsInverseSplitTunneling(), prefs.SplitTunnelAllowWhenNoVpn, isVpnConnected, addressesCfg, prefs.SplitTunnelApps)
+	return splittun.ApplyConfig(prefs.IsSplitTunnel, prefs.IsInverseSplitTunneling(), prefs.SplitTunnelAllowWhenNoVpn, isVpnConnected, addressesCfg, prefs.SplitTunnelApps, prefs.SplitTunnelUsers, s.splitTunnelling_getDnsServer())
 }
 
-func (s *Service) SplitTunnelling_AddApp(exec string) (cmdToExecute string, isAlreadyRunning bool, err error) {
+// SplitTunnelling_AddApp adds application to Split Tunnel environment
+// Parameters:
+// exec 		- Windows: full path to the app binary; Linux: command to be executed in ST environment (or full path to the binary when 'isPersistent')
+// isPersistent - (Linux) add persistent rule by executable path: all processes started from the binary will be added to ST automatically
+func (s *Service) SplitTunnelling_AddApp(exec string, isPersistent bool) (cmdToExecute string, isAlreadyRunning bool, err error) {
 	if !s._preferences.IsSplitTunnel {
 		return "", false, fmt.Errorf("unable to run application in Split Tunnel environment: Split Tunnel is disabled")
 	}
 	// apply ST configuration after function ends
 	defer s.splitTunnelling_ApplyConfig()
-	return s.implSplitTunnelling_AddApp(exec)
+	return s.implSplitTunnelling_AddApp(exec, isPersistent)
+}
+
+// SplitTunnelling_SetUserRules sets the Split Tunnel rules by user account (applicable for Linux)
+// All traffic of the user (or the group) bypasses the VPN tunnel or uses only the VPN tunnel (no matter how its processes start).
+// The rules are effective when Split Tunnel is enabled.
+func (s *Service) SplitTunnelling_SetUserRules(rules []splittun.UserRule) error {
+	if err := s.implSplitTunnelling_CheckUserRules(rules); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.SplitTunnelUsers = rules
+	s.setPreferences(prefs)
+
+	return s.splitTunnelling_ApplyConfig()
 }
 
 func (s *Service) SplitTunnelling_RemoveApp(pid int, exec string) (err error) {
@@ -44,6 +44,7 @@ const (
 	OnUiClientConnected autoConnectReason = iota
 	OnSessionLogon      autoConnectReason = iota
 	OnWifiChanged       autoConnectReason = iota
+	OnNetworkChanged    autoConnectReason = iota
 )
 
 func (cr autoConnectReason) ToString() string {
@@ -54,6 +55,8 @@ func (cr autoConnectReason) ToString() string {
 		return "UIAppLaunch"
 	case OnWifiChanged:
 		return "WiFiChanged"
+	case OnNetworkChanged:
+		return "NetworkChanged"
 	case OnSessionLogon:
 		return "UserSessionLogon"
 	default:
@@ -84,8 +87,9 @@ type automaticAction struct {
 }
 
 type lastProcessedWiFiInfo struct {
-	wifi   wifiNotifier.WifiInfo
-	params preferences.WiFiParams
+	wifi    wifiNotifier.WifiInfo
+	network networkIdentity
+	params  preferences.WiFiParams
 }
 
 var autoconnectLastProcessedWifi lastProcessedWiFiInfo
@@ -126,7 +130,7 @@ func (s *Service) isTrustedWifiForcingToBlockLan(wifiInfoPtr *wifiNotifier.WifiI
 		wifiInfo = *wifiInfoPtr
 	}
 
-	action := s.getActionForWifiNetwork(wifiInfo)
+	action := s.getActionForWifiNetwork(wifiInfo, s.getNetworkIdentity(prefs.WiFiControl))
 
 	return action.Firewall == FW_On_and_blockLan
 }
@@ -158,12 +162,13 @@ func (s *Service) autoConnectIfRequired(reason autoConnectReason, wifiInfoPtr *w
 	// Check if WiFi already processed
 	isWifiProcessedAlready := false
 
-	currWiFi := lastProcessedWiFiInfo{wifi: wifiInfo, params: prefs.WiFiControl}
+	netId := s.getNetworkIdentity(prefs.WiFiControl)
+	currWiFi := lastProcessedWiFiInfo{wifi: wifiInfo, network: netId, params: prefs.WiFiControl}
 	lastWifi := autoconnectLastProcessedWifi
 
 	if reflect.DeepEqual(lastWifi, currWiFi) {
 		// this wifi network change has been processed already
-		if reason == OnWifiChanged {
+		if reason == OnWifiChanged || reason == OnNetworkChanged {
 			return nil
 		}
 		isWifiProcessedAlready = true
@@ -191,7 +196,7 @@ func (s *Service) autoConnectIfRequired(reason autoConnectReason, wifiInfoPtr *w
 	//
 
 	// Check "Trusted WiFi" actions
-	action := s.getActionForWifiNetwork(wifiInfo)
+	action := s.getActionForWifiNetwork(wifiInfo, netId)
 
 	isVpnOffRequired := false
 	if isWifiProcessedAlready {
@@ -317,7 +322,9 @@ func (s *Service) isCanApplyWiFiActions() bool {
 	return true
 }
 
-func (s *Service) getActionForWifiNetwork(wifiInfo wifiNotifier.WifiInfo) (retAction automaticAction) {
+// getActionForWifiNetwork returns the 'trusted-network' action for the current network.
+// WiFi rules have priority; the network rules (gateway MAC, DNS suffix, host reachability) are used when no WiFi rule matches.
+func (s *Service) getActionForWifiNetwork(wifiInfo wifiNotifier.WifiInfo, netId networkIdentity) (retAction automaticAction) {
 	prefs := s.Preferences()
 	if !prefs.Session.IsLoggedIn() {
 		return
@@ -328,24 +335,20 @@ func (s *Service) getActionForWifiNetwork(wifiInfo wifiNotifier.WifiInfo) (retAc
 	}
 
 	wifiParams := prefs.WiFiControl
-	if !wifiParams.TrustedNetworksControl || wifiInfo.SSID == "" {
+	if !wifiParams.TrustedNetworksControl {
 		return
 	}
 
-	var isNetworkTrusted *bool // nil - no action
+	// nil - no action
+	isNetworkTrusted := getTrustStatusForWifiRules(wifiParams.Networks, wifiInfo.SSID, wifiInfo.BSSID)
 
-	// get config for ssid
-	for _, w := range wifiParams.Networks {
-		if w.SSID != wifiInfo.SSID {
-			continue
-		}
-
-		isNetworkTrusted = &w.IsTrusted
-		break
+	if isNetworkTrusted == nil {
+		// no WiFi rules for the network: check rules based on network properties (applicable for wired networks too)
+		isNetworkTrusted = getTrustStatusForNetworkRules(wifiParams.NetworkRules, netId)
 	}
 
-	if isNetworkTrusted == nil {
-		// network not defined in settings. Using default configuration
+	if isNetworkTrusted == nil && wifiInfo.SSID != "" {
+		// WiFi network not defined in settings. Using default configuration
 		isNetworkTrusted = wifiParams.DefaultTrustStatusTrusted
 	}
 
@@ -0,0 +1,454 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+)
+
+// Captive portal detection.
+// When the firewall is enabled and VPN is not connected, a known HTTP endpoint is probed outside the tunnel.
+// If the probe is redirected (or the response is replaced by the portal page), the temporary firewall exception
+// is opened: only the gateway and the portal addresses are allowed (DNS requests to the gateway and HTTP/HTTPS).
+// The exception is closed as soon as the connectivity is confirmed or the time limit expired.
+// Note: supported only where the firewall implements temporary exceptions restricted by protocol/port (Linux).
+
+const (
+	captivePortalProbeHost     = "connectivitycheck.gstatic.com"
+	captivePortalProbePath     = "/generate_204"
+	captivePortalProbeTimeout  = 5 * time.Second
+	captivePortalRecheckPeriod = 5 * time.Second
+	captivePortalMaxDuration   = 5 * time.Minute
+	// max number of portal addresses to allow (in case if the portal host resolved to many addresses)
+	captivePortalMaxPortalIPs = 8
+)
+
+type captivePortalState struct {
+	mutex    sync.Mutex
+	stopChan chan struct{}
+	status   types.CaptivePortalStatus
+}
+
+// captivePortal_getStatus returns current status of the captive portal detection
+func (s *Service) captivePortal_getStatus() types.CaptivePortalStatus {
+	s._captivePortal.mutex.Lock()
+	defer s._captivePortal.mutex.Unlock()
+
+	ret := s._captivePortal.status
+	ret.IsEnabled = s._preferences.IsCaptivePortalDetection
+	ret.AllowedIPs = append([]string{}, ret.AllowedIPs...)
+	return ret
+}
+
+// captivePortal_stop stops the captive portal monitoring (if running) and closes the temporary firewall exception
+func (s *Service) captivePortal_stop() {
+	s._captivePortal.mutex.Lock()
+	defer s._captivePortal.mutex.Unlock()
+	s.captivePortal_stopNoLock()
+}
+
+func (s *Service) captivePortal_stopNoLock() {
+	if s._captivePortal.stopChan == nil {
+		return
+	}
+	close(s._captivePortal.stopChan)
+	s._captivePortal.stopChan = nil
+
+	if err := firewall.SetTemporaryExceptions(nil); err != nil {
+		log.Error("Captive portal: failed to remove temporary firewall exceptions: ", err)
+	}
+
+	isDetected := s._captivePortal.status.IsDetected
+	s._captivePortal.status.IsDetected = false
+	s._captivePortal.status.PortalURL = ""
+	s._captivePortal.status.AllowedIPs = nil
+	s._captivePortal.status.ExpiresAt = 0
+
+	if isDetected {
+		status := s._captivePortal.status
+		status.IsEnabled = s._preferences.IsCaptivePortalDetection
+		go s._evtReceiver.OnCaptivePortalStatusChanged(status)
+	}
+}
+
+// captivePortal_detectAsync checks (in background) if the current network is behind a captive portal.
+// If the portal is detected: the temporary firewall exception is opened to allow the portal login,
+// clients are notified and the connectivity is monitored.
+// The 'onDone' function is called when the detection is finished and no portal detected, or when the monitoring
+// is finished (connectivity confirmed or time limit expired). It is not called when the detection is cancelled
+// (captivePortal_stop() called or a newer detection started).
+func (s *Service) captivePortal_detectAsync(onDone func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("captivePortal_detect PANIC (recovered): ", r)
+			}
+		}()
+		s.captivePortal_detect(onDone)
+	}()
+}
+
+func (s *Service) captivePortal_detect(onDone func()) {
+	notifyDone := func() {
+		if onDone != nil {
+			onDone()
+		}
+	}
+
+	if !s._preferences.IsCaptivePortalDetection || s.Connected() || !firewall.IsTemporaryExceptionsSupported() {
+		s.captivePortal_stop()
+		notifyDone()
+		return
+	}
+	if isFwEnabled, err := firewall.GetEnabled(); err != nil || !isFwEnabled {
+		// firewall is disabled: nothing blocks the portal login
+		s.captivePortal_stop()
+		notifyDone()
+		return
+	}
+
+	gw, err := netinfo.DefaultGatewayIP()
+	if err != nil || gw == nil || gw.To4() == nil {
+		log.Info("Captive portal: skipping detection (default gateway IPv4 address not defined)")
+		s.captivePortal_stop()
+		notifyDone()
+		return
+	}
+
+	// stop previous detection (if running) and start the new one
+	stopChan := make(chan struct{})
+	s._captivePortal.mutex.Lock()
+	s.captivePortal_stopNoLock()
+	s._captivePortal.stopChan = stopChan
+	s._captivePortal.mutex.Unlock()
+
+	// finish the detection: no portal detected (or error)
+	finish := func() {
+		if s.captivePortal_stopIfCurrent(stopChan) {
+			notifyDone()
+		}
+	}
+
+	// allow access to the gateway (DNS and HTTP/HTTPS) in order to be able to perform the probe
+	gwRules := captivePortal_exceptionRules(gw, true)
+	if err := s.captivePortal_setExceptions(stopChan, gwRules); err != nil {
+		log.Info("Captive portal: ", err)
+		finish()
+		return
+	}
+
+	probeIPs, _, err := dns.ResolveHost(dns.DnsSettingsCreate(gw), captivePortalProbeHost, captivePortalProbeTimeout, false)
+	if err != nil {
+		log.Info("Captive portal: ", err)
+		finish()
+		return
+	}
+	if probeIPs = captivePortal_filterProbeIPs(gw, captivePortal_gatewayNetworks(gw), probeIPs); len(probeIPs) == 0 {
+		log.Info(fmt.Sprintf("Captive portal: '%s' resolved to local addresses only", captivePortalProbeHost))
+		finish()
+		return
+	}
+
+	probeRules := append([]firewall.UserException{}, gwRules...)
+	for _, ip := range probeIPs {
+		probeRules = append(probeRules, captivePortal_exceptionRules(ip, false)...)
+	}
+	if err := s.captivePortal_setExceptions(stopChan, probeRules); err != nil {
+		log.Info("Captive portal: ", err)
+		finish()
+		return
+	}
+
+	isPortal, portalURL, err := captivePortal_probe(probeIPs)
+	s.captivePortal_setProbeResult(isPortal, err)
+	if err != nil || !isPortal {
+		if err != nil {
+			log.Info("Captive portal: probe failed: ", err)
+		} else {
+			log.Info("Captive portal: not detected")
+		}
+		finish()
+		return
+	}
+
+	// allow the portal addresses
+	allowedIPs := []string{gw.String()}
+	portalRules := probeRules
+	for _, ip := range captivePortal_resolvePortal(gw, portalURL) {
+		if !ip.Equal(gw) {
+			portalRules = append(portalRules, captivePortal_exceptionRules(ip, false)...)
+			allowedIPs = append(allowedIPs, ip.String())
+		}
+	}
+	for _, ip := range probeIPs {
+		allowedIPs = append(allowedIPs, ip.String())
+	}
+	if err := s.captivePortal_setExceptions(stopChan, portalRules); err != nil {
+		log.Info("Captive portal: ", err)
+		finish()
+		return
+	}
+
+	expiresAt := time.Now().Add(captivePortalMaxDuration)
+
+	s._captivePortal.mutex.Lock()
+	if s._captivePortal.stopChan != stopChan {
+		// a newer detection started in the meantime
+		s._captivePortal.mutex.Unlock()
+		return
+	}
+	s._captivePortal.status.IsDetected = true
+	s._captivePortal.status.PortalURL = portalURL
+	s._captivePortal.status.AllowedIPs = allowedIPs
+	s._captivePortal.status.ExpiresAt = expiresAt.Unix()
+	s._captivePortal.mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Captive portal: detected (portal: '%s'; allowed: %s; expires: %s)", portalURL, strings.Join(allowedIPs, ", "), expiresAt.Format(time.TimeOnly)))
+	s._evtReceiver.OnCaptivePortalStatusChanged(s.captivePortal_getStatus())
+
+	go s.captivePortal_monitor(stopChan, probeIPs, expiresAt, onDone)
+}
+
+// captivePortal_stopIfCurrent stops the detection only if it was not restarted in the meantime.
+// Returns 'true' when stopped.
+func (s *Service) captivePortal_stopIfCurrent(stopChan chan struct{}) bool {
+	s._captivePortal.mutex.Lock()
+	defer s._captivePortal.mutex.Unlock()
+
+	if s._captivePortal.stopChan != stopChan {
+		return false
+	}
+	s.captivePortal_stopNoLock()
+	return true
+}
+
+// captivePortal_monitor periodically probes the connectivity; the temporary firewall exception is closed
+// as soon as the connectivity is confirmed or the time limit expired
+func (s *Service) captivePortal_monitor(stopChan chan struct{}, probeIPs []net.IP, expiresAt time.Time, onFinished func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("captivePortal_monitor PANIC (recovered): ", r)
+		}
+	}()
+
+	ticker := time.NewTicker(captivePortalRecheckPeriod)
+	defer ticker.Stop()
+	expireTimer := time.NewTimer(time.Until(expiresAt))
+	defer expireTimer.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-expireTimer.C:
+			log.Info("Captive portal: time limit expired, closing temporary firewall exceptions")
+		case <-ticker.C:
+			isPortal, _, err := captivePortal_probe(probeIPs)
+			s.captivePortal_setProbeResult(isPortal, err)
+			if err != nil || isPortal {
+				continue
+			}
+			log.Info("Captive portal: connectivity confirmed, closing temporary firewall exceptions")
+		}
+
+		// stop only if the monitoring was not restarted in the meantime
+		if s.captivePortal_stopIfCurrent(stopChan) && onFinished != nil {
+			onFinished()
+		}
+		return
+	}
+}
+
+func (s *Service) captivePortal_setExceptions(stopChan <-chan struct{}, rules []firewall.UserException) error {
+	s._captivePortal.mutex.Lock()
+	defer s._captivePortal.mutex.Unlock()
+
+	if s._captivePortal.stopChan != stopChan {
+		return fmt.Errorf("detection cancelled")
+	}
+	if err := firewall.SetTemporaryExceptions(rules); err != nil {
+		return fmt.Errorf("failed to apply temporary firewall exceptions: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) captivePortal_setProbeResult(isPortal bool, err error) {
+	s._captivePortal.mutex.Lock()
+	defer s._captivePortal.mutex.Unlock()
+	s._captivePortal.status.LastCheck = time.Now().Unix()
+	s._captivePortal.status.IsConnectivityConfirmed = err == nil && !isPortal
+}
+
+// captivePortal_exceptionRules returns outbound exceptions for HTTP/HTTPS (and DNS, if 'isDnsAllowed') to the host
+func captivePortal_exceptionRules(ip net.IP, isDnsAllowed bool) []firewall.UserException {
+	network := net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}
+	rule := func(protocol string, port int) firewall.UserException {
+		return firewall.UserException{Network: network, Protocol: protocol, PortFrom: port, PortTo: port, Direction: firewall.UserExceptionDirectionOut}
+	}
+
+	ret := []firewall.UserException{rule("tcp", 80), rule("tcp", 443)}
+	if isDnsAllowed {
+		ret = append(ret, rule("udp", 53), rule("tcp", 53))
+	}
+	return ret
+}
+
+// captivePortal_probe sends HTTP request to the probe endpoint (the host name is already resolved to 'probeIPs').
+// Returns isPortal=false when the expected empty response received (Internet is accessible).
+// When the request is redirected or the response content is replaced, it is considered as a captive portal.
+func captivePortal_probe(probeIPs []net.IP) (isPortal bool, portalURL string, err error) {
+	if len(probeIPs) == 0 {
+		return false, "", fmt.Errorf("probe host address not defined")
+	}
+	probeURL := "http://" + captivePortalProbeHost + captivePortalProbePath
+
+	dialer := net.Dialer{Timeout: captivePortalProbeTimeout}
+	client := &http.Client{
+		Timeout: captivePortalProbeTimeout,
+		Transport: &http.Transport{
+			Proxy:             nil,
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				// connect to the resolved address (the system DNS can not be used while the firewall is enabled)
+				var lastErr error
+				for _, ip := range probeIPs {
+					conn, err := dialer.DialContext(ctx, "tcp4", net.JoinHostPort(ip.String(), "80"))
+					if err == nil {
+						return conn, nil
+					}
+					lastErr = err
+				}
+				return nil, lastErr
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(probeURL)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return false, "", nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		location, err := resp.Location()
+		if err != nil {
+			return true, probeURL, nil
+		}
+		return true, location.String(), nil
+	case resp.StatusCode == http.StatusOK:
+		// the response is replaced by the portal page
+		return true, probeURL, nil
+	default:
+		return false, "", fmt.Errorf("unexpected response status '%s'", resp.Status)
+	}
+}
+
+// captivePortal_filterProbeIPs returns the addresses of the probe host which are allowed to be added to the firewall exceptions:
+// the probe host is a public host, so the private, local and gateway subnet addresses are ignored
+// (except the gateway itself: it is already allowed). The number of addresses is limited by 'captivePortalMaxPortalIPs'.
+func captivePortal_filterProbeIPs(gw net.IP, gwNetworks []net.IPNet, ips []net.IP) []net.IP {
+	var ret []net.IP
+	for _, ip := range ips {
+		if len(ret) >= captivePortalMaxPortalIPs {
+			break
+		}
+		if ip.Equal(gw) {
+			ret = append(ret, ip)
+			continue
+		}
+		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() || ip.Equal(net.IPv4bcast) {
+			continue
+		}
+		isInGatewayNetwork := false
+		for _, n := range gwNetworks {
+			if n.Contains(ip) {
+				isInGatewayNetwork = true
+				break
+			}
+		}
+		if !isInGatewayNetwork {
+			ret = append(ret, ip)
+		}
+	}
+	return ret
+}
+
+// captivePortal_gatewayNetworks returns the local networks which contain the gateway address
+func captivePortal_gatewayNetworks(gw net.IP) []net.IPNet {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ret []net.IPNet
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.Contains(gw) {
+			ret = append(ret, net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask})
+		}
+	}
+	return ret
+}
+
+// captivePortal_resolvePortal returns addresses of the portal host (resolved by the gateway DNS)
+func captivePortal_resolvePortal(gw net.IP, portalURL string) []net.IP {
+	u, err := url.Parse(portalURL)
+	if err != nil || len(u.Hostname()) == 0 || u.Hostname() == captivePortalProbeHost {
+		return nil
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if ip.To4() == nil {
+			return nil
+		}
+		return []net.IP{ip}
+	}
+
+	ips, _, err := dns.ResolveHost(dns.DnsSettingsCreate(gw), u.Hostname(), captivePortalProbeTimeout, false)
+	if err != nil {
+		log.Info("Captive portal: ", err)
+		return nil
+	}
+	if len(ips) > captivePortalMaxPortalIPs {
+		ips = ips[:captivePortalMaxPortalIPs]
+	}
+	return ips
+}
@@ -0,0 +1,66 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestCaptivePortalFilterProbeIPs(t *testing.T) {
+	gw := net.ParseIP("100.64.0.1")
+	_, gwNet, _ := net.ParseCIDR("100.64.0.0/24")
+	gwNetworks := []net.IPNet{*gwNet}
+
+	parse := func(addrs ...string) []net.IP {
+		var ret []net.IP
+		for _, a := range addrs {
+			ret = append(ret, net.ParseIP(a))
+		}
+		return ret
+	}
+
+	var many []string
+	for i := 1; i <= captivePortalMaxPortalIPs+2; i++ {
+		many = append(many, fmt.Sprintf("203.0.113.%d", i))
+	}
+
+	tests := []struct {
+		ips  []net.IP
+		want []net.IP
+	}{
+		{nil, nil},
+		{parse("142.250.74.35"), parse("142.250.74.35")},
+		{parse("100.64.0.1"), parse("100.64.0.1")},
+		{parse("10.0.0.5", "192.168.1.1", "172.16.0.1", "127.0.0.1", "169.254.1.1", "0.0.0.0", "255.255.255.255", "224.0.0.1"), nil},
+		{parse("100.64.0.7", "142.250.74.35"), parse("142.250.74.35")},
+		{parse(many...), parse(many[:captivePortalMaxPortalIPs]...)},
+	}
+	for _, tt := range tests {
+		if got := captivePortal_filterProbeIPs(gw, gwNetworks, tt.ips); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: unexpected result %v (expected %v)", tt.ips, got, tt.want)
+		}
+	}
+}
@@ -133,14 +133,21 @@ func (s *Service) Connect(params types.ConnectionParams) (err error) {
 	}
 
 	// Normalize hosts list
-	// - in case of multiple entry hosts - take one random host from the list
-	// - in case of multiple exit hosts - take one random host from the list
-	if err := params.NormalizeHosts(); err != nil {
+	// - in case of multiple entry hosts - select one host from the list (according to the host selection strategy)
+	// - in case of multiple exit hosts - select one host from the list (according to the host selection strategy)
+	latencies := s.ping_getLastResults()
+	if err := params.NormalizeHosts(latencies); err != nil {
 		return fmt.Errorf("failed to normalize hosts: %w", err)
 	}
+	if entryHost, err := params.EntryHostInfo(); err == nil {
+		log.Info(fmt.Sprintf("Selected host: %s (load: %.1f%%; ping: %dms; strategy: %s)", entryHost.Hostname, entryHost.Load, latencies[entryHost.Host], params.Metadata.HostSelection))
+		s._entryHostnameMutex.Lock()
+		s._entryHostname = entryHost.Hostname
+		s._entryHostnameMutex.Unlock()
+	}
 
 	// ------------------------ Inverse Split Tunnel block start ------------------------
-	if prefs.IsInverseSplitTunneling() {
+	if prefs.IsInverseSplitTunneling() || prefs.IsInverseSplitTunnelRoutes() {
 		if params.FirewallOn || params.FirewallOnDuringConnection {
 			log.Info("The Firewall will not be enabled for the current connection because Split Tunnel Inverse mode is active")
 			params.FirewallOn = false
@@ -149,6 +156,23 @@ func (s *Service) Connect(params types.ConnectionParams) (err error) {
 	}
 	// ------------------------ Inverse Split Tunnel block end --------------------------
 
+	if prefs.SplitTunnelNetns {
+		if vpn.Type(params.VpnType) != vpn.WireGuard {
+			return fmt.Errorf("the network namespace mode is applicable only for WireGuard connections")
+		}
+		if params.FirewallOn || params.FirewallOnDuringConnection {
+			log.Info("The Firewall will not be enabled for the current connection because the network namespace mode is active")
+			params.FirewallOn = false
+			params.FirewallOnDuringConnection = false
+		}
+	}
+
+	// Select the physical interface for the encrypted traffic (if defined)
+	if err := s.underlay_init(params.UnderlayInterface, params.UnderlayFailoverInterface); err != nil {
+		return err
+	}
+	defer s.underlay_reset()
+
 	// ------------------------ V2RAY block start ------------------------
 	// 'originalEntryServerInfo' - will contain original info about EntryServer/Port (it is not 'nil' for V2Ray connections).
 	//  We need this info to notify correct data about vpn.CONNECTED state: for V2Ray connection the original parameters are overwriten by local V2Ray proxy params ('127.0.0.1:local_port')
@@ -296,6 +320,7 @@ func (s *Service) Connect(params types.ConnectionParams) (err error) {
 				ipv6Prefix,
 				params.WireGuardParameters.Mtu)
 		}
+		connectionParams.SetMtuAuto(params.WireGuardParameters.MtuAuto)
 
 		return s.connectWireGuard(originalEntryServerInfo, connectionParams, params.ManualDNS, params.Metadata.AntiTracker, params.FirewallOn, params.FirewallOnDuringConnection, v2RayWrapper)
 	}
@@ -474,6 +499,11 @@ func (s *Service) connectWireGuard(originalEntryServerInfo *svrConnInfo, connect
 			return nil, fmt.Errorf("error updating WG connection preferences (failed parsing local IP for WG connection)")
 		}
 		connectionParams.SetCredentials(session.WGPrivateKey, session.WGPresharedKey, localip)
+		if s.Preferences().SplitTunnelNetns {
+			connectionParams.SetNetns(vpnNetnsName)
+		} else {
+			connectionParams.SetNetns("")
+		}
 
 		vpnObj, err := wireguard.NewWireGuardObject(
 			platform.WgBinaryPath(),
@@ -535,7 +565,7 @@ func (s *Service) keepConnection(originalEntryServerInfo *svrConnInfo, createVpn
 		}
 
 		prefs = s.Preferences()
-		isInverseSplitTun := prefs.IsInverseSplitTunneling()
+		isInverseSplitTun := prefs.IsInverseSplitTunneling() || prefs.IsInverseSplitTunnelRoutes() || prefs.SplitTunnelNetns
 
 		// start connection
 		connErr := s.connect(originalEntryServerInfo, vpnObj, manualDns, antitracker, firewallOn && !isInverseSplitTun, firewallDuringConnection && !isInverseSplitTun, v2rayWrapper)
@@ -627,6 +657,9 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 
 	log.Info("Connecting...")
 
+	// save the original system DNS (can be used by the apps in Split Tunnel environment)
+	s.splitTunnelling_saveSystemDns()
+
 	// save vpn object
 	s._vpn = vpnProc
 
@@ -666,6 +699,9 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 		// notify routines to stop
 		close(stopChannel)
 
+		// stop 'auto' DNS selection
+		s.dnsAuto_stop()
+
 		// resetting manual DNS (if it is necessary)
 		err = vpnProc.ResetManualDNS()
 		if err != nil {
@@ -674,12 +710,16 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 
 		connectRoutinesWaiter.Wait()
 
+		// remove routes via the underlay interface
+		s.underlay_stop()
+
 		// Forget VPN object
 		s._vpn = nil
 
 		// Notify Split-Tunneling module about disconnected VPN status
 		// It is important to call it only after 's._vpn = nil' (so ST functionality will be correctly notified about VPN disconnected state)
 		s.splitTunnelling_ApplyConfig()
+		s.splitRoutes_apply()
 
 		log.Info("VPN process stopped")
 	}()
@@ -689,6 +729,13 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 	//	FALSE - other route changed
 	routesChangedChan := make(chan INetChangeDetectorMessage, 1)
 
+	// channel for notifying when the system resumed after sleep
+	powerResumeChan := s.power_registerResumeChan()
+	defer s.power_unregisterResumeChan(powerResumeChan)
+
+	// channel for notifying when the underlay interface was switched (e.g. the primary interface has lost carrier)
+	underlayChangedChan := make(chan struct{}, 1)
+
 	destinationIpAddresses := make([]net.IP, 0)
 	// Add VPN server IP to firewall exceptions
 	destinationIpAddresses = append(destinationIpAddresses, vpnProc.DestinationIP())
@@ -712,15 +759,25 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 		}()
 
 		var state vpn.StateInfo
+		var prevState vpn.State
 		for isRuning := true; isRuning; {
 			select {
 			case state = <-internalStateChan:
+				// repeated CONNECTED notification only updates the connection info (e.g. the detected MTU value)
+				isConnectionInfoUpdate := state.State == vpn.CONNECTED && prevState == vpn.CONNECTED
+				prevState = state.State
 
 				// store info about current time
 				state.Time = time.Now().Unix()
 				// store info about VPN connection type
 				state.VpnType = vpnProc.Type()
 
+				if state.State == vpn.CONNECTED {
+					s._entryHostnameMutex.Lock()
+					state.ServerHostname = s._entryHostname
+					s._entryHostnameMutex.Unlock()
+				}
+
 				// 'originalEntryServerInfo' contains original info about EntryServer/Port (it is not 'nil' for V2Ray connections).
 				// We need this info to notify correct data about vpn.CONNECTED state: for V2Ray connection the original parameters are overwriten by local V2Ray proxy params ('127.0.0.1:local_port')
 				if state.State == vpn.CONNECTED && originalEntryServerInfo != nil {
@@ -737,6 +794,10 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 
 					log.Info(fmt.Sprintf("State: %v", state))
 
+					if isConnectionInfoUpdate {
+						return
+					}
+
 					// internally process VPN state change
 					switch state.State {
 
@@ -768,7 +829,7 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 							if err := s._netChangeDetector.Init(routesChangedChan, netInterfaceToProtect); err != nil {
 								log.Error(fmt.Errorf("failed to init route change detection: %w", err))
 							}
-							if s._preferences.IsInverseSplitTunneling() {
+							if s._preferences.IsInverseSplitTunneling() || s._preferences.IsInverseSplitTunnelRoutes() {
 								// Inversed split-tunneling: disable monitoring of the default route to the VPN server.
 								// Note: the monitoring must be enabled as soon as the inverse split-tunneling is disabled!
 								log.Info("Disabled the monitoring of the default route to the VPN server due to Inverse Split-Tunnel")
@@ -816,6 +877,14 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 						// Notify Split-Tunneling module about connected VPN status
 						// It is important to call it after 's._vpn' initialised. So ST functionality will be correctly informed about 'VPN connected' status
 						s.splitTunnelling_ApplyConfig()
+						// Apply routes of destination-based split tunnelling
+						s.splitRoutes_apply()
+
+						// 'auto' DNS mode: select the fastest predefined DNS provider (from inside the tunnel)
+						s.dnsAuto_start()
+
+						// VPN is connected: temporary firewall exceptions for captive portal are not required anymore
+						s.captivePortal_stop()
 					default:
 					}
 				}()
@@ -836,7 +905,8 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 		}()
 
 		for isRuning := true; isRuning; {
-			needToReconnect := false // true - need to reconnect VPN
+			needToReconnect := false     // true - need to reconnect VPN
+			isV2RayRouteUpdated := false // true - the route to V2Ray server is already updated
 			var routeMsg INetChangeDetectorMessage
 
 			select {
@@ -844,6 +914,20 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 				if routeMsg.IsInterfaceLeak() {
 					needToReconnect = s._vpn.IsReconnectRequiredOnRoutingChange()
 				}
+			case <-powerResumeChan:
+				// the tunnel is not usable after sleep (the WireGuard session or OpenVPN connection is expired): reconnect immediately
+				log.Info("System resumed after sleep")
+				// the default gateway can be changed after resume: update the route to V2Ray server (also in Paused state)
+				if v2rayWrapper != nil {
+					if err := s.updateV2RayRoute(v2rayWrapper, true); err != nil {
+						log.Error(fmt.Errorf("failed to update V2Ray route: %w", err))
+					}
+					isV2RayRouteUpdated = true
+				}
+				needToReconnect = true
+			case <-underlayChangedChan:
+				// the existing V2Ray session is bound to the previous interface
+				needToReconnect = v2rayWrapper != nil || s._vpn.IsReconnectRequiredOnRoutingChange()
 			case <-stopChannel:
 				isRuning = false
 			}
@@ -865,7 +949,7 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 							}
 						}()
 						log.Info("Route change detected. Reconnecting...")
-						if v2rayWrapper != nil {
+						if v2rayWrapper != nil && !isV2RayRouteUpdated {
 							if err := s.updateV2RayRoute(v2rayWrapper, true); err != nil {
 								log.Error(fmt.Errorf("failed to update V2Ray route: %w", err))
 							}
@@ -891,6 +975,11 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 				s._vpn.OnRoutingChanged()
 			}
 
+			// Routes of destination-based split tunnelling must use the new default gateway
+			if routeMsg.NewDefaultGateway() != nil {
+				s.splitRoutes_apply()
+			}
+
 			// Ensure that current DNS configuration is correct. If not - it re-apply the required configuration.
 			// Currently, it is in use for macOS - like a DNS change monitor.
 			go func() {
@@ -982,6 +1071,13 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 		return err
 	}
 
+	// route the encrypted traffic via the pinned underlay interface
+	// (V2Ray: the route to the V2Ray server is managed by the V2Ray wrapper)
+	if err := s.underlay_start([]net.IP{vpnProc.DestinationIP()}, underlayChangedChan); err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
 	log.Info("Starting VPN process")
 	// connect: start VPN process and wait until it finishes
 	err = vpnProc.Connect(internalStateChan)
@@ -997,6 +1093,14 @@ func (s *Service) updateV2RayRoute(v2rayWrapper *v2r.V2RayWrapper, force bool) e
 	if v2rayWrapper == nil || s._vpn == nil {
 		return nil
 	}
+	// the underlay interface is pinned: use its gateway
+	if gwIp, isPinned, err := s.underlay_gateway(); isPinned {
+		if err != nil {
+			return err
+		}
+		return v2rayWrapper.UpdateMainRoute(gwIp, force)
+	}
+
 	defGwIp, err := netinfo.DefaultGatewayIP()
 	if err != nil || defGwIp == nil {
 		return fmt.Errorf("failed to get default gateway info: %w", err)
@@ -1124,6 +1228,12 @@ func (s *Service) startV2Ray(params types.ConnectionParams, v2RayType v2r.V2RayT
 		return params, nil, nil, fmt.Errorf("failed to start: no V2Ray inbound ports defined")
 	}
 
+	// gateway for the route to V2Ray server (nil - default gateway)
+	routeGateway, _, err := s.underlay_gateway()
+	if err != nil {
+		return params, nil, nil, err
+	}
+
 	// Start V2Ray process
 	v, err := v2r.Start(platform.V2RayBinaryPath(), platform.V2RayConfigFile(),
 		isTcpLocalPort,
@@ -1131,7 +1241,8 @@ func (s *Service) startV2Ray(params types.ConnectionParams, v2RayType v2r.V2RayT
 		outboundIp, outboundPort,
 		inboundIp, inboundPort,
 		outboundUserId,
-		outboundTlsSvrName)
+		outboundTlsSvrName,
+		routeGateway)
 	if err != nil {
 		return params, nil, nil, fmt.Errorf("failed to start v2ray: %w", err)
 	}
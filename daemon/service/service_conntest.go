@@ -25,6 +25,7 @@ package service
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -130,7 +131,7 @@ func (s *Service) doDetectAccessiblePorts(portsToTest []types.PortInfo) ([]types
 	const theTimeout = time.Millisecond * 500
 
 	checkPort := func(p types.PortInfo) error {
-		servAddr := fmt.Sprintf("%s:%d", remoteEchoServerIP, p.Port)
+		servAddr := net.JoinHostPort(remoteEchoServerIP, strconv.Itoa(p.Port))
 
 		if p.IsUDP() {
 			conn, err := net.Dial("udp", servAddr)
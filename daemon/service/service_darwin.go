@@ -32,6 +32,7 @@ import (
 	protocolTypes "github.com/ivpn/desktop-app/daemon/protocol/types"
 	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
+	"github.com/ivpn/desktop-app/daemon/splittun"
 )
 
 func (s *Service) implIsCanApplyUserPreferences(userPrefs preferences.UserPreferences) error {
@@ -53,7 +54,7 @@ func (s *Service) implPingServersStopped(hosts []net.IP) error {
 	return firewall.RemoveHostsFromExceptions(hosts, onlyForICMP, isPersistent)
 }
 
-func (s *Service) implSplitTunnelling_AddApp(binaryFile string) (requiredCmdToExec string, isAlreadyRunning bool, err error) {
+func (s *Service) implSplitTunnelling_AddApp(binaryFile string, isPersistent bool) (requiredCmdToExec string, isAlreadyRunning bool, err error) {
 	// Split Tunneling is not implemented for macOS
 	return "", false, nil
 }
@@ -71,3 +72,28 @@ func (s *Service) implGetDiagnosticExtraInfo() (string, error) {
 
 	return fmt.Sprintf("%s\n%s\n%s", ifconfig, netstat, scutil), nil
 }
+
+// implStartNetworkChangeMonitor - calls 'onChange' on network configuration changes (default gateway or local addresses)
+func (s *Service) implStartNetworkChangeMonitor(onChange func()) error {
+	pollNetworkChanges(onChange)
+	return nil
+}
+
+func (s *Service) implSplitTunnelling_SetNetnsMode(enable bool) error {
+	return fmt.Errorf("function not applicable for this platform")
+}
+func (s *Service) implSplitTunnelling_NetnsExec(client, daemon net.Addr, args []string) (pid int, err error) {
+	return 0, fmt.Errorf("function not applicable for this platform")
+}
+func (s *Service) implSplitTunnelling_CheckUserRules(rules []splittun.UserRule) error {
+	return fmt.Errorf("function not applicable for this platform")
+}
+func (s *Service) implSplitTunnelling_CheckDnsPolicy(policy splittun.DnsPolicy) error {
+	if policy == splittun.DnsPolicyDefault {
+		return nil
+	}
+	return fmt.Errorf("function not applicable for this platform")
+}
+func (s *Service) implSplitTunnelling_GetSystemDns() net.IP {
+	return nil
+}
@@ -0,0 +1,330 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+)
+
+// 'Auto' DNS mode: the fastest predefined encrypted DNS provider is selected automatically when VPN connected.
+// The providers are benchmarked from inside the tunnel; the selection is re-checked periodically
+// and the provider is changed if the current one stops answering.
+
+const (
+	dnsAutoProbesCount         = 3
+	dnsAutoProbeTimeout        = 3 * time.Second
+	dnsAutoRecheckInterval     = 10 * time.Minute
+	dnsAutoHealthCheckInterval = 30 * time.Second
+	dnsAutoMaxHealthFailures   = 2
+	// latency penalty for each failed probe (used to calculate provider score)
+	dnsAutoFailurePenaltyMs = 1000
+	// switch to another provider on periodic re-check only if it is faster at least on this value
+	dnsAutoSwitchMinGainMs = 20
+)
+
+type dnsAutoState struct {
+	mutex      sync.Mutex
+	stopChan   chan struct{}
+	selected   dns.DnsSettings
+	candidates []types.DnsAutoCandidate
+	lastCheck  time.Time
+}
+
+// SetDnsAutoMode enables/disables 'auto' DNS mode
+func (s *Service) SetDnsAutoMode(enabled bool) error {
+	params := s.GetConnectionParams()
+	if params.Metadata.DnsAuto == enabled {
+		return nil
+	}
+
+	if enabled {
+		if cfgs, err := dns.GetPredefinedDnsConfigurations(); err != nil || len(cfgs) == 0 {
+			return fmt.Errorf("unable to enable 'auto' DNS mode: no predefined encrypted DNS configurations available")
+		}
+		// 'auto' mode replaces custom DNS
+		params.ManualDNS = dns.DnsSettings{}
+	}
+	params.Metadata.DnsAuto = enabled
+	s.setConnectionParams(params)
+
+	if enabled && s.Connected() {
+		s.dnsAuto_start()
+		return nil
+	}
+
+	s.dnsAuto_stop()
+	return s.reApplyDnsIfConnected()
+}
+
+// GetDnsAutoStatus returns status of 'auto' DNS mode
+func (s *Service) GetDnsAutoStatus() types.DnsAutoStatus {
+	s._dnsAuto.mutex.Lock()
+	defer s._dnsAuto.mutex.Unlock()
+
+	ret := types.DnsAutoStatus{
+		IsEnabled:  s.GetConnectionParams().Metadata.DnsAuto,
+		Selected:   s._dnsAuto.selected,
+		Candidates: s._dnsAuto.candidates,
+	}
+	if !s._dnsAuto.lastCheck.IsZero() {
+		ret.LastCheck = s._dnsAuto.lastCheck.Unix()
+	}
+	return ret
+}
+
+// dnsAuto_isApplicable returns 'true' when 'auto' DNS mode must be in use for current configuration
+func (s *Service) dnsAuto_isApplicable() bool {
+	params := s.GetConnectionParams()
+	return params.Metadata.DnsAuto && !params.Metadata.AntiTracker.Enabled && params.ManualDNS.IsEmpty()
+}
+
+// dnsAuto_getSelected returns DNS provider selected by 'auto' DNS mode (empty if not selected or not applicable)
+func (s *Service) dnsAuto_getSelected() dns.DnsSettings {
+	if !s.dnsAuto_isApplicable() {
+		return dns.DnsSettings{}
+	}
+	s._dnsAuto.mutex.Lock()
+	defer s._dnsAuto.mutex.Unlock()
+	return s._dnsAuto.selected
+}
+
+// dnsAuto_start starts 'auto' DNS selection (must be called when VPN connected)
+func (s *Service) dnsAuto_start() {
+	// stop and start under the same lock: concurrent calls must not start multiple selection loops
+	s._dnsAuto.mutex.Lock()
+	defer s._dnsAuto.mutex.Unlock()
+
+	s.dnsAuto_stopLocked()
+
+	if !s.dnsAuto_isApplicable() {
+		return
+	}
+
+	stopChan := make(chan struct{})
+	s._dnsAuto.stopChan = stopChan
+	go s.dnsAuto_loop(stopChan)
+}
+
+func (s *Service) dnsAuto_stop() {
+	s._dnsAuto.mutex.Lock()
+	defer s._dnsAuto.mutex.Unlock()
+
+	s.dnsAuto_stopLocked()
+}
+
+// dnsAuto_stopLocked stops 'auto' DNS selection (s._dnsAuto.mutex must be locked)
+func (s *Service) dnsAuto_stopLocked() {
+	if s._dnsAuto.stopChan != nil {
+		close(s._dnsAuto.stopChan)
+		s._dnsAuto.stopChan = nil
+	}
+	s._dnsAuto.selected = dns.DnsSettings{}
+}
+
+func (s *Service) dnsAuto_loop(stopChan chan struct{}) {
+	log.Info("'Auto' DNS selection started")
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("PANIC (recovered): ", r)
+		}
+		log.Info("'Auto' DNS selection stopped")
+	}()
+
+	isStopped := func() bool {
+		select {
+		case <-stopChan:
+			return true
+		default:
+			return !s.Connected() || !s.dnsAuto_isApplicable()
+		}
+	}
+
+	s.dnsAuto_selectBest(stopChan, false)
+
+	healthTicker := time.NewTicker(dnsAutoHealthCheckInterval)
+	defer healthTicker.Stop()
+	healthFailures := 0
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-healthTicker.C:
+		}
+
+		if isStopped() {
+			return
+		}
+		if vpn := s._vpn; vpn != nil && vpn.IsPaused() {
+			continue
+		}
+
+		s._dnsAuto.mutex.Lock()
+		selected := s._dnsAuto.selected
+		lastCheck := s._dnsAuto.lastCheck
+		s._dnsAuto.mutex.Unlock()
+
+		if selected.IsEmpty() {
+			// nothing selected yet (e.g. all providers failed on previous check)
+			s.dnsAuto_selectBest(stopChan, false)
+			continue
+		}
+
+		if res := dns.TestConfig(selected, "", dnsAutoProbeTimeout); res.IsOk {
+			healthFailures = 0
+		} else {
+			healthFailures++
+			log.Info(fmt.Sprintf("'Auto' DNS: current provider not answering (%d/%d): %s", healthFailures, dnsAutoMaxHealthFailures, res.Error))
+		}
+
+		if healthFailures >= dnsAutoMaxHealthFailures {
+			healthFailures = 0
+			s.dnsAuto_selectBest(stopChan, true)
+		} else if time.Since(lastCheck) >= dnsAutoRecheckInterval {
+			s.dnsAuto_selectBest(stopChan, false)
+		}
+	}
+}
+
+// dnsAuto_selectBest benchmarks predefined DNS providers and applies the best one
+// 'isCurrentFailed' - the current provider is not answering (must be changed)
+func (s *Service) dnsAuto_selectBest(stopChan chan struct{}, isCurrentFailed bool) {
+	cfgs, err := dns.GetPredefinedDnsConfigurations()
+	if err != nil || len(cfgs) == 0 {
+		log.Warning("'Auto' DNS: no predefined DNS configurations available")
+		return
+	}
+
+	candidates := dnsAuto_benchmark(cfgs)
+
+	s._dnsAuto.mutex.Lock()
+	if s._dnsAuto.stopChan != stopChan {
+		// stopped during the benchmark
+		s._dnsAuto.mutex.Unlock()
+		return
+	}
+	current := s._dnsAuto.selected
+	s._dnsAuto.candidates = candidates
+	s._dnsAuto.lastCheck = time.Now()
+	s._dnsAuto.mutex.Unlock()
+
+	best := candidates[0]
+	if best.Failures >= dnsAutoProbesCount {
+		log.Warning("'Auto' DNS: all predefined DNS providers failed")
+		s._evtReceiver.OnDnsChanged()
+		return
+	}
+
+	if !current.IsEmpty() && !isCurrentFailed {
+		// keep current provider if it is not significantly slower than the best one
+		for _, c := range candidates {
+			if c.Dns.Equal(current) {
+				if c.Failures == 0 && dnsAuto_score(c)-dnsAuto_score(best) < dnsAutoSwitchMinGainMs {
+					s._evtReceiver.OnDnsChanged()
+					return
+				}
+				break
+			}
+		}
+	}
+
+	if best.Dns.Equal(current) {
+		s._evtReceiver.OnDnsChanged()
+		return
+	}
+
+	log.Info(fmt.Sprintf("'Auto' DNS: selected %s (%dms)", best.Dns.InfoString(), best.AvgLatencyMs))
+
+	if !s.dnsAuto_applySelected(stopChan, best.Dns) {
+		return
+	}
+	s._evtReceiver.OnDnsChanged()
+}
+
+// dnsAuto_applySelected applies DNS selected by 'auto' DNS mode.
+// DNS is applied under 's._dnsAuto.mutex' lock and only when the selection is not stopped
+// (the selection is stopped under the same lock, e.g. when custom DNS defined: the user's DNS must never be overwritten).
+// Returns 'true' when DNS applied.
+func (s *Service) dnsAuto_applySelected(stopChan chan struct{}, dnsCfg dns.DnsSettings) bool {
+	s._dnsAuto.mutex.Lock()
+	defer s._dnsAuto.mutex.Unlock()
+
+	if s._dnsAuto.stopChan != stopChan || !s.dnsAuto_isApplicable() {
+		return false
+	}
+
+	vpn := s._vpn
+	if vpn == nil {
+		return false
+	}
+	if err := vpn.SetManualDNS(dnsCfg); err != nil {
+		log.Error(fmt.Errorf("'auto' DNS: failed to apply DNS: %w", err))
+		return false
+	}
+
+	s._dnsAuto.selected = dnsCfg
+	return true
+}
+
+// dnsAuto_benchmark tests all DNS configurations (in parallel) and returns results sorted by score (the best is the first)
+func dnsAuto_benchmark(cfgs []dns.DnsSettings) []types.DnsAutoCandidate {
+	ret := make([]types.DnsAutoCandidate, len(cfgs))
+
+	var wg sync.WaitGroup
+	for i, cfg := range cfgs {
+		wg.Add(1)
+		go func(idx int, cfg dns.DnsSettings) {
+			defer wg.Done()
+
+			c := types.DnsAutoCandidate{Dns: cfg}
+			var latencySum int64
+			for p := 0; p < dnsAutoProbesCount; p++ {
+				if res := dns.TestConfig(cfg, "", dnsAutoProbeTimeout); res.IsOk {
+					latencySum += res.LatencyMs
+				} else {
+					c.Failures++
+				}
+			}
+			if okCnt := dnsAutoProbesCount - c.Failures; okCnt > 0 {
+				c.AvgLatencyMs = latencySum / int64(okCnt)
+			}
+			ret[idx] = c
+		}(i, cfg)
+	}
+	wg.Wait()
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return dnsAuto_score(ret[i]) < dnsAuto_score(ret[j])
+	})
+	return ret
+}
+
+func dnsAuto_score(c types.DnsAutoCandidate) int64 {
+	return c.AvgLatencyMs + int64(c.Failures)*dnsAutoFailurePenaltyMs
+}
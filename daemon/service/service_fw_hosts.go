@@ -0,0 +1,428 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+)
+
+// Firewall exceptions defined by host names (e.g. "git.example.com").
+// The host names are resolved using trusted resolver (the request is sent directly to the DNS server, bypassing the OS DNS configuration),
+// resolved addresses are allowed in the firewall and refreshed on TTL expiry (addresses which are not in use anymore are removed).
+
+const (
+	fwHostsResolveTimeout     = 5 * time.Second
+	fwHostsMinRefreshInterval = time.Minute
+	fwHostsMaxRefreshInterval = time.Hour
+	fwHostsRetryInterval      = 30 * time.Second
+)
+
+// fwHostsFallbackResolver - trusted resolver in use when VPN is disconnected and no other encrypted DNS configuration available
+var fwHostsFallbackResolver = dns.DnsSettings{
+	DnsHost:     "9.9.9.9",
+	Encryption:  dns.EncryptionDnsOverHttps,
+	DohTemplate: "https://dns.quad9.net/dns-query",
+}
+
+type fwHostsState struct {
+	mutex    sync.Mutex
+	stopChan chan struct{}
+	hosts    map[string]*fwHostEntry
+
+	// firewall exceptions for the resolvers (in use when VPN is disconnected)
+	resolversMutex sync.Mutex
+	resolvers      map[string]*fwHostsResolverException // "IP:port" -> exception info (resolvers and network probe hosts)
+}
+
+type fwHostsResolverException struct {
+	refs int                    // number of users of the exception
+	rule firewall.UserException // exception restricted to the TCP port (e.g. DoH/DoT port of the resolver)
+}
+
+type fwHostEntry struct {
+	ips        []net.IP
+	lastUpdate time.Time
+	nextUpdate time.Time
+	err        error
+}
+
+// fwHosts_getStatus returns state of firewall exceptions defined by host names
+func (s *Service) fwHosts_getStatus() []types.FwExceptionHost {
+	s._fwHosts.mutex.Lock()
+	defer s._fwHosts.mutex.Unlock()
+
+	ret := make([]types.FwExceptionHost, 0, len(s._fwHosts.hosts))
+	for host, e := range s._fwHosts.hosts {
+		h := types.FwExceptionHost{Host: host}
+		for _, ip := range e.ips {
+			h.IPs = append(h.IPs, ip.String())
+		}
+		if !e.lastUpdate.IsZero() {
+			h.LastUpdate = e.lastUpdate.Unix()
+		}
+		if !e.nextUpdate.IsZero() {
+			h.NextUpdate = e.nextUpdate.Unix()
+		}
+		if e.err != nil {
+			h.Error = e.err.Error()
+		}
+		ret = append(ret, h)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Host < ret[j].Host })
+	return ret
+}
+
+// fwHosts_update must be called each time the firewall user exceptions were changed
+func (s *Service) fwHosts_update() {
+	s.fwHosts_stop()
+
+	hosts := firewall.GetUserExceptionHosts()
+
+	s._fwHosts.mutex.Lock()
+	defer s._fwHosts.mutex.Unlock()
+
+	if s._fwHosts.hosts == nil {
+		s._fwHosts.hosts = make(map[string]*fwHostEntry)
+	}
+
+	required := make(map[string]struct{}, len(hosts))
+	for _, h := range hosts {
+		required[h] = struct{}{}
+		if _, ok := s._fwHosts.hosts[h]; !ok {
+			s._fwHosts.hosts[h] = &fwHostEntry{}
+		}
+	}
+	// remove hosts which are not in use anymore
+	for h := range s._fwHosts.hosts {
+		if _, ok := required[h]; !ok {
+			s.fwHosts_applyIPs(h, nil)
+			delete(s._fwHosts.hosts, h)
+		}
+	}
+
+	if len(s._fwHosts.hosts) == 0 {
+		return
+	}
+
+	stopChan := make(chan struct{})
+	s._fwHosts.stopChan = stopChan
+	go s.fwHosts_loop(stopChan)
+}
+
+func (s *Service) fwHosts_stop() {
+	s._fwHosts.mutex.Lock()
+	defer s._fwHosts.mutex.Unlock()
+
+	if s._fwHosts.stopChan != nil {
+		close(s._fwHosts.stopChan)
+		s._fwHosts.stopChan = nil
+	}
+}
+
+func (s *Service) fwHosts_loop(stopChan chan struct{}) {
+	log.Info("Firewall exceptions (host names): refresh started")
+	defer log.Info("Firewall exceptions (host names): refresh stopped")
+
+	for {
+		nextUpdate := s.fwHosts_refresh(stopChan)
+
+		timer := time.NewTimer(time.Until(nextUpdate))
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// fwHosts_refresh resolves all hosts which require update; returns time of the next required update
+func (s *Service) fwHosts_refresh(stopChan chan struct{}) (nextUpdate time.Time) {
+	now := time.Now()
+
+	var hostsToResolve []string
+	s._fwHosts.mutex.Lock()
+	for h, e := range s._fwHosts.hosts {
+		if !e.nextUpdate.After(now) {
+			hostsToResolve = append(hostsToResolve, h)
+		}
+	}
+	s._fwHosts.mutex.Unlock()
+
+	if len(hostsToResolve) > 0 {
+		type result struct {
+			ips []net.IP
+			ttl time.Duration
+			err error
+		}
+		results := make(map[string]result, len(hostsToResolve))
+
+		resolver, releaseResolver := s.fwHosts_getResolver()
+		withIPv6 := s.fwHosts_isIPv6Enabled()
+		for _, h := range hostsToResolve {
+			ips, ttl, err := dns.ResolveHost(resolver, h, fwHostsResolveTimeout, withIPv6)
+			results[h] = result{ips: ips, ttl: ttl, err: err}
+		}
+		releaseResolver()
+
+		s._fwHosts.mutex.Lock()
+		select {
+		case <-stopChan:
+			// configuration changed while resolving: do not apply results
+			s._fwHosts.mutex.Unlock()
+			return time.Now()
+		default:
+		}
+
+		for h, r := range results {
+			e, ok := s._fwHosts.hosts[h]
+			if !ok {
+				continue
+			}
+			e.err = r.err
+			if r.err != nil {
+				// keep previously resolved addresses; try again later
+				log.Warning(fmt.Sprintf("Firewall exception '%s': %v", h, r.err))
+				e.nextUpdate = time.Now().Add(fwHostsRetryInterval)
+				continue
+			}
+
+			interval := r.ttl
+			if interval < fwHostsMinRefreshInterval {
+				interval = fwHostsMinRefreshInterval
+			} else if interval > fwHostsMaxRefreshInterval {
+				interval = fwHostsMaxRefreshInterval
+			}
+			e.lastUpdate = time.Now()
+			e.nextUpdate = e.lastUpdate.Add(interval)
+			s.fwHosts_applyIPs(h, r.ips)
+		}
+		s._fwHosts.mutex.Unlock()
+	}
+
+	s._fwHosts.mutex.Lock()
+	defer s._fwHosts.mutex.Unlock()
+	for _, e := range s._fwHosts.hosts {
+		if nextUpdate.IsZero() || e.nextUpdate.Before(nextUpdate) {
+			nextUpdate = e.nextUpdate
+		}
+	}
+	return nextUpdate
+}
+
+// fwHosts_applyIPs updates firewall exceptions for the host: new addresses are allowed, old addresses are removed
+// (addresses which are still in use by other hosts are kept).
+// Must be called under 's._fwHosts.mutex' lock.
+func (s *Service) fwHosts_applyIPs(host string, ips []net.IP) {
+	e, ok := s._fwHosts.hosts[host]
+	if !ok {
+		return
+	}
+
+	inUseByOthers := make(map[string]struct{})
+	for h, other := range s._fwHosts.hosts {
+		if h == host {
+			continue
+		}
+		for _, ip := range other.ips {
+			inUseByOthers[ip.String()] = struct{}{}
+		}
+	}
+
+	newIPs := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		newIPs[ip.String()] = struct{}{}
+	}
+	oldIPs := make(map[string]struct{}, len(e.ips))
+	for _, ip := range e.ips {
+		oldIPs[ip.String()] = struct{}{}
+	}
+
+	var toAdd, toRemove []net.IP
+	for _, ip := range ips {
+		if _, ok := oldIPs[ip.String()]; !ok {
+			toAdd = append(toAdd, ip)
+		}
+	}
+	for _, ip := range e.ips {
+		_, isNew := newIPs[ip.String()]
+		_, isInUse := inUseByOthers[ip.String()]
+		if !isNew && !isInUse {
+			toRemove = append(toRemove, ip)
+		}
+	}
+
+	if len(toRemove) > 0 {
+		log.Info(fmt.Sprintf("Firewall exception '%s': removing %v", host, toRemove))
+		if err := firewall.RemoveHostsFromExceptions(toRemove, false, true); err != nil {
+			log.Error(fmt.Sprintf("Firewall exception '%s': %v", host, err))
+		}
+	}
+	if len(toAdd) > 0 {
+		log.Info(fmt.Sprintf("Firewall exception '%s': adding %v", host, toAdd))
+		if err := firewall.AddHostsToExceptions(toAdd, false, true); err != nil {
+			log.Error(fmt.Sprintf("Firewall exception '%s': %v", host, err))
+		}
+	}
+
+	e.ips = ips
+}
+
+// fwHosts_isIPv6Enabled returns 'true' when IPv6 addresses of the hosts are required:
+//   - VPN connected: the tunnel supports IPv6;
+//   - VPN disconnected: the system has IPv6 connectivity.
+func (s *Service) fwHosts_isIPv6Enabled() bool {
+	if vpn := s._vpn; vpn != nil && s.Connected() && !vpn.IsPaused() {
+		return vpn.IsIPv6InTunnel()
+	}
+	ip, err := netinfo.GetOutboundIP(true)
+	return err == nil && ip != nil && !ip.IsUnspecified()
+}
+
+// fwHosts_getResolver returns trusted DNS resolver to be used for resolving firewall exceptions:
+//   - VPN connected: DNS server in use for current connection (the requests go through the VPN tunnel);
+//   - VPN disconnected: encrypted DNS configuration (custom DNS, predefined DNS or the fallback one).
+//     In this case, the resolver address is temporarily allowed in the firewall (until 'release' function called;
+//     the exception is restricted to the DoH/DoT port where the platform supports it).
+func (s *Service) fwHosts_getResolver() (resolver dns.DnsSettings, release func()) {
+	release = func() {}
+
+	if s.Connected() {
+		if active, err := s.GetActiveDNS(); err == nil && !active.IsEmpty() {
+			return active, release
+		}
+	}
+
+	if manualDns := s.GetConnectionParams().ManualDNS; manualDns.Encryption != dns.EncryptionNone && !manualDns.IsEmpty() {
+		resolver = manualDns
+	} else if predefined, err := dns.GetPredefinedDnsConfigurations(); err == nil {
+		for _, d := range predefined {
+			if d.Encryption != dns.EncryptionNone && !d.IsEmpty() {
+				resolver = d
+				break
+			}
+		}
+	}
+	if resolver.IsEmpty() {
+		resolver = fwHostsFallbackResolver
+	}
+
+	if enabled, _ := firewall.GetEnabled(); enabled && !s.Connected() {
+		if ip := resolver.Ip(); ip != nil {
+			port := dns.EncryptedServerPort(resolver)
+			if err := s.fwHosts_allowTcpOut(ip, port); err != nil {
+				log.Warning(fmt.Sprintf("Firewall exceptions: failed to allow the resolver %s: %v", ip, err))
+			} else {
+				release = func() { s.fwHosts_releaseTcpOut(ip, port) }
+			}
+		}
+	}
+	return resolver, release
+}
+
+// fwHosts_allowTcpOut allows outgoing TCP connections to the host port in the firewall: e.g. to the resolver or to the network probe host
+// (the number of users is counted; see fwHosts_releaseTcpOut()).
+// The exception is restricted to the DoH/DoT port of the resolver (see firewall.SetResolverExceptions() for platform specifics).
+func (s *Service) fwHosts_allowTcpOut(ip net.IP, port int) error {
+	if port <= 0 {
+		return fmt.Errorf("port not defined")
+	}
+	network := net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+	if ip4 := ip.To4(); ip4 != nil {
+		network = net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+
+	s._fwHosts.resolversMutex.Lock()
+	defer s._fwHosts.resolversMutex.Unlock()
+
+	key := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	if r, ok := s._fwHosts.resolvers[key]; ok {
+		r.refs++
+		return nil
+	}
+
+	if s._fwHosts.resolvers == nil {
+		s._fwHosts.resolvers = make(map[string]*fwHostsResolverException)
+	}
+	s._fwHosts.resolvers[key] = &fwHostsResolverException{
+		refs: 1,
+		rule: firewall.UserException{
+			Network:   network,
+			Protocol:  "tcp",
+			PortFrom:  port,
+			PortTo:    port,
+			Direction: firewall.UserExceptionDirectionOut},
+	}
+
+	if err := s.fwHosts_applyResolverRules(); err != nil {
+		delete(s._fwHosts.resolvers, key)
+		return err
+	}
+	return nil
+}
+
+// fwHosts_releaseTcpOut removes the firewall exception for the host port when it is not in use anymore
+func (s *Service) fwHosts_releaseTcpOut(ip net.IP, port int) {
+	s._fwHosts.resolversMutex.Lock()
+	defer s._fwHosts.resolversMutex.Unlock()
+
+	key := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	r, ok := s._fwHosts.resolvers[key]
+	if !ok {
+		return
+	}
+	if r.refs--; r.refs > 0 {
+		return
+	}
+	delete(s._fwHosts.resolvers, key)
+
+	if err := s.fwHosts_applyResolverRules(); err != nil {
+		log.Error("Firewall exceptions: failed to remove exception: ", err)
+	}
+}
+
+// fwHosts_applyResolverRules applies firewall exceptions for all hosts in use (see fwHosts_allowTcpOut()).
+// Must be called under 's._fwHosts.resolversMutex' lock.
+func (s *Service) fwHosts_applyResolverRules() error {
+	keys := make([]string, 0, len(s._fwHosts.resolvers))
+	for k := range s._fwHosts.resolvers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rules := make([]firewall.UserException, 0, len(keys))
+	for _, k := range keys {
+		rules = append(rules, s._fwHosts.resolvers[k].rule)
+	}
+	return firewall.SetResolverExceptions(rules)
+}
@@ -26,6 +26,7 @@ type ServiceEventType uint32
 
 const (
 	On_Power_WakeUp  ServiceEventType = 0x10
+	On_Power_Sleep   ServiceEventType = 0x11
 	On_Session_Logon ServiceEventType = 0x20
 )
 
@@ -39,9 +40,16 @@ func (s *Service) startProcessingPowerEvents() bool {
 		defer log.Info("Power events receiver stopped")
 		for {
 			evt := <-eventsChan
-			if evt == On_Session_Logon {
+			switch evt {
+			case On_Session_Logon:
 				log.Info("Event: On_Session_Logon")
 				s.autoConnectIfRequired(OnSessionLogon, nil)
+			case On_Power_Sleep:
+				log.Info("Event: On_Power_Sleep")
+				s.power_onSleep()
+			case On_Power_WakeUp:
+				log.Info("Event: On_Power_WakeUp")
+				s.power_onWakeUp()
 			}
 		}
 	}()
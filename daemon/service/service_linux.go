@@ -28,11 +28,14 @@ package service
 import (
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
 	protocolTypes "github.com/ivpn/desktop-app/daemon/protocol/types"
 	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
@@ -79,7 +82,7 @@ func (s *Service) implPingServersStopped(hosts []net.IP) error {
 	return firewall.RemoveHostsFromExceptions(hosts, onlyForICMP, isPersistent)
 }
 
-func (s *Service) implSplitTunnelling_AddApp(execCmd string) (requiredCmdToExec string, isAlreadyRunning bool, err error) {
+func (s *Service) implSplitTunnelling_AddApp(execCmd string, isPersistent bool) (requiredCmdToExec string, isAlreadyRunning bool, err error) {
 	if !s._preferences.IsSplitTunnel {
 		return "", false, fmt.Errorf("unable to run application in Split Tunnel environment: Split Tunnel is disabled")
 	}
@@ -88,6 +91,10 @@ func (s *Service) implSplitTunnelling_AddApp(execCmd string) (requiredCmdToExec
 		return "", false, nil
 	}
 
+	if isPersistent {
+		return "", false, s.splitTunnelling_AddBinaryRule(execCmd)
+	}
+
 	isRunning, err := isAbleToAddAppToConfig(execCmd)
 	if err != nil {
 		return "", isRunning, err
@@ -103,9 +110,118 @@ func (s *Service) implSplitTunnelling_AddApp(execCmd string) (requiredCmdToExec
 }
 
 func (s *Service) implSplitTunnelling_RemoveApp(pid int, binaryPath string) (err error) {
+	binaryPath = strings.TrimSpace(binaryPath)
+	if pid <= 0 && len(binaryPath) > 0 {
+		return s.splitTunnelling_RemoveBinaryRule(binaryPath)
+	}
 	return splittun.RemovePid(pid)
 }
 
+// splitTunnelling_AddBinaryRule adds persistent rule by executable path (saved in preferences: survives reboots).
+// All processes started from the binary will be added to ST environment automatically.
+func (s *Service) splitTunnelling_AddBinaryRule(binaryPath string) error {
+	if !filepath.IsAbs(binaryPath) {
+		return fmt.Errorf("full path to the binary expected (%s)", binaryPath)
+	}
+	realPath, err := filepath.EvalSymlinks(binaryPath)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(realPath)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("the file is not an executable binary (%s)", binaryPath)
+	}
+
+	// Ensure no binaries from IVPN package is included into apps list to Split-Tunnel
+	if ex, err := os.Executable(); err == nil && len(ex) > 0 {
+		if ex, err = filepath.EvalSymlinks(ex); err == nil && ex == realPath {
+			return fmt.Errorf("Split-Tunnelling for IVPN binaries is forbidden (%s)", binaryPath)
+		}
+	}
+	if strings.HasPrefix(realPath, "/opt/ivpn/") || filepath.Base(realPath) == "ivpn" {
+		return fmt.Errorf("Split-Tunnelling for IVPN binaries is forbidden (%s)", binaryPath)
+	}
+
+	prefs := s._preferences
+	for _, a := range prefs.SplitTunnelApps {
+		if a == binaryPath {
+			// the binary is already in configuration
+			return nil
+		}
+	}
+
+	prefs.SplitTunnelApps = append(prefs.SplitTunnelApps, binaryPath)
+	s.setPreferences(prefs)
+
+	// start the process exec monitor and move already running processes of the binary to ST environment
+	return s.splitTunnelling_ApplyConfig()
+}
+
+// splitTunnelling_RemoveBinaryRule removes persistent rule by executable path
+func (s *Service) splitTunnelling_RemoveBinaryRule(binaryPath string) error {
+	prefs := s._preferences
+	newStApps := make([]string, 0, len(prefs.SplitTunnelApps))
+	for _, a := range prefs.SplitTunnelApps {
+		if a == binaryPath {
+			continue
+		}
+		newStApps = append(newStApps, a)
+	}
+
+	if len(newStApps) == len(prefs.SplitTunnelApps) {
+		return fmt.Errorf("the binary is not in the Split Tunnel configuration (%s)", binaryPath)
+	}
+
+	prefs.SplitTunnelApps = newStApps
+	s.setPreferences(prefs)
+
+	// remove running processes of the binary from ST environment (and stop the process exec monitor if not required anymore)
+	return s.splitTunnelling_ApplyConfig()
+}
+
+// implSplitTunnelling_CheckUserRules validates Split Tunnel rules by user account
+func (s *Service) implSplitTunnelling_CheckUserRules(rules []splittun.UserRule) error {
+	ids := make(map[string]struct{}, len(rules))
+	for _, r := range rules {
+		idType := "UID"
+		if r.IsGroup {
+			idType = "GID"
+		}
+		if r.Id == 0 {
+			// the daemon is running under 'root'; its own traffic must not be affected
+			return fmt.Errorf("split tunnel rule for %s 0 (root) is not allowed", idType)
+		}
+		key := fmt.Sprintf("%s:%d", idType, r.Id)
+		if _, ok := ids[key]; ok {
+			return fmt.Errorf("duplicate split tunnel rule for %s %d", idType, r.Id)
+		}
+		ids[key] = struct{}{}
+	}
+	return nil
+}
+
+func (s *Service) implSplitTunnelling_CheckDnsPolicy(policy splittun.DnsPolicy) error {
+	return nil
+}
+
+// implSplitTunnelling_GetSystemDns returns the first IPv4 DNS server of the system network configuration
+func (s *Service) implSplitTunnelling_GetSystemDns() net.IP {
+	servers, err := netinfo.DnsServers()
+	if err != nil {
+		log.Warning(fmt.Sprintf("Unable to detect system DNS servers: %v", err))
+		return nil
+	}
+	for _, ip := range servers {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return nil
+}
+
 // Inform the daemon about started process in ST environment
 // Parameters:
 // pid 			- process PID
@@ -303,3 +419,21 @@ func isAbleToAddAppToConfig(cmd string) (isAlreadyRunning bool, notAbleToRunErro
 
 	return retIsAlreadyRunning, nil
 }
+
+// implStartNetworkChangeMonitor - calls 'onChange' on network configuration changes (addresses or interfaces)
+func (s *Service) implStartNetworkChangeMonitor(onChange func()) error {
+	onNetChange := make(chan struct{}, 1)
+	if err := netlink.RegisterLanChangeListener(onNetChange); err != nil {
+		return err
+	}
+	if err := netlink.RegisterLinkChangeListener(onNetChange); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			<-onNetChange
+			onChange()
+		}
+	}()
+	return nil
+}
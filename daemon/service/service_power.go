@@ -0,0 +1,135 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+)
+
+// Suspend/resume handling.
+// Before sleep the VPN state is noted. After resume, the daemon waits for the default route and recovers
+// the active connection immediately (the route to V2Ray server is updated and VPN is reconnected)
+// instead of waiting for the WireGuard handshake timeout or the OpenVPN 'ping-restart'.
+// The trusted WiFi/network state is refreshed as well.
+// Note: the resume is processed only when the sleep event was received before
+// (only Linux sends On_Power_Sleep; the wake-up events on other platforms are ignored).
+
+const (
+	powerWakeUpDefaultRouteTimeout = time.Second * 30
+	powerWakeUpDefaultRouteCheck   = time.Millisecond * 500
+)
+
+type powerState struct {
+	mutex                  sync.Mutex
+	sleepTime              time.Time
+	isConnectedBeforeSleep bool
+	// notification channel of the active connection (see connect()): receives event when the system resumed
+	resumeChan chan struct{}
+}
+
+func (s *Service) power_onSleep() {
+	s._power.mutex.Lock()
+	defer s._power.mutex.Unlock()
+
+	s._power.sleepTime = time.Now()
+	s._power.isConnectedBeforeSleep = s.Connected()
+	log.Info(fmt.Sprintf("System is going to sleep (VPN connected: %v)", s._power.isConnectedBeforeSleep))
+}
+
+func (s *Service) power_onWakeUp() {
+	s._power.mutex.Lock()
+	sleepTime, isConnectedBeforeSleep := s._power.sleepTime, s._power.isConnectedBeforeSleep
+	s._power.sleepTime, s._power.isConnectedBeforeSleep = time.Time{}, false
+	s._power.mutex.Unlock()
+
+	if sleepTime.IsZero() {
+		return // no sleep notification received before (the platform does not send it)
+	}
+	log.Info(fmt.Sprintf("System resumed (sleep duration: %v)", time.Since(sleepTime).Round(time.Second)))
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("power_onWakeUp PANIC (recovered): ", r)
+			}
+		}()
+
+		// the network connectivity can be not restored yet: wait for the default route
+		if err := waitForDefaultRoute(powerWakeUpDefaultRouteTimeout); err != nil {
+			log.Warning(fmt.Sprintf("System resumed: %v", err))
+		}
+
+		// refresh 'trusted-wifi' and 'trusted-network' state
+		s.onWiFiChanged()
+		s.onNetworkChanged()
+
+		if !s.Connected() && !isConnectedBeforeSleep {
+			return
+		}
+
+		// notify the active connection
+		s._power.mutex.Lock()
+		resumeChan := s._power.resumeChan
+		s._power.mutex.Unlock()
+		if resumeChan != nil {
+			select {
+			case resumeChan <- struct{}{}:
+			default:
+			}
+		}
+	}()
+}
+
+// power_registerResumeChan returns the channel which receives notification when the system resumed
+// (must be unregistered by power_unregisterResumeChan())
+func (s *Service) power_registerResumeChan() chan struct{} {
+	s._power.mutex.Lock()
+	defer s._power.mutex.Unlock()
+
+	s._power.resumeChan = make(chan struct{}, 1)
+	return s._power.resumeChan
+}
+
+func (s *Service) power_unregisterResumeChan(c chan struct{}) {
+	s._power.mutex.Lock()
+	defer s._power.mutex.Unlock()
+
+	if s._power.resumeChan == c {
+		s._power.resumeChan = nil
+	}
+}
+
+func waitForDefaultRoute(timeout time.Duration) error {
+	for start := time.Now(); ; time.Sleep(powerWakeUpDefaultRouteCheck) {
+		if gw, err := netinfo.DefaultGatewayIP(); err == nil && gw != nil {
+			return nil
+		}
+		if time.Since(start) > timeout {
+			return fmt.Errorf("default route not found")
+		}
+	}
+}
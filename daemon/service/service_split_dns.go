@@ -0,0 +1,117 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/splittun"
+)
+
+// DNS resolver for the apps in Split Tunnel environment.
+// By default, the apps use the system DNS configuration (the VPN DNS when VPN is connected).
+// The DNS requests can be redirected to the original system DNS (saved before connecting to VPN)
+// or to the explicitly defined DNS server. The requests are sent outside the VPN tunnel.
+
+type splitDnsState struct {
+	mutex sync.Mutex
+	// the system DNS server saved before connecting to VPN
+	systemDns net.IP
+}
+
+// SplitTunnelling_SetDnsPolicy sets DNS resolver for the apps in Split Tunnel environment (applicable for Linux)
+// customDns - IPv4 address of the DNS server (applicable only for splittun.DnsPolicyCustom)
+func (s *Service) SplitTunnelling_SetDnsPolicy(policy splittun.DnsPolicy, customDns string) error {
+	customDns = strings.TrimSpace(customDns)
+
+	switch policy {
+	case splittun.DnsPolicyDefault, splittun.DnsPolicySystem:
+		customDns = ""
+	case splittun.DnsPolicyCustom:
+		ip := net.ParseIP(customDns)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("IPv4 address of DNS server expected (%s)", customDns)
+		}
+		if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() {
+			return fmt.Errorf("the address is not applicable for DNS server (%s)", customDns)
+		}
+		customDns = ip.String()
+	default:
+		return fmt.Errorf("unexpected DNS policy (%d)", policy)
+	}
+
+	if err := s.implSplitTunnelling_CheckDnsPolicy(policy); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.SplitTunnelDnsPolicy = policy
+	prefs.SplitTunnelDnsCustom = customDns
+	s.setPreferences(prefs)
+
+	return s.splitTunnelling_ApplyConfig()
+}
+
+// splitTunnelling_saveSystemDns saves the system DNS configuration (must be called before connecting to VPN)
+func (s *Service) splitTunnelling_saveSystemDns() {
+	if s._preferences.SplitTunnelDnsPolicy != splittun.DnsPolicySystem {
+		return
+	}
+	ip := s.implSplitTunnelling_GetSystemDns()
+
+	s._splitDns.mutex.Lock()
+	defer s._splitDns.mutex.Unlock()
+	if ip != nil {
+		s._splitDns.systemDns = ip
+	}
+}
+
+// splitTunnelling_getDnsServer returns the DNS server for the apps in Split Tunnel environment
+// (nil - the apps use the system DNS configuration)
+func (s *Service) splitTunnelling_getDnsServer() net.IP {
+	prefs := s.Preferences()
+	if prefs.IsInverseSplitTunneling() {
+		return nil
+	}
+
+	switch prefs.SplitTunnelDnsPolicy {
+	case splittun.DnsPolicyCustom:
+		return net.ParseIP(prefs.SplitTunnelDnsCustom)
+	case splittun.DnsPolicySystem:
+		if !s.Connected() {
+			// VPN is not connected: the current system DNS configuration is the original one
+			s.splitTunnelling_saveSystemDns()
+		}
+		s._splitDns.mutex.Lock()
+		defer s._splitDns.mutex.Unlock()
+		if s._splitDns.systemDns == nil {
+			log.Warning("Split Tunnel DNS: the system DNS server is not known; the apps use the system DNS configuration")
+		}
+		return s._splitDns.systemDns
+	default:
+		return nil
+	}
+}
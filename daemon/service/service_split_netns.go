@@ -0,0 +1,76 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+)
+
+// "VPN-only" network namespace mode (applicable for Linux and WireGuard).
+// The daemon creates the dedicated network namespace which contains only the WireGuard interface (moved there after the connection established).
+// Applications started in the namespace (e.g. 'ivpn exec-vpn <command>') have no network access when the VPN tunnel is down.
+// The rest of the system uses the default connection (the default route and DNS configuration of the host are not changed).
+
+const vpnNetnsName = "ivpn"
+
+// SplitTunnelling_SetNetnsMode enables/disables "VPN-only" network namespace mode.
+// The mode can be changed only when VPN is disconnected.
+func (s *Service) SplitTunnelling_SetNetnsMode(enable bool) error {
+	if s._preferences.SplitTunnelNetns == enable {
+		return nil
+	}
+	if s.Connected() {
+		return fmt.Errorf("unable to change the network namespace mode while VPN is connected; please disconnect VPN first")
+	}
+	if enable {
+		if enabled, _ := s.FirewallEnabled(); enabled {
+			return fmt.Errorf("unable to activate the network namespace mode: the Firewall is enabled; please, disable IVPN Firewall first")
+		}
+	}
+
+	if err := s.implSplitTunnelling_SetNetnsMode(enable); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.SplitTunnelNetns = enable
+	s.setPreferences(prefs)
+
+	s._evtReceiver.OnSplitTunnelStatusChanged()
+	return nil
+}
+
+// SplitTunnelling_NetnsExec runs the command in "VPN-only" network namespace.
+// The command is started with the credentials, working directory and standard input/output of the client process
+// (the process which owns the connection 'client' -> 'daemon'); only a safe subset of the client environment is passed.
+// Returns PID of the started process.
+func (s *Service) SplitTunnelling_NetnsExec(client, daemon net.Addr, args []string) (pid int, err error) {
+	if !s._preferences.SplitTunnelNetns {
+		return 0, fmt.Errorf("the network namespace mode is disabled")
+	}
+	if len(args) == 0 || len(args[0]) == 0 {
+		return 0, fmt.Errorf("command not defined")
+	}
+	return s.implSplitTunnelling_NetnsExec(client, daemon, args)
+}
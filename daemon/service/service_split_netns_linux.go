@@ -0,0 +1,354 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netns"
+)
+
+func (s *Service) implSplitTunnelling_SetNetnsMode(enable bool) error {
+	if enable {
+		if err := netns.Create(vpnNetnsName); err != nil {
+			return fmt.Errorf("failed to create network namespace: %w", err)
+		}
+		return nil
+	}
+	if err := netns.Delete(vpnNetnsName); err != nil {
+		return fmt.Errorf("failed to delete network namespace: %w", err)
+	}
+	return nil
+}
+
+// netnsExecEnvAllowlist - environment variables of the client process which are passed to the command started in the network namespace
+// (names ending with '*' are prefixes)
+var netnsExecEnvAllowlist = []string{
+	"PATH", "HOME", "LANG", "LANGUAGE", "LC_*", "TZ", "TERM", "COLORTERM",
+	"DISPLAY", "WAYLAND_DISPLAY", "XAUTHORITY", "XDG_RUNTIME_DIR", "XDG_SESSION_TYPE", "XDG_CURRENT_DESKTOP",
+	"XDG_DATA_DIRS", "XDG_CONFIG_DIRS", "DBUS_SESSION_BUS_ADDRESS", "PULSE_SERVER",
+}
+
+func (s *Service) implSplitTunnelling_NetnsExec(client, daemon net.Addr, args []string) (pid int, err error) {
+	clientAddr, ok := client.(*net.TCPAddr)
+	daemonAddr, ok2 := daemon.(*net.TCPAddr)
+	if !ok || !ok2 || clientAddr == nil || daemonAddr == nil {
+		return 0, fmt.Errorf("unable to detect the client process: unsupported connection type")
+	}
+	clientPid, err := findTcpClientProcess(clientAddr, daemonAddr)
+	if err != nil {
+		return 0, fmt.Errorf("unable to detect the client process: %w", err)
+	}
+
+	uid, gid, groups, err := readProcCredentials(clientPid)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read credentials of the client process: %w", err)
+	}
+	if uid == 0 {
+		return 0, fmt.Errorf("running commands with root privileges in the network namespace is not allowed")
+	}
+
+	if !netns.IsExists(vpnNetnsName) {
+		if err := netns.Create(vpnNetnsName); err != nil {
+			return 0, fmt.Errorf("failed to create network namespace: %w", err)
+		}
+	}
+
+	procDir := filepath.Join("/proc", strconv.Itoa(clientPid))
+	var env []string
+	if environ, err := os.ReadFile(filepath.Join(procDir, "environ")); err == nil {
+		env = filterEnv(strings.Split(string(environ), "\x00"), netnsExecEnvAllowlist)
+	}
+
+	cmd, err := netns.Command(vpnNetnsName, uid, gid, groups, env, args[0], args[1:]...)
+	if err != nil {
+		return 0, err
+	}
+	if cwd, err := os.Readlink(filepath.Join(procDir, "cwd")); err == nil {
+		cmd.Dir = cwd
+	}
+
+	// use standard input/output of the client process
+	// (the descriptor is reopened only with the access mode the client process already has)
+	var stdFiles []*os.File
+	defer func() {
+		for _, f := range stdFiles {
+			f.Close()
+		}
+	}()
+	openStd := func(fd int, flag int) *os.File {
+		if accMode, err := readFdAccessMode(clientPid, fd); err != nil || (accMode != flag && accMode != os.O_RDWR) {
+			return nil
+		}
+		f, err := os.OpenFile(filepath.Join(procDir, "fd", strconv.Itoa(fd)), flag, 0)
+		if err != nil {
+			return nil
+		}
+		stdFiles = append(stdFiles, f)
+		return f
+	}
+	if f := openStd(0, os.O_RDONLY); f != nil {
+		cmd.Stdin = f
+	}
+	if f := openStd(1, os.O_WRONLY); f != nil {
+		cmd.Stdout = f
+	}
+	if f := openStd(2, os.O_WRONLY); f != nil {
+		cmd.Stderr = f
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start command: %w", err)
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Info(fmt.Sprintf("Command in network namespace '%s' finished: %v", vpnNetnsName, err))
+		}
+	}()
+
+	log.Info(fmt.Sprintf("Started command in network namespace '%s' (PID:%d; UID:%d): %s", vpnNetnsName, cmd.Process.Pid, uid, strings.Join(args, " ")))
+	return cmd.Process.Pid, nil
+}
+
+// filterEnv returns the environment variables (in format "NAME=VALUE") which names are in the allowlist
+func filterEnv(environ []string, allowlist []string) []string {
+	var ret []string
+	for _, e := range environ {
+		name, _, found := strings.Cut(e, "=")
+		if !found || len(name) == 0 {
+			continue
+		}
+		for _, a := range allowlist {
+			if name == a || (strings.HasSuffix(a, "*") && strings.HasPrefix(name, strings.TrimSuffix(a, "*"))) {
+				ret = append(ret, e)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// readFdAccessMode returns access mode (os.O_RDONLY, os.O_WRONLY or os.O_RDWR) of the file descriptor of the process
+func readFdAccessMode(pid, fd int) (int, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "fdinfo", strconv.Itoa(fd)))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if val, found := strings.CutPrefix(line, "flags:"); found {
+			flags, err := strconv.ParseUint(strings.TrimSpace(val), 8, 32)
+			if err != nil {
+				return 0, err
+			}
+			return int(flags) & syscall.O_ACCMODE, nil
+		}
+	}
+	return 0, fmt.Errorf("flags not found")
+}
+
+// findTcpClientProcess returns PID of the local process which owns the established TCP connection 'client' -> 'daemon'
+func findTcpClientProcess(client, daemon *net.TCPAddr) (int, error) {
+	var socket *procNetTcpEntry
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		if socket = findTcpSocket(file, client, daemon); socket != nil {
+			break
+		}
+	}
+	if socket == nil {
+		return 0, fmt.Errorf("connection %s->%s not found", client, daemon)
+	}
+
+	socketLink := "socket:[" + socket.inode + "]"
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && link == socketLink {
+				if uid, _, _, err := readProcCredentials(pid); err != nil || uid != socket.uid {
+					return 0, fmt.Errorf("owner of the connection %s->%s does not match the process %d", client, daemon, pid)
+				}
+				return pid, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("process for connection %s->%s not found", client, daemon)
+}
+
+const procNetTcpStateEstablished = 0x01
+
+// procNetTcpEntry - TCP socket info (one line of /proc/net/tcp or /proc/net/tcp6)
+type procNetTcpEntry struct {
+	local  *net.TCPAddr
+	remote *net.TCPAddr
+	state  int
+	uid    uint32
+	inode  string
+}
+
+// findTcpSocket returns the established TCP socket with local address 'local' connected to 'remote' (file format: /proc/net/tcp)
+func findTcpSocket(file string, local, remote *net.TCPAddr) *procNetTcpEntry {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		e, err := parseProcNetTcpLine(scanner.Text())
+		if err != nil || e.state != procNetTcpStateEstablished {
+			continue
+		}
+		if isTCPAddrEqual(e.local, local) && isTCPAddrEqual(e.remote, remote) {
+			return &e
+		}
+	}
+	return nil
+}
+
+func isTCPAddrEqual(a, b *net.TCPAddr) bool {
+	return a.Port == b.Port && a.IP.Equal(b.IP)
+}
+
+// parseProcNetTcpLine parses the line of /proc/net/tcp (or /proc/net/tcp6):
+// "sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ..."
+func parseProcNetTcpLine(line string) (procNetTcpEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 10 {
+		return procNetTcpEntry{}, fmt.Errorf("unexpected format")
+	}
+	local, err := parseProcNetTcpAddr(fields[1])
+	if err != nil {
+		return procNetTcpEntry{}, fmt.Errorf("bad local address: %w", err)
+	}
+	remote, err := parseProcNetTcpAddr(fields[2])
+	if err != nil {
+		return procNetTcpEntry{}, fmt.Errorf("bad remote address: %w", err)
+	}
+	state, err := strconv.ParseUint(fields[3], 16, 8)
+	if err != nil {
+		return procNetTcpEntry{}, fmt.Errorf("bad state: %w", err)
+	}
+	uid, err := strconv.ParseUint(fields[7], 10, 32)
+	if err != nil {
+		return procNetTcpEntry{}, fmt.Errorf("bad uid: %w", err)
+	}
+	return procNetTcpEntry{local: local, remote: remote, state: int(state), uid: uint32(uid), inode: fields[9]}, nil
+}
+
+// parseProcNetTcpAddr parses the address in format "0100007F:1F90"
+// (IP address is a sequence of 32-bit words in host byte order; port is big-endian)
+func parseProcNetTcpAddr(str string) (*net.TCPAddr, error) {
+	ipStr, portStr, found := strings.Cut(str, ":")
+	if !found {
+		return nil, fmt.Errorf("unexpected format '%s'", str)
+	}
+	ipHex, err := hex.DecodeString(ipStr)
+	if err != nil || (len(ipHex) != net.IPv4len && len(ipHex) != net.IPv6len) {
+		return nil, fmt.Errorf("unexpected IP address '%s'", ipStr)
+	}
+	ip := make(net.IP, len(ipHex))
+	for i := 0; i < len(ipHex); i += 4 {
+		binary.NativeEndian.PutUint32(ip[i:], binary.BigEndian.Uint32(ipHex[i:]))
+	}
+	port, err := strconv.ParseUint(portStr, 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected port '%s'", portStr)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProcCredentials returns real UID, GID and supplementary groups of the process (from /proc/<pid>/status)
+func readProcCredentials(pid int) (uid, gid uint32, groups []uint32, err error) {
+	f, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer f.Close()
+
+	parseId := func(s string) (uint32, error) {
+		v, err := strconv.ParseUint(s, 10, 32)
+		return uint32(v), err
+	}
+
+	isUidFound, isGidFound := false, false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 1 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			if len(fields) > 1 {
+				if uid, err = parseId(fields[1]); err != nil {
+					return 0, 0, nil, err
+				}
+				isUidFound = true
+			}
+		case "Gid:":
+			if len(fields) > 1 {
+				if gid, err = parseId(fields[1]); err != nil {
+					return 0, 0, nil, err
+				}
+				isGidFound = true
+			}
+		case "Groups:":
+			for _, g := range fields[1:] {
+				if v, err := parseId(g); err == nil {
+					groups = append(groups, v)
+				}
+			}
+		}
+	}
+	if !isUidFound || !isGidFound {
+		return 0, 0, nil, fmt.Errorf("credentials not found")
+	}
+	return uid, gid, groups, nil
+}
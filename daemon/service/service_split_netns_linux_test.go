@@ -0,0 +1,149 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package service
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseProcNetTcpLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr bool
+		local   string
+		remote  string
+		state   int
+		uid     uint32
+		inode   string
+	}{
+		{
+			name:   "IPv4 established",
+			line:   "   3: 0100007F:D2F4 0100007F:1F90 01 00000000:00000000 00:00000000 00000000  1000        0 123456 1 0000000000000000 20 4 30 10 -1",
+			local:  "127.0.0.1:54004",
+			remote: "127.0.0.1:8080",
+			state:  procNetTcpStateEstablished,
+			uid:    1000,
+			inode:  "123456",
+		},
+		{
+			name:   "IPv4 listening",
+			line:   "   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1111 1 0000000000000000 100 0 0 10 0",
+			local:  "0.0.0.0:22",
+			remote: "0.0.0.0:0",
+			state:  0x0A,
+			uid:    0,
+			inode:  "1111",
+		},
+		{
+			name:   "IPv6 mapped IPv4",
+			line:   "   1: 0000000000000000FFFF00000100007F:D2F4 0000000000000000FFFF00000100007F:1F90 01 00000000:00000000 00:00000000 00000000  1000        0 2222 1 0000000000000000 20 4 30 10 -1",
+			local:  "127.0.0.1:54004",
+			remote: "127.0.0.1:8080",
+			state:  procNetTcpStateEstablished,
+			uid:    1000,
+			inode:  "2222",
+		},
+		{name: "header", line: "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode", wantErr: true},
+		{name: "short", line: "   3: 0100007F:D2F4 0100007F:1F90 01", wantErr: true},
+		{name: "bad address", line: "   3: 0100007F 0100007F:1F90 01 00000000:00000000 00:00000000 00000000  1000        0 123456 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := parseProcNetTcpLine(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("error expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// test data is in little-endian host byte order
+			if !isLittleEndianHost() {
+				t.Skip("big-endian host")
+			}
+			if e.local.String() != tt.local || e.remote.String() != tt.remote {
+				t.Errorf("unexpected addresses: %s -> %s", e.local, e.remote)
+			}
+			if e.state != tt.state || e.uid != tt.uid || e.inode != tt.inode {
+				t.Errorf("unexpected entry: %+v", e)
+			}
+		})
+	}
+}
+
+func isLittleEndianHost() bool {
+	a, err := parseProcNetTcpAddr("0100007F:0000")
+	return err == nil && a.IP.Equal(net.IPv4(127, 0, 0, 1))
+}
+
+func TestFindTcpSocket(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			defer c.Close()
+			c.Read(make([]byte, 1))
+		}
+	}()
+
+	conn, err := net.Dial("tcp4", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := conn.LocalAddr().(*net.TCPAddr)
+	daemon := l.Addr().(*net.TCPAddr)
+
+	if s := findTcpSocket("/proc/net/tcp", client, daemon); s == nil || s.state != procNetTcpStateEstablished {
+		t.Errorf("client socket not found")
+	}
+	// the server side of the connection has reversed addresses
+	if s := findTcpSocket("/proc/net/tcp", daemon, client); s == nil || s.local.Port != daemon.Port {
+		t.Errorf("server side socket not found")
+	}
+	if s := findTcpSocket("/proc/net/tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 2), Port: client.Port}, daemon); s != nil {
+		t.Errorf("unexpected socket found for another address: %+v", s)
+	}
+}
+
+func TestFilterEnv(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "LD_PRELOAD=/tmp/x.so", "LC_ALL=C", "DISPLAY=:0", "=bad", "", "SECRET_TOKEN=1", "LD_LIBRARY_PATH=/tmp"}
+	got := filterEnv(environ, netnsExecEnvAllowlist)
+	want := []string{"PATH=/usr/bin", "LC_ALL=C", "DISPLAY=:0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected result: %v", got)
+	}
+}
@@ -0,0 +1,364 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	"github.com/ivpn/desktop-app/daemon/splittun"
+)
+
+// Destination-based split tunnelling: IPv4 networks and domain names which are routed outside the VPN tunnel
+// (or, in inverse mode, the only destinations which are routed through the VPN tunnel).
+// Domain names are resolved using trusted resolver (same as for firewall exceptions defined by host names) and refreshed on TTL expiry.
+// In normal mode, the destinations are also allowed by the firewall.
+
+type splitRoutesState struct {
+	mutex           sync.Mutex
+	stopChan        chan struct{}
+	domains         map[string]*splitRouteDomain
+	physicalGateway net.IP // last known default gateway of the physical interface
+}
+
+type splitRouteDomain struct {
+	ips        []net.IP
+	nextUpdate time.Time
+	err        error
+}
+
+// parseSplitTunnelRoute parses destination of the split tunnel route: IPv4 network in CIDR notation, IPv4 address or domain name.
+// Returns network (for IP addresses and networks) or domain name in lowercase.
+func parseSplitTunnelRoute(dest string) (network *net.IPNet, domain string, err error) {
+	dest = strings.TrimSpace(dest)
+	if len(dest) == 0 {
+		return nil, "", fmt.Errorf("empty destination")
+	}
+
+	if firewall.IsUserExceptionHost(dest) {
+		return nil, strings.ToLower(strings.TrimSuffix(dest, ".")), nil
+	}
+
+	cidr := dest
+	if ip := net.ParseIP(dest); ip != nil {
+		if ip.To4() == nil {
+			return nil, "", fmt.Errorf("'%s': IPv6 is not supported", dest)
+		}
+		cidr = dest + "/32"
+	}
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, "", fmt.Errorf("'%s': not a network or domain name", dest)
+	}
+	if n.IP.To4() == nil {
+		return nil, "", fmt.Errorf("'%s': IPv6 is not supported", dest)
+	}
+	if ones, _ := n.Mask.Size(); ones < 3 {
+		// the route must be more specific than routes in use by VPN connection (and by inverse mode)
+		return nil, "", fmt.Errorf("'%s': network is too wide (minimum mask length is 3)", dest)
+	}
+	return n, "", nil
+}
+
+// SplitTunnelling_SetRoutes sets the list of destinations (IPv4 networks or domain names) for destination-based split tunnelling
+// isInversed - when true: only the destinations use VPN tunnel; all the rest traffic is routed outside the VPN tunnel
+func (s *Service) SplitTunnelling_SetRoutes(routes []string, isInversed bool) error {
+	newRoutes := make([]string, 0, len(routes))
+	known := make(map[string]struct{}, len(routes))
+	for _, r := range routes {
+		network, domain, err := parseSplitTunnelRoute(r)
+		if err != nil {
+			return fmt.Errorf("invalid split tunnel route %w", err)
+		}
+		val := domain
+		if network != nil {
+			val = network.String()
+		}
+		if _, ok := known[val]; ok {
+			continue
+		}
+		known[val] = struct{}{}
+		newRoutes = append(newRoutes, val)
+	}
+
+	if isInversed && len(newRoutes) > 0 {
+		if enabled, _ := s.FirewallEnabled(); enabled {
+			return fmt.Errorf("unable to activate Inverse Split Tunnel routes: the Firewall is enabled; please, disable IVPN Firewall first")
+		}
+	}
+
+	prefs := s._preferences
+	prefs.SplitTunnelRoutes = newRoutes
+	prefs.SplitTunnelRoutesInversed = isInversed
+	s.setPreferences(prefs)
+
+	s.splitRoutes_update()
+
+	if !prefs.Session.IsLoggedIn() {
+		s._evtReceiver.OnSplitTunnelStatusChanged()
+		return nil
+	}
+	// Inverse mode affects monitoring of the default route (see splitTunnelling_ApplyConfig())
+	return s.splitTunnelling_ApplyConfig()
+}
+
+// splitRoutes_getStatus returns list of routes applied by destination-based split tunnelling
+func (s *Service) splitRoutes_getStatus() []string {
+	return splittun.GetAppliedRoutes()
+}
+
+// splitRoutes_update must be called each time the split tunnel routes configuration was changed
+func (s *Service) splitRoutes_update() {
+	s.splitRoutes_stop()
+
+	var domains []string
+	for _, r := range s._preferences.SplitTunnelRoutes {
+		if _, domain, err := parseSplitTunnelRoute(r); err == nil && domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	s._splitRoutes.mutex.Lock()
+	newDomains := make(map[string]*splitRouteDomain, len(domains))
+	for _, d := range domains {
+		if e, ok := s._splitRoutes.domains[d]; ok {
+			newDomains[d] = e
+		} else {
+			newDomains[d] = &splitRouteDomain{}
+		}
+	}
+	s._splitRoutes.domains = newDomains
+
+	if len(newDomains) > 0 {
+		stopChan := make(chan struct{})
+		s._splitRoutes.stopChan = stopChan
+		go s.splitRoutes_loop(stopChan)
+	}
+	s._splitRoutes.mutex.Unlock()
+
+	s.splitRoutes_apply()
+}
+
+func (s *Service) splitRoutes_stop() {
+	s._splitRoutes.mutex.Lock()
+	defer s._splitRoutes.mutex.Unlock()
+
+	if s._splitRoutes.stopChan != nil {
+		close(s._splitRoutes.stopChan)
+		s._splitRoutes.stopChan = nil
+	}
+}
+
+func (s *Service) splitRoutes_loop(stopChan chan struct{}) {
+	log.Info("Split tunnel routes (domain names): refresh started")
+	defer log.Info("Split tunnel routes (domain names): refresh stopped")
+
+	for {
+		nextUpdate, isChanged := s.splitRoutes_refresh(stopChan)
+		if isChanged {
+			s.splitRoutes_apply()
+		}
+
+		timer := time.NewTimer(time.Until(nextUpdate))
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// splitRoutes_refresh resolves all domain names which require update.
+// Returns time of the next required update and 'true' when resolved addresses were changed
+func (s *Service) splitRoutes_refresh(stopChan chan struct{}) (nextUpdate time.Time, isChanged bool) {
+	now := time.Now()
+
+	var toResolve []string
+	s._splitRoutes.mutex.Lock()
+	for d, e := range s._splitRoutes.domains {
+		if !e.nextUpdate.After(now) {
+			toResolve = append(toResolve, d)
+		}
+	}
+	s._splitRoutes.mutex.Unlock()
+
+	if len(toResolve) > 0 {
+		resolver, releaseResolver := s.fwHosts_getResolver()
+		for _, d := range toResolve {
+			ips, ttl, err := dns.ResolveHost(resolver, d, fwHostsResolveTimeout, false)
+
+			s._splitRoutes.mutex.Lock()
+			select {
+			case <-stopChan:
+				// configuration changed while resolving: do not apply results
+				s._splitRoutes.mutex.Unlock()
+				releaseResolver()
+				return time.Now(), false
+			default:
+			}
+
+			if e, ok := s._splitRoutes.domains[d]; ok {
+				e.err = err
+				if err != nil {
+					// keep previously resolved addresses; try again later
+					log.Warning(fmt.Sprintf("Split tunnel route '%s': %v", d, err))
+					e.nextUpdate = time.Now().Add(fwHostsRetryInterval)
+				} else {
+					var ipv4 []net.IP
+					for _, ip := range ips {
+						if ip.To4() != nil {
+							ipv4 = append(ipv4, ip.To4())
+						}
+					}
+					sort.Slice(ipv4, func(i, j int) bool { return ipv4[i].String() < ipv4[j].String() })
+					if !isIPListEqual(e.ips, ipv4) {
+						isChanged = true
+					}
+					e.ips = ipv4
+
+					if ttl < fwHostsMinRefreshInterval {
+						ttl = fwHostsMinRefreshInterval
+					} else if ttl > fwHostsMaxRefreshInterval {
+						ttl = fwHostsMaxRefreshInterval
+					}
+					e.nextUpdate = time.Now().Add(ttl)
+				}
+			}
+			s._splitRoutes.mutex.Unlock()
+		}
+		releaseResolver()
+	}
+
+	s._splitRoutes.mutex.Lock()
+	defer s._splitRoutes.mutex.Unlock()
+	for _, e := range s._splitRoutes.domains {
+		if nextUpdate.IsZero() || e.nextUpdate.Before(nextUpdate) {
+			nextUpdate = e.nextUpdate
+		}
+	}
+	return nextUpdate, isChanged
+}
+
+// splitRoutes_apply applies firewall exceptions and routes for destination-based split tunnelling.
+// It must be called each time when VPN connection state or the default gateway was changed.
+func (s *Service) splitRoutes_apply() {
+	prefs := s.Preferences()
+
+	var networks []net.IPNet
+	s._splitRoutes.mutex.Lock()
+	for _, r := range prefs.SplitTunnelRoutes {
+		network, domain, err := parseSplitTunnelRoute(r)
+		if err != nil {
+			continue
+		}
+		if network != nil {
+			networks = append(networks, *network)
+			continue
+		}
+		if e, ok := s._splitRoutes.domains[domain]; ok {
+			for _, ip := range e.ips {
+				networks = append(networks, net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
+			}
+		}
+	}
+	s._splitRoutes.mutex.Unlock()
+
+	isInversed := prefs.IsInverseSplitTunnelRoutes()
+
+	// Firewall: the destinations routed outside the VPN tunnel must be allowed
+	fwExceptions := networks
+	if isInversed {
+		fwExceptions = nil
+	}
+	if err := firewall.SetRouteExceptions(fwExceptions); err != nil {
+		log.Error(fmt.Errorf("failed to apply firewall exceptions for split tunnel routes: %w", err))
+	}
+
+	cfg := splittun.RoutesConfig{
+		IsInversed:      isInversed,
+		Networks:        networks,
+		PhysicalGateway: s.splitRoutes_getPhysicalGateway(),
+	}
+
+	isVpnConnected := s.Connected() && !s.IsPaused()
+	if isVpnConnected && isInversed {
+		sInf := s.GetVpnSessionInfo()
+		cfg.VpnLocalIP = sInf.VpnLocalIPv4
+		if sInf.VpnLocalIPv4 != nil {
+			if inf, err := netinfo.InterfaceByIPAddr(sInf.VpnLocalIPv4); err == nil {
+				cfg.VpnInterface = inf
+			}
+		}
+		// DNS server of the VPN connection must be accessible through the VPN tunnel
+		if activeDns, err := s.GetActiveDNS(); err == nil {
+			if ip := activeDns.Ip(); ip != nil && ip.To4() != nil {
+				cfg.Networks = append(cfg.Networks, net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)})
+			}
+		}
+	}
+
+	if err := splittun.ApplyRoutes(isVpnConnected, cfg); err != nil {
+		log.Error(err)
+	}
+}
+
+// splitRoutes_getPhysicalGateway returns default gateway of the physical interface
+// (the last known one, when the default route points to the VPN interface)
+func (s *Service) splitRoutes_getPhysicalGateway() net.IP {
+	s._splitRoutes.mutex.Lock()
+	defer s._splitRoutes.mutex.Unlock()
+
+	gw, err := netinfo.DefaultGatewayIP()
+	if err == nil && gw != nil {
+		isVpnGateway := false
+		if vpnObj := s._vpn; vpnObj != nil {
+			if vpnGw := vpnObj.DefaultRouteGatewayIP(); vpnGw != nil && vpnGw.Equal(gw) {
+				isVpnGateway = true
+			}
+		}
+		if !isVpnGateway {
+			s._splitRoutes.physicalGateway = gw
+		}
+	}
+	return s._splitRoutes.physicalGateway
+}
+
+func isIPListEqual(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
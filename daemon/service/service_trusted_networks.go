@@ -0,0 +1,298 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	"github.com/ivpn/desktop-app/daemon/service/preferences"
+)
+
+// Trusted networks identified by network properties (gateway MAC, DNS suffix, internal host reachability).
+// It allows to apply 'trusted-network' actions for wired networks (where is no WiFi name to use).
+
+const (
+	delayBeforeNetworkChangeNotify = time.Second * 3
+	networkChangePollInterval      = time.Second * 5
+	networkHostReachableTimeout    = time.Second * 2
+)
+
+var timerDelayedNetworkNotify *time.Timer
+
+// networkIdentity - properties of the current network which are used by the network rules
+// (only properties required by the configured rules are obtained)
+type networkIdentity struct {
+	GatewayMAC     string
+	DnsSuffixes    []string
+	ReachableHosts []string // hosts (from 'host_reachable' rules) accepting connections
+}
+
+func (s *Service) initNetworkChangeMonitor() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("initNetworkChangeMonitor PANIC (recovered): ", r)
+		}
+	}()
+
+	return s.implStartNetworkChangeMonitor(s.onNetworkChanged)
+}
+
+func (s *Service) onNetworkChanged() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("onNetworkChanged PANIC (recovered): ", r)
+		}
+	}()
+
+	prefs := s.Preferences()
+	isNetworkRulesInUse := prefs.WiFiControl.TrustedNetworksControl && len(prefs.WiFiControl.NetworkRules) > 0
+	isSplitRoutesInUse := len(prefs.SplitTunnelRoutes) > 0
+	if !isNetworkRulesInUse && !isSplitRoutesInUse {
+		return
+	}
+
+	// Stop old postponed notifier call
+	oldTimerId := timerDelayedNetworkNotify
+	timerDelayedNetworkNotify = nil
+	if oldTimerId != nil {
+		oldTimerId.Stop()
+	}
+
+	// Delay before processing network change
+	// (network configuration is changing in several steps: link, address, routes, DHCP ...)
+	timerDelayedNetworkNotify = time.AfterFunc(delayBeforeNetworkChangeNotify, func() {
+		if isSplitRoutesInUse {
+			// routes of destination-based split tunnelling must use the actual default gateway
+			s.splitRoutes_apply()
+		}
+		if isNetworkRulesInUse {
+			// 'trusted-network' functionality: auto-connect if necessary
+			s.autoConnectIfRequired(OnNetworkChanged, nil)
+		}
+	})
+}
+
+// pollNetworkChanges calls 'onChange' when the default gateway or local addresses are changed
+// (used on platforms where is no network change notifications)
+func pollNetworkChanges(onChange func()) {
+	getState := func() string {
+		gw, _ := netinfo.DefaultGatewayIP()
+		addrs, _ := netinfo.GetAllLocalV4Addresses()
+		state := make([]string, 0, len(addrs)+1)
+		state = append(state, gw.String())
+		for _, a := range addrs {
+			state = append(state, a.String())
+		}
+		sort.Strings(state[1:])
+		return strings.Join(state, ",")
+	}
+
+	go func() {
+		lastState := getState()
+		for {
+			time.Sleep(networkChangePollInterval)
+			if state := getState(); state != lastState {
+				lastState = state
+				onChange()
+			}
+		}
+	}()
+}
+
+// getNetworkIdentity returns properties of the current network required by the network rules
+func (s *Service) getNetworkIdentity(wifiParams preferences.WiFiParams) (ret networkIdentity) {
+	if !wifiParams.TrustedNetworksControl || len(wifiParams.NetworkRules) == 0 {
+		return ret
+	}
+
+	var hosts []string
+	isMacRequired, isDnsRequired := false, false
+	for _, r := range wifiParams.NetworkRules {
+		switch r.Type {
+		case preferences.NetworkRuleGatewayMAC:
+			isMacRequired = true
+		case preferences.NetworkRuleDnsSuffix:
+			isDnsRequired = true
+		case preferences.NetworkRuleHostReachable:
+			if !r.IsTrusted {
+				hosts = append(hosts, r.Normalize().Value)
+			}
+		}
+	}
+
+	if isMacRequired {
+		if mac, err := netinfo.DefaultGatewayMAC(); err != nil {
+			log.Warning(err)
+		} else {
+			ret.GatewayMAC = mac.String()
+		}
+	}
+
+	if isDnsRequired {
+		if suffixes, err := netinfo.DnsSuffixes(); err != nil {
+			log.Warning(fmt.Errorf("unable to obtain DNS suffixes: %w", err))
+		} else {
+			sort.Strings(suffixes)
+			ret.DnsSuffixes = suffixes
+		}
+	}
+
+	if len(hosts) > 0 {
+		ret.ReachableHosts = s.getReachableHosts(hosts)
+	}
+
+	return ret
+}
+
+// getReachableHosts returns the hosts ("IP:port") accepting TCP connections in the local network.
+// The connections are established through the physical interface (even when VPN is connected);
+// when the firewall is enabled, the hosts are temporarily allowed (restricted to the TCP port where the platform supports it).
+func (s *Service) getReachableHosts(hosts []string) (reachable []string) {
+	iface, err := netinfo.DefaultGatewayInterface()
+	if err != nil {
+		log.Warning(fmt.Errorf("unable to check hosts reachability: %w", err))
+		return nil
+	}
+
+	if enabled, _ := firewall.GetEnabled(); enabled {
+		for _, h := range hosts {
+			host, portStr, err := net.SplitHostPort(h)
+			if err != nil {
+				continue
+			}
+			ip := net.ParseIP(host)
+			port, err := strconv.Atoi(portStr)
+			if ip == nil || err != nil {
+				continue
+			}
+			if err := s.fwHosts_allowTcpOut(ip, port); err != nil {
+				log.Warning(fmt.Sprintf("Firewall exceptions: failed to allow the host %s: %v", h, err))
+				continue
+			}
+			defer s.fwHosts_releaseTcpOut(ip, port)
+		}
+	}
+
+	dialer := net.Dialer{Timeout: networkHostReachableTimeout, Control: netinfo.InterfaceBindControl(iface)}
+
+	// check hosts in parallel
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			conn, err := dialer.Dial("tcp", host)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			mutex.Lock()
+			reachable = append(reachable, host)
+			mutex.Unlock()
+		}(h)
+	}
+	wg.Wait()
+	sort.Strings(reachable)
+	return reachable
+}
+
+// getTrustStatusForWifiRules returns the trust status defined by the WiFi rules for the network.
+// When several rules are matching, the most specific one is used:
+// exact SSID + BSSID; exact SSID; SSID pattern + BSSID; SSID pattern (the first one in the list for equal priority).
+// The network is untrusted when its name matches a rule with BSSIDs but the BSSID does not match it,
+// unless a more specific rule matches (e.g. an access point impersonating the trusted network is not trusted).
+// Returns nil when no rules are matching.
+func getTrustStatusForWifiRules(networks []preferences.WiFiNetwork, ssid, bssid string) *bool {
+	var isNetworkTrusted *bool
+
+	bestPriority := 0
+	bssidMismatchPriority := 0
+	for _, w := range networks {
+		priority := 1
+		if !w.IsPattern() {
+			priority += 2
+		}
+		if len(w.BSSIDs) > 0 {
+			priority += 1
+		}
+
+		if !w.Matches(ssid, bssid) {
+			if len(w.BSSIDs) > 0 && w.MatchesSSID(ssid) && priority > bssidMismatchPriority {
+				bssidMismatchPriority = priority
+			}
+			continue
+		}
+
+		if priority > bestPriority {
+			bestPriority = priority
+			isTrusted := w.IsTrusted
+			isNetworkTrusted = &isTrusted
+		}
+	}
+
+	if bssidMismatchPriority > bestPriority {
+		isTrusted := false
+		return &isTrusted
+	}
+	return isNetworkTrusted
+}
+
+// getTrustStatusForNetworkRules returns the trust status defined by the first rule matching the network.
+// Returns nil when no rules are matching.
+func getTrustStatusForNetworkRules(rules []preferences.NetworkRule, netId networkIdentity) *bool {
+	for _, r := range rules {
+		isMatch := false
+		switch r.Type {
+		case preferences.NetworkRuleGatewayMAC:
+			isMatch = r.MatchesGatewayMAC(netId.GatewayMAC)
+		case preferences.NetworkRuleDnsSuffix:
+			isMatch = r.MatchesDnsSuffix(netId.DnsSuffixes)
+		case preferences.NetworkRuleHostReachable:
+			if r.IsTrusted {
+				continue // not allowed: the host reachability can be spoofed (the rule can be saved by an older version)
+			}
+			host := r.Normalize().Value
+			for _, h := range netId.ReachableHosts {
+				if h == host {
+					isMatch = true
+					break
+				}
+			}
+		}
+
+		if isMatch {
+			isTrusted := r.IsTrusted
+			return &isTrusted
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"testing"
+
+	"github.com/ivpn/desktop-app/daemon/service/preferences"
+)
+
+func TestGetTrustStatusForWifiRules(t *testing.T) {
+	const (
+		bssidHome  = "aa:bb:cc:dd:ee:ff"
+		bssidOther = "aa:bb:cc:dd:ee:00"
+	)
+	pinnedHome := preferences.WiFiNetwork{SSID: "Home", IsTrusted: true, BSSIDs: []string{bssidHome}}
+
+	tests := []struct {
+		name     string
+		networks []preferences.WiFiNetwork
+		ssid     string
+		bssid    string
+		want     string // "trusted", "untrusted" or "" (no rule)
+	}{
+		{"no rules", nil, "Home", bssidHome, ""},
+		{"not matching", []preferences.WiFiNetwork{pinnedHome}, "Office", bssidHome, ""},
+		{"pinned BSSID", []preferences.WiFiNetwork{pinnedHome}, "Home", bssidHome, "trusted"},
+		{"pinned BSSID mismatch", []preferences.WiFiNetwork{pinnedHome}, "Home", bssidOther, "untrusted"},
+		{"pinned BSSID unknown", []preferences.WiFiNetwork{pinnedHome}, "Home", "", "untrusted"},
+		{"pinned BSSID mismatch: less specific rule ignored", []preferences.WiFiNetwork{
+			{SSID: "*", SSIDMatch: preferences.WiFiSSIDMatchGlob, IsTrusted: true},
+			pinnedHome,
+		}, "Home", bssidOther, "untrusted"},
+		{"pinned BSSID mismatch: other pinned rule matches", []preferences.WiFiNetwork{
+			pinnedHome,
+			{SSID: "Home", IsTrusted: true, BSSIDs: []string{bssidOther}},
+		}, "Home", bssidOther, "trusted"},
+		{"exact SSID has priority over pattern", []preferences.WiFiNetwork{
+			{SSID: "Ho*", SSIDMatch: preferences.WiFiSSIDMatchGlob, IsTrusted: false},
+			{SSID: "Home", IsTrusted: true},
+		}, "Home", "", "trusted"},
+		{"first rule for equal priority", []preferences.WiFiNetwork{
+			{SSID: "Home", IsTrusted: false},
+			{SSID: "Home", IsTrusted: true},
+		}, "Home", "", "untrusted"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if ret := getTrustStatusForWifiRules(tt.networks, tt.ssid, tt.bssid); ret != nil {
+			got = "untrusted"
+			if *ret {
+				got = "trusted"
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: unexpected result '%s' (expected '%s')", tt.name, got, tt.want)
+		}
+	}
+}
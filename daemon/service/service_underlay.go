@@ -0,0 +1,281 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+)
+
+// Underlay interface pinning.
+// The encrypted traffic to the VPN server (WireGuard/OpenVPN endpoint, obfsproxy or V2Ray outbound) is routed
+// via the specified physical interface instead of following the default route.
+// The host routes to the VPN server are applied via the gateway of the selected interface. When the primary interface has lost
+// carrier, the failover interface (if defined) is in use until the primary interface is connected back.
+
+const underlayCheckInterval = time.Second * 5
+
+type underlayState struct {
+	mutex sync.Mutex
+	// configured interface names (empty 'primary' - the underlay is not pinned)
+	primary  string
+	failover string
+	// currently selected interface and its gateway (nil gateway - point-to-point link)
+	iface   *net.Interface
+	gateway net.IP
+	// remote hosts which are routed via the selected interface
+	hosts       []net.IP
+	isRouted    bool
+	stopChan    chan struct{}
+	changedChan chan struct{}
+}
+
+// underlay_init must be called before the connection: it checks the interfaces configuration and selects the interface to use
+func (s *Service) underlay_init(primary, failover string) error {
+	if len(primary) == 0 && len(failover) > 0 {
+		return fmt.Errorf("the underlay failover interface is defined but the primary interface is not")
+	}
+	if len(primary) > 0 && primary == failover {
+		return fmt.Errorf("the underlay primary and failover interfaces must be different")
+	}
+	for _, name := range []string{primary, failover} {
+		if len(name) == 0 {
+			continue
+		}
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return fmt.Errorf("underlay interface '%s' not found", name)
+		}
+		if iface.Flags&net.FlagLoopback != 0 {
+			return fmt.Errorf("underlay interface '%s' is a loopback interface", name)
+		}
+	}
+
+	s._underlay.mutex.Lock()
+	defer s._underlay.mutex.Unlock()
+
+	s._underlay.primary, s._underlay.failover = primary, failover
+	s._underlay.iface, s._underlay.gateway = nil, nil
+	if len(primary) == 0 {
+		return nil
+	}
+
+	iface, gw, err := s._underlay.selectInterface()
+	if err != nil {
+		s._underlay.primary, s._underlay.failover = "", ""
+		return err
+	}
+	s._underlay.iface, s._underlay.gateway = iface, gw
+	log.Info(fmt.Sprintf("Underlay interface: %s (gateway: %v)", iface.Name, gw))
+	return nil
+}
+
+// underlay_reset must be called when the connection is finished (see underlay_init())
+func (s *Service) underlay_reset() {
+	s._underlay.mutex.Lock()
+	defer s._underlay.mutex.Unlock()
+
+	s._underlay.primary, s._underlay.failover = "", ""
+	s._underlay.iface, s._underlay.gateway = nil, nil
+}
+
+// underlay_gateway returns gateway of the selected underlay interface
+// (isPinned is false when the underlay is not pinned: the default gateway must be used)
+func (s *Service) underlay_gateway() (gateway net.IP, isPinned bool, err error) {
+	s._underlay.mutex.Lock()
+	defer s._underlay.mutex.Unlock()
+
+	if s._underlay.iface == nil {
+		return nil, false, nil
+	}
+	if s._underlay.gateway == nil {
+		return nil, true, fmt.Errorf("underlay interface '%s' has no gateway", s._underlay.iface.Name)
+	}
+	return s._underlay.gateway, true, nil
+}
+
+// underlay_start applies the routes to the remote hosts via the selected interface and starts the interface monitoring.
+// 'changedChan' receives notification when the underlay interface (or its gateway) was changed.
+func (s *Service) underlay_start(hosts []net.IP, changedChan chan struct{}) error {
+	s.underlay_stop()
+
+	s._underlay.mutex.Lock()
+	defer s._underlay.mutex.Unlock()
+
+	if s._underlay.iface == nil {
+		return nil
+	}
+
+	s._underlay.hosts = nil
+	for _, h := range hosts {
+		if h == nil || h.IsLoopback() {
+			continue // e.g. local V2Ray proxy (V2Ray manages the route to its server by itself)
+		}
+		if h.To4() == nil {
+			log.Warning(fmt.Sprintf("Underlay interface pinning is not applicable for IPv6 host %s", h))
+			continue
+		}
+		s._underlay.hosts = append(s._underlay.hosts, h)
+	}
+
+	if err := s._underlay.applyRoutes(); err != nil {
+		return err
+	}
+
+	stopChan := make(chan struct{})
+	s._underlay.stopChan = stopChan
+	s._underlay.changedChan = changedChan
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("PANIC (recovered) in underlay interface monitor: ", r)
+			}
+		}()
+
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-time.After(underlayCheckInterval):
+				s.underlay_check()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// underlay_stop stops the interface monitoring and removes the routes to the remote hosts
+func (s *Service) underlay_stop() {
+	s._underlay.mutex.Lock()
+	defer s._underlay.mutex.Unlock()
+
+	if s._underlay.stopChan != nil {
+		close(s._underlay.stopChan)
+		s._underlay.stopChan = nil
+	}
+	s._underlay.changedChan = nil
+	s._underlay.removeRoutes()
+	s._underlay.hosts = nil
+}
+
+func (s *Service) underlay_check() {
+	s._underlay.mutex.Lock()
+	defer s._underlay.mutex.Unlock()
+
+	if s._underlay.stopChan == nil || s._underlay.iface == nil {
+		return
+	}
+
+	iface, gw, err := s._underlay.selectInterface()
+	if err != nil {
+		// keep the current routes: the traffic must not follow the default route
+		return
+	}
+
+	if iface.Index == s._underlay.iface.Index && gw.Equal(s._underlay.gateway) {
+		// ensure the routes were not removed (e.g. by VPN client on the internal reconnection)
+		if s._underlay.isRouted && !s._underlay.isRoutesActive() {
+			log.Info("The routes via the underlay interface are not active. Re-applying...")
+			s._underlay.removeRoutes()
+			if err := s._underlay.applyRoutes(); err != nil {
+				log.Error(err)
+			}
+		}
+		return
+	}
+
+	log.Info(fmt.Sprintf("Underlay interface changed: %s (gateway: %v) -> %s (gateway: %v)", s._underlay.iface.Name, s._underlay.gateway, iface.Name, gw))
+	s._underlay.removeRoutes()
+	s._underlay.iface, s._underlay.gateway = iface, gw
+	if err := s._underlay.applyRoutes(); err != nil {
+		log.Error(err)
+	}
+
+	if s._underlay.changedChan != nil {
+		select {
+		case s._underlay.changedChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// selectInterface returns the first connected interface: primary or (if primary has no carrier) failover
+func (u *underlayState) selectInterface() (*net.Interface, net.IP, error) {
+	for _, name := range []string{u.primary, u.failover} {
+		if len(name) == 0 {
+			continue
+		}
+		iface, err := net.InterfaceByName(name)
+		if err != nil || !netinfo.IsInterfaceConnected(iface) {
+			continue
+		}
+		gw, err := netinfo.InterfaceGateway(iface)
+		if err != nil {
+			log.Warning(err)
+			continue
+		}
+		return iface, gw, nil
+	}
+
+	if len(u.failover) > 0 {
+		return nil, nil, fmt.Errorf("underlay interfaces '%s' and '%s' are not connected", u.primary, u.failover)
+	}
+	return nil, nil, fmt.Errorf("underlay interface '%s' is not connected", u.primary)
+}
+
+func (u *underlayState) applyRoutes() error {
+	for _, h := range u.hosts {
+		if err := implUnderlayRouteAdd(h, u.gateway, u.iface); err != nil {
+			return fmt.Errorf("failed to apply route to %s via underlay interface '%s': %w", h, u.iface.Name, err)
+		}
+		u.isRouted = true
+	}
+	return nil
+}
+
+func (u *underlayState) removeRoutes() {
+	if !u.isRouted {
+		return
+	}
+	for _, h := range u.hosts {
+		if err := implUnderlayRouteDelete(h, u.gateway, u.iface); err != nil {
+			log.Warning(fmt.Sprintf("failed to remove route to %s via underlay interface '%s': %v", h, u.iface.Name, err))
+		}
+	}
+	u.isRouted = false
+}
+
+func (u *underlayState) isRoutesActive() bool {
+	for _, h := range u.hosts {
+		if !implUnderlayIsRouteActive(h, u.iface) {
+			return false
+		}
+	}
+	return true
+}
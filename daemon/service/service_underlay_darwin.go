@@ -0,0 +1,70 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build darwin
+// +build darwin
+
+package service
+
+import (
+	"net"
+	"regexp"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// /sbin/route -n add -inet -host 198.51.100.1 192.168.1.1
+// /sbin/route -n add -inet -host 198.51.100.1 -interface ppp0   (point-to-point link)
+func implUnderlayRouteAdd(host net.IP, gateway net.IP, iface *net.Interface) error {
+	// replace the existing route to the host (if any)
+	shell.Exec(nil, "/sbin/route", "-n", "delete", "-inet", "-host", host.String())
+
+	if gateway != nil {
+		return shell.Exec(log, "/sbin/route", "-n", "add", "-inet", "-host", host.String(), gateway.String())
+	}
+	return shell.Exec(log, "/sbin/route", "-n", "add", "-inet", "-host", host.String(), "-interface", iface.Name)
+}
+
+func implUnderlayRouteDelete(host net.IP, gateway net.IP, iface *net.Interface) error {
+	return shell.Exec(log, "/sbin/route", "-n", "delete", "-inet", "-host", host.String())
+}
+
+func implUnderlayIsRouteActive(host net.IP, iface *net.Interface) bool {
+	// Expected output of "/sbin/route -n get -inet 198.51.100.1" command (part):
+	//    route to: 198.51.100.1
+	//     gateway: 192.168.1.1
+	//   interface: en0
+	isActive := false
+	outRegexp := regexp.MustCompile(`^\s*interface\s*:\s*(\S+)`)
+	outParse := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		if cols := outRegexp.FindStringSubmatch(text); len(cols) == 2 && cols[1] == iface.Name {
+			isActive = true
+		}
+	}
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", "/sbin/route", "-n", "get", "-inet", host.String()); err != nil {
+		return false
+	}
+	return isActive
+}
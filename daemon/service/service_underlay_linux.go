@@ -0,0 +1,71 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package service
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// ip route replace 198.51.100.1/32 via 192.168.1.1 dev enp0s3
+// ip route replace 198.51.100.1/32 dev wwan0   (point-to-point link)
+func implUnderlayRouteAdd(host net.IP, gateway net.IP, iface *net.Interface) error {
+	return shell.Exec(log, "ip", append([]string{"route", "replace"}, underlayRouteArgs(host, gateway, iface)...)...)
+}
+
+func implUnderlayRouteDelete(host net.IP, gateway net.IP, iface *net.Interface) error {
+	return shell.Exec(log, "ip", append([]string{"route", "delete"}, underlayRouteArgs(host, gateway, iface)...)...)
+}
+
+func implUnderlayIsRouteActive(host net.IP, iface *net.Interface) bool {
+	// Expected output of "ip route show exact 198.51.100.1/32" command:
+	// 198.51.100.1 via 192.168.1.1 dev enp0s3
+	isActive := false
+	outParse := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		cols := strings.Fields(text)
+		for i := 0; i < len(cols)-1; i++ {
+			if cols[i] == "dev" && cols[i+1] == iface.Name {
+				isActive = true
+			}
+		}
+	}
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", "ip", "route", "show", "exact", host.String()+"/32"); err != nil {
+		return false
+	}
+	return isActive
+}
+
+func underlayRouteArgs(host net.IP, gateway net.IP, iface *net.Interface) []string {
+	if gateway != nil {
+		return []string{host.String() + "/32", "via", gateway.String(), "dev", iface.Name}
+	}
+	return []string{host.String() + "/32", "dev", iface.Name}
+}
@@ -0,0 +1,77 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build windows
+// +build windows
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// route.exe add 198.51.100.1 mask 255.255.255.255 192.168.1.1 metric 1 if 12
+func implUnderlayRouteAdd(host net.IP, gateway net.IP, iface *net.Interface) error {
+	if gateway == nil {
+		return fmt.Errorf("the interface has no gateway")
+	}
+	routeBinary, err := underlayRouteBinaryPath()
+	if err != nil {
+		return err
+	}
+	return shell.Exec(log, routeBinary, "add", host.String(), "mask", "255.255.255.255", gateway.String(), "metric", "1", "if", fmt.Sprintf("%d", iface.Index))
+}
+
+func implUnderlayRouteDelete(host net.IP, gateway net.IP, iface *net.Interface) error {
+	if gateway == nil {
+		return nil
+	}
+	routeBinary, err := underlayRouteBinaryPath()
+	if err != nil {
+		return err
+	}
+	return shell.Exec(log, routeBinary, "delete", host.String(), "mask", "255.255.255.255", gateway.String())
+}
+
+func implUnderlayIsRouteActive(host net.IP, iface *net.Interface) bool {
+	localIp, err := netinfo.GetOutboundIPEx(host)
+	if err != nil {
+		return false
+	}
+	ifaceByIp, err := netinfo.InterfaceByIPAddr(localIp)
+	return err == nil && ifaceByIp.Index == iface.Index
+}
+
+func underlayRouteBinaryPath() (string, error) {
+	envVarSystemroot := strings.ToLower(os.Getenv("SYSTEMROOT"))
+	if len(envVarSystemroot) == 0 {
+		return "", fmt.Errorf("unable to determine 'SYSTEMROOT' environment variable")
+	}
+	return strings.ReplaceAll(path.Join(envVarSystemroot, "system32", "route.exe"), "/", "\\"), nil
+}
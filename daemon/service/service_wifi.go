@@ -69,8 +69,9 @@ func (s *Service) onWiFiChanged() {
 		// notify clients about WiFi change
 		s._evtReceiver.OnWiFiChanged(info, err)
 
-		// 'trusted-wifi' functionality: auto-connect if necessary
-		s.autoConnectIfRequired(OnWifiChanged, &info)
+		// captive portal detection (in background): 'trusted-wifi' actions (auto-connect if necessary)
+		// are postponed until the detection finished or, if the portal is detected, until the portal login completed
+		s.captivePortal_detectAsync(func() { s.autoConnectIfRequired(OnWifiChanged, &info) })
 	})
 }
 
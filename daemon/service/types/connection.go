@@ -23,9 +23,7 @@
 package types
 
 import (
-	"crypto/rand"
 	"fmt"
-	"math/big"
 
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
@@ -64,6 +62,13 @@ type ConnectMetadata struct {
 
 	AntiTracker AntiTrackerMetadata
 
+	// 'Auto' DNS mode: the fastest predefined encrypted DNS provider is selected automatically after connection
+	// (ignored when AntiTracker enabled or custom DNS defined)
+	DnsAuto bool
+
+	// How the host is selected from the hosts of the chosen entry/exit server
+	HostSelection HostSelectionStrategy
+
 	// (only if Fastest server in use) List of fastest servers which must be ignored (only gateway ID in use: e.g."us-tx.wg.ivpn.net" => "us-tx")
 	FastestGatewaysExcludeList []string
 }
@@ -88,6 +93,11 @@ type ConnectionParams struct {
 	// (has effect only if Firewall not enabled before)
 	FirewallOnDuringConnection bool
 
+	// Name of the physical network interface to use for the encrypted traffic to the VPN server (empty - follow the default route)
+	UnderlayInterface string
+	// Name of the interface to use when the 'UnderlayInterface' has lost carrier (optional)
+	UnderlayFailoverInterface string
+
 	WireGuardParameters struct {
 		// Port in use only for Single-Hop connections
 		Port struct {
@@ -102,6 +112,8 @@ type ConnectionParams struct {
 		MultihopExitServer MultiHopExitServer_WireGuard
 
 		Mtu int // Set 0 to use default MTU value
+		// Detect MTU automatically after the connection established (ignored when 'Mtu' defined)
+		MtuAuto bool
 
 		V2RayProxy v2r.V2RayTransportType // V2Ray config
 	}
@@ -167,33 +179,31 @@ func (p ConnectionParams) V2Ray() v2r.V2RayTransportType {
 }
 
 // NormalizeHosts - normalize hosts list
-// 1) in case of multiple entry hosts - take random host from the list
-// 2) in case of multiple exit hosts - take random host from the list
+// 1) in case of multiple entry hosts - select one host from the list (according to 'Metadata.HostSelection')
+// 2) in case of multiple exit hosts - select one host from the list (according to 'Metadata.HostSelection'; latency is not in use)
 // 3) (WireGuard) filter entry hosts: use IPv6 hosts
 // 4) (WireGuard) filter exit servers (Multi-Hop connection):
 // 4.1) each exit server must have initialized 'multihop_port' field
 // 4.2) (in case of IPv6Only) IPv6 local address should be defined
-func (p *ConnectionParams) NormalizeHosts() error {
+//
+// 'latencies' - the ping results: map[hostIP]latencyMs (can be nil)
+func (p *ConnectionParams) NormalizeHosts(latencies map[string]int) error {
+	strategy := p.Metadata.HostSelection
 
 	if vpn.Type(p.VpnType) == vpn.OpenVPN {
-		// in case of multiple entry hosts - take random host from the list
+		// in case of multiple entry hosts - select one host from the list
 		entryHosts := p.OpenVpnParameters.EntryVpnServer.Hosts
 		if len(entryHosts) > 1 {
-			rndHost := entryHosts[0]
-			if rnd, err := rand.Int(rand.Reader, big.NewInt(int64(len(entryHosts)))); err == nil {
-				rndHost = entryHosts[rnd.Int64()]
-			}
-			p.OpenVpnParameters.EntryVpnServer.Hosts = []api_types.OpenVPNServerHostInfo{rndHost}
+			idx := SelectHost(hostsInfoBase(entryHosts), strategy, latencies)
+			p.OpenVpnParameters.EntryVpnServer.Hosts = []api_types.OpenVPNServerHostInfo{entryHosts[idx]}
 		}
 
-		// in case of multiple exit hosts - take random host from the list
+		// in case of multiple exit hosts - select one host from the list
+		// (latency from the local machine is not relevant for the exit server)
 		exitHosts := p.OpenVpnParameters.MultihopExitServer.Hosts
 		if len(exitHosts) > 1 {
-			rndHost := exitHosts[0]
-			if rnd, err := rand.Int(rand.Reader, big.NewInt(int64(len(exitHosts)))); err == nil {
-				rndHost = exitHosts[rnd.Int64()]
-			}
-			p.OpenVpnParameters.MultihopExitServer.Hosts = []api_types.OpenVPNServerHostInfo{rndHost}
+			idx := SelectHost(hostsInfoBase(exitHosts), strategy, nil)
+			p.OpenVpnParameters.MultihopExitServer.Hosts = []api_types.OpenVPNServerHostInfo{exitHosts[idx]}
 		}
 
 	} else if vpn.Type(p.VpnType) == vpn.WireGuard {
@@ -245,22 +255,17 @@ func (p *ConnectionParams) NormalizeHosts() error {
 			}
 		}
 
-		// in case of multiple entry hosts - take random host from the list
-		if len(p.WireGuardParameters.EntryVpnServer.Hosts) > 1 {
-			rndHost := p.WireGuardParameters.EntryVpnServer.Hosts[0]
-			if rnd, err := rand.Int(rand.Reader, big.NewInt(int64(len(p.WireGuardParameters.EntryVpnServer.Hosts)))); err == nil {
-				rndHost = p.WireGuardParameters.EntryVpnServer.Hosts[rnd.Int64()]
-			}
-			p.WireGuardParameters.EntryVpnServer.Hosts = []api_types.WireGuardServerHostInfo{rndHost}
+		// in case of multiple entry hosts - select one host from the list
+		if entryHosts := p.WireGuardParameters.EntryVpnServer.Hosts; len(entryHosts) > 1 {
+			idx := SelectHost(hostsInfoBase(entryHosts), strategy, latencies)
+			p.WireGuardParameters.EntryVpnServer.Hosts = []api_types.WireGuardServerHostInfo{entryHosts[idx]}
 		}
 
-		// in case of multiple exit hosts - take random host from the list
-		if len(p.WireGuardParameters.MultihopExitServer.Hosts) > 1 {
-			rndHost := p.WireGuardParameters.MultihopExitServer.Hosts[0]
-			if rnd, err := rand.Int(rand.Reader, big.NewInt(int64(len(p.WireGuardParameters.MultihopExitServer.Hosts)))); err == nil {
-				rndHost = p.WireGuardParameters.MultihopExitServer.Hosts[rnd.Int64()]
-			}
-			p.WireGuardParameters.MultihopExitServer.Hosts = []api_types.WireGuardServerHostInfo{rndHost}
+		// in case of multiple exit hosts - select one host from the list
+		// (latency from the local machine is not relevant for the exit server)
+		if exitHosts := p.WireGuardParameters.MultihopExitServer.Hosts; len(exitHosts) > 1 {
+			idx := SelectHost(hostsInfoBase(exitHosts), strategy, nil)
+			p.WireGuardParameters.MultihopExitServer.Hosts = []api_types.WireGuardServerHostInfo{exitHosts[idx]}
 		}
 
 	} else {
@@ -270,6 +275,26 @@ func (p *ConnectionParams) NormalizeHosts() error {
 	return nil
 }
 
+// EntryHostInfo returns info about the entry host (the first one in the list; see NormalizeHosts())
+func (p ConnectionParams) EntryHostInfo() (api_types.HostInfoBase, error) {
+	if p.VpnType == vpn.WireGuard {
+		if len(p.WireGuardParameters.EntryVpnServer.Hosts) > 0 {
+			return p.WireGuardParameters.EntryVpnServer.Hosts[0].HostInfoBase, nil
+		}
+	} else if len(p.OpenVpnParameters.EntryVpnServer.Hosts) > 0 {
+		return p.OpenVpnParameters.EntryVpnServer.Hosts[0].HostInfoBase, nil
+	}
+	return api_types.HostInfoBase{}, fmt.Errorf("entry host not defined")
+}
+
+func hostsInfoBase[T interface{ GetHostInfoBase() api_types.HostInfoBase }](hosts []T) []api_types.HostInfoBase {
+	ret := make([]api_types.HostInfoBase, 0, len(hosts))
+	for _, h := range hosts {
+		ret = append(ret, h.GetHostInfoBase())
+	}
+	return ret
+}
+
 type MultiHopExitServer_WireGuard struct {
 	// ExitSrvID (geteway ID) just in use to keep clients notified about connected MH exit server
 	// Example: "gateway":"zz.wg.ivpn.net" => "zz"
@@ -0,0 +1,174 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package types
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	api_types "github.com/ivpn/desktop-app/daemon/api/types"
+)
+
+// HostSelectionStrategy - how the host is selected from the hosts of the chosen location (gateway)
+type HostSelectionStrategy int
+
+const (
+	// Random host; the lightly loaded hosts with lower latency have higher probability to be selected (default)
+	HostSelectionWeightedRandom HostSelectionStrategy = iota
+	// Host with the lowest load
+	HostSelectionLeastLoad HostSelectionStrategy = iota
+	// Host with the lowest latency (ping result). If no ping results available - host with the lowest load
+	HostSelectionLowestPing HostSelectionStrategy = iota
+)
+
+func (s HostSelectionStrategy) String() string {
+	switch s {
+	case HostSelectionWeightedRandom:
+		return "weighted_random"
+	case HostSelectionLeastLoad:
+		return "least_load"
+	case HostSelectionLowestPing:
+		return "lowest_ping"
+	}
+	return "<Unknown>"
+}
+
+// ParseHostSelectionStrategy parses text representation of the strategy (see HostSelectionStrategy.String())
+func ParseHostSelectionStrategy(str string) (HostSelectionStrategy, error) {
+	str = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(str)), "-", "_")
+	for _, s := range []HostSelectionStrategy{HostSelectionWeightedRandom, HostSelectionLeastLoad, HostSelectionLowestPing} {
+		if s.String() == str {
+			return s, nil
+		}
+	}
+	return HostSelectionWeightedRandom, fmt.Errorf("unknown host selection strategy '%s'", str)
+}
+
+const (
+	// weight of the host which latency is unknown (relative to the host with the lowest latency)
+	hostWeightUnknownLatency = 0.5
+	// minimal weight of the host (fully loaded hosts still can be selected)
+	hostWeightMinLoad = 1.0
+)
+
+// SelectHost returns index of the host to use.
+// 'latencies' - the ping results: map[hostIP]latencyMs (can be nil)
+func SelectHost(hosts []api_types.HostInfoBase, strategy HostSelectionStrategy, latencies map[string]int) int {
+	return selectHost(hosts, strategy, latencies, randFloat64)
+}
+
+// selectHost returns index of the host to use ('rnd' - returns random value in the interval [0.0, 1.0))
+func selectHost(hosts []api_types.HostInfoBase, strategy HostSelectionStrategy, latencies map[string]int, rnd func() float64) int {
+	if len(hosts) <= 1 {
+		return 0
+	}
+
+	latency := func(i int) int {
+		if l, ok := latencies[hosts[i].Host]; ok && l > 0 {
+			return l
+		}
+		return 0
+	}
+
+	// isBetterLoad returns true when host 'i' is better than host 'j' (lower load; for equal load - lower known latency)
+	isBetterLoad := func(i, j int) bool {
+		if hosts[i].Load != hosts[j].Load {
+			return hosts[i].Load < hosts[j].Load
+		}
+		li, lj := latency(i), latency(j)
+		return li > 0 && (lj == 0 || li < lj)
+	}
+
+	switch strategy {
+	case HostSelectionLeastLoad:
+		best := 0
+		for i := range hosts {
+			if isBetterLoad(i, best) {
+				best = i
+			}
+		}
+		return best
+
+	case HostSelectionLowestPing:
+		best := -1
+		for i := range hosts {
+			li := latency(i)
+			if li == 0 {
+				continue
+			}
+			if best < 0 || li < latency(best) || (li == latency(best) && hosts[i].Load < hosts[best].Load) {
+				best = i
+			}
+		}
+		if best < 0 {
+			// no ping results
+			return selectHost(hosts, HostSelectionLeastLoad, latencies, rnd)
+		}
+		return best
+
+	default: // HostSelectionWeightedRandom
+		minLatency := 0
+		for i := range hosts {
+			if l := latency(i); l > 0 && (minLatency == 0 || l < minLatency) {
+				minLatency = l
+			}
+		}
+
+		weights := make([]float64, len(hosts))
+		total := 0.0
+		for i, h := range hosts {
+			w := 100.0 - float64(h.Load)
+			if w < hostWeightMinLoad {
+				w = hostWeightMinLoad
+			}
+			if minLatency > 0 {
+				if l := latency(i); l > 0 {
+					w *= float64(minLatency) / float64(l)
+				} else {
+					w *= hostWeightUnknownLatency
+				}
+			}
+			weights[i] = w
+			total += w
+		}
+
+		v := rnd() * total
+		for i, w := range weights {
+			if v < w {
+				return i
+			}
+			v -= w
+		}
+		return len(hosts) - 1
+	}
+}
+
+func randFloat64() float64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0
+	}
+	return float64(binary.LittleEndian.Uint64(b[:])>>11) / (1 << 53)
+}
@@ -0,0 +1,176 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package types
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api_types "github.com/ivpn/desktop-app/daemon/api/types"
+	"github.com/ivpn/desktop-app/daemon/vpn"
+)
+
+func loadServersFixture(t *testing.T) api_types.ServersInfoResponse {
+	data, err := os.ReadFile(filepath.Join("testdata", "servers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var svrs api_types.ServersInfoResponse
+	if err := json.Unmarshal(data, &svrs); err != nil {
+		t.Fatal(err)
+	}
+	return svrs
+}
+
+func wgGatewayHosts(t *testing.T, svrs api_types.ServersInfoResponse, gateway string) []api_types.WireGuardServerHostInfo {
+	for _, s := range svrs.WireguardServers {
+		if s.Gateway == gateway {
+			return s.Hosts
+		}
+	}
+	t.Fatalf("gateway '%s' not found in fixture", gateway)
+	return nil
+}
+
+func TestParseHostSelectionStrategy(t *testing.T) {
+	for _, s := range []HostSelectionStrategy{HostSelectionWeightedRandom, HostSelectionLeastLoad, HostSelectionLowestPing} {
+		if parsed, err := ParseHostSelectionStrategy(s.String()); err != nil || parsed != s {
+			t.Errorf("'%s': unexpected result: %v (%v)", s, parsed, err)
+		}
+	}
+	if parsed, err := ParseHostSelectionStrategy(" Least-Load "); err != nil || parsed != HostSelectionLeastLoad {
+		t.Errorf("unexpected result: %v (%v)", parsed, err)
+	}
+	if _, err := ParseHostSelectionStrategy("fastest"); err == nil {
+		t.Error("error expected for unknown strategy")
+	}
+}
+
+func TestSelectHostLeastLoad(t *testing.T) {
+	svrs := loadServersFixture(t)
+
+	hosts := hostsInfoBase(wgGatewayHosts(t, svrs, "nl.wg.ivpn.net"))
+	if idx := SelectHost(hosts, HostSelectionLeastLoad, nil); hosts[idx].Hostname != "nl4.wg.ivpn.net" {
+		t.Errorf("unexpected host: %s", hosts[idx].Hostname)
+	}
+
+	// equal load: the host with lower latency is preferred
+	hosts = hostsInfoBase(wgGatewayHosts(t, svrs, "de.wg.ivpn.net"))
+	latencies := map[string]int{"198.51.100.1": 40, "198.51.100.2": 15}
+	if idx := SelectHost(hosts, HostSelectionLeastLoad, latencies); hosts[idx].Hostname != "de2.wg.ivpn.net" {
+		t.Errorf("unexpected host: %s", hosts[idx].Hostname)
+	}
+}
+
+func TestSelectHostLowestPing(t *testing.T) {
+	svrs := loadServersFixture(t)
+	hosts := hostsInfoBase(wgGatewayHosts(t, svrs, "nl.wg.ivpn.net"))
+
+	// host without ping result is ignored
+	latencies := map[string]int{"192.0.2.3": 20, "192.0.2.4": 45}
+	if idx := SelectHost(hosts, HostSelectionLowestPing, latencies); hosts[idx].Hostname != "nl3.wg.ivpn.net" {
+		t.Errorf("unexpected host: %s", hosts[idx].Hostname)
+	}
+
+	// no ping results: the least loaded host
+	if idx := SelectHost(hosts, HostSelectionLowestPing, nil); hosts[idx].Hostname != "nl4.wg.ivpn.net" {
+		t.Errorf("unexpected host (no ping results): %s", hosts[idx].Hostname)
+	}
+}
+
+func TestSelectHostWeightedRandom(t *testing.T) {
+	svrs := loadServersFixture(t)
+	hosts := hostsInfoBase(wgGatewayHosts(t, svrs, "nl.wg.ivpn.net"))
+
+	// weights (no ping results): nl3=25.5, nl4=87.75, nl5=69
+	for _, tc := range []struct {
+		rnd      float64
+		expected string
+	}{
+		{0.0, "nl3.wg.ivpn.net"},
+		{0.13, "nl3.wg.ivpn.net"},
+		{0.15, "nl4.wg.ivpn.net"},
+		{0.6, "nl4.wg.ivpn.net"},
+		{0.63, "nl5.wg.ivpn.net"},
+		{0.999, "nl5.wg.ivpn.net"},
+	} {
+		idx := selectHost(hosts, HostSelectionWeightedRandom, nil, func() float64 { return tc.rnd })
+		if hosts[idx].Hostname != tc.expected {
+			t.Errorf("rnd=%v: unexpected host %s (expected %s)", tc.rnd, hosts[idx].Hostname, tc.expected)
+		}
+	}
+
+	// distribution: lightly loaded and low-latency hosts are selected more often
+	latencies := map[string]int{"192.0.2.3": 20, "192.0.2.4": 20, "192.0.2.5": 80}
+	counts := make(map[string]int)
+	const samples = 1000
+	for i := 0; i < samples; i++ {
+		v := float64(i) / samples
+		idx := selectHost(hosts, HostSelectionWeightedRandom, latencies, func() float64 { return v })
+		counts[hosts[idx].Hostname]++
+	}
+	if !(counts["nl4.wg.ivpn.net"] > counts["nl3.wg.ivpn.net"] && counts["nl3.wg.ivpn.net"] > counts["nl5.wg.ivpn.net"] && counts["nl5.wg.ivpn.net"] > 0) {
+		t.Errorf("unexpected distribution: %v", counts)
+	}
+
+	// fully loaded hosts still can be selected
+	hosts = hostsInfoBase(wgGatewayHosts(t, svrs, "de.wg.ivpn.net"))
+	if idx := selectHost(hosts, HostSelectionWeightedRandom, nil, func() float64 { return 0.75 }); hosts[idx].Hostname != "de2.wg.ivpn.net" {
+		t.Errorf("unexpected host: %s", hosts[idx].Hostname)
+	}
+}
+
+func TestNormalizeHostsSelection(t *testing.T) {
+	svrs := loadServersFixture(t)
+	latencies := map[string]int{"192.0.2.3": 10, "192.0.2.4": 50, "192.0.2.5": 30, "198.51.100.1": 5}
+
+	// WireGuard Multi-Hop: the latency is in use only for the entry host
+	var p ConnectionParams
+	p.VpnType = vpn.WireGuard
+	p.Metadata.HostSelection = HostSelectionLowestPing
+	p.WireGuardParameters.EntryVpnServer.Hosts = wgGatewayHosts(t, svrs, "nl.wg.ivpn.net")
+	p.WireGuardParameters.MultihopExitServer.Hosts = wgGatewayHosts(t, svrs, "de.wg.ivpn.net")
+	if err := p.NormalizeHosts(latencies); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := p.EntryHostInfo(); err != nil || h.Hostname != "nl3.wg.ivpn.net" {
+		t.Errorf("unexpected entry host: %s (%v)", h.Hostname, err)
+	}
+	if len(p.WireGuardParameters.MultihopExitServer.Hosts) != 1 || p.WireGuardParameters.MultihopExitServer.Hosts[0].Hostname != "de1.wg.ivpn.net" {
+		t.Errorf("unexpected exit hosts: %v", p.WireGuardParameters.MultihopExitServer.Hosts)
+	}
+
+	// OpenVPN
+	p = ConnectionParams{}
+	p.VpnType = vpn.OpenVPN
+	p.Metadata.HostSelection = HostSelectionLeastLoad
+	p.OpenVpnParameters.EntryVpnServer.Hosts = svrs.OpenvpnServers[0].Hosts
+	if err := p.NormalizeHosts(latencies); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := p.EntryHostInfo(); err != nil || h.Hostname != "nl4.gw.ivpn.net" {
+		t.Errorf("unexpected entry host: %s (%v)", h.Hostname, err)
+	}
+}
@@ -22,13 +22,65 @@
 
 package types
 
+import (
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+)
+
 type KillSwitchStatus struct {
 	IsEnabled         bool   // FW state
 	IsPersistent      bool   // configuration: true - when persistent
 	IsAllowLAN        bool   // configuration: 'Allow LAN'
 	IsAllowMulticast  bool   // configuration: 'Allow multicast'
 	IsAllowApiServers bool   // configuration: 'Allow API servers'
-	UserExceptions    string // configuration: Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	UserExceptions    string // configuration: Firewall exceptions: comma separated list of IP addresses (masks) in format: [DIRECTION:][PROTOCOL[:PORT[-PORT]]@]x.x.x.x[/xx]
 
 	StateLanAllowed bool // real state of 'Allow LAN'
+
+	UserExceptionsHosts []FwExceptionHost // state of host names defined in 'UserExceptions' (resolved addresses)
+
+	InterfaceExceptions       firewall.InterfaceExceptions // configuration: Firewall exceptions for network interfaces
+	InterfaceExceptionsActive []string                     // network interfaces which are currently allowed (matching 'InterfaceExceptions' patterns)
+
+	AppAllowlist firewall.AppAllowlist // configuration: applications allowed to communicate when firewall is enabled (Linux only)
+
+	CaptivePortal CaptivePortalStatus // state of the captive portal detection
+}
+
+// CaptivePortalStatus - status of the captive portal detection
+// (the portal is detected by probing a known HTTP endpoint outside the VPN tunnel while the firewall is enabled)
+type CaptivePortalStatus struct {
+	IsEnabled  bool     // configuration
+	IsDetected bool     // captive portal detected: the temporary firewall exception is opened to allow the portal login
+	PortalURL  string   // address of the portal login page (if known)
+	AllowedIPs []string // addresses allowed by the temporary firewall exception (gateway and portal addresses)
+	ExpiresAt  int64    // time when the temporary firewall exception will be closed (Unix time)
+	LastCheck  int64    // time of the last probe (Unix time)
+	// true when the last probe confirmed the Internet connectivity (no captive portal)
+	IsConnectivityConfirmed bool
+}
+
+// FwExceptionHost - state of firewall exception defined by host name
+type FwExceptionHost struct {
+	Host       string
+	IPs        []string // currently allowed addresses
+	LastUpdate int64    // time of the last successful resolution (Unix time)
+	NextUpdate int64    // time of the next resolution (Unix time)
+	Error      string   // error of the last resolution attempt (empty if succeeded)
+}
+
+// DnsAutoStatus - status of the 'auto' DNS mode
+// (the fastest predefined encrypted DNS provider is selected automatically when VPN connected)
+type DnsAutoStatus struct {
+	IsEnabled  bool               // configuration
+	Selected   dns.DnsSettings    // currently applied DNS provider (empty if not selected)
+	Candidates []DnsAutoCandidate // results of the last benchmark (sorted: the best is the first)
+	LastCheck  int64              // time of the last benchmark (Unix time)
+}
+
+// DnsAutoCandidate - benchmark result of the predefined DNS provider
+type DnsAutoCandidate struct {
+	Dns          dns.DnsSettings
+	AvgLatencyMs int64 // average latency of successful probes
+	Failures     int   // number of failed probes
 }
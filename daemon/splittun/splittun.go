@@ -23,6 +23,7 @@
 package splittun
 
 import (
+	"fmt"
 	"net"
 	"sync"
 
@@ -61,6 +62,47 @@ type RunningApp struct {
 	ExtModifiedCmdLine string
 }
 
+// UserRule is the Split Tunnel rule by user account (applicable for Linux)
+// All traffic of the processes running under the user (or the group) is processed according to the rule,
+// no matter how the processes are started.
+type UserRule struct {
+	Id      uint32 // UID or GID
+	IsGroup bool   // false - 'Id' is UID; true - 'Id' is GID
+	IsVpn   bool   // false - traffic bypasses the VPN tunnel; true - traffic uses only the VPN tunnel
+}
+
+func (r UserRule) String() string {
+	idType := "uid"
+	if r.IsGroup {
+		idType = "gid"
+	}
+	action := "bypass"
+	if r.IsVpn {
+		action = "vpn"
+	}
+	return fmt.Sprintf("%s:%s:%d", action, idType, r.Id)
+}
+
+// DnsPolicy defines DNS resolver for the apps in Split Tunnel environment (applicable for Linux; not applicable for Inverse mode)
+type DnsPolicy int
+
+const (
+	DnsPolicyDefault DnsPolicy = iota // system DNS configuration (the VPN DNS when VPN is connected)
+	DnsPolicySystem                   // original system DNS saved before connecting to VPN
+	DnsPolicyCustom                   // explicitly defined DNS server
+)
+
+func (p DnsPolicy) String() string {
+	switch p {
+	case DnsPolicySystem:
+		return "system"
+	case DnsPolicyCustom:
+		return "custom"
+	default:
+		return "default"
+	}
+}
+
 // Initialize must be called first (before accessing any ST functionality)
 // Normally, it should check if the ST functionality available
 // Returns non-nil error object if Split-Tunneling functionality not available
@@ -91,7 +133,8 @@ func Reset() error {
 }
 
 // ApplyConfig control split-tunnel functionality
-func ApplyConfig(isStEnabled, isStInverse, isStInverseAllowWhenNoVpn, isVpnEnabled bool, addrConfig ConfigAddresses, splitTunnelApps []string) error {
+// stDns - (Linux) DNS server for the apps in Split Tunnel environment (nil - use system DNS configuration)
+func ApplyConfig(isStEnabled, isStInverse, isStInverseAllowWhenNoVpn, isVpnEnabled bool, addrConfig ConfigAddresses, splitTunnelApps []string, userRules []UserRule, stDns net.IP) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
@@ -100,7 +143,7 @@ func ApplyConfig(isStEnabled, isStInverse, isStInverseAllowWhenNoVpn, isVpnEnabl
 		addrConfig.IPv6Tunnel = nil
 	}
 
-	retErr := implApplyConfig(isStEnabled, isStInverse, isStInverseAllowWhenNoVpn, isVpnEnabled, addrConfig, splitTunnelApps)
+	retErr := implApplyConfig(isStEnabled, isStInverse, isStInverseAllowWhenNoVpn, isVpnEnabled, addrConfig, splitTunnelApps, userRules, stDns)
 	if retErr != nil {
 		log.Error(retErr)
 	}
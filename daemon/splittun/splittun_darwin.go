@@ -24,6 +24,7 @@ package splittun
 
 import (
 	"fmt"
+	"net"
 )
 
 var (
@@ -42,7 +43,7 @@ func implReset() error {
 	return notImplementedError
 }
 
-func implApplyConfig(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled bool, addrConfig ConfigAddresses, splitTunnelApps []string) error {
+func implApplyConfig(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled bool, addrConfig ConfigAddresses, splitTunnelApps []string, userRules []UserRule, stDns net.IP) error {
 	return notImplementedError
 }
 
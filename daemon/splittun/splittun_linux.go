@@ -27,6 +27,7 @@ package splittun
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -34,6 +35,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/cgroups"
 	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 	"github.com/ivpn/desktop-app/daemon/shell"
@@ -51,11 +53,31 @@ var (
 // (map[<PID>]<command>)
 var _addedRootProcesses map[int]string = map[int]string{}
 
-const stPidsFile = "/sys/fs/cgroup/net_cls/ivpn-exclude/cgroup.procs"
+const (
+	// cgroup v1: 'net_cls' controller (packets are matched by class ID)
+	stPidsFileCgroupV1 = "/sys/fs/cgroup/net_cls/ivpn-exclude/cgroup.procs"
+	// cgroup v2: unified hierarchy (packets are matched by the cgroup path of the socket)
+	stPidsFileCgroupV2 = "/sys/fs/cgroup/ivpn-exclude/cgroup.procs"
+	// cgroup v2: original cgroups of the processes added to ST (one file per PID containing the cgroup path).
+	// NOTE! The Split Tunnel script uses the same folder to move the processes back to their original cgroups.
+	stCgroupOriginsFolder = "/run/ivpn-exclude-cgroups"
+)
+
+// File with the list of processes in ST environment (depends on cgroup version; see implInitialize())
+var stPidsFile = stPidsFileCgroupV1
 
 func implInitialize() error {
 	funcNotAvailableError = nil
 
+	// NOTE! The Split Tunnel script uses the same rule to select cgroup version.
+	if cgroups.IsV2() {
+		log.Info("Split Tunnel: using cgroup v2")
+		stPidsFile = stPidsFileCgroupV2
+	} else {
+		log.Info("Split Tunnel: using cgroup v1 (net_cls)")
+		stPidsFile = stPidsFileCgroupV1
+	}
+
 	snapEvs := platform.GetSnapEnvs()
 	if snapEvs != nil {
 		funcNotAvailableError = fmt.Errorf("Split-Tunnelling not applicable out from snap sandbox")
@@ -90,7 +112,7 @@ func implInitialize() error {
 	}
 
 	// Ensure that ST is disable on daemon startup
-	enable(false, false, false, false, false)
+	enable(false, false, false, false, false, nil)
 
 	// Register network change detector
 	//
@@ -118,6 +140,10 @@ func implInitialize() error {
 						if err != nil {
 							log.Error("failed to update routes for SplitTunneling functionality")
 						}
+						// the rules by user account depend on the default interface
+						if err := reapplyUserRules(); err != nil {
+							log.Error(err)
+						}
 					})
 				}
 			}
@@ -137,17 +163,33 @@ func implReset() error {
 	return shell.Exec(nil, stScriptPath, "reset")
 }
 
-func implApplyConfig(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled bool, addrConfig ConfigAddresses, splitTunnelApps []string) error {
+func implApplyConfig(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled bool, addrConfig ConfigAddresses, splitTunnelApps []string, userRules []UserRule, stDns net.IP) error {
 	// If VPN does not support IPv6 - block IPv6 connectivity for 'splitted' apps in inverse mode
 	vpnNoIPv6 := false
 	if isVpnEnabled && len(addrConfig.IPv6Tunnel) == 0 {
 		vpnNoIPv6 = true
 	}
 
-	err := enable(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled, vpnNoIPv6)
+	err := enable(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled, vpnNoIPv6, stDns)
 	if err != nil {
 		log.Error(err)
 	}
+
+	// persistent rules by executable path
+	if e := applyAppRules(isStEnabled && err == nil, splitTunnelApps); e != nil {
+		log.Error(e)
+		if err == nil {
+			err = e
+		}
+	}
+
+	// rules by user account
+	if e := applyUserRules(isStEnabled && err == nil, userRules); e != nil {
+		log.Error(e)
+		if err == nil {
+			err = e
+		}
+	}
 	return err
 }
 
@@ -373,7 +415,7 @@ func isEnabled() (bool, error) {
 	return true, nil
 }
 
-func enable(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, vpnNoIPv6 bool) error {
+func enable(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, vpnNoIPv6 bool, stDns net.IP) error {
 	if !isEnable {
 		enabled, err := isEnabled()
 		if err == nil && !enabled {
@@ -398,7 +440,12 @@ func enable(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, v
 			}
 
 		}
-		_, outErrText, _, _, err := shell.ExecAndGetOutput(log, 1024, "", stScriptPath, "start", inversedArg, inverseBlockArg)
+		args := []string{"start", inversedArg, inverseBlockArg}
+		if stDns4 := stDns.To4(); stDns4 != nil && !isStInversed {
+			// DNS requests of the apps are redirected to the specified DNS server (outside the VPN tunnel)
+			args = append(args, "-dns", stDns4.String())
+		}
+		_, outErrText, _, _, err := shell.ExecAndGetOutput(log, 1024, "", stScriptPath, args...)
 		if err != nil {
 			if len(outErrText) > 0 {
 				err = fmt.Errorf("(%w) %s", err, outErrText)
@@ -461,7 +508,7 @@ func readProcEnvVarIvpnId(pid int) (int, error) {
 	}
 
 	id := 0
-	vars := strings.Split(string(bytes), string(0))
+	vars := strings.Split(string(bytes), "\x00")
 	for _, line := range vars {
 		cols := strings.Split(line, "=")
 		if len(cols) != 2 {
@@ -0,0 +1,198 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package splittun
+
+// Persistent Split Tunnel rules by executable path.
+// Every process which executes a binary from the configuration is moved to the Split Tunnel cgroup automatically
+// (including processes started from the desktop launcher, not only by 'ivpn exclude <command>').
+// New processes are detected by the process exec monitor (netlink process connector).
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/cgroups"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/procmon"
+)
+
+var (
+	appRulesMutex sync.Mutex
+	// executable paths (with resolved symlinks) of the binaries to be added to ST automatically
+	appRules   map[string]struct{}
+	appMonitor *procmon.Monitor
+)
+
+// applyAppRules updates the list of executable paths and starts/stops the process exec monitor
+func applyAppRules(isStEnabled bool, apps []string) error {
+	appRulesMutex.Lock()
+	defer appRulesMutex.Unlock()
+
+	newRules := make(map[string]struct{}, len(apps))
+	if isStEnabled {
+		for _, a := range apps {
+			if p := resolveExecutablePath(a); len(p) > 0 {
+				newRules[p] = struct{}{}
+			}
+		}
+	}
+
+	// remove from ST the processes of the binaries which are not in configuration anymore
+	removedRules := make(map[string]struct{})
+	for p := range appRules {
+		if _, ok := newRules[p]; !ok {
+			removedRules[p] = struct{}{}
+		}
+	}
+	if len(removedRules) > 0 && isStEnabled {
+		if runningApps, err := implGetRunningApps(); err == nil {
+			for _, app := range runningApps {
+				if _, ok := removedRules[app.Exe]; ok && app.ExtIvpnRootPid == 0 {
+					log.Info(fmt.Sprintf("Removing PID:%d (%s): the binary is removed from the configuration", app.Pid, app.Exe))
+					implRemovePid(app.Pid)
+				}
+			}
+		}
+	}
+
+	appRules = newRules
+
+	if len(appRules) == 0 {
+		if appMonitor != nil {
+			log.Info("Stopping process exec monitor")
+			appMonitor.Stop()
+			appMonitor = nil
+		}
+		return nil
+	}
+
+	if appMonitor == nil {
+		log.Info("Starting process exec monitor")
+		m, err := procmon.Start(onProcessExec)
+		if err != nil {
+			return fmt.Errorf("failed to start process exec monitor: %w", err)
+		}
+		appMonitor = m
+	}
+
+	// add already running processes
+	procDirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return err
+	}
+	stPids := make(map[string]struct{})
+	if data, err := os.ReadFile(stPidsFile); err == nil {
+		for _, p := range strings.Fields(string(data)) {
+			stPids[p] = struct{}{}
+		}
+	}
+	for _, d := range procDirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		if _, ok := stPids[d.Name()]; ok {
+			continue // already in ST environment
+		}
+		if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+			if _, ok := appRules[exe]; ok {
+				addPidToCgroup(pid, exe)
+			}
+		}
+	}
+
+	return nil
+}
+
+// onProcessExec is called by process exec monitor
+func onProcessExec(pid int) {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return // process already finished (or kernel thread)
+	}
+
+	appRulesMutex.Lock()
+	_, isMatch := appRules[exe]
+	appRulesMutex.Unlock()
+
+	if isMatch {
+		addPidToCgroup(pid, exe)
+	}
+}
+
+// addPidToCgroup moves the process to the Split Tunnel cgroup
+// (writing directly to 'cgroup.procs': the process must be moved as soon as possible, before it opens connections)
+func addPidToCgroup(pid int, exe string) {
+	saveOriginalCgroup(pid)
+
+	f, err := os.OpenFile(stPidsFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to add PID:%d (%s): %s", pid, exe, err))
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strconv.Itoa(pid)); err != nil {
+		log.Error(fmt.Sprintf("Failed to add PID:%d (%s): %s", pid, exe, err))
+		return
+	}
+	log.Info(fmt.Sprintf("Added PID:%d (%s)", pid, exe))
+}
+
+// saveOriginalCgroup saves the original cgroup of the process (cgroup v2),
+// so the Split Tunnel script is able to move the process back when it is removed from ST
+func saveOriginalCgroup(pid int) {
+	if stPidsFile != stPidsFileCgroupV2 {
+		return
+	}
+	origin, err := cgroups.ProcessPathV2(pid)
+	if err != nil || cgroups.ProcsFileV2(origin) == stPidsFileCgroupV2 {
+		return
+	}
+	if err := os.MkdirAll(stCgroupOriginsFolder, 0700); err != nil {
+		log.Warning(fmt.Sprintf("Failed to save original cgroup of PID:%d: %s", pid, err))
+		return
+	}
+	if err := os.WriteFile(filepath.Join(stCgroupOriginsFolder, strconv.Itoa(pid)), []byte(origin), 0600); err != nil {
+		log.Warning(fmt.Sprintf("Failed to save original cgroup of PID:%d: %s", pid, err))
+	}
+}
+
+// resolveExecutablePath returns absolute path to the binary with resolved symlinks
+// (the same as the kernel reports in '/proc/<PID>/exe')
+func resolveExecutablePath(binaryPath string) string {
+	if !filepath.IsAbs(binaryPath) {
+		return ""
+	}
+	p, err := filepath.EvalSymlinks(binaryPath)
+	if err != nil {
+		return filepath.Clean(binaryPath)
+	}
+	return p
+}
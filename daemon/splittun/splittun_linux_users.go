@@ -0,0 +1,82 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package splittun
+
+// Split Tunnel rules by user account.
+// Packets are matched by the owner (UID/GID) of the socket (iptables 'owner' match) and are marked for the Split Tunnel
+// routing table (bypass VPN) or are kept in the main routing table (VPN only).
+// The rules are applied by the Split Tunnel script (command 'update-user-rules').
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+var (
+	userRulesMutex sync.Mutex
+	userRules      []UserRule
+)
+
+// applyUserRules saves the user rules and applies them (the ST script must be started)
+func applyUserRules(isStEnabled bool, rules []UserRule) error {
+	userRulesMutex.Lock()
+	defer userRulesMutex.Unlock()
+
+	userRules = nil
+	if isStEnabled {
+		userRules = append(userRules, rules...)
+	}
+	if !isStEnabled || !isActive {
+		// nothing to do: the script removes all rules on Split Tunnel disabling
+		return nil
+	}
+	return updateUserRules()
+}
+
+// reapplyUserRules applies the rules one more time (e.g. when the default network interface changed)
+func reapplyUserRules() error {
+	userRulesMutex.Lock()
+	defer userRulesMutex.Unlock()
+
+	if !isActive || len(userRules) == 0 {
+		return nil
+	}
+	return updateUserRules()
+}
+
+func updateUserRules() error {
+	args := make([]string, 0, len(userRules)+1)
+	args = append(args, "update-user-rules")
+	for _, r := range userRules {
+		args = append(args, r.String())
+	}
+	if err := shell.Exec(log, stScriptPath, args...); err != nil {
+		return fmt.Errorf("failed to apply Split Tunnel rules by user account: %w", err)
+	}
+	return nil
+}
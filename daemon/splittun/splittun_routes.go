@@ -0,0 +1,160 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package splittun
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Destination-based split tunnelling (IPv4 only: IPv6 networks are ignored).
+// Routes for the specified networks are applied only when VPN is connected:
+//   - normal mode: the networks are routed via the default gateway of the physical interface (bypassing the VPN tunnel);
+//   - inverse mode: only the networks are routed via the VPN interface. All the rest traffic is routed via the default gateway
+//     of the physical interface by more specific routes ("/2") which are overlapping the VPN routes ("/1" or "/0").
+
+// RoutesConfig - configuration of destination-based split tunnelling
+type RoutesConfig struct {
+	IsInversed bool
+	Networks   []net.IPNet
+	// Gateway of the physical interface (required when VPN connected)
+	PhysicalGateway net.IP
+	// VPN interface and its local IP address (required for inverse mode when VPN connected)
+	VpnInterface *net.Interface
+	VpnLocalIP   net.IP
+}
+
+// route - single routing rule; the next hop is a gateway or (when gateway is nil) the VPN interface
+type route struct {
+	network net.IPNet
+	gateway net.IP
+	iface   *net.Interface
+	localIP net.IP
+}
+
+func (r route) String() string {
+	if r.gateway != nil {
+		return fmt.Sprintf("%s via %s", r.network.String(), r.gateway.String())
+	}
+	return fmt.Sprintf("%s dev %s", r.network.String(), r.iface.Name)
+}
+
+func (r route) isEqual(r2 route) bool {
+	if r.network.String() != r2.network.String() || !r.gateway.Equal(r2.gateway) {
+		return false
+	}
+	if r.iface == nil || r2.iface == nil {
+		return r.iface == r2.iface
+	}
+	return r.iface.Index == r2.iface.Index
+}
+
+// subnets to cover all IPv4 addresses by routes which are more specific than the VPN routes (used in inverse mode)
+var inverseRoutesOverlapNetworks = []string{"0.0.0.0/2", "64.0.0.0/2", "128.0.0.0/2", "192.0.0.0/2"}
+
+var (
+	routesMutex   sync.Mutex
+	appliedRoutes map[string]route // map[<network>]route
+)
+
+// ApplyRoutes applies routes for destination-based split tunnelling.
+// All applied routes are removed when VPN is not connected or when the list of networks is empty.
+func ApplyRoutes(isVpnConnected bool, cfg RoutesConfig) (retErr error) {
+	routesMutex.Lock()
+	defer routesMutex.Unlock()
+
+	required := make(map[string]route)
+	if isVpnConnected && len(cfg.Networks) > 0 {
+		if cfg.PhysicalGateway == nil {
+			retErr = fmt.Errorf("unable to apply split tunnel routes: default gateway of the physical interface is not defined")
+		} else if cfg.IsInversed && (cfg.VpnInterface == nil || cfg.VpnLocalIP == nil) {
+			retErr = fmt.Errorf("unable to apply inverse split tunnel routes: VPN interface is not defined")
+		} else {
+			if cfg.IsInversed {
+				for _, n := range inverseRoutesOverlapNetworks {
+					_, network, _ := net.ParseCIDR(n)
+					required[network.String()] = route{network: *network, gateway: cfg.PhysicalGateway}
+				}
+			}
+			for _, n := range cfg.Networks {
+				if n.IP.To4() == nil {
+					continue // IPv6 is not supported
+				}
+				r := route{network: n, gateway: cfg.PhysicalGateway}
+				if cfg.IsInversed {
+					r = route{network: n, iface: cfg.VpnInterface, localIP: cfg.VpnLocalIP}
+				}
+				required[n.String()] = r
+			}
+		}
+	}
+
+	if appliedRoutes == nil {
+		appliedRoutes = make(map[string]route)
+	}
+
+	// remove routes which are not required anymore (or changed)
+	for key, r := range appliedRoutes {
+		if newRoute, ok := required[key]; ok && newRoute.isEqual(r) {
+			continue
+		}
+		if err := implRouteDelete(r); err != nil {
+			log.Warning(fmt.Sprintf("failed to remove split tunnel route (%s): %v", r, err))
+		}
+		delete(appliedRoutes, key)
+	}
+
+	// add new routes
+	for key, r := range required {
+		if _, ok := appliedRoutes[key]; ok {
+			continue
+		}
+		if err := implRouteAdd(r); err != nil {
+			// the route can remain from the previous daemon run (the applied routes are not saved): re-create it
+			if implRouteDelete(r) != nil || implRouteAdd(r) != nil {
+				retErr = fmt.Errorf("failed to add split tunnel route (%s): %w", r, err)
+				log.Error(retErr)
+				continue
+			}
+		}
+		appliedRoutes[key] = r
+	}
+
+	return retErr
+}
+
+// GetAppliedRoutes returns list of routes applied by destination-based split tunnelling
+// (e.g. "192.0.2.0/24 via 192.168.1.1")
+func GetAppliedRoutes() []string {
+	routesMutex.Lock()
+	defer routesMutex.Unlock()
+
+	ret := make([]string, 0, len(appliedRoutes))
+	for _, r := range appliedRoutes {
+		ret = append(ret, r.String())
+	}
+	sort.Strings(ret)
+	return ret
+}
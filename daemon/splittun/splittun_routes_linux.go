@@ -0,0 +1,48 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package splittun
+
+import (
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// ip route replace 192.0.2.0/24 via 192.168.1.1
+// ip route replace 192.0.2.0/24 dev wgivpn
+// ('replace': the route can remain from the previous daemon run)
+func implRouteAdd(r route) error {
+	return shell.Exec(log, "ip", append([]string{"route", "replace"}, routeArgs(r)...)...)
+}
+
+func implRouteDelete(r route) error {
+	return shell.Exec(log, "ip", append([]string{"route", "delete"}, routeArgs(r)...)...)
+}
+
+func routeArgs(r route) []string {
+	if r.gateway != nil {
+		return []string{r.network.String(), "via", r.gateway.String()}
+	}
+	return []string{r.network.String(), "dev", r.iface.Name}
+}
@@ -0,0 +1,55 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build windows
+// +build windows
+
+package splittun
+
+import (
+	"fmt"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// route.exe add 192.0.2.0/24 192.168.1.1
+// route.exe add 192.0.2.0/24 10.0.0.2 if 12   (VPN interface: the next hop is the local address of the interface)
+func implRouteAdd(r route) error {
+	if routeBinaryPath == "" {
+		return fmt.Errorf("route.exe location not specified")
+	}
+	if r.gateway != nil {
+		return shell.Exec(log, routeBinaryPath, "add", r.network.String(), r.gateway.String())
+	}
+	return shell.Exec(log, routeBinaryPath, "add", r.network.String(), r.localIP.String(), "if", fmt.Sprintf("%d", r.iface.Index))
+}
+
+func implRouteDelete(r route) error {
+	if routeBinaryPath == "" {
+		return fmt.Errorf("route.exe location not specified")
+	}
+	nextHop := r.gateway
+	if nextHop == nil {
+		nextHop = r.localIP
+	}
+	return shell.Exec(log, routeBinaryPath, "delete", r.network.String(), nextHop.String())
+}
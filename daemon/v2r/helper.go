@@ -25,6 +25,7 @@ package v2r
 import (
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/ivpn/desktop-app/daemon/netinfo"
 )
@@ -43,6 +44,8 @@ import (
 //	inboundIp - IP address of Dokodemo server
 //	inboundPort - port of Dokodemo server
 //	vnextUserId - user ID
+//	quicTlsSvrName - TLS server name (QUIC only)
+//	routeGateway - gateway for the route to the V2Ray server (nil - use the default gateway)
 func Start(binary string,
 	tmpConfigFile string,
 	isTcpLocalPort bool,
@@ -52,7 +55,8 @@ func Start(binary string,
 	inboundIp string,
 	inboundPort int,
 	outboundUserId string,
-	quicTlsSvrName string) (*V2RayWrapper, error) {
+	quicTlsSvrName string,
+	routeGateway net.IP) (*V2RayWrapper, error) {
 	var cfg *V2RayConfig
 	if outboundType == QUIC {
 		if quicTlsSvrName == "" {
@@ -65,9 +69,12 @@ func Start(binary string,
 		return nil, errors.New("unknown outbound type")
 	}
 
-	defGwIp, err := netinfo.DefaultGatewayIP()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get default gateway IP: %v", err)
+	defGwIp := routeGateway
+	if defGwIp == nil {
+		var err error
+		if defGwIp, err = netinfo.DefaultGatewayIP(); err != nil {
+			return nil, fmt.Errorf("failed to get default gateway IP: %v", err)
+		}
 	}
 
 	var lastError error
@@ -142,19 +142,20 @@ type StateInfo struct {
 	State       State
 	Description string
 
-	VpnType      Type
-	Time         int64                  // unix time (seconds)
-	IsTCP        bool                   // applicable only for 'CONNECTED' state
-	ClientIP     net.IP                 // applicable only for 'CONNECTED' state
-	ClientIPv6   net.IP                 // applicable only for 'CONNECTED' state. Initialized only if protocol supports IPv6 inside tunnel
-	ClientPort   int                    // applicable only for 'CONNECTED' state (source port)
-	ServerIP     net.IP                 // applicable only for 'CONNECTED' state
-	ServerPort   int                    // applicable only for 'CONNECTED' state (destination port)
-	V2RayProxy   v2r.V2RayTransportType // applicable only for 'CONNECTED' state
-	Obfsproxy    obfsproxy.Config       // applicable only for 'CONNECTED' state (OpenVPN)
-	ExitHostname string                 // applicable only for 'CONNECTED' state
-	Mtu          int                    // applicable only for 'CONNECTED' state (WireGuard)
-	IsAuthError  bool                   // applicable only for 'EXITING' state
+	VpnType        Type
+	Time           int64                  // unix time (seconds)
+	IsTCP          bool                   // applicable only for 'CONNECTED' state
+	ClientIP       net.IP                 // applicable only for 'CONNECTED' state
+	ClientIPv6     net.IP                 // applicable only for 'CONNECTED' state. Initialized only if protocol supports IPv6 inside tunnel
+	ClientPort     int                    // applicable only for 'CONNECTED' state (source port)
+	ServerIP       net.IP                 // applicable only for 'CONNECTED' state
+	ServerPort     int                    // applicable only for 'CONNECTED' state (destination port)
+	ServerHostname string                 // applicable only for 'CONNECTED' state (hostname of the entry host, e.g. "us-tx1.wg.ivpn.net")
+	V2RayProxy     v2r.V2RayTransportType // applicable only for 'CONNECTED' state
+	Obfsproxy      obfsproxy.Config       // applicable only for 'CONNECTED' state (OpenVPN)
+	ExitHostname   string                 // applicable only for 'CONNECTED' state
+	Mtu            int                    // applicable only for 'CONNECTED' state (WireGuard)
+	IsAuthError    bool                   // applicable only for 'EXITING' state
 
 	// TODO: try to avoid using this protocol-specific parameter in future
 	// Currently, in use by OpenVPN connection to inform about "RECONNECTING" reason (e.g. "tls-error", "init_instance"...)
@@ -54,6 +54,10 @@ type ConnectionParams struct {
 	ipv6Prefix           string
 	multihopExitHostname string // (e.g.: "nl4.wg.ivpn.net") we need it only for informing clients about connection status
 	mtu                  int    // Set 0 to use default MTU value
+	isMtuAuto            bool   // detect MTU after the connection established (ignored when 'mtu' defined)
+	// (applicable for Linux) "VPN-only" network namespace: the WireGuard interface is moved to it after the connection established
+	// (only processes running in the namespace are using the VPN tunnel). Empty string - not in use.
+	netnsName string
 }
 
 func (cp *ConnectionParams) GetIPv6ClientLocalIP() net.IP {
@@ -76,6 +80,16 @@ func (cp *ConnectionParams) SetCredentials(privateKey string, presharedKey strin
 	cp.clientLocalIP = localIP
 }
 
+// SetNetns sets "VPN-only" network namespace for the WireGuard interface (applicable for Linux)
+func (cp *ConnectionParams) SetNetns(name string) {
+	cp.netnsName = name
+}
+
+// SetMtuAuto enables detection of the path MTU after the connection established (ignored when custom MTU defined)
+func (cp *ConnectionParams) SetMtuAuto(isMtuAuto bool) {
+	cp.isMtuAuto = isMtuAuto
+}
+
 // CreateConnectionParams initializing connection parameters object
 func CreateConnectionParams(
 	multihopExitHostName string,
@@ -104,6 +118,8 @@ type WireGuard struct {
 	configFilePath string
 	connectParams  ConnectionParams
 	localPort      int
+	// MTU detected automatically (see ConnectionParams.SetMtuAuto()); 0 - not detected
+	detectedMtu int
 
 	isDisconnected        bool
 	isDisconnectRequested bool
@@ -320,6 +336,11 @@ func (wg *WireGuard) waitHandshakeAndNotifyConnected(stateChan chan<- vpn.StateI
 func (wg *WireGuard) newStateInfoConnected() vpn.StateInfo {
 	const isTCP = false
 
+	mtu := wg.connectParams.mtu
+	if wg.detectedMtu > 0 {
+		mtu = wg.detectedMtu
+	}
+
 	si := vpn.NewStateInfoConnected(
 		isTCP,
 		wg.connectParams.clientLocalIP,
@@ -327,7 +348,7 @@ func (wg *WireGuard) newStateInfoConnected() vpn.StateInfo {
 		wg.localPort,
 		wg.connectParams.hostIP,
 		wg.connectParams.hostPort,
-		wg.connectParams.mtu)
+		mtu)
 
 	si.ExitHostname = wg.connectParams.multihopExitHostname
 	return si
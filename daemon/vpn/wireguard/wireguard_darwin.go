@@ -222,7 +222,9 @@ func (wg *WireGuard) internalConnect(stateChan chan<- vpn.StateInfo) error {
 	select {
 	case <-isStartedChannel: // Process started. Perform initialization...
 		if initError = wg.initialize(); initError == nil {
-			initError = wg.waitHandshakeAndNotifyConnected(stateChan)
+			if initError = wg.waitHandshakeAndNotifyConnected(stateChan); initError == nil {
+				wg.autoMtuAndNotify(stateChan)
+			}
 		}
 	case <-time.After(time.Second * 5): // stop process if WG not successfully started during 5 sec
 		initError = fmt.Errorf("WireGuard process initialization timeout")
@@ -464,6 +466,29 @@ func (wg *WireGuard) setWgConfiguration() error {
 	}
 }
 
+// isHostRouteExists returns true if the routing table contains the route to the host (not the default route)
+func isHostRouteExists(host net.IP) bool {
+	// Expected output of "/sbin/route -n get -inet 198.51.100.1" command (part):
+	//    route to: 198.51.100.1
+	// destination: 198.51.100.1
+	//     gateway: 192.168.1.1
+	//   interface: en0
+	isExists := false
+	outRegexp := regexp.MustCompile(`^\s*destination\s*:\s*(\S+)`)
+	outParse := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		if cols := outRegexp.FindStringSubmatch(text); len(cols) == 2 && host.Equal(net.ParseIP(cols[1])) {
+			isExists = true
+		}
+	}
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", "/sbin/route", "-n", "get", "-inet", host.String()); err != nil {
+		return false
+	}
+	return isExists
+}
+
 func (wg *WireGuard) setRoutes() error {
 	log.Info("Modifying routing table...")
 
@@ -471,7 +496,11 @@ func (wg *WireGuard) setRoutes() error {
 	if !net.IPv4(127, 0, 0, 1).Equal(wg.connectParams.hostIP) {
 		// do not create route for 'hostIP' if it is '127.0.0.1'
 		if err := shell.Exec(log, "/sbin/route", "-n", "add", "-inet", wg.connectParams.hostIP.String(), wg.internals.defGateway.String()); err != nil {
-			return fmt.Errorf("adding route shell comand error : %w", err)
+			// the route to the host can be already defined by the daemon (underlay interface is pinned)
+			if !isHostRouteExists(wg.connectParams.hostIP) {
+				return fmt.Errorf("adding route shell comand error : %w", err)
+			}
+			log.Info(fmt.Sprintf("Route to remote server already exists (%v)", err))
 		}
 	}
 
@@ -651,3 +680,24 @@ func (wg *WireGuard) isReconnectRequiredOnRoutingChange() bool {
 func (wg *WireGuard) defaultRouteGatewayIP() net.IP {
 	return wg.connectParams.hostLocalIP
 }
+
+// implGetMtu returns the current MTU of the WireGuard interface
+func (wg *WireGuard) implGetMtu() (int, error) {
+	iface, err := net.InterfaceByName(wg.getTunnelName())
+	if err != nil {
+		return 0, err
+	}
+	return iface.MTU, nil
+}
+
+// implPingDontFragment sends ICMP echo request with 'Don't Fragment' flag from the local address of the tunnel.
+// Returns true if the reply received.
+// /sbin/ping -D -c 1 -t 1 -s 1392 -S 10.0.0.121 172.16.0.1
+func (wg *WireGuard) implPingDontFragment(host net.IP, payloadSize int) bool {
+	return shell.Exec(nil, "/sbin/ping", "-D", "-c", "1", "-t", "1", "-s", strconv.Itoa(payloadSize), "-S", wg.connectParams.clientLocalIP.String(), host.String()) == nil
+}
+
+// /sbin/ifconfig utun7 mtu 1400
+func (wg *WireGuard) implSetMtu(mtu int) error {
+	return shell.Exec(log, "/sbin/ifconfig", wg.getTunnelName(), "mtu", strconv.Itoa(mtu))
+}
@@ -29,11 +29,13 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netns"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/shell"
 	"github.com/ivpn/desktop-app/daemon/vpn"
@@ -80,6 +82,7 @@ type internalVariables struct {
 	isPaused             atomic.Bool
 	resumeDisconnectChan chan *operationRequest // control connection pause\resume or disconnect from paused state
 	lastOpRequest        *operationRequest
+	isInNetns            atomic.Bool // true when the WireGuard interface is moved to the "VPN-only" network namespace
 }
 
 func (wg *WireGuard) init() error {
@@ -94,6 +97,12 @@ func (wg *WireGuard) init() error {
 	// We should close it in this case. Otherwise, new connection would not be established
 	wgInterfaceName := filepath.Base(wg.configFilePath)
 	wgInterfaceName = strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
+	// the interface can be left in the "VPN-only" network namespace
+	if ns := wg.connectParams.netnsName; ns != "" && netns.IsExists(ns) {
+		if err := shell.Exec(nil, "ip", "-n", ns, "link", "delete", wgInterfaceName); err == nil {
+			log.Info(fmt.Sprintf("Removed WireGuard interface '%s' from network namespace '%s'", wgInterfaceName, ns))
+		}
+	}
 	// stop current WG connection (if exists)
 	i, _ := net.InterfaceByName(wgInterfaceName)
 	if i != nil {
@@ -142,12 +151,23 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 	}()
 
 	internalRestoreDNSFunc := func() {
+		if wg.connectParams.netnsName != "" {
+			// "VPN-only" network namespace: the DNS configuration of the host was not changed
+			if err := netns.SetDns(wg.connectParams.netnsName, nil); err != nil {
+				log.Warning(fmt.Sprintf("failed to remove DNS configuration of the network namespace: %s", err))
+			}
+			return
+		}
 		// restore DNS configuration
 		if err := dns.DeleteManual(nil, wg.connectParams.clientLocalIP); err != nil {
 			log.Warning(fmt.Sprintf("failed to restore DNS configuration: %s", err))
 		}
 	}
 	internalDisconnectFunc := func() error {
+		// the interface must be in the default network namespace to be removed by 'wg-quick down'
+		if err := wg.netnsRestoreInterface(); err != nil {
+			log.Warning(err)
+		}
 		err := shell.Exec(log, wg.binaryPath, "down", wg.configFilePath)
 		if err != nil {
 			return fmt.Errorf("failed to stop WireGuard: %w", err)
@@ -185,7 +205,11 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 			}()
 
 			// update DNS configuration
-			if !wg.internals.manualDNS.IsEmpty() {
+			if wg.connectParams.netnsName != "" {
+				if err := netns.SetDns(wg.connectParams.netnsName, wg.netnsDnsServers()); err != nil {
+					return fmt.Errorf("failed to set DNS for network namespace: %w", err)
+				}
+			} else if !wg.internals.manualDNS.IsEmpty() {
 				if err := dns.SetManual(wg.internals.manualDNS, wg.connectParams.clientLocalIP); err != nil {
 					return fmt.Errorf("failed to set manual DNS: %w", err)
 				}
@@ -202,6 +226,15 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 				return err
 			}
 
+			// "VPN-only" network namespace: move the interface to the namespace
+			// (the encrypted traffic keeps using the socket of the default namespace)
+			if err := wg.netnsMoveInterface(); err != nil {
+				return err
+			}
+
+			// path MTU detection (when the tunnel is already allowed by the firewall and moved to the network namespace)
+			wg.autoMtuAndNotify(stateChan)
+
 			wgInterfaceName := filepath.Base(wg.configFilePath)
 			wgInterfaceName = strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
 
@@ -210,7 +243,7 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 				for {
 					select {
 					case <-time.After(time.Millisecond * 200):
-						i, err := net.InterfaceByName(wgInterfaceName)
+						i, err := wg.interfaceByName(wgInterfaceName)
 						if i == nil {
 							if err != nil {
 								fmt.Println(err)
@@ -353,6 +386,9 @@ func (wg *WireGuard) setManualDNS(dnsCfg dns.DnsSettings) error {
 	if wg.isPaused() || !wg.isRunning() {
 		return nil
 	}
+	if wg.connectParams.netnsName != "" {
+		return netns.SetDns(wg.connectParams.netnsName, wg.netnsDnsServers())
+	}
 	return dns.SetManual(dnsCfg, wg.connectParams.clientLocalIP)
 }
 
@@ -362,6 +398,12 @@ func (wg *WireGuard) resetManualDNS() error {
 	if wg.isPaused() {
 		return nil
 	}
+	if wg.connectParams.netnsName != "" {
+		if wg.isRunning() {
+			return netns.SetDns(wg.connectParams.netnsName, wg.netnsDnsServers())
+		}
+		return nil
+	}
 
 	if wg.isRunning() {
 		// changing DNS to default value for current WireGuard connection
@@ -401,3 +443,110 @@ func (wg *WireGuard) isReconnectRequiredOnRoutingChange() bool {
 func (wg *WireGuard) defaultRouteGatewayIP() net.IP {
 	return nil
 }
+
+// interfaceByName returns the WireGuard interface (from the "VPN-only" network namespace, if the interface was moved there)
+func (wg *WireGuard) interfaceByName(ifName string) (*net.Interface, error) {
+	if !wg.internals.isInNetns.Load() {
+		return net.InterfaceByName(ifName)
+	}
+
+	var ret *net.Interface
+	err := netns.Do(wg.connectParams.netnsName, func() (err error) {
+		ret, err = net.InterfaceByName(ifName)
+		return err
+	})
+	return ret, err
+}
+
+// netnsDnsServers returns DNS servers for the "VPN-only" network namespace.
+// Encrypted DNS is not available inside the namespace (the local DNS proxy is not accessible):
+// the DNS server of the VPN connection is in use in this case.
+func (wg *WireGuard) netnsDnsServers() []string {
+	if m := wg.internals.manualDNS; !m.IsEmpty() && m.Encryption == dns.EncryptionNone {
+		return []string{m.DnsHost}
+	}
+	return []string{wg.connectParams.hostLocalIP.String()}
+}
+
+// netnsMoveInterface moves the WireGuard interface to the "VPN-only" network namespace and configures it there
+// (addresses and routes are not preserved when the interface is moved to another namespace).
+// The namespace contains only loopback and WireGuard interfaces: processes inside it have no network access when the tunnel is down.
+func (wg *WireGuard) netnsMoveInterface() error {
+	ns := wg.connectParams.netnsName
+	if ns == "" {
+		return nil
+	}
+	ifName := wg.getTunnelName()
+
+	if err := netns.Create(ns); err != nil {
+		return err
+	}
+
+	if err := shell.Exec(log, "ip", "link", "set", "dev", ifName, "netns", ns); err != nil {
+		return fmt.Errorf("failed to move WireGuard interface to network namespace '%s': %w", ns, err)
+	}
+	wg.internals.isInNetns.Store(true)
+
+	commands := [][]string{{"-n", ns, "address", "add", wg.connectParams.clientLocalIP.String() + "/32", "dev", ifName}}
+	if ipv6 := wg.connectParams.GetIPv6ClientLocalIP(); ipv6 != nil {
+		commands = append(commands, []string{"-n", ns, "address", "add", ipv6.String() + "/128", "dev", ifName})
+	}
+	commands = append(commands, []string{"-n", ns, "link", "set", "dev", ifName, "up"})
+	commands = append(commands, []string{"-n", ns, "route", "add", "default", "dev", ifName})
+	if wg.connectParams.GetIPv6ClientLocalIP() != nil {
+		commands = append(commands, []string{"-n", ns, "-6", "route", "add", "default", "dev", ifName})
+	}
+
+	for _, args := range commands {
+		if err := shell.Exec(log, "ip", args...); err != nil {
+			return fmt.Errorf("failed to configure WireGuard interface in network namespace '%s': %w", ns, err)
+		}
+	}
+	log.Info(fmt.Sprintf("WireGuard interface moved to the network namespace '%s'", ns))
+	return nil
+}
+
+// netnsRestoreInterface moves the WireGuard interface back to the default network namespace
+func (wg *WireGuard) netnsRestoreInterface() error {
+	if !wg.internals.isInNetns.Load() {
+		return nil
+	}
+	wg.internals.isInNetns.Store(false)
+
+	ns := wg.connectParams.netnsName
+	if err := shell.Exec(log, "ip", "-n", ns, "link", "set", "dev", wg.getTunnelName(), "netns", "1"); err != nil {
+		return fmt.Errorf("failed to move WireGuard interface from network namespace '%s': %w", ns, err)
+	}
+	return nil
+}
+
+// implGetMtu returns the current MTU of the WireGuard interface
+func (wg *WireGuard) implGetMtu() (int, error) {
+	iface, err := wg.interfaceByName(wg.GetTunnelName())
+	if err != nil {
+		return 0, err
+	}
+	return iface.MTU, nil
+}
+
+// implPingDontFragment sends ICMP echo request with 'Don't Fragment' flag via the WireGuard interface.
+// Returns true if the reply received.
+// ping -M do -c 1 -W 1 -s 1392 -I wgivpn 172.16.0.1
+// (when the interface is in the "VPN-only" network namespace: ip netns exec <ns> ping ...)
+func (wg *WireGuard) implPingDontFragment(host net.IP, payloadSize int) bool {
+	args := []string{"ping", "-M", "do", "-c", "1", "-W", "1", "-s", strconv.Itoa(payloadSize), "-I", wg.GetTunnelName(), host.String()}
+	if wg.internals.isInNetns.Load() {
+		args = append([]string{"netns", "exec", wg.connectParams.netnsName}, args...)
+		return shell.Exec(nil, "ip", args...) == nil
+	}
+	return shell.Exec(nil, args[0], args[1:]...) == nil
+}
+
+// ip link set dev wgivpn mtu 1400
+// (when the interface is in the "VPN-only" network namespace: ip -n <ns> link set ...)
+func (wg *WireGuard) implSetMtu(mtu int) error {
+	if wg.internals.isInNetns.Load() {
+		return shell.Exec(log, "ip", "-n", wg.connectParams.netnsName, "link", "set", "dev", wg.GetTunnelName(), "mtu", strconv.Itoa(mtu))
+	}
+	return shell.Exec(log, "ip", "link", "set", "dev", wg.GetTunnelName(), "mtu", strconv.Itoa(mtu))
+}
@@ -0,0 +1,136 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wireguard
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ivpn/desktop-app/daemon/vpn"
+)
+
+// Path MTU detection (see ConnectionParams.SetMtuAuto()).
+// After the 'connected' notification (when the firewall allows the tunnel traffic), the ICMP echo requests with 'Don't Fragment' flag are sent to the internal address of the server
+// (inside the tunnel). The largest size which passes through is detected by a binary search
+// in the interval [mtuAutoMin - <current interface MTU>] and applied to the WireGuard interface.
+// The detected value is reported by the repeated vpn.CONNECTED notification.
+
+const (
+	mtuAutoMin           = 1280 // minimal MTU value (required for IPv6 inside the tunnel)
+	mtuProbeAttempts     = 2    // number of probes for each size (to be tolerant to packet loss)
+	mtuProbeWaitAttempts = 5    // number of probes of the minimal size (the firewall rules for the tunnel can be applied with a delay)
+	mtuProbeIcmpOverhead = 28   // IPv4 header (20) + ICMP header (8)
+)
+
+// autoMtuAndNotify detects the path MTU (if enabled), applies it to the WireGuard interface
+// and sends the vpn.CONNECTED notification with the updated MTU value.
+// Must be called after the 'connected' notification.
+func (wg *WireGuard) autoMtuAndNotify(stateChan chan<- vpn.StateInfo) {
+	if !wg.connectParams.isMtuAuto || wg.connectParams.mtu > 0 || wg.isDisconnectRequested || wg.isDisconnected {
+		return
+	}
+	if wg.autoMtu() && !wg.isDisconnectRequested && !wg.isDisconnected {
+		wg.notifyConnectedStat(stateChan)
+	}
+}
+
+// autoMtu detects the path MTU and applies it to the WireGuard interface.
+// Returns true if the interface MTU was changed.
+// Errors are not critical: the default MTU keeps in use.
+func (wg *WireGuard) autoMtu() bool {
+	mtu, currentMtu, err := wg.detectMtu()
+	if err != nil {
+		log.Warning(fmt.Sprintf("MTU detection failed (the default MTU value is in use): %v", err))
+		return false
+	}
+
+	if mtu != currentMtu {
+		log.Info(fmt.Sprintf("Configuring detected MTU = %d ...", mtu))
+		if err := wg.implSetMtu(mtu); err != nil {
+			log.Error(fmt.Errorf("failed to set detected MTU (%d): %w", mtu, err))
+			return false
+		}
+		wg.detectedMtu = mtu
+		return true
+	}
+
+	log.Info(fmt.Sprintf("Detected MTU = %d (no changes required)", mtu))
+	wg.detectedMtu = mtu
+	return false
+}
+
+// detectMtu returns the largest MTU which passes through the path to the server (not bigger than the current interface MTU)
+func (wg *WireGuard) detectMtu() (mtu int, currentMtu int, err error) {
+	host := wg.connectParams.hostLocalIP
+	if host == nil || host.To4() == nil {
+		return 0, 0, fmt.Errorf("internal IPv4 address of the server is not defined")
+	}
+
+	currentMtu, err = wg.implGetMtu()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get MTU of the WireGuard interface: %w", err)
+	}
+	if currentMtu < mtuAutoMin {
+		return 0, 0, fmt.Errorf("unexpected MTU of the WireGuard interface (%d)", currentMtu)
+	}
+
+	log.Info(fmt.Sprintf("Detecting MTU (%s; max %d) ...", host, currentMtu))
+	// the minimal size must pass through; waiting a bit longer for it since the firewall rules can be not applied yet
+	if !wg.probeMtuAttempts(host, mtuAutoMin, mtuProbeWaitAttempts) {
+		return 0, 0, fmt.Errorf("no response from %s", host)
+	}
+	if wg.probeMtu(host, currentMtu) {
+		return currentMtu, currentMtu, nil
+	}
+
+	// 'low' passes through; 'high' does not
+	low, high := mtuAutoMin, currentMtu
+	for high-low > 1 {
+		if wg.isDisconnectRequested || wg.isDisconnected {
+			return 0, 0, fmt.Errorf("disconnected")
+		}
+		mid := (low + high) / 2
+		if wg.probeMtu(host, mid) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low, currentMtu, nil
+}
+
+func (wg *WireGuard) probeMtu(host net.IP, mtu int) bool {
+	return wg.probeMtuAttempts(host, mtu, mtuProbeAttempts)
+}
+
+func (wg *WireGuard) probeMtuAttempts(host net.IP, mtu int, attempts int) bool {
+	for i := 0; i < attempts; i++ {
+		if wg.isDisconnectRequested || wg.isDisconnected {
+			return false
+		}
+		if wg.implPingDontFragment(host, mtu-mtuProbeIcmpOverhead) {
+			return true
+		}
+	}
+	return false
+}
@@ -27,6 +27,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -38,6 +39,7 @@ import (
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/mgr"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
 var (
@@ -492,6 +494,9 @@ func (wg *WireGuard) installService(stateChan chan<- vpn.StateInfo) error {
 	if err != nil {
 		return err
 	}
+
+	// path MTU detection (after the 'connected' notification: when the tunnel is allowed by the firewall)
+	wg.autoMtuAndNotify(stateChan)
 	return nil
 }
 
@@ -589,3 +594,50 @@ func (wg *WireGuard) isReconnectRequiredOnRoutingChange() bool {
 func (wg *WireGuard) defaultRouteGatewayIP() net.IP {
 	return nil
 }
+
+// implGetMtu returns the current MTU of the WireGuard interface
+func (wg *WireGuard) implGetMtu() (int, error) {
+	iface, err := net.InterfaceByName(wg.GetTunnelName())
+	if err != nil {
+		return 0, err
+	}
+	return iface.MTU, nil
+}
+
+// implPingDontFragment sends ICMP echo request with 'Don't Fragment' flag from the local address of the tunnel.
+// Returns true if the reply received.
+// ping.exe -f -n 1 -w 1000 -l 1392 -S 10.0.0.121 172.16.0.1
+func (wg *WireGuard) implPingDontFragment(host net.IP, payloadSize int) bool {
+	envVarSystemroot := os.Getenv("SYSTEMROOT")
+	if len(envVarSystemroot) == 0 {
+		return false
+	}
+	pingBinary := filepath.Join(envVarSystemroot, "system32", "ping.exe")
+
+	// Note: ping.exe returns success also for 'Destination host unreachable' replies (checking for 'TTL=' in the output)
+	isReplyReceived := false
+	outParse := func(text string, isError bool) {
+		if !isError && strings.Contains(text, "TTL=") {
+			isReplyReceived = true
+		}
+	}
+	err := shell.ExecAndProcessOutput(nil, outParse, "", pingBinary, "-f", "-n", "1", "-w", "1000", "-l", strconv.Itoa(payloadSize), "-S", wg.connectParams.clientLocalIP.String(), host.String())
+	return err == nil && isReplyReceived
+}
+
+func (wg *WireGuard) implSetMtu(mtu int) error {
+	iface, err := net.InterfaceByName(wg.GetTunnelName())
+	if err != nil {
+		return err
+	}
+	luid, err := winipcfg.LUIDFromIndex(uint32(iface.Index))
+	if err != nil {
+		return err
+	}
+	ipif, err := luid.IPInterface(windows.AF_INET)
+	if err != nil {
+		return err
+	}
+	ipif.NLMTU = uint32(mtu)
+	return ipif.Set()
+}
@@ -12,9 +12,26 @@ func init() {
 	log = logger.NewLogger("wifi")
 }
 
+// WifiSecurity - security type of the WiFi network
+type WifiSecurity string
+
+const (
+	WifiSecurityUnknown    WifiSecurity = ""
+	WifiSecurityOpen       WifiSecurity = "open"
+	WifiSecurityOWE        WifiSecurity = "owe" // Enhanced Open: encrypted, but no authentication
+	WifiSecurityWEP        WifiSecurity = "wep"
+	WifiSecurityWPA        WifiSecurity = "wpa"
+	WifiSecurityWPA2       WifiSecurity = "wpa2"
+	WifiSecurityWPA3       WifiSecurity = "wpa3"
+	WifiSecurityEnterprise WifiSecurity = "enterprise" // WPA/WPA2/WPA3 with 802.1X authentication
+)
+
 type WifiInfo struct {
 	SSID       string
 	IsInsecure bool
+	// The fields below are not reported on all platforms (empty when not known)
+	BSSID    string       // MAC address of the access point (e.g. "01:23:45:67:89:ab")
+	Security WifiSecurity // security type of the network
 }
 
 // GetAvailableSSIDs returns the list of the names of available Wi-Fi networks
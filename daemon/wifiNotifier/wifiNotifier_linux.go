@@ -3,274 +3,22 @@
 
 package wifiNotifier
 
-/*
-// Trying to avoid using dynamic linking, therefore disabled 'iwlib'
-// (wireless-tools library, which is requires to have installed correspond package).
-// If you want to use original iwlib package (and do not use custom 'linux_iwlib_2.c'):
-// 1) uncomment '#cgo LDFLAGS: -liw'
-// 2) comment '#include "iwlib_2_linux.c"'
-// 3) remove  suffix '_2' from function names (in this file): iw_get_range_info_2, iw_init_event_stream_2, iw_extract_event_stream_2
-// #cgo LDFLAGS: -liw
-#include "linux_lib/iwlib_2_linux.c"
+// WiFi information is obtained using nl80211 (generic netlink) interface.
+// It does not require wireless-tools (libiw) and works with modern drivers which do not support wireless extensions.
 
-#include <stdio.h>  // printf
-#include <string.h> // strndup prototype
-#include <stdlib.h> // free protype
-
-#include <netinet/in.h>
-#include <linux/netlink.h>
-#include <linux/rtnetlink.h>
-#include <net/if.h>
-#include <arpa/inet.h>
-
-#include <sys/types.h>
-#include <sys/socket.h>
-
-#include <iwlib.h> // sudo apt-get install libiw-dev
-#include <ifaddrs.h>
-
-static inline char* concatenate(char* baseString, const char* toAdd, char delimiter) {
-	if (toAdd == NULL)
-		return baseString;
-	int addingLen = strlen(toAdd);
-	if (addingLen == 0)
-		return baseString;
-
-	if (baseString == NULL) {
-		baseString = (char*)malloc(addingLen +1);
-
-		memset(baseString, 0, addingLen + 1);
-		strcpy(baseString, toAdd);
-		return baseString;
-	}
-
-	int newSize = strlen(baseString) + ((delimiter != 0) ? 1 : 0) + addingLen + 1;
-	char* newString = (char*)malloc(newSize);
-
-	if (delimiter != 0)
-		sprintf(newString, "%s%c%s", baseString, delimiter, toAdd);
-	else
-		sprintf(newString, "%s%s", baseString, toAdd);
-
-	free(baseString);
-
-	return newString;
-}
-
-static inline char*  scanSSIDList(const char* interfaceName, int *retIsInsecure, const char* ssidToCheckSecurity) {
-    char *ret = NULL;
-
-    int sockfd = socket(AF_INET, SOCK_DGRAM, 0);
-    if (sockfd == -1)
-        return NULL;
-
-    //---------------------------------------------------------------------
-
-    struct iw_range range;
-
-    if ((iw_get_range_info_2(sockfd, interfaceName, &range) < 0) ||
-        (range.we_version_compiled < 14))
-    {
-        close(sockfd);
-        return NULL; // interface doesn't support scanning
-    }
-
-    __u8 wev = range.we_version_compiled;
-
-    //---------------------------------------------------------------------
-
-    struct iwreq request;
-    memset(&request, 0, sizeof(request));
-    request.u.param.flags = IW_SCAN_DEFAULT;
-    request.u.param.value = 0;
-
-    if (iw_set_ext(sockfd, interfaceName, SIOCSIWSCAN, &request) == -1)
-    {
-        close(sockfd);
-        return NULL;
-    }
-
-    //---------------------------------------------------------------------
-
-    struct timeval startTime, endTime, diffTime = { 0, 0 };
-    gettimeofday(&startTime, NULL);
-
-    char scanBuffer[0xFFFF];
-
-    int replyFound = 0;
-    while (replyFound == 0)
-    {
-        memset(scanBuffer, 0, sizeof(scanBuffer));
-        request.u.data.pointer = scanBuffer;
-        request.u.data.length = sizeof(scanBuffer);
-        request.u.data.flags = 0;
-
-        int result = iw_get_ext(sockfd,
-                                interfaceName,
-                                SIOCGIWSCAN,
-                                &request);
-
-        if (result == -1 && errno != EAGAIN)
-        {
-            close(sockfd);
-            return NULL;
-        }
-
-        if (result == 0)
-        {
-            replyFound = 1;
-            break;
-        }
-
-        gettimeofday(&endTime, NULL);
-        timersub(&endTime, &startTime, &diffTime);
-        if (diffTime.tv_sec > 10)
-            break;
-
-        usleep(100000);
-    }
-    close(sockfd);
-
-    //---------------------------------------------------------------------
-
-    if (replyFound)
-    {
-        struct iw_event iwe;
-        struct stream_descr stream;
-
-        iw_init_event_stream_2(&stream,
-                             scanBuffer,
-                             request.u.data.length);
-
-        char eventBuffer[512] = {0};
-
-        char essid[IW_ESSID_MAX_SIZE+1];
-        unsigned short encodeFlags = -1;
-        while (iw_extract_event_stream_2(&stream, &iwe, wev) > 0)
-        {
-            switch (iwe.cmd)
-            {
-                case SIOCGIWESSID:
-                {
-                    memset(essid, 0, sizeof(essid));
-                    if((iwe.u.essid.pointer) && (iwe.u.essid.length))
-                    {
-                        memcpy(essid,
-                            iwe.u.essid.pointer,
-                            iwe.u.essid.length);
-
-                        essid[iwe.u.essid.length] = 0;
-                        ret = concatenate(ret, essid, '\n');
-
-                        if (retIsInsecure!=NULL
-                            && ssidToCheckSecurity!=NULL
-                            && encodeFlags != -1
-                            && strcmp(essid, ssidToCheckSecurity)==0)
-                        {
-                            // TODO: networks with WEP encodong must be also trusred as insecure
-                            *retIsInsecure = ( encodeFlags & IW_ENCODE_DISABLED ) > 0;
-                            encodeFlags = -1;
-                        }
-                    }
-                }
-                break;
-
-                case SIOCGIWENCODE:
-                {
-                    encodeFlags = iwe.u.encoding.flags;
-                    break;
-                }
-            }
-        }
-    }
-
-    return ret;
-}
-
-static inline char* get_essid (char *iface)
-{
-   int           fd;
-   struct iwreq  w;
-   char          essid[IW_ESSID_MAX_SIZE+1];
-   if (!iface) return NULL;
-
-   fd = socket(AF_INET, SOCK_DGRAM, 0);
-
-   strncpy (w.ifr_ifrn.ifrn_name, iface, IFNAMSIZ);
-   memset (essid, 0, IW_ESSID_MAX_SIZE);
-   w.u.essid.pointer = (caddr_t *) essid;
-   w.u.data.length = IW_ESSID_MAX_SIZE;
-   w.u.data.flags = 0;
-
-   int isOK = ioctl (fd, SIOCGIWESSID, &w);
-   close (fd);
-
-   if (isOK != 0) return NULL;
-
-   return strndup (essid, 32); // normally, the IW_ESSID_MAX_SIZE is 32 bytes (the coping with potential security flaws within the driver)
-}
-
-static inline char * getCurrentWifiInfo(int* retIsInsecure) {
-    if (retIsInsecure != NULL)
-    {
-        *retIsInsecure = 0xFFFFFFFF;
-    }
-
-    char* retSSID = NULL;
-
-    // get all available network interfaces
-    struct ifaddrs *addrs,*tmp_addrs;
-    getifaddrs(&addrs);
-    tmp_addrs = addrs;
-    while (tmp_addrs)
-    {
-        if (tmp_addrs->ifa_addr && tmp_addrs->ifa_addr->sa_family == AF_PACKET)
-        {
-            retSSID = get_essid (tmp_addrs->ifa_name);
-            // do not forget to free 'retSSID' from memory!
-            if (retSSID!=NULL)
-            {
-                if (retIsInsecure!=NULL) {
-                    char* wifiList = scanSSIDList(tmp_addrs->ifa_name, retIsInsecure, retSSID);
-                    if (wifiList!=NULL) free(wifiList);
-                }
-                break;
-            }
-        }
-
-        tmp_addrs = tmp_addrs->ifa_next;
-    }
-    freeifaddrs(addrs);
-
-    return retSSID;
-}
-
-static inline char* getAvailableSSIDs(void) {
-    char* retSSID = NULL;
-
-    // get all available network interfaces
-    struct ifaddrs *addrs,*tmp_addrs;
-    getifaddrs(&addrs);
-    tmp_addrs = addrs;
-    while (tmp_addrs)
-    {
-        if (tmp_addrs->ifa_addr && tmp_addrs->ifa_addr->sa_family == AF_PACKET)
-            retSSID = concatenate(retSSID, scanSSIDList(tmp_addrs->ifa_name, NULL, NULL), '\n');
-        tmp_addrs = tmp_addrs->ifa_next;
-    }
-    freeifaddrs(addrs);
-
-    return retSSID;
-}
-*/
-import "C"
 import (
+	"errors"
 	"fmt"
-	"strings"
 	"sync"
-	"unsafe"
+	"time"
 
 	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/nl80211"
+)
+
+const (
+	scanTimeout          = 10 * time.Second
+	eventsReconnectDelay = 5 * time.Second
 )
 
 var (
@@ -279,10 +27,42 @@ var (
 
 // GetAvailableSSIDs returns the list of the names of available Wi-Fi networks
 func implGetAvailableSSIDs() ([]string, error) {
-	ssidList := C.getAvailableSSIDs()
-	goSsidList := C.GoString(ssidList)
-	C.free(unsafe.Pointer(ssidList))
-	return strings.Split(goSsidList, "\n"), nil
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	conn, err := nl80211.Dial()
+	if err != nil {
+		if errors.Is(err, nl80211.ErrFamilyNotFound) {
+			return nil, nil // no wireless interfaces
+		}
+		return nil, err
+	}
+	defer conn.Close()
+
+	ifaces, err := stationInterfaces(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	scanAndWait(conn, ifaces)
+
+	var ret []string
+	unique := make(map[string]struct{})
+	for _, iface := range ifaces {
+		bssList, err := conn.ScanResults(iface.Index)
+		if err != nil {
+			log.Warning(fmt.Sprintf("%s: %s", iface.Name, err))
+			continue
+		}
+		for _, bss := range bssList {
+			if _, exists := unique[bss.SSID]; len(bss.SSID) == 0 || exists {
+				continue // skip hidden networks and duplicates
+			}
+			unique[bss.SSID] = struct{}{}
+			ret = append(ret, bss.SSID)
+		}
+	}
+	return ret, nil
 }
 
 // GetCurrentWifiInfo returns current WiFi info
@@ -290,16 +70,49 @@ func implGetCurrentWifiInfo() (WifiInfo, error) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	var isInsecure C.int
+	conn, err := nl80211.Dial()
+	if err != nil {
+		if errors.Is(err, nl80211.ErrFamilyNotFound) {
+			return WifiInfo{}, nil // no wireless interfaces
+		}
+		return WifiInfo{}, err
+	}
+	defer conn.Close()
 
-	ssid := C.getCurrentWifiInfo(&isInsecure)
-	goSsid := C.GoString(ssid)
-	C.free(unsafe.Pointer(ssid))
+	ifaces, err := stationInterfaces(conn)
+	if err != nil {
+		return WifiInfo{}, err
+	}
+
+	for _, iface := range ifaces {
+		bssList, err := conn.ScanResults(iface.Index)
+		if err != nil {
+			log.Warning(fmt.Sprintf("%s: %s", iface.Name, err))
+		}
+		for _, bss := range bssList {
+			if !bss.IsAssociated {
+				continue
+			}
+			ssid := bss.SSID
+			if len(ssid) == 0 {
+				ssid = iface.SSID
+			}
+			security := WifiSecurity(bss.Security)
+			return WifiInfo{
+				SSID:       ssid,
+				IsInsecure: security == WifiSecurityOpen || security == WifiSecurityWEP,
+				BSSID:      bss.BSSID.String(),
+				Security:   security,
+			}, nil
+		}
+
+		if len(iface.SSID) > 0 {
+			// connected BSS not found in scan results: security type is not known
+			return WifiInfo{SSID: iface.SSID}, nil
+		}
+	}
 
-	return WifiInfo{
-		SSID:       goSsid,
-		IsInsecure: int(isInsecure) == 1,
-	}, nil
+	return WifiInfo{}, nil
 }
 
 // SetWifiNotifier initializes a handler method 'OnWifiChanged'
@@ -308,18 +121,129 @@ func implSetWifiNotifier(cb func()) error {
 		return fmt.Errorf("callback function not defined")
 	}
 
-	onNetChange := make(chan struct{}, 1)
+	conn, err := dialEvents()
+	if err != nil {
+		// nl80211 not available: falling back to the notifications about network address changes
+		log.Warning(fmt.Sprintf("unable to subscribe to nl80211 events (%s); using network address changes notifications", err))
 
-	if err := netlink.RegisterLanChangeListener(onNetChange); err != nil {
-		return err
+		onNetChange := make(chan struct{}, 1)
+		if err := netlink.RegisterLanChangeListener(onNetChange); err != nil {
+			return err
+		}
+		go func() {
+			for {
+				<-onNetChange
+				cb()
+			}
+		}()
+		return nil
 	}
 
 	go func() {
 		for {
-			<-onNetChange
-			cb()
+			events, err := conn.ReadEvents()
+			if err != nil {
+				log.Error(fmt.Sprintf("nl80211 events: %s", err))
+				conn.Close()
+				for {
+					time.Sleep(eventsReconnectDelay)
+					if conn, err = dialEvents(); err == nil {
+						break
+					}
+				}
+				cb() // the changes could be missed
+				continue
+			}
+
+			for _, evt := range events {
+				if isConnectionEvent(evt.Cmd) {
+					cb()
+					break
+				}
+			}
 		}
 	}()
 
 	return nil
 }
+
+// dialEvents creates nl80211 socket subscribed to connection events
+func dialEvents() (*nl80211.Conn, error) {
+	conn, err := nl80211.Dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.JoinGroups(nl80211.GroupMlme, nl80211.GroupConfig); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func isConnectionEvent(cmd uint8) bool {
+	switch cmd {
+	case 0, // unknown (some events were lost)
+		nl80211.CmdConnect,
+		nl80211.CmdRoam,
+		nl80211.CmdDisconnect,
+		nl80211.CmdDeauthenticate,
+		nl80211.CmdDisassociate,
+		nl80211.CmdNewInterface,
+		nl80211.CmdDelInterface:
+		return true
+	}
+	return false
+}
+
+func stationInterfaces(conn *nl80211.Conn) ([]nl80211.Interface, error) {
+	ifaces, err := conn.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]nl80211.Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.IsStation {
+			ret = append(ret, iface)
+		}
+	}
+	return ret, nil
+}
+
+// scanAndWait triggers scanning on the interfaces and waits until the scan results are ready
+func scanAndWait(conn *nl80211.Conn, ifaces []nl80211.Interface) {
+	if len(ifaces) == 0 {
+		return
+	}
+
+	evtConn, err := nl80211.Dial()
+	if err != nil {
+		return
+	}
+	defer evtConn.Close()
+	if err := evtConn.JoinGroups(nl80211.GroupScan); err != nil {
+		return
+	}
+
+	pending := make(map[int]struct{})
+	for _, iface := range ifaces {
+		if err := conn.TriggerScan(iface.Index); err != nil {
+			// e.g. the scan is already in progress (EBUSY): the last known results will be used
+			log.Debug(fmt.Sprintf("%s: %s", iface.Name, err))
+			continue
+		}
+		pending[iface.Index] = struct{}{}
+	}
+
+	deadline := time.Now().Add(scanTimeout)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		events, err := evtConn.ReadEvents()
+		if err != nil {
+			return
+		}
+		for _, evt := range events {
+			if evt.Cmd == nl80211.CmdNewScanResults || evt.Cmd == nl80211.CmdScanAborted {
+				delete(pending, evt.IfIndex)
+			}
+		}
+	}
+}
@@ -75,6 +75,30 @@ export function isValidIpOrMask(ipStr) {
   return true;
 }
 
+// Returns the string which identifies the WiFi network trust rule (rules with the same key are duplicates).
+// Must be the same as WiFiNetwork.Key() in the daemon.
+export function wifiNetworkKey(wifi) {
+  let bssids = [];
+  for (const b of wifi?.bssids || []) {
+    const mac = normalizeMacAddress(b);
+    if (mac && !bssids.includes(mac)) bssids.push(mac);
+  }
+  bssids.sort();
+  return `${wifi?.ssidMatch || ""}:${wifi?.ssid || ""}@${bssids.join(",")}`;
+}
+
+// Returns MAC address in canonical form ("01:23:45:67:89:ab") or null if the address is not valid
+function normalizeMacAddress(mac) {
+  const str = String(mac).trim().toLowerCase();
+  let hex = null;
+  if (/^[0-9a-f]{2}([:-])([0-9a-f]{2}\1){4}[0-9a-f]{2}$/.test(str))
+    hex = str.replace(/[:-]/g, "");
+  else if (/^([0-9a-f]{4}\.){2}[0-9a-f]{4}$/.test(str))
+    hex = str.replace(/\./g, "");
+  if (!hex) return null;
+  return hex.match(/../g).join(":");
+}
+
 export function capitalizeFirstLetter(string) {
   return string.charAt(0).toUpperCase() + string.slice(1);
 }